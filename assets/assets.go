@@ -0,0 +1,20 @@
+// Package assets embeds the static assets of the application into the binary.
+package assets
+
+import "embed"
+
+// Migrations holds the sql migrations of the db under the "migrations" directory.
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS
+
+// MigrationsDir is the directory of the migrations in Migrations and in the assets directory of the source tree.
+const MigrationsDir = "migrations"
+
+// Seeds holds the fixtures of the seed sets, one directory per set (e.g. dev, demo and testing).
+//
+//go:embed seeds
+var Seeds embed.FS
+
+// SeedsDir is the directory of the seed sets in Seeds and in the assets directory of the source tree.
+const SeedsDir = "seeds"
@@ -0,0 +1,11 @@
+BEGIN;
+
+DELETE FROM users WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_users_deleted_at;
+DROP INDEX IF EXISTS idx_users_email;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users(email);
+
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
+
+COMMIT;
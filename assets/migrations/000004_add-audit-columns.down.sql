@@ -0,0 +1,11 @@
+BEGIN;
+
+-- created_at is part of the original users table, so it is kept
+ALTER TABLE users DROP COLUMN IF EXISTS updated_by;
+ALTER TABLE users DROP COLUMN IF EXISTS created_by;
+ALTER TABLE users DROP COLUMN IF EXISTS updated_at;
+
+DROP FUNCTION IF EXISTS drop_audit_columns(REGCLASS);
+DROP FUNCTION IF EXISTS add_audit_columns(REGCLASS);
+
+COMMIT;
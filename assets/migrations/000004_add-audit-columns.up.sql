@@ -0,0 +1,29 @@
+BEGIN;
+
+-- add_audit_columns adds the columns of the embeddable common.Audit struct to an existing table.
+-- Usage in later migrations: SELECT add_audit_columns('table_name');
+CREATE OR REPLACE FUNCTION add_audit_columns(target_table REGCLASS) RETURNS VOID AS
+$$
+BEGIN
+    EXECUTE format('ALTER TABLE %s ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()', target_table);
+    EXECUTE format('ALTER TABLE %s ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()', target_table);
+    EXECUTE format('ALTER TABLE %s ADD COLUMN IF NOT EXISTS created_by UUID NULL', target_table);
+    EXECUTE format('ALTER TABLE %s ADD COLUMN IF NOT EXISTS updated_by UUID NULL', target_table);
+END;
+$$ LANGUAGE plpgsql;
+
+-- drop_audit_columns reverts add_audit_columns, it can be used in the down migrations.
+CREATE OR REPLACE FUNCTION drop_audit_columns(target_table REGCLASS) RETURNS VOID AS
+$$
+BEGIN
+    EXECUTE format('ALTER TABLE %s DROP COLUMN IF EXISTS updated_by', target_table);
+    EXECUTE format('ALTER TABLE %s DROP COLUMN IF EXISTS created_by', target_table);
+    EXECUTE format('ALTER TABLE %s DROP COLUMN IF EXISTS updated_at', target_table);
+    EXECUTE format('ALTER TABLE %s DROP COLUMN IF EXISTS created_at', target_table);
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT add_audit_columns('users');
+UPDATE users SET updated_at = created_at;
+
+COMMIT;
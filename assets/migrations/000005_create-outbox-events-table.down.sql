@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_outbox_events_pending;
+DROP TABLE IF EXISTS outbox_events;
+
+COMMIT;
@@ -0,0 +1,18 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id              BIGSERIAL PRIMARY KEY,
+    event_type      TEXT        NOT NULL,
+    payload         JSONB       NOT NULL,
+    status          TEXT        NOT NULL DEFAULT 'pending',
+    attempts        INT         NOT NULL DEFAULT 0,
+    last_error      TEXT        NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    delivered_at    TIMESTAMPTZ NULL
+);
+
+-- the relay only scans the pending events
+CREATE INDEX IF NOT EXISTS idx_outbox_events_pending ON outbox_events(next_attempt_at, id) WHERE status = 'pending';
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_jobs_unique_key;
+DROP INDEX IF EXISTS idx_jobs_status;
+DROP INDEX IF EXISTS idx_jobs_pending;
+DROP TABLE IF EXISTS jobs;
+
+COMMIT;
@@ -0,0 +1,25 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS jobs (
+    id           BIGSERIAL PRIMARY KEY,
+    queue        TEXT        NOT NULL DEFAULT 'default',
+    kind         TEXT        NOT NULL,
+    payload      JSONB       NOT NULL,
+    status       TEXT        NOT NULL DEFAULT 'pending',
+    attempts     INT         NOT NULL DEFAULT 0,
+    max_attempts INT         NOT NULL DEFAULT 10,
+    last_error   TEXT        NOT NULL DEFAULT '',
+    unique_key   TEXT        NULL,
+    run_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    locked_at    TIMESTAMPTZ NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ NULL
+);
+
+-- the workers only scan the pending jobs of their queues
+CREATE INDEX IF NOT EXISTS idx_jobs_pending ON jobs(queue, run_at, id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs(status);
+-- a unique job can be enqueued again once the previous one is completed or failed
+CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_unique_key ON jobs(unique_key) WHERE status IN ('pending', 'running');
+
+COMMIT;
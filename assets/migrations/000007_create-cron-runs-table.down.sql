@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx_cron_runs_task_name;
+DROP INDEX IF EXISTS idx_cron_runs_occurrence;
+DROP TABLE IF EXISTS cron_runs;
+
+COMMIT;
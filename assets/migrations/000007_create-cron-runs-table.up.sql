@@ -0,0 +1,19 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS cron_runs (
+    id           BIGSERIAL PRIMARY KEY,
+    task_name    TEXT        NOT NULL,
+    trigger      TEXT        NOT NULL,
+    status       TEXT        NOT NULL DEFAULT 'running',
+    scheduled_at TIMESTAMPTZ NOT NULL,
+    started_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at  TIMESTAMPTZ NULL,
+    error        TEXT        NOT NULL DEFAULT '',
+    host         TEXT        NOT NULL DEFAULT ''
+);
+
+-- each occurrence of a schedule runs at most once, even if two replicas briefly consider themselves the leader
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cron_runs_occurrence ON cron_runs(task_name, scheduled_at) WHERE trigger = 'schedule';
+CREATE INDEX IF NOT EXISTS idx_cron_runs_task_name ON cron_runs(task_name, started_at DESC);
+
+COMMIT;
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/pkg/logger"
+	"github.com/amahdian/golang-gin-boilerplate/storage/pg"
+	"github.com/pkg/errors"
+)
+
+var checkSchemaCmd = &command{
+	name:        "check-schema",
+	description: "Compares the schema of the models with the db and fails if the drift breaks the queries of a model",
+	run:         checkSchema,
+}
+
+func checkSchema([]string) error {
+	envs, err := loadEnvs()
+	if err != nil {
+		return err
+	}
+	logger.ConfigureFromEnvs(envs)
+
+	db, err := pg.OpenGormDb(envs.Db.Dsn, pg.LogLevel(strings.ToLower(envs.Db.LogLevel)))
+	if err != nil {
+		return errors.Wrap(err, "failed to open gorm connection")
+	}
+	if sqlDb, err := db.DB(); err == nil {
+		defer sqlDb.Close()
+	}
+
+	report, err := pg.CheckSchemaDrift(context.Background(), db, model.All())
+	if err != nil {
+		return err
+	}
+	if err = report.Print(os.Stdout); err != nil {
+		return err
+	}
+	if report.HasErrors() {
+		return errors.New("the schema of the models does not match the db")
+	}
+	return nil
+}
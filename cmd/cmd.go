@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/amahdian/golang-gin-boilerplate/global/env"
+	"github.com/pkg/errors"
+)
+
+const defaultCommand = "serve"
+
+type command struct {
+	name        string
+	description string
+	run         func(args []string) error
+}
+
+var commands = []*command{
+	serveCmd,
+	workerCmd,
+	migrateCmd,
+	checkSchemaCmd,
+	seedCmd,
+	loadtestCmd,
+}
+
+// Execute runs the command named by the first argument with the rest of the arguments.
+// The server is started when no command is given.
+func Execute(args []string) error {
+	name := defaultCommand
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage()
+		return nil
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd.run(args)
+		}
+	}
+
+	printUsage()
+	return fmt.Errorf("unknown command %q", name)
+}
+
+func printUsage() {
+	usage := &strings.Builder{}
+	usage.WriteString("Usage: app-bin <COMMAND> [ARGS]\n\nAvailable commands are:\n\n")
+	for _, cmd := range commands {
+		_, _ = fmt.Fprintf(usage, "    %-16s%s\n", cmd.name, cmd.description)
+	}
+	_, _ = fmt.Fprint(os.Stderr, usage.String())
+}
+
+func loadEnvs() (*env.Envs, error) {
+	envs, err := env.Load("")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load env variables")
+	}
+	return envs, nil
+}
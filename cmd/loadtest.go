@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/amahdian/golang-gin-boilerplate/pkg/loadtest"
+	"github.com/pkg/errors"
+)
+
+const loadtestUsage = `Usage: app-bin loadtest [FLAGS] <SCENARIO>
+
+Replays the weighted endpoints of a YAML or JSON scenario file against a running server, and reports the latency
+percentiles, the error rates and the throughput of the endpoints. The flags override the settings of the scenario.
+The command fails if the report exceeds the thresholds of the scenario.
+
+Flags are:
+
+`
+
+var loadtestCmd = &command{
+	name:        "loadtest",
+	description: "Replays a scenario file against a running server and reports its latencies, run \"loadtest help\" for the usage",
+	run:         runLoadtest,
+}
+
+func runLoadtest(args []string) error {
+	flags := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	flags.Usage = func() {
+		_, _ = fmt.Fprint(os.Stderr, loadtestUsage)
+		flags.PrintDefaults()
+	}
+	baseUrl := flags.String("url", "", "the base url of the server, e.g. "+loadtest.DefaultBaseUrl)
+	rps := flags.Float64("rps", 0, "the target number of requests per second")
+	concurrency := flags.Int("concurrency", 0, "the number of concurrent clients, or the maximum requests in flight with -rps")
+	duration := flags.Duration("duration", 0, "the duration of the test, e.g. 30s")
+	requests := flags.Int("requests", 0, "the total number of requests")
+	format := flags.String("format", "table", "the format of the report on stdout, table or json")
+	out := flags.String("out", "", "a file to write the json report to, e.g. to compare it with the previous release")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if flags.NArg() != 1 || flags.Arg(0) == "help" {
+		flags.Usage()
+		if flags.NArg() == 1 {
+			return nil
+		}
+		return errors.New("a single scenario file is required")
+	}
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("unknown report format %q", *format)
+	}
+
+	scenario, err := loadtest.LoadScenario(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "url":
+			scenario.BaseUrl = *baseUrl
+		case "rps":
+			scenario.Rps = *rps
+		case "concurrency":
+			scenario.Concurrency = *concurrency
+		case "duration":
+			scenario.Duration = loadtest.Duration(*duration)
+		case "requests":
+			scenario.Requests = *requests
+			if !isFlagSet(flags, "duration") {
+				// the number of requests ends the test instead of the duration of the scenario
+				scenario.Duration = 0
+			}
+		}
+	})
+	runner, err := loadtest.NewRunner(scenario)
+	if err != nil {
+		return errors.Wrap(err, "invalid scenario")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	_, _ = fmt.Fprintf(os.Stderr, "running the %s scenario against %s, press ctrl+c to stop it\n", scenario.Name, scenario.BaseUrl)
+	report, err := runner.Run(ctx)
+	if err != nil {
+		return err
+	}
+	report.Check(scenario)
+
+	if *format == "json" {
+		err = report.WriteJson(os.Stdout)
+	} else {
+		err = report.Print(os.Stdout)
+	}
+	if err != nil {
+		return err
+	}
+	if *out != "" {
+		if err = writeLoadtestReport(*out, report); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "wrote the json report to %s\n", *out)
+	}
+	if report.Failed() {
+		return fmt.Errorf("the load test exceeded %d of its thresholds", len(report.Violations))
+	}
+	return nil
+}
+
+func writeLoadtestReport(path string, report *loadtest.Report) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to create the report file")
+	}
+	defer file.Close()
+	return report.WriteJson(file)
+}
+
+func isFlagSet(flags *flag.FlagSet, name string) bool {
+	set := false
+	flags.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
+}
@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/amahdian/golang-gin-boilerplate/assets"
+	"github.com/amahdian/golang-gin-boilerplate/global/env"
+	"github.com/amahdian/golang-gin-boilerplate/pkg/logger"
+	"github.com/amahdian/golang-gin-boilerplate/storage/pg"
+	"github.com/pkg/errors"
+)
+
+const migrateUsage = `Usage: app-bin migrate <SUBCOMMAND> [ARGS]
+
+Available subcommands are:
+
+    status          Lists the migrations and whether they are applied
+    up [N|all]      Applies the next N migrations, or all of them by default
+    down [N|all]    Rolls back the last N migrations, or only the last one by default
+    goto V          Migrates up or down to version V
+    force V         Sets the version to V without running any migration, e.g. after fixing a dirty migration
+    create NAME     Creates the up and down files of a new migration under ASSETS_DIR
+`
+
+var migrateCmd = &command{
+	name:        "migrate",
+	description: "Manages the db migrations embedded in the binary, run \"migrate help\" for the subcommands",
+	run:         migrateDb,
+}
+
+func migrateDb(args []string) error {
+	if len(args) == 0 || args[0] == "help" {
+		_, _ = fmt.Fprint(os.Stderr, migrateUsage)
+		return nil
+	}
+	subcommand, args := args[0], args[1:]
+	if subcommand == "create" {
+		return createMigration(args)
+	}
+
+	envs, err := loadEnvs()
+	if err != nil {
+		return err
+	}
+	logger.ConfigureFromEnvs(envs)
+
+	if err = pg.EnsureDatabaseExists(envs.Db.Dsn); err != nil {
+		return errors.Wrap(err, "failed to create database")
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	migrator, err := pg.NewMigrator(ctx, envs.Db.Dsn)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	switch subcommand {
+	case "status":
+		err = printMigrationStatus(migrator)
+	case "up":
+		var n int
+		if n, err = stepsArg(args, 0); err == nil {
+			err = migrator.Up(n)
+		}
+	case "down":
+		var n int
+		if n, err = stepsArg(args, 1); err == nil {
+			err = migrator.Down(n)
+		}
+	case "goto":
+		var version int
+		if version, err = requiredIntArg(args, "version"); err == nil {
+			if version < 0 {
+				return errors.New("the version must not be negative")
+			}
+			err = migrator.Goto(uint(version))
+		}
+	case "force":
+		var version int
+		if version, err = requiredIntArg(args, "version"); err == nil {
+			err = migrator.Force(version)
+		}
+	default:
+		_, _ = fmt.Fprint(os.Stderr, migrateUsage)
+		return fmt.Errorf("unknown migrate subcommand %q", subcommand)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to run migrate %s", subcommand)
+	}
+	if subcommand != "status" {
+		return printMigrationVersion(migrator)
+	}
+	return nil
+}
+
+// createMigration creates the files of a new migration. It only needs the assets dir, so the migrations can be
+// created without the db and the secrets of the server.
+func createMigration(args []string) error {
+	if len(args) != 1 {
+		return errors.New("the name of the migration is required")
+	}
+	var envs struct {
+		AssetsDir string `env:"ASSETS_DIR, default=./assets"`
+	}
+	if err := env.LoadInto("", &envs); err != nil {
+		return errors.Wrap(err, "failed to load env variables")
+	}
+
+	upFile, downFile, err := pg.CreateMigration(filepath.Join(envs.AssetsDir, assets.MigrationsDir), args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Printf("created %s\ncreated %s\n", upFile, downFile)
+	return nil
+}
+
+func printMigrationStatus(migrator *pg.Migrator) error {
+	if err := printMigrationVersion(migrator); err != nil {
+		return err
+	}
+	statuses, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "VERSION\tNAME\tSTATUS")
+	for _, status := range statuses {
+		state := "pending"
+		if status.Applied {
+			state = "applied"
+		}
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, state)
+	}
+	return w.Flush()
+}
+
+func printMigrationVersion(migrator *pg.Migrator) error {
+	version, dirty, err := migrator.Version()
+	if err != nil {
+		return errors.Wrap(err, "failed to get the current version")
+	}
+	if dirty {
+		fmt.Printf("current version: %d (dirty, fix the failed migration and run force %d)\n", version, version)
+		return nil
+	}
+	fmt.Printf("current version: %d\n", version)
+	return nil
+}
+
+// stepsArg parses the optional number of migrations to run. Zero (or "all") means all the migrations.
+func stepsArg(args []string, defaultValue int) (int, error) {
+	if len(args) == 0 {
+		return defaultValue, nil
+	}
+	if args[0] == "all" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid number of migrations %q", args[0])
+	}
+	return n, nil
+}
+
+func requiredIntArg(args []string, name string) (int, error) {
+	if len(args) == 0 {
+		return 0, fmt.Errorf("the %s is required", name)
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", name, args[0])
+	}
+	return n, nil
+}
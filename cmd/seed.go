@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/amahdian/golang-gin-boilerplate/assets"
+	"github.com/amahdian/golang-gin-boilerplate/pkg/logger"
+	"github.com/amahdian/golang-gin-boilerplate/storage/pg"
+	"github.com/amahdian/golang-gin-boilerplate/storage/seed"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+)
+
+const seedUsage = `Usage: app-bin seed <SET | FILE...>
+
+Loads the fixtures of a seed set embedded in the binary, or the given YAML or JSON fixture files.
+The existing records are matched by their natural keys and updated, so seeding again is safe.
+
+Available seed sets are: %s
+`
+
+var seedCmd = &command{
+	name:        "seed",
+	description: "Loads a seed set (e.g. dev or demo) or fixture files into the db, run \"seed help\" for the usage",
+	run:         seedDb,
+}
+
+func seedDb(args []string) error {
+	seedFs, err := fs.Sub(assets.Seeds, assets.SeedsDir)
+	if err != nil {
+		return err
+	}
+	sets, err := seed.Sets(seedFs)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 || args[0] == "help" {
+		_, _ = fmt.Fprintf(os.Stderr, seedUsage, strings.Join(sets, ", "))
+		return nil
+	}
+
+	envs, err := loadEnvs()
+	if err != nil {
+		return err
+	}
+	logger.ConfigureFromEnvs(envs)
+
+	db, err := pg.OpenGormDb(envs.Db.Dsn, pg.LogLevel(strings.ToLower(envs.Db.LogLevel)))
+	if err != nil {
+		return errors.Wrap(err, "failed to open gorm connection")
+	}
+	if sqlDb, err := db.DB(); err == nil {
+		defer sqlDb.Close()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	loader := seed.NewLoader(pg.NewStg(db))
+
+	var result seed.Result
+	if len(args) == 1 && lo.Contains(sets, args[0]) {
+		result, err = loader.LoadSet(ctx, seedFs, args[0])
+	} else {
+		result, err = loader.LoadFiles(ctx, args...)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to seed the db")
+	}
+	fmt.Printf("seeded the db: %s\n", result)
+	return nil
+}
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"github.com/amahdian/golang-gin-boilerplate/server"
+)
+
+var serveCmd = &command{
+	name:        "serve",
+	description: "Runs the http server and, unless JOBS_RUN_IN_SERVER is false, the job workers",
+	run:         serve,
+}
+
+func serve([]string) error {
+	envs, err := loadEnvs()
+	if err != nil {
+		return err
+	}
+
+	s, err := server.NewServer(envs)
+	if err != nil {
+		return err
+	}
+	return s.Run()
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"context"
+	"os/signal"
+	"syscall"
+
+	"github.com/amahdian/golang-gin-boilerplate/server"
+)
+
+var workerCmd = &command{
+	name:        "worker",
+	description: "Runs only the job workers until it is interrupted",
+	run:         worker,
+}
+
+func worker([]string) error {
+	envs, err := loadEnvs()
+	if err != nil {
+		return err
+	}
+
+	s, err := server.NewServer(envs)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	return s.RunWorker(ctx)
+}
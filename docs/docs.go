@@ -15,39 +15,76 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/v1/user/login": {
-            "post": {
+        "/api/v1/admin/cron/runs": {
+            "get": {
                 "security": [
                     {
                         "Bearer": []
                     }
                 ],
-                "consumes": [
-                    "application/json"
-                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "User"
+                    "Admin"
                 ],
-                "summary": "login and get the jwt auth token",
+                "summary": "list the run history of the recurring tasks, the latest runs first",
                 "parameters": [
                     {
-                        "description": "login credentials",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/req.Login"
-                        }
+                        "type": "string",
+                        "description": "comma separated list of fields to return, e.g. \"id,status\". all fields are returned when empty.",
+                        "name": "fields",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "description": "sort order. default order is asc.\n* asc - Ascending, from A to Z.\n* desc - Descending, from Z to A.",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "field to sort the results by. if orderBy is empty, the order will be ignored.",
+                        "name": "orderBy",
+                        "in": "query"
+                    },
+                    {
+                        "minimum": 0,
+                        "type": "integer",
+                        "description": "starts from 0",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 1000,
+                        "minimum": 0,
+                        "type": "integer",
+                        "description": "must be in 0-1000 range. default: 100",
+                        "name": "pageSize",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "comma separated list of fields to sort the results by. prefix a field with \"-\" to sort it in descending order.\ne.g. \"-created_at,email\". takes precedence over orderBy and order when provided.",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "lists the runs of all the tasks when empty",
+                        "name": "task",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/resp.Response-string"
+                            "$ref": "#/definitions/resp.PaginatedResponse-model_CronRun"
                         }
                     },
                     "400": {
@@ -56,6 +93,24 @@ const docTemplate = `{
                             "$ref": "#/definitions/resp.ErrorResponse"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -65,39 +120,193 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/user/register": {
-            "post": {
+        "/api/v1/admin/cron/tasks": {
+            "get": {
                 "security": [
                     {
                         "Bearer": []
                     }
                 ],
-                "consumes": [
+                "produces": [
                     "application/json"
                 ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "list the recurring tasks with their next run time and last run",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/resp.Response-array_cron_TaskInfo"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/cron/tasks/{name}/trigger": {
+            "post": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "User"
+                    "Admin"
                 ],
-                "summary": "register new user",
+                "summary": "run a recurring task immediately in the background",
                 "parameters": [
                     {
-                        "description": "register data",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
+                        "type": "string",
+                        "description": "task name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/req.Register"
+                            "$ref": "#/definitions/resp.Response-model_CronRun"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
                         }
                     }
+                }
+            }
+        },
+        "/api/v1/admin/jobs": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "list the background jobs, e.g. the failed ones",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "comma separated list of fields to return, e.g. \"id,status\". all fields are returned when empty.",
+                        "name": "fields",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "description": "sort order. default order is asc.\n* asc - Ascending, from A to Z.\n* desc - Descending, from Z to A.",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "field to sort the results by. if orderBy is empty, the order will be ignored.",
+                        "name": "orderBy",
+                        "in": "query"
+                    },
+                    {
+                        "minimum": 0,
+                        "type": "integer",
+                        "description": "starts from 0",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 1000,
+                        "minimum": 0,
+                        "type": "integer",
+                        "description": "must be in 0-1000 range. default: 100",
+                        "name": "pageSize",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "comma separated list of fields to sort the results by. prefix a field with \"-\" to sort it in descending order.\ne.g. \"-created_at,email\". takes precedence over orderBy and order when provided.",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "pending",
+                            "running",
+                            "completed",
+                            "failed"
+                        ],
+                        "type": "string",
+                        "x-enum-varnames": [
+                            "JobPending",
+                            "JobRunning",
+                            "JobCompleted",
+                            "JobFailed"
+                        ],
+                        "description": "lists all the jobs when empty",
+                        "name": "status",
+                        "in": "query"
+                    }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/resp.Response-string"
+                            "$ref": "#/definitions/resp.PaginatedResponse-model_Job"
                         }
                     },
                     "400": {
@@ -106,6 +315,18 @@ const docTemplate = `{
                             "$ref": "#/definitions/resp.ErrorResponse"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -115,101 +336,554 @@ const docTemplate = `{
                 }
             }
         },
-        "/health": {
-            "get": {
-                "consumes": [
-                    "application/json"
+        "/api/v1/admin/jobs/{id}/retry": {
+            "post": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Public"
+                    "Admin"
+                ],
+                "summary": "retry a failed background job with a fresh set of attempts",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "job id",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "health check",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/resp.HealthResponseDto"
+                            "$ref": "#/definitions/resp.Response-model_Job"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/server-time": {
+        "/api/v1/admin/schema": {
             "get": {
-                "consumes": [
-                    "application/json"
+                "security": [
+                    {
+                        "Bearer": []
+                    }
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Public"
+                    "Admin"
+                ],
+                "summary": "describe the tables of the db with their columns, keys and indexes",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "reads the schema again from the db instead of the cache",
+                        "name": "refresh",
+                        "in": "query"
+                    }
                 ],
-                "summary": "getServerTime",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/resp.Response-storage_DbSchema"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "msg.Message": {
-            "type": "object",
-            "properties": {
-                "level": {
-                    "$ref": "#/definitions/msg.MessageLevel"
-                },
-                "text": {
-                    "type": "string"
-                }
-            }
-        },
-        "msg.MessageLevel": {
-            "type": "integer",
-            "enum": [
-                0,
-                1,
-                2,
-                3
-            ],
-            "x-enum-varnames": [
-                "Fatal",
-                "Error",
-                "Warning",
-                "Info"
-            ]
-        },
-        "req.Login": {
-            "type": "object",
-            "required": [
-                "email",
-                "password"
-            ],
-            "properties": {
-                "email": {
-                    "type": "string"
-                },
-                "password": {
-                    "type": "string"
-                }
-            }
         },
-        "req.Register": {
-            "type": "object",
-            "required": [
-                "email",
-                "password"
-            ],
-            "properties": {
-                "email": {
+        "/api/v1/user/me": {
+            "get": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "get the profile of the current user, with its version in the ETag header",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/resp.Response-resp_UserProfile"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "the version of the profile, for the If-Match header of the updates"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "update the profile of the current user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "the ETag of the profile, the update fails with 409 if the profile has been modified since, and with 412 for the weak ETags",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "description": "profile data",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/req.UpdateProfile"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/resp.Response-resp_UserProfile"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "the new version of the profile"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/health": {
+            "get": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Public"
+                ],
+                "summary": "health check",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/resp.Response-resp_HealthResponseDto"
+                        }
+                    }
+                }
+            }
+        },
+        "/server-time": {
+            "get": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Public"
+                ],
+                "summary": "getServerTime",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/resp.Response-string"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/login": {
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "login and get the jwt auth token",
+                "parameters": [
+                    {
+                        "description": "login credentials",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/req.Login"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/resp.Response-string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/register": {
+            "post": {
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "register new user",
+                "parameters": [
+                    {
+                        "description": "register data",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/req.Register"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/resp.Response-string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/resp.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "cron.TaskInfo": {
+            "type": "object",
+            "properties": {
+                "last_run": {
+                    "$ref": "#/definitions/model.CronRun"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "next_run_at": {
+                    "description": "the next occurrence of the schedule, which is only run if there is a leader at that time",
+                    "type": "string"
+                },
+                "schedule": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.CronRun": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "host": {
+                    "description": "the host of the replica which has run the task",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "scheduled_at": {
+                    "description": "the occurrence of the schedule for the scheduled runs, and the trigger time for the manual runs",
+                    "type": "string"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/model.CronRunStatus"
+                },
+                "task_name": {
+                    "type": "string"
+                },
+                "trigger": {
+                    "$ref": "#/definitions/model.CronRunTrigger"
+                }
+            }
+        },
+        "model.CronRunStatus": {
+            "type": "string",
+            "enum": [
+                "running",
+                "succeeded",
+                "failed"
+            ],
+            "x-enum-varnames": [
+                "CronRunRunning",
+                "CronRunSucceeded",
+                "CronRunFailed"
+            ]
+        },
+        "model.CronRunTrigger": {
+            "type": "string",
+            "enum": [
+                "schedule",
+                "manual"
+            ],
+            "x-enum-varnames": [
+                "CronRunScheduled",
+                "CronRunManual"
+            ]
+        },
+        "model.Job": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "completed_at": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "locked_at": {
+                    "type": "string"
+                },
+                "max_attempts": {
+                    "type": "integer"
+                },
+                "payload": {
+                    "type": "object"
+                },
+                "queue": {
+                    "type": "string"
+                },
+                "run_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/model.JobStatus"
+                },
+                "unique_key": {
+                    "description": "UniqueKey prevents enqueueing a job while another pending or running job has the same key.",
+                    "type": "string"
+                }
+            }
+        },
+        "model.JobStatus": {
+            "type": "string",
+            "enum": [
+                "pending",
+                "running",
+                "completed",
+                "failed"
+            ],
+            "x-enum-varnames": [
+                "JobPending",
+                "JobRunning",
+                "JobCompleted",
+                "JobFailed"
+            ]
+        },
+        "msg.Message": {
+            "type": "object",
+            "properties": {
+                "level": {
+                    "type": "string",
+                    "enum": [
+                        "Fatal",
+                        "Error",
+                        "Warning",
+                        "Info"
+                    ]
+                },
+                "text": {
+                    "type": "string"
+                }
+            }
+        },
+        "req.Login": {
+            "type": "object",
+            "required": [
+                "email",
+                "password"
+            ],
+            "properties": {
+                "email": {
                     "type": "string"
                 },
                 "password": {
@@ -217,6 +891,32 @@ const docTemplate = `{
                 }
             }
         },
+        "req.Register": {
+            "type": "object",
+            "required": [
+                "email",
+                "password"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                }
+            }
+        },
+        "req.UpdateProfile": {
+            "type": "object",
+            "required": [
+                "email"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string"
+                }
+            }
+        },
         "resp.ErrorResponse": {
             "type": "object",
             "properties": {
@@ -249,6 +949,192 @@ const docTemplate = `{
                 }
             }
         },
+        "resp.PageInfo": {
+            "type": "object",
+            "properties": {
+                "elementsCount": {
+                    "type": "integer"
+                },
+                "hasMore": {
+                    "type": "boolean"
+                },
+                "isEmpty": {
+                    "type": "boolean"
+                },
+                "page": {
+                    "type": "integer"
+                },
+                "pageSize": {
+                    "type": "integer"
+                },
+                "totalCount": {
+                    "type": "integer"
+                }
+            }
+        },
+        "resp.PaginatedResponse-model_CronRun": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.CronRun"
+                    }
+                },
+                "pageInfo": {
+                    "$ref": "#/definitions/resp.PageInfo"
+                },
+                "success": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "resp.PaginatedResponse-model_Job": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Job"
+                    }
+                },
+                "pageInfo": {
+                    "$ref": "#/definitions/resp.PageInfo"
+                },
+                "success": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "resp.Response-array_cron_TaskInfo": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/cron.TaskInfo"
+                    }
+                },
+                "messages": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "array",
+                        "items": {
+                            "$ref": "#/definitions/msg.Message"
+                        }
+                    }
+                },
+                "success": {
+                    "type": "boolean",
+                    "default": true
+                }
+            }
+        },
+        "resp.Response-model_CronRun": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/model.CronRun"
+                },
+                "messages": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "array",
+                        "items": {
+                            "$ref": "#/definitions/msg.Message"
+                        }
+                    }
+                },
+                "success": {
+                    "type": "boolean",
+                    "default": true
+                }
+            }
+        },
+        "resp.Response-model_Job": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/model.Job"
+                },
+                "messages": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "array",
+                        "items": {
+                            "$ref": "#/definitions/msg.Message"
+                        }
+                    }
+                },
+                "success": {
+                    "type": "boolean",
+                    "default": true
+                }
+            }
+        },
+        "resp.Response-resp_HealthResponseDto": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/resp.HealthResponseDto"
+                },
+                "messages": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "array",
+                        "items": {
+                            "$ref": "#/definitions/msg.Message"
+                        }
+                    }
+                },
+                "success": {
+                    "type": "boolean",
+                    "default": true
+                }
+            }
+        },
+        "resp.Response-resp_UserProfile": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/resp.UserProfile"
+                },
+                "messages": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "array",
+                        "items": {
+                            "$ref": "#/definitions/msg.Message"
+                        }
+                    }
+                },
+                "success": {
+                    "type": "boolean",
+                    "default": true
+                }
+            }
+        },
+        "resp.Response-storage_DbSchema": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "$ref": "#/definitions/storage.DbSchema"
+                },
+                "messages": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "array",
+                        "items": {
+                            "$ref": "#/definitions/msg.Message"
+                        }
+                    }
+                },
+                "success": {
+                    "type": "boolean",
+                    "default": true
+                }
+            }
+        },
         "resp.Response-string": {
             "type": "object",
             "properties": {
@@ -269,6 +1155,136 @@ const docTemplate = `{
                     "default": true
                 }
             }
+        },
+        "resp.UserProfile": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "storage.ColumnSchema": {
+            "type": "object",
+            "properties": {
+                "data_type": {
+                    "description": "the sql data type of the column, e.g. bigint or USER-DEFINED for the enums",
+                    "type": "string"
+                },
+                "default": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "nullable": {
+                    "type": "boolean"
+                },
+                "type": {
+                    "description": "the name of the type in the db, e.g. int8 or timestamptz",
+                    "type": "string"
+                }
+            }
+        },
+        "storage.DbSchema": {
+            "type": "object",
+            "properties": {
+                "loaded_at": {
+                    "description": "the time the schema has been read from the db",
+                    "type": "string"
+                },
+                "tables": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/storage.TableSchema"
+                    }
+                }
+            }
+        },
+        "storage.ForeignKey": {
+            "type": "object",
+            "properties": {
+                "columns": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "referenced_columns": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "referenced_table": {
+                    "type": "string"
+                }
+            }
+        },
+        "storage.IndexSchema": {
+            "type": "object",
+            "properties": {
+                "definition": {
+                    "description": "the create index statement of the index",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "primary": {
+                    "type": "boolean"
+                },
+                "unique": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "storage.TableSchema": {
+            "type": "object",
+            "properties": {
+                "columns": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/storage.ColumnSchema"
+                    }
+                },
+                "foreign_keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/storage.ForeignKey"
+                    }
+                },
+                "indexes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/storage.IndexSchema"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "primary_key": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
         }
     },
     "securityDefinitions": {
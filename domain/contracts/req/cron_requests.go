@@ -0,0 +1,17 @@
+package req
+
+import "github.com/amahdian/golang-gin-boilerplate/domain/model/common"
+
+type ListCronRuns struct {
+	common.Pagination
+
+	// lists the runs of all the tasks when empty
+	Task string `form:"task"`
+
+	// comma separated list of fields to return, e.g. "id,status". all fields are returned when empty.
+	Fields string `form:"fields"`
+}
+
+type CronTaskName struct {
+	Name string `uri:"name" binding:"required"`
+}
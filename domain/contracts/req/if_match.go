@@ -0,0 +1,33 @@
+package req
+
+import (
+	"net/http"
+
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/amahdian/golang-gin-boilerplate/server/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// IfMatchVersions returns the versions of the If-Match header of PUT and PATCH requests, so the update fails with a
+// conflict unless the model is still at one of them. No versions are returned when the header is absent or is "*".
+// The header fails the precondition if it only has weak entity tags, since they never match the strong comparison.
+func IfMatchVersions(ctx *gin.Context) ([]int64, error) {
+	method := ctx.Request.Method
+	if method != http.MethodPut && method != http.MethodPatch {
+		return nil, nil
+	}
+
+	ifMatch := ctx.GetHeader(utils.IfMatchHeader)
+	if ifMatch == "" {
+		return nil, nil
+	}
+
+	versions, anyVersion, err := utils.ParseIfMatch(ifMatch)
+	if err != nil {
+		return nil, errs.Newf(errs.InvalidArgument, err, "invalid %s header", utils.IfMatchHeader)
+	}
+	if !anyVersion && len(versions) == 0 {
+		return nil, errs.Newf(errs.FailedPrecondition, nil, "the weak entity tags of the %s header never match", utils.IfMatchHeader)
+	}
+	return versions, nil
+}
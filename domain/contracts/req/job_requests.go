@@ -0,0 +1,20 @@
+package req
+
+import (
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/domain/model/common"
+)
+
+type ListJobs struct {
+	common.Pagination
+
+	// lists all the jobs when empty
+	Status model.JobStatus `form:"status" binding:"omitempty,oneof=pending running completed failed" enums:"pending,running,completed,failed"`
+
+	// comma separated list of fields to return, e.g. "id,status". all fields are returned when empty.
+	Fields string `form:"fields"`
+}
+
+type JobId struct {
+	ID int64 `uri:"id" binding:"required"`
+}
@@ -0,0 +1,16 @@
+package req
+
+// All returns the request DTOs bound by the handlers, so their binding tags can be checked when the router is set up
+// instead of on their first request. A new request DTO must be added here.
+func All() []any {
+	return []any{
+		&Login{},
+		&Register{},
+		&UpdateProfile{},
+		&ListJobs{},
+		&JobId{},
+		&ListCronRuns{},
+		&CronTaskName{},
+		&DescribeSchema{},
+	}
+}
@@ -0,0 +1,6 @@
+package req
+
+type DescribeSchema struct {
+	// reads the schema again from the db instead of the cache
+	Refresh bool `form:"refresh"`
+}
@@ -6,6 +6,10 @@ type Login struct {
 }
 
 type Register struct {
-	Email    string `json:"email" binding:"required"`
+	Email    string `json:"email" binding:"required,unique=users email"`
 	Password string `json:"password" binding:"required"`
 }
+
+type UpdateProfile struct {
+	Email string `json:"email" binding:"required,email"`
+}
@@ -0,0 +1,41 @@
+package resp
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// SelectFields converts the given elements to json objects that only contain the given json fields.
+// It is used to serve sparse field selections (e.g. "?fields=id,email") without defining a new dto per selection.
+// The fields are validated and selected by the storages, so the fields which the elements do not have are skipped.
+func SelectFields[T any](data []T, fields []string) ([]map[string]any, error) {
+	selected := make([]map[string]any, 0, len(data))
+	for _, element := range data {
+		obj, err := selectFields(element, fields)
+		if err != nil {
+			return nil, err
+		}
+		selected = append(selected, obj)
+	}
+	return selected, nil
+}
+
+func selectFields(element any, fields []string) (map[string]any, error) {
+	bytes, err := json.Marshal(element)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal the element for field selection")
+	}
+	all := make(map[string]any)
+	if err = json.Unmarshal(bytes, &all); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal the element for field selection")
+	}
+
+	obj := make(map[string]any, len(fields))
+	for _, field := range fields {
+		if v, ok := all[field]; ok {
+			obj[field] = v
+		}
+	}
+	return obj, nil
+}
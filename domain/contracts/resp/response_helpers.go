@@ -10,6 +10,7 @@ import (
 	"github.com/amahdian/golang-gin-boilerplate/pkg/logger"
 	"github.com/amahdian/golang-gin-boilerplate/pkg/msg"
 	"github.com/amahdian/golang-gin-boilerplate/server/binding"
+	"github.com/amahdian/golang-gin-boilerplate/server/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/pkg/errors"
@@ -27,7 +28,8 @@ func AbortWithError(ctx *gin.Context, err error) {
 		ctx.AbortWithStatusJSON(customErr.Code.HttpStatus(), NewErrorResponse(err))
 		return
 	default:
-		ctx.AbortWithStatusJSON(http.StatusInternalServerError, NewErrorResponse(err))
+		// the code of the plain errors is detected as well, e.g. a context deadline is reported as 504
+		ctx.AbortWithStatusJSON(errs.Code(err).HttpStatus(), NewErrorResponse(err))
 		return
 	}
 }
@@ -73,6 +75,46 @@ func PaginatedOk[T any](ctx *gin.Context, data []T, pagination *common.Paginatio
 	ctx.JSON(http.StatusOK, NewPaginatedResponse(data, pagination))
 }
 
+// OkWithETag exposes the version of a versioned model as the ETag header.
+// Clients can send it back in the If-Match header of PUT and PATCH requests.
+func OkWithETag(ctx *gin.Context, data interface{}, version int64) {
+	SetETag(ctx, version)
+	Ok(ctx, data)
+}
+
+func SetETag(ctx *gin.Context, version int64) {
+	ctx.Header(utils.ETagHeader, utils.FormatVersionETag(version))
+}
+
+// OkWithFields serializes only the given json fields of data. All fields are serialized when fields is empty.
+func OkWithFields(ctx *gin.Context, data interface{}, fields []string) {
+	if len(fields) == 0 {
+		Ok(ctx, data)
+		return
+	}
+	selected, err := selectFields(data, fields)
+	if err != nil {
+		AbortWithError(ctx, err)
+		return
+	}
+	Ok(ctx, selected)
+}
+
+// PaginatedOkWithFields serializes only the given json fields of each element. All fields are serialized when fields is empty.
+// The elements should be loaded with the same field selection, e.g. by storage.JobStorage.ListByStatus.
+func PaginatedOkWithFields[T any](ctx *gin.Context, data []T, pagination *common.Pagination, fields []string) {
+	if len(fields) == 0 {
+		PaginatedOk(ctx, data, pagination)
+		return
+	}
+	selected, err := SelectFields(data, fields)
+	if err != nil {
+		AbortWithError(ctx, err)
+		return
+	}
+	PaginatedOk(ctx, selected, pagination)
+}
+
 func Created(ctx *gin.Context, data ...interface{}) {
 	if len(data) > 0 {
 		ctx.JSON(http.StatusCreated, NewResponse(data[0]))
@@ -0,0 +1,27 @@
+package resp
+
+import (
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/google/uuid"
+)
+
+// UserProfile is the user without its credentials.
+type UserProfile struct {
+	ID        uuid.UUID `json:"id"`
+	Email     string    `json:"email"`
+	Version   int64     `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func NewUserProfile(user *model.User) *UserProfile {
+	return &UserProfile{
+		ID:        user.ID,
+		Email:     user.Email,
+		Version:   user.Version,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}
+}
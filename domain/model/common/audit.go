@@ -0,0 +1,19 @@
+package common
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Audit can be embedded into a model to keep track of when and by whom the model is created and last updated.
+// The timestamps are filled by gorm, and the user columns are filled from the actor of the context (see the actor
+// package), e.g. the authenticated user of the request, by the audit callbacks of the storage. The user columns are
+// left empty for anonymous contexts.
+// The model table must have the audit columns, see the add_audit_columns migration function.
+type Audit struct {
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	CreatedBy *uuid.UUID `json:"created_by,omitempty" swaggertype:"string" format:"uuid"`
+	UpdatedBy *uuid.UUID `json:"updated_by,omitempty" swaggertype:"string" format:"uuid"`
+}
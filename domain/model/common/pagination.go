@@ -1,5 +1,7 @@
 package common
 
+import "strings"
+
 const (
 	SortOrderAscending  = "ASC"
 	SortOrderDescending = "DESC"
@@ -29,6 +31,10 @@ type Pagination struct {
 	// field to sort the results by. if orderBy is empty, the order will be ignored.
 	OrderBy string `json:"orderBy" form:"orderBy"`
 
+	// comma separated list of fields to sort the results by. prefix a field with "-" to sort it in descending order.
+	// e.g. "-created_at,email". takes precedence over orderBy and order when provided.
+	Sort string `json:"sort" form:"sort"`
+
 	// sort order. default order is asc.
 	// * asc - Ascending, from A to Z.
 	// * desc - Descending, from Z to A.
@@ -46,6 +52,22 @@ func DefaultPagination() *Pagination {
 	}
 }
 
+// SortFields returns the fields the results should be sorted by, in order of precedence.
+// The Sort field is used when provided, otherwise it falls back to OrderBy and Order.
+func (p *Pagination) SortFields() []SortField {
+	if p.Sort != "" {
+		return ParseSort(p.Sort)
+	}
+	if p.OrderBy == "" {
+		return make([]SortField, 0)
+	}
+	order := strings.ToUpper(p.Order)
+	if order == "" {
+		order = DefaultSortOrder
+	}
+	return []SortField{{FieldName: p.OrderBy, Order: order}}
+}
+
 var internalPagination = &Pagination{}
 
 // InternalPagination returns a special pagination object that is only used in internal API calls.
@@ -63,11 +85,58 @@ func NewPagination(orderBy string, order string) *Pagination {
 	}
 }
 
+type SortField struct {
+	FieldName string
+	Order     string
+}
+
+// ParseSort parses a sort expression like "-created_at,email" into sort fields.
+// Fields prefixed with "-" are sorted descending and the rest ascending. Empty entries are ignored.
+func ParseSort(sort string) []SortField {
+	fields := make([]SortField, 0)
+	for _, part := range strings.Split(sort, ",") {
+		part = strings.TrimSpace(part)
+		order := SortOrderAscending
+		if strings.HasPrefix(part, "-") {
+			order = SortOrderDescending
+			part = strings.TrimPrefix(part, "-")
+		} else {
+			part = strings.TrimPrefix(part, "+")
+		}
+		if part == "" {
+			continue
+		}
+		fields = append(fields, SortField{FieldName: part, Order: order})
+	}
+	return fields
+}
+
 type SearchParams struct {
 	Filters []*FieldFilter `json:"filters" binding:"dive"`
+
+	// comma separated list of fields to return, e.g. "id,email". all fields are returned when empty.
+	Fields string `json:"fields" form:"fields"`
+
 	*Pagination
 }
 
+// FieldNames returns the requested projection fields. An empty result means all fields are requested.
+func (p *SearchParams) FieldNames() []string {
+	return ParseFields(p.Fields)
+}
+
+// ParseFields splits a comma separated field list like "id,email" and drops the empty entries.
+func ParseFields(fields string) []string {
+	names := make([]string, 0)
+	for _, name := range strings.Split(fields, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
 func DefaultSearchParams() *SearchParams {
 	return &SearchParams{
 		Filters:    make([]*FieldFilter, 0),
@@ -0,0 +1,45 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSort(t *testing.T) {
+	for _, test := range []struct {
+		sort string
+		want []SortField
+	}{
+		{"", []SortField{}},
+		{"email", []SortField{{"email", SortOrderAscending}}},
+		{"-created_at,email", []SortField{{"created_at", SortOrderDescending}, {"email", SortOrderAscending}}},
+		{" +email , -id ,,", []SortField{{"email", SortOrderAscending}, {"id", SortOrderDescending}}},
+	} {
+		got := ParseSort(test.sort)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseSort(%q): got %v, want %v", test.sort, got, test.want)
+		}
+	}
+}
+
+func TestSortFields(t *testing.T) {
+	p := NewPagination("email", "desc")
+	want := []SortField{{"email", SortOrderDescending}}
+	if got := p.SortFields(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	p.Sort = "-created_at,id"
+	want = []SortField{{"created_at", SortOrderDescending}, {"id", SortOrderAscending}}
+	if got := p.SortFields(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	got := ParseFields(" id, email ,,")
+	want := []string{"id", "email"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,14 @@
+package common
+
+import "gorm.io/gorm"
+
+// SoftDelete can be embedded into a model to opt in to soft deletes.
+// Deleting a soft deletable model only sets its deleted_at column, and the deleted rows are excluded
+// from the queries unless they are explicitly included. The model table must have a nullable deleted_at column.
+type SoftDelete struct {
+	DeletedAt gorm.DeletedAt `json:"deleted_at,omitempty" swaggertype:"string" format:"date-time"`
+}
+
+func (sd SoftDelete) IsDeleted() bool {
+	return sd.DeletedAt.Valid
+}
@@ -0,0 +1,22 @@
+package common
+
+// Versioned can be embedded into a model to opt in to optimistic concurrency control.
+// UpdateOne and UpdatePartial only update a versioned model if its version still matches the stored one,
+// and increment the version on every successful update. The model table must have a version column.
+type Versioned struct {
+	Version int64 `json:"version" gorm:"not null;default:1"`
+}
+
+func (v *Versioned) GetVersion() int64 {
+	return v.Version
+}
+
+func (v *Versioned) SetVersion(version int64) {
+	v.Version = version
+}
+
+// VersionedModel is implemented by the models that embed Versioned.
+type VersionedModel interface {
+	GetVersion() int64
+	SetVersion(version int64)
+}
@@ -0,0 +1,37 @@
+package model
+
+import "time"
+
+type CronRunStatus string
+
+const (
+	CronRunRunning   CronRunStatus = "running"
+	CronRunSucceeded CronRunStatus = "succeeded"
+	CronRunFailed    CronRunStatus = "failed"
+)
+
+type CronRunTrigger string
+
+const (
+	CronRunScheduled CronRunTrigger = "schedule"
+	CronRunManual    CronRunTrigger = "manual"
+)
+
+// CronRun is a single run of a recurring task, either at one of its scheduled occurrences or triggered manually.
+type CronRun struct {
+	ID       int64          `json:"id"`
+	TaskName string         `json:"task_name"`
+	Trigger  CronRunTrigger `json:"trigger"`
+	Status   CronRunStatus  `json:"status"`
+	// the occurrence of the schedule for the scheduled runs, and the trigger time for the manual runs
+	ScheduledAt time.Time  `json:"scheduled_at"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+	Error       string     `json:"error"`
+	// the host of the replica which has run the task
+	Host string `json:"host"`
+}
+
+func (*CronRun) TableName() string {
+	return "cron_runs"
+}
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type JobStatus string
+
+const (
+	// JobPending is the status of the jobs waiting for their run time or for a free worker, including the retries.
+	JobPending   JobStatus = "pending"
+	JobRunning   JobStatus = "running"
+	JobCompleted JobStatus = "completed"
+	// JobFailed is the status of the jobs which could not be completed after the maximum attempts.
+	JobFailed JobStatus = "failed"
+)
+
+// Job is a unit of background work that is persisted in the db and executed by the job workers.
+type Job struct {
+	ID          int64           `json:"id"`
+	Queue       string          `json:"queue"`
+	Kind        string          `json:"kind"`
+	Payload     json.RawMessage `json:"payload" gorm:"type:jsonb" swaggertype:"object"`
+	Status      JobStatus       `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	LastError   string          `json:"last_error"`
+	// UniqueKey prevents enqueueing a job while another pending or running job has the same key.
+	UniqueKey   *string    `json:"unique_key"`
+	RunAt       time.Time  `json:"run_at"`
+	LockedAt    *time.Time `json:"locked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+func (*Job) TableName() string {
+	return "jobs"
+}
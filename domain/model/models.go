@@ -0,0 +1,14 @@
+package model
+
+import "gorm.io/gorm/schema"
+
+// All returns an instance of every model that is stored in the db.
+// New models must be added here to be covered by the schema drift check.
+func All() []schema.Tabler {
+	return []schema.Tabler{
+		&User{},
+		&OutboxEvent{},
+		&Job{},
+		&CronRun{},
+	}
+}
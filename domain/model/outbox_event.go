@@ -0,0 +1,33 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type OutboxEventStatus string
+
+const (
+	OutboxEventPending   OutboxEventStatus = "pending"
+	OutboxEventDelivered OutboxEventStatus = "delivered"
+	// OutboxEventDead is the status of the events which could not be delivered after the maximum attempts.
+	OutboxEventDead OutboxEventStatus = "dead"
+)
+
+// OutboxEvent is a domain event that is stored in the same transaction as the changes that raised it,
+// and is delivered to the subscribers by the outbox relay after the transaction is committed.
+type OutboxEvent struct {
+	ID            int64             `json:"id"`
+	EventType     string            `json:"event_type"`
+	Payload       json.RawMessage   `json:"payload" gorm:"type:jsonb" swaggertype:"object"`
+	Status        OutboxEventStatus `json:"status"`
+	Attempts      int               `json:"attempts"`
+	LastError     string            `json:"last_error"`
+	NextAttemptAt time.Time         `json:"next_attempt_at"`
+	CreatedAt     time.Time         `json:"created_at"`
+	DeliveredAt   *time.Time        `json:"delivered_at"`
+}
+
+func (*OutboxEvent) TableName() string {
+	return "outbox_events"
+}
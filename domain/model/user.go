@@ -1,8 +1,7 @@
 package model
 
 import (
-	"time"
-
+	"github.com/amahdian/golang-gin-boilerplate/domain/model/common"
 	"github.com/google/uuid"
 )
 
@@ -10,7 +9,10 @@ type User struct {
 	ID           uuid.UUID `json:"id" gorm:"type:uuid;default:uuid_generate_v4()"`
 	Email        string    `json:"email"`
 	PasswordHash string    `json:"password_hash"`
-	CreatedAt    time.Time `json:"created_at"`
+
+	common.Audit
+	common.SoftDelete
+	common.Versioned
 }
 
 func (*User) TableName() string {
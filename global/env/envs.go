@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/sethvargo/go-envconfig"
 
@@ -24,22 +25,102 @@ type Envs struct {
 		LogFormat       string `env:"LOG_FORMAT, default=text"`
 		HttpPort        string `env:"HTTP_PORT, default=8090"`
 		SwaggerHostAddr string `env:"SWAGGER_HOST_ADDR"`
-		AssetsDir       string `env:"ASSETS_DIR, required"`
+		AssetsDir       string `env:"ASSETS_DIR, default=./assets"`
 		JwtSecret       string `env:"JWT_SECRET, required"`
+		// the users that can access the admin endpoints
+		AdminEmails []string `env:"ADMIN_EMAILS"`
 	}
 
 	Db struct {
 		Dsn      string `env:"DB_DSN, required"`
 		LogLevel string `env:"DB_LOG_LEVEL, default=error"`
+		// the embedded migrations are applied on startup, set it to false to run them with the migrate command instead
+		MigrateOnStartup bool `env:"DB_MIGRATE_ON_STARTUP, default=true"`
+		// the schema of the models is compared with the db on startup: "off" skips the check, "warn" logs the drift
+		// and "strict" fails the startup if the drift breaks the queries of a model
+		SchemaCheck string `env:"DB_SCHEMA_CHECK, default=warn"`
+		// the introspected schema of the db is read again once it is older than the ttl. a zero ttl never expires it.
+		SchemaCacheTTL time.Duration `env:"DB_SCHEMA_CACHE_TTL, default=5m"`
+
+		// a zero max open conns means unlimited connections, and a zero lifetime or idle time means no limit
+		MaxOpenConns    int           `env:"DB_MAX_OPEN_CONNS, default=25"`
+		MaxIdleConns    int           `env:"DB_MAX_IDLE_CONNS, default=10"`
+		ConnMaxLifetime time.Duration `env:"DB_CONN_MAX_LIFETIME, default=30m"`
+		ConnMaxIdleTime time.Duration `env:"DB_CONN_MAX_IDLE_TIME, default=5m"`
+		// the statement timeout is enforced by postgres for every statement, while the query timeout is the default
+		// deadline of the queries which can be overridden per route, up to the statement timeout. a zero timeout
+		// disables it.
+		StatementTimeout time.Duration `env:"DB_STATEMENT_TIMEOUT, default=60s"`
+		QueryTimeout     time.Duration `env:"DB_QUERY_TIMEOUT, default=30s"`
+		// the queries slower than the threshold are logged. a zero threshold disables it.
+		SlowQueryThreshold time.Duration `env:"DB_SLOW_QUERY_THRESHOLD, default=500ms"`
+
+		// soft deleted records are purged permanently once they are older than the retention period.
+		// a zero purge interval disables the purge job.
+		SoftDeleteRetention time.Duration `env:"DB_SOFT_DELETE_RETENTION, default=720h"`
+		PurgeInterval       time.Duration `env:"DB_PURGE_INTERVAL, default=24h"`
+
+		// the read-only queries outside the transactions are routed to the replicas, if there are any.
+		// a replica is taken out of rotation while it fails the health check or lags behind more than the max lag.
+		ReplicaDsns                []string      `env:"DB_REPLICA_DSNS"`
+		ReplicaMaxLag              time.Duration `env:"DB_REPLICA_MAX_LAG, default=10s"`
+		ReplicaHealthCheckInterval time.Duration `env:"DB_REPLICA_HEALTH_CHECK_INTERVAL, default=5s"`
+	}
+
+	Telemetry struct {
+		// the metrics are served in the Prometheus format on this path of the http port. an empty path disables it.
+		MetricsPath string `env:"METRICS_PATH, default=/metrics"`
+		// the exporter of the spans: "none" only propagates the trace context (e.g. for the trace ids of the logs),
+		// and "stdout" writes the spans to the standard output
+		TracesExporter    string  `env:"TRACES_EXPORTER, default=none"`
+		TracesSampleRatio float64 `env:"TRACES_SAMPLE_RATIO, default=1"`
+	}
+
+	Events struct {
+		// a zero relay interval disables the outbox relay
+		RelayInterval  time.Duration `env:"EVENTS_RELAY_INTERVAL, default=1s"`
+		RelayBatchSize int           `env:"EVENTS_RELAY_BATCH_SIZE, default=100"`
+		// the claimed events are delivered again after the lease if the relay fails to record their results
+		ClaimLease  time.Duration `env:"EVENTS_CLAIM_LEASE, default=5m"`
+		MaxAttempts int           `env:"EVENTS_MAX_ATTEMPTS, default=10"`
+		WebhookUrl  string        `env:"EVENTS_WEBHOOK_URL"`
+	}
+
+	Jobs struct {
+		// a zero poll interval disables the job workers
+		PollInterval time.Duration `env:"JOBS_POLL_INTERVAL, default=1s"`
+		// the concurrency of each queue e.g. "default:10,emails:2"
+		Queues      map[string]int `env:"JOBS_QUEUES, default=default:10"`
+		MaxAttempts int            `env:"JOBS_MAX_ATTEMPTS, default=10"`
+		Timeout     time.Duration  `env:"JOBS_TIMEOUT, default=5m"`
+		// disable it to run the job workers only in dedicated worker processes
+		RunInServer bool `env:"JOBS_RUN_IN_SERVER, default=true"`
+	}
+
+	Cron struct {
+		// how often the replicas try to become the leader of the scheduler, and the leader checks its lock.
+		// a zero interval disables the scheduler
+		LeaderPollInterval time.Duration `env:"CRON_LEADER_POLL_INTERVAL, default=10s"`
 	}
 }
 
 // Load loads the environment variables from the .env files
 // we use a base env file called ".env" and override it for different environment (e.g. dev, test, prod, or testing)
 func Load(basePath string) (*Envs, error) {
+	var configs Envs
+	if err := LoadInto(basePath, &configs); err != nil {
+		return nil, err
+	}
+
+	return &configs, nil
+}
+
+// LoadInto loads the environment variables from the .env files like Load, but only processes the fields of target,
+// e.g. for the commands which don't need the required variables of the server.
+func LoadInto(basePath string, target any) error {
 	envFiles, err := filepath.Glob(filepath.Join(basePath, ".env*"))
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to find env files")
+		return errors.Wrap(err, "failed to find env files")
 	}
 
 	profileToEnvFile := map[string]string{}
@@ -57,7 +138,7 @@ func Load(basePath string) (*Envs, error) {
 	if profile != "" {
 		envFile, ok := profileToEnvFile[profile]
 		if !ok {
-			return nil, fmt.Errorf("no env file found for %q profile", profile)
+			return fmt.Errorf("no env file found for %q profile", profile)
 		}
 		activeEnvFiles = append(activeEnvFiles, envFile)
 	}
@@ -71,16 +152,11 @@ func Load(basePath string) (*Envs, error) {
 		log.Print("no .env file found")
 	}
 
-	log.Printf(fmt.Sprintf("trying to load %v env files", activeEnvFiles))
+	log.Printf("trying to load %v env files", activeEnvFiles)
 	err = godotenv.Load(activeEnvFiles...)
 	if err != nil {
-		return nil, err
-	}
-
-	var configs Envs
-	if err = envconfig.Process(context.Background(), &configs); err != nil {
-		return nil, err
+		return err
 	}
 
-	return &configs, nil
+	return envconfig.Process(context.Background(), target)
 }
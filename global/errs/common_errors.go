@@ -7,6 +7,9 @@ import (
 const (
 	DefaultEntryNotFoundMessage = "%q entry by id %d could not be found"
 	InvalidSearchFieldMessage   = "invalid field %q for search condition"
+	InvalidSortFieldMessage     = "invalid field %q for sort condition"
+	InvalidSelectFieldMessage   = "invalid field %q for field selection"
+	InvalidUpsertFieldMessage   = "invalid field %q for upsert"
 	FailedToListItemsMessage    = "failed to list %q"
 )
 
@@ -14,6 +17,18 @@ func NewInvalidSearchFieldErr(fieldName string) error {
 	return fmt.Errorf(InvalidSearchFieldMessage, fieldName)
 }
 
+func NewInvalidSortFieldErr(fieldName string) error {
+	return Newf(InvalidArgument, nil, InvalidSortFieldMessage, fieldName)
+}
+
+func NewInvalidSelectFieldErr(fieldName string) error {
+	return Newf(InvalidArgument, nil, InvalidSelectFieldMessage, fieldName)
+}
+
+func NewInvalidUpsertFieldErr(fieldName string) error {
+	return Newf(InvalidArgument, nil, InvalidUpsertFieldMessage, fieldName)
+}
+
 type EntryNotFoundErr struct {
 	message string
 }
@@ -33,6 +33,8 @@ func (i ErrorCode) String() string {
 		return "DependencyConflict"
 	case Unavailable:
 		return "Unavailable"
+	case Conflict:
+		return "Conflict"
 	}
 	return "Unknown"
 }
@@ -65,6 +67,8 @@ func (i ErrorCode) HttpStatus() int {
 		return http.StatusConflict
 	case Unavailable:
 		return http.StatusServiceUnavailable
+	case Conflict:
+		return http.StatusConflict
 	}
 	return http.StatusInternalServerError
 }
@@ -116,4 +120,7 @@ const (
 	DependencyConflict ErrorCode = 13
 
 	Unavailable ErrorCode = 14
+
+	// Conflict The resource has been modified concurrently, e.g. its version does not match the expected one.
+	Conflict ErrorCode = 15
 )
@@ -2,11 +2,11 @@ package main
 
 import (
 	"log"
+	"os"
 	"time"
 	_ "time/tzdata"
 
-	"github.com/amahdian/golang-gin-boilerplate/global/env"
-	"github.com/amahdian/golang-gin-boilerplate/server"
+	"github.com/amahdian/golang-gin-boilerplate/cmd"
 )
 
 func init() {
@@ -26,16 +26,7 @@ func init() {
 // @description				Type "Bearer" followed by a space and JWT token.
 // @x-extension-openapi		{"example": "value on a json format"}
 func main() {
-	envs, err := env.Load("")
-	if err != nil {
-		log.Fatalf("failed to load env variables: %v", err)
-	}
-
-	s, err := server.NewServer(envs)
-	if err != nil {
-		log.Fatal(err)
-	}
-	err = s.Run()
+	err := cmd.Execute(os.Args[1:])
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,22 @@
+// Package actor carries the id of the user who makes the changes of a context, e.g. the authenticated user of a
+// request, so the storage can fill the audit columns without depending on the authentication.
+package actor
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type userIdCtx struct{}
+
+// WithUserId returns a context whose changes are made by the user of the given id.
+func WithUserId(ctx context.Context, userId uuid.UUID) context.Context {
+	return context.WithValue(ctx, userIdCtx{}, userId)
+}
+
+// UserIdFromCtx returns the id of the user who makes the changes of the context, or uuid.Nil if it is anonymous.
+func UserIdFromCtx(ctx context.Context) uuid.UUID {
+	userId, _ := ctx.Value(userIdCtx{}).(uuid.UUID)
+	return userId
+}
@@ -0,0 +1,13 @@
+package backoff
+
+import "time"
+
+// Exponential doubles the base duration after every failed attempt and caps it at max.
+// The attempts start from 1, e.g. for a base of 1s: 1s, 2s, 4s, 8s, ...
+func Exponential(attempt int, base, max time.Duration) time.Duration {
+	backoff := base
+	for i := 1; i < attempt && backoff < max; i++ {
+		backoff *= 2
+	}
+	return min(backoff, max)
+}
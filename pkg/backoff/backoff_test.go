@@ -0,0 +1,22 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExponential(t *testing.T) {
+	for _, test := range []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{100, time.Hour},
+	} {
+		if got := Exponential(test.attempt, time.Second, time.Hour); got != test.want {
+			t.Errorf("Exponential(%d): got %v, want %v", test.attempt, got, test.want)
+		}
+	}
+}
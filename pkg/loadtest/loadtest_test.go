@@ -0,0 +1,258 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseScenario(t *testing.T) {
+	s, err := ParseScenario([]byte(`
+requests: 100
+timeout: 250ms
+auth:
+  admin:
+    login: {path: /user/login, email: admin@example.com, password: admin}
+endpoints:
+  - path: /health
+  - name: list jobs
+    method: get
+    path: /api/v1/admin/jobs
+    weight: 3
+    auth: admin
+thresholds:
+  max_error_rate: 0.05
+  max_dropped: 0
+  p99: 1s
+`))
+	require.NoError(t, err)
+	assert.Equal(t, DefaultBaseUrl, s.BaseUrl)
+	assert.Equal(t, DefaultConcurrency, s.Concurrency)
+	assert.Zero(t, s.Duration, "the number of requests ends the test")
+	assert.Equal(t, Duration(250*time.Millisecond), s.Timeout)
+	assert.Equal(t, DefaultTokenField, s.Auth["admin"].Login.TokenField)
+	assert.Equal(t, "endpoint 1", s.Endpoints[0].Name)
+	assert.Equal(t, http.MethodGet, s.Endpoints[0].Method)
+	assert.Equal(t, 1, s.Endpoints[0].Weight)
+	assert.Equal(t, http.MethodGet, s.Endpoints[1].Method)
+	assert.Equal(t, 0.05, *s.Thresholds.MaxErrorRate)
+	assert.Equal(t, 0, *s.Thresholds.MaxDropped)
+	assert.Equal(t, Duration(time.Second), s.Thresholds.P99)
+
+	s, err = ParseScenario([]byte(`{"endpoints": [{"path": "/health"}]}`))
+	require.NoError(t, err, "a json scenario is valid yaml as well")
+	assert.Equal(t, Duration(DefaultDuration), s.Duration)
+
+	for name, scenario := range map[string]string{
+		"field wieght not found":                 "endpoints: [{path: /health, wieght: 2}]",
+		"invalid duration":                       "duration: 10 seconds\nendpoints: [{path: /health}]",
+		"the scenario has no endpoints":          "rps: 10",
+		"must start with /":                      "endpoints: [{path: health}]",
+		"uses the undefined auth \"admin\"":      "endpoints: [{path: /health, auth: admin}]",
+		"must have either a token or a login":    "auth: {admin: {}}\nendpoints: [{path: /health}]",
+		"is not an absolute url":                 "base_url: localhost\nendpoints: [{path: /health}]",
+		"the health endpoint is defined more":    "endpoints: [{name: health, path: /health}, {name: health, path: /health}]",
+		"the weight of the health endpoint must": "endpoints: [{name: health, path: /health, weight: -1}]",
+		"the rps must be between 0 and 1000000":  "rps: 1e12\nendpoints: [{path: /health}]",
+		"the max dropped requests can't be":      "thresholds: {max_dropped: -1}\nendpoints: [{path: /health}]",
+		"of the health endpoint can only be set": "endpoints: [{name: health, path: /health, thresholds: {max_dropped: 1}}]",
+	} {
+		_, err = ParseScenario([]byte(scenario))
+		require.Error(t, err, scenario)
+		assert.Contains(t, err.Error(), name)
+	}
+}
+
+func TestRunConcurrently(t *testing.T) {
+	var bodies sync.Map
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/user/login":
+			_, _ = io.WriteString(w, `{"success": true, "data": "the-token"}`)
+		case "/jobs":
+			if r.Header.Get("Authorization") != "Bearer the-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			body, _ := io.ReadAll(r.Body)
+			bodies.Store(string(body), true)
+			w.WriteHeader(http.StatusCreated)
+		case "/fail":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	s, err := ParseScenario([]byte(`
+requests: 60
+concurrency: 4
+auth:
+  admin:
+    login: {path: /user/login, email: admin@example.com, password: admin}
+endpoints:
+  - name: create job
+    method: POST
+    path: /jobs
+    auth: admin
+    weight: 2
+    body: '{"seq": {{.Seq}}}'
+  - name: fail
+    path: /fail
+  - name: not found
+    path: /missing
+    expect: [404]
+`))
+	require.NoError(t, err)
+	s.BaseUrl = srv.URL
+
+	runner, err := NewRunner(s)
+	require.NoError(t, err)
+	report, err := runner.Run(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, 60, report.Total.Requests)
+	require.Len(t, report.Endpoints, 3)
+	create, fail, notFound := report.Endpoints[0], report.Endpoints[1], report.Endpoints[2]
+	assert.Equal(t, 60, create.Requests+fail.Requests+notFound.Requests)
+	assert.Greater(t, create.Requests, fail.Requests, "the endpoints are picked by their weights")
+
+	assert.Zero(t, create.Errors)
+	assert.Equal(t, map[int]int{http.StatusCreated: create.Requests}, create.Statuses)
+	assert.Equal(t, fail.Requests, fail.Errors)
+	assert.Equal(t, map[string]int{"status 500": fail.Requests}, fail.ErrorKinds)
+	assert.Zero(t, notFound.Errors, "the expected statuses are successful")
+	assert.Equal(t, fail.Errors, report.Total.Errors)
+	assert.InDelta(t, float64(fail.Errors)/60, report.Total.ErrorRate, 1e-9)
+
+	count := 0
+	bodies.Range(func(key, _ any) bool {
+		count++
+		return true
+	})
+	assert.Equal(t, create.Requests, count, "every request has its own sequence number")
+}
+
+func TestRunAtRate(t *testing.T) {
+	var requests atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	s, err := ParseScenario([]byte(`
+rps: 100
+concurrency: 2
+duration: 300ms
+endpoints:
+  - path: /slow
+`))
+	require.NoError(t, err)
+	s.BaseUrl = srv.URL
+
+	runner, err := NewRunner(s)
+	require.NoError(t, err)
+	report, err := runner.Run(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, int(requests.Load()), report.Total.Requests, "the requests in flight are awaited")
+	assert.Positive(t, report.Total.Requests)
+	assert.Positive(t, report.Dropped, "the requests beyond the concurrency are dropped")
+
+	maxDropped := 0
+	s.Thresholds.MaxDropped = &maxDropped
+	report.Check(s)
+	assert.Contains(t, report.Violations, fmt.Sprintf("TOTAL: the %d dropped requests exceed 0", report.Dropped))
+	assert.GreaterOrEqual(t, report.Total.Latency.P50, 50.0)
+	assert.Equal(t, 100.0, report.TargetRps)
+}
+
+func TestRunLoginFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = io.WriteString(w, `{"success": false}`)
+	}))
+	defer srv.Close()
+
+	s, err := ParseScenario([]byte(`
+requests: 1
+auth:
+  admin:
+    login: {path: /user/login, email: admin@example.com, password: wrong}
+endpoints:
+  - path: /health
+    auth: admin
+`))
+	require.NoError(t, err)
+	s.BaseUrl = srv.URL
+
+	runner, err := NewRunner(s)
+	require.NoError(t, err)
+	_, err = runner.Run(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to log in as the admin auth: the login responded with 400")
+}
+
+func TestReport(t *testing.T) {
+	latencies := make([]time.Duration, 0, 100)
+	for i := 100; i >= 1; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	latency := summarize(latencies)
+	assert.Equal(t, Latency{Min: 1, Mean: 50.5, P50: 50, P90: 90, P95: 95, P99: 99, Max: 100}, latency)
+	assert.Equal(t, Latency{}, summarize(nil))
+
+	maxErrorRate := 0.01
+	s := &Scenario{
+		Thresholds: Thresholds{MaxErrorRate: &maxErrorRate, P99: Duration(time.Second)},
+		Endpoints: []*Endpoint{
+			{Name: "health", Thresholds: Thresholds{P95: Duration(50 * time.Millisecond)}},
+		},
+	}
+	report := &Report{
+		Scenario: "smoke",
+		Total: &Stats{
+			Name: "TOTAL", Requests: 100, Errors: 2, ErrorRate: 0.02, Rps: 10, Latency: latency,
+			Statuses: map[int]int{200: 98, 500: 2}, ErrorKinds: map[string]int{"status 500": 2},
+		},
+		Endpoints: []*Stats{{
+			Name: "health", Requests: 100, Errors: 2, ErrorRate: 0.02, Rps: 10, Latency: latency,
+			Statuses: map[int]int{200: 98, 500: 2}, ErrorKinds: map[string]int{"status 500": 2},
+		}},
+	}
+	report.Check(s)
+	assert.True(t, report.Failed())
+	assert.Equal(t, []string{
+		"TOTAL: the error rate 2.00% exceeds 1.00%",
+		"health: the p95 latency 95.0ms exceeds 50ms",
+	}, report.Violations)
+
+	out := &bytes.Buffer{}
+	require.NoError(t, report.Print(out))
+	lines := strings.Split(out.String(), "\n")
+	assert.Contains(t, lines[0], "scenario smoke: 100 requests")
+	assert.Regexp(t, `^health\s+100\s+2\s+2.00%\s+10.0\s+1.0ms\s+50.5ms\s+50.0ms\s+90.0ms\s+95.0ms\s+99.0ms\s+100.0ms$`, lines[3])
+	assert.Regexp(t, `^TOTAL\s+100\s+2`, lines[4])
+	assert.Contains(t, out.String(), "threshold exceeded: health: the p95 latency 95.0ms exceeds 50ms")
+
+	out.Reset()
+	require.NoError(t, report.WriteJson(out))
+	decoded := &Report{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), decoded))
+	assert.Equal(t, report.Total, decoded.Total)
+	assert.Equal(t, report.Violations, decoded.Violations)
+}
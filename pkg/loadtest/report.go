@@ -0,0 +1,309 @@
+package loadtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// percentiles are reported in the Latency of the stats.
+var percentiles = []float64{50, 90, 95, 99}
+
+// Report is the result of a load test. The latencies are in milliseconds and the durations in seconds, so the json
+// report can be compared between the releases.
+type Report struct {
+	Scenario    string    `json:"scenario"`
+	BaseUrl     string    `json:"base_url"`
+	TargetRps   float64   `json:"target_rps,omitempty"`
+	Concurrency int       `json:"concurrency"`
+	StartedAt   time.Time `json:"started_at"`
+	Elapsed     float64   `json:"elapsed_seconds"`
+	// Dropped is the number of requests which were not sent at the target rate, since Concurrency requests were
+	// already in flight.
+	Dropped     int      `json:"dropped"`
+	Interrupted bool     `json:"interrupted,omitempty"`
+	Total       *Stats   `json:"total"`
+	Endpoints   []*Stats `json:"endpoints"`
+	// Violations lists the thresholds which the test exceeded, see Check.
+	Violations []string `json:"violations,omitempty"`
+}
+
+// Stats are the results of the requests of an endpoint, or of all of them.
+type Stats struct {
+	Name      string  `json:"name"`
+	Requests  int     `json:"requests"`
+	Errors    int     `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+	Rps       float64 `json:"rps"`
+	Latency   Latency `json:"latency"`
+	// Statuses counts the responses by their status.
+	Statuses map[int]int `json:"statuses"`
+	// ErrorKinds counts the errors by their kind, e.g. "status 500" or "timeout".
+	ErrorKinds map[string]int `json:"error_kinds,omitempty"`
+}
+
+// Latency summarizes the latencies of the responses in milliseconds. The requests which failed without a response
+// are not included.
+type Latency struct {
+	Min  float64 `json:"min_ms"`
+	Mean float64 `json:"mean_ms"`
+	P50  float64 `json:"p50_ms"`
+	P90  float64 `json:"p90_ms"`
+	P95  float64 `json:"p95_ms"`
+	P99  float64 `json:"p99_ms"`
+	Max  float64 `json:"max_ms"`
+}
+
+// Check compares the total stats and the dropped requests with the thresholds of the scenario, and the stats of the
+// endpoints with their own thresholds, and adds the exceeded ones to the violations.
+func (r *Report) Check(s *Scenario) {
+	r.Total.check(s.Thresholds, r.violate)
+	if s.Thresholds.MaxDropped != nil && r.Dropped > *s.Thresholds.MaxDropped {
+		r.violate("%s: the %d dropped requests exceed %d", r.Total.Name, r.Dropped, *s.Thresholds.MaxDropped)
+	}
+	for i, e := range s.Endpoints {
+		if i < len(r.Endpoints) {
+			r.Endpoints[i].check(e.Thresholds, r.violate)
+		}
+	}
+}
+
+func (stats *Stats) check(t Thresholds, violate func(format string, args ...any)) {
+	if t.MaxErrorRate != nil && stats.ErrorRate > *t.MaxErrorRate {
+		violate("%s: the error rate %.2f%% exceeds %.2f%%", stats.Name, stats.ErrorRate*100, *t.MaxErrorRate*100)
+	}
+	if t.MinRps > 0 && stats.Rps < t.MinRps {
+		violate("%s: the throughput %.1f rps is below %.1f rps", stats.Name, stats.Rps, t.MinRps)
+	}
+	for _, threshold := range []struct {
+		name  string
+		value float64
+		max   Duration
+	}{
+		{"p50", stats.Latency.P50, t.P50},
+		{"p95", stats.Latency.P95, t.P95},
+		{"p99", stats.Latency.P99, t.P99},
+	} {
+		if threshold.max > 0 && threshold.value > millis(time.Duration(threshold.max)) {
+			violate("%s: the %s latency %.1fms exceeds %s", stats.Name, threshold.name, threshold.value, threshold.max)
+		}
+	}
+}
+
+// Failed reports whether the test exceeded any threshold.
+func (r *Report) Failed() bool {
+	return len(r.Violations) > 0
+}
+
+func (r *Report) violate(format string, args ...any) {
+	r.Violations = append(r.Violations, fmt.Sprintf(format, args...))
+}
+
+// WriteJson writes the indented json of the report.
+func (r *Report) WriteJson(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// Print writes the stats of the endpoints as a table, followed by their errors and the violated thresholds.
+func (r *Report) Print(w io.Writer) error {
+	target := ""
+	if r.TargetRps > 0 {
+		target = fmt.Sprintf(", target %.1f rps", r.TargetRps)
+	}
+	_, _ = fmt.Fprintf(w, "scenario %s: %d requests to %s in %.1fs (%.1f rps%s, concurrency %d, %d dropped)\n",
+		r.Scenario, r.Total.Requests, r.BaseUrl, r.Elapsed, r.Total.Rps, target, r.Concurrency, r.Dropped)
+	if r.Interrupted {
+		_, _ = fmt.Fprintln(w, "the test was interrupted, the report only covers the completed requests")
+	}
+	_, _ = fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "ENDPOINT\tREQUESTS\tERRORS\tERROR RATE\tRPS\tMIN\tMEAN\tP50\tP90\tP95\tP99\tMAX")
+	for _, stats := range append(slices.Clone(r.Endpoints), r.Total) {
+		l := stats.Latency
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\t%.2f%%\t%.1f\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			stats.Name, stats.Requests, stats.Errors, stats.ErrorRate*100, stats.Rps,
+			formatMillis(l.Min), formatMillis(l.Mean), formatMillis(l.P50), formatMillis(l.P90),
+			formatMillis(l.P95), formatMillis(l.P99), formatMillis(l.Max))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if r.Total.Errors > 0 {
+		_, _ = fmt.Fprintln(w)
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "ENDPOINT\tCOUNT\tERROR")
+		for _, stats := range r.Endpoints {
+			kinds := make([]string, 0, len(stats.ErrorKinds))
+			for kind := range stats.ErrorKinds {
+				kinds = append(kinds, kind)
+			}
+			sort.Strings(kinds)
+			for _, kind := range kinds {
+				_, _ = fmt.Fprintf(tw, "%s\t%d\t%s\n", stats.Name, stats.ErrorKinds[kind], kind)
+			}
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if len(r.Violations) > 0 {
+		_, _ = fmt.Fprintln(w)
+		for _, violation := range r.Violations {
+			_, _ = fmt.Fprintf(w, "threshold exceeded: %s\n", violation)
+		}
+	}
+	return nil
+}
+
+func formatMillis(ms float64) string {
+	return fmt.Sprintf("%.1fms", ms)
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// collector records the results of the requests from the concurrent clients.
+type collector struct {
+	scenario  *Scenario
+	mu        sync.Mutex
+	startedAt time.Time
+	dropped   int
+	total     *results
+	endpoints []*results
+	byName    map[string]*results
+}
+
+type results struct {
+	name       string
+	requests   int
+	errors     int
+	latencies  []time.Duration
+	statuses   map[int]int
+	errorKinds map[string]int
+}
+
+func newResults(name string) *results {
+	return &results{name: name, statuses: map[int]int{}, errorKinds: map[string]int{}}
+}
+
+func newCollector(s *Scenario, endpoints []*endpoint) *collector {
+	c := &collector{scenario: s, total: newResults("TOTAL"), byName: map[string]*results{}}
+	for _, e := range endpoints {
+		res := newResults(e.Name)
+		c.endpoints = append(c.endpoints, res)
+		c.byName[e.Name] = res
+	}
+	return c
+}
+
+func (c *collector) start() {
+	c.startedAt = time.Now()
+}
+
+func (c *collector) drop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dropped++
+}
+
+// record adds the result of a request. The status is 0 if the request failed without a response.
+func (c *collector) record(e *endpoint, status int, latency time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, res := range []*results{c.byName[e.Name], c.total} {
+		res.requests++
+		switch {
+		case err != nil:
+			res.errors++
+			res.errorKinds[errorKind(err)]++
+		case !e.succeeded(status):
+			res.errors++
+			res.errorKinds[fmt.Sprintf("status %d", status)]++
+		}
+		if status != 0 {
+			res.statuses[status]++
+			res.latencies = append(res.latencies, latency)
+		}
+	}
+}
+
+func (c *collector) report() *Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elapsed := time.Since(c.startedAt)
+	report := &Report{
+		Scenario:    c.scenario.Name,
+		BaseUrl:     c.scenario.BaseUrl,
+		TargetRps:   c.scenario.Rps,
+		Concurrency: c.scenario.Concurrency,
+		StartedAt:   c.startedAt.UTC(),
+		Elapsed:     elapsed.Seconds(),
+		Dropped:     c.dropped,
+		Total:       c.total.stats(elapsed),
+	}
+	for _, res := range c.endpoints {
+		report.Endpoints = append(report.Endpoints, res.stats(elapsed))
+	}
+	return report
+}
+
+func (r *results) stats(elapsed time.Duration) *Stats {
+	stats := &Stats{
+		Name:       r.name,
+		Requests:   r.requests,
+		Errors:     r.errors,
+		Statuses:   r.statuses,
+		ErrorKinds: r.errorKinds,
+		Latency:    summarize(r.latencies),
+	}
+	if r.requests > 0 {
+		stats.ErrorRate = float64(r.errors) / float64(r.requests)
+	}
+	if elapsed > 0 {
+		stats.Rps = float64(r.requests) / elapsed.Seconds()
+	}
+	return stats
+}
+
+func summarize(latencies []time.Duration) Latency {
+	if len(latencies) == 0 {
+		return Latency{}
+	}
+	sorted := slices.Clone(latencies)
+	slices.Sort(sorted)
+	var sum time.Duration
+	for _, latency := range sorted {
+		sum += latency
+	}
+	values := make([]float64, len(percentiles))
+	for i, p := range percentiles {
+		values[i] = millis(percentile(sorted, p))
+	}
+	return Latency{
+		Min:  millis(sorted[0]),
+		Mean: millis(sum / time.Duration(len(sorted))),
+		P50:  values[0],
+		P90:  values[1],
+		P95:  values[2],
+		P99:  values[3],
+		Max:  millis(sorted[len(sorted)-1]),
+	}
+}
+
+// percentile returns the nearest rank percentile of the sorted latencies.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
@@ -0,0 +1,142 @@
+package loadtest
+
+import (
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// TemplateData is the data of the templates of a request, e.g. {{.Seq}}.
+type TemplateData struct {
+	// Seq is the number of the request in the test, starting from 1.
+	Seq int64
+	// Endpoint is the name of the endpoint.
+	Endpoint string
+}
+
+// templateFuncs are the functions of the templates. The random values are not reproducible, since the order of the
+// concurrent requests is not either.
+var templateFuncs = template.FuncMap{
+	"uuid": uuid.NewString,
+	// randInt returns a random number in [min, max]
+	"randInt": func(min, max int) (int, error) {
+		if max < min {
+			return 0, errors.Errorf("randInt: the max %d is less than the min %d", max, min)
+		}
+		return min + rand.IntN(max-min+1), nil
+	},
+	// randString returns a random string of lowercase letters and digits
+	"randString": func(n int) string {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = alphabet[rand.IntN(len(alphabet))]
+		}
+		return string(b)
+	},
+	"now": func() string {
+		return time.Now().UTC().Format(time.RFC3339)
+	},
+}
+
+// endpoint is an Endpoint with its parsed templates.
+type endpoint struct {
+	*Endpoint
+	path    *template.Template
+	body    *template.Template
+	headers map[string]*template.Template
+	expect  map[int]bool
+}
+
+func newEndpoint(e *Endpoint) (*endpoint, error) {
+	parsed := &endpoint{Endpoint: e, headers: map[string]*template.Template{}, expect: map[int]bool{}}
+	var err error
+	if parsed.path, err = parseTemplate(e.Name+" path", e.Path); err != nil {
+		return nil, err
+	}
+	if e.Body != "" {
+		if parsed.body, err = parseTemplate(e.Name+" body", e.Body); err != nil {
+			return nil, err
+		}
+	}
+	for name, value := range e.Headers {
+		if parsed.headers[name], err = parseTemplate(e.Name+" "+name+" header", value); err != nil {
+			return nil, err
+		}
+	}
+	for _, status := range e.Expect {
+		parsed.expect[status] = true
+	}
+	return parsed, nil
+}
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	t, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the template of the %s", name)
+	}
+	return t, nil
+}
+
+func render(t *template.Template, data *TemplateData) (string, error) {
+	text := &strings.Builder{}
+	if err := t.Execute(text, data); err != nil {
+		return "", err
+	}
+	return text.String(), nil
+}
+
+// newRequest renders the templates of the endpoint into a request. The headers of the endpoint override the headers
+// of the scenario.
+func (e *endpoint) newRequest(ctx context.Context, s *Scenario, token string, data *TemplateData) (*http.Request, error) {
+	path, err := render(e.path, data)
+	if err != nil {
+		return nil, err
+	}
+	var body io.Reader
+	if e.body != nil {
+		text, err := render(e.body, data)
+		if err != nil {
+			return nil, err
+		}
+		body = strings.NewReader(text)
+	}
+
+	r, err := http.NewRequestWithContext(ctx, e.Method, s.BaseUrl+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	for name, value := range s.Headers {
+		r.Header.Set(name, value)
+	}
+	for name, t := range e.headers {
+		value, err := render(t, data)
+		if err != nil {
+			return nil, err
+		}
+		r.Header.Set(name, value)
+	}
+	return r, nil
+}
+
+// succeeded reports whether the status is expected from the endpoint.
+func (e *endpoint) succeeded(status int) bool {
+	if len(e.expect) > 0 {
+		return e.expect[status]
+	}
+	return status < http.StatusBadRequest
+}
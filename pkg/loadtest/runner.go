@@ -0,0 +1,253 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Runner runs the load test of a scenario.
+type Runner struct {
+	scenario  *Scenario
+	client    *http.Client
+	endpoints []*endpoint
+	// weights holds the cumulative weights of the endpoints
+	weights []int
+	tokens  map[string]string
+	seq     atomic.Int64
+}
+
+// NewRunner parses the templates of the scenario and returns its runner.
+func NewRunner(s *Scenario) (*Runner, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	r := &Runner{
+		scenario: s,
+		client: &http.Client{
+			Timeout: time.Duration(s.Timeout),
+			Transport: &http.Transport{
+				// the server is called directly, regardless of the proxy settings of the environment
+				Proxy:               nil,
+				DialContext:         (&net.Dialer{Timeout: time.Duration(s.Timeout)}).DialContext,
+				MaxIdleConns:        s.Concurrency,
+				MaxIdleConnsPerHost: s.Concurrency,
+				IdleConnTimeout:     time.Minute,
+			},
+		},
+		tokens: map[string]string{},
+	}
+	total := 0
+	for _, e := range s.Endpoints {
+		parsed, err := newEndpoint(e)
+		if err != nil {
+			return nil, err
+		}
+		total += e.Weight
+		r.endpoints = append(r.endpoints, parsed)
+		r.weights = append(r.weights, total)
+	}
+	return r, nil
+}
+
+// Run logs in with the credentials of the scenario and sends its requests until the duration is over or the number
+// of requests is reached. The requests in flight are awaited when the test is over. When ctx is canceled, the test
+// is stopped and the report covers the requests which were completed before.
+func (r *Runner) Run(ctx context.Context) (*Report, error) {
+	if err := r.authenticate(ctx); err != nil {
+		return nil, err
+	}
+
+	runCtx := ctx
+	if r.scenario.Duration > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, time.Duration(r.scenario.Duration))
+		defer cancel()
+	}
+	collector := newCollector(r.scenario, r.endpoints)
+	collector.start()
+	if r.scenario.Rps > 0 {
+		r.runAtRate(ctx, runCtx, collector)
+	} else {
+		r.runConcurrently(ctx, runCtx, collector)
+	}
+	report := collector.report()
+	report.Interrupted = ctx.Err() != nil
+	return report, nil
+}
+
+// runConcurrently sends the requests from Concurrency clients, each one after its previous response.
+func (r *Runner) runConcurrently(ctx, runCtx context.Context, collector *collector) {
+	wg := sync.WaitGroup{}
+	for range r.scenario.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for runCtx.Err() == nil {
+				seq, ok := r.next()
+				if !ok {
+					return
+				}
+				r.send(ctx, seq, collector)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// runAtRate sends the requests at the target rate regardless of the responses. A request is dropped if Concurrency
+// requests are already in flight, so a slow server shows up as dropped requests rather than a lower rate.
+func (r *Runner) runAtRate(ctx, runCtx context.Context, collector *collector) {
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / r.scenario.Rps))
+	defer ticker.Stop()
+	inFlight := make(chan struct{}, r.scenario.Concurrency)
+	wg := sync.WaitGroup{}
+	defer wg.Wait()
+	for {
+		seq, ok := r.next()
+		if !ok {
+			return
+		}
+		select {
+		case inFlight <- struct{}{}:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-inFlight }()
+				r.send(ctx, seq, collector)
+			}()
+		default:
+			collector.drop()
+		}
+
+		select {
+		case <-runCtx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// next returns the number of the next request, unless the number of requests is reached.
+func (r *Runner) next() (int64, bool) {
+	seq := r.seq.Add(1)
+	return seq, r.scenario.Requests == 0 || seq <= int64(r.scenario.Requests)
+}
+
+func (r *Runner) send(ctx context.Context, seq int64, collector *collector) {
+	e := r.pick()
+	req, err := e.newRequest(ctx, r.scenario, r.tokens[e.Auth], &TemplateData{Seq: seq, Endpoint: e.Name})
+	if err != nil {
+		collector.record(e, 0, 0, errors.Wrap(err, "failed to render the request"))
+		return
+	}
+
+	start := time.Now()
+	res, err := r.client.Do(req)
+	if err == nil {
+		_, err = io.Copy(io.Discard, res.Body)
+		_ = res.Body.Close()
+	}
+	latency := time.Since(start)
+	if ctx.Err() != nil {
+		// the test was interrupted, so the request did not fail on its own
+		return
+	}
+	if err != nil {
+		collector.record(e, 0, latency, err)
+		return
+	}
+	collector.record(e, res.StatusCode, latency, nil)
+}
+
+// pick returns a random endpoint in proportion to the weights.
+func (r *Runner) pick() *endpoint {
+	n := rand.IntN(r.weights[len(r.weights)-1])
+	for i, weight := range r.weights {
+		if n < weight {
+			return r.endpoints[i]
+		}
+	}
+	return r.endpoints[len(r.endpoints)-1]
+}
+
+// authenticate logs in with the credentials of the scenario, before the test is started.
+func (r *Runner) authenticate(ctx context.Context) error {
+	for name, auth := range r.scenario.Auth {
+		if auth.Token != "" {
+			r.tokens[name] = auth.Token
+			continue
+		}
+		token, err := r.login(ctx, auth.Login)
+		if err != nil {
+			return errors.Wrapf(err, "failed to log in as the %s auth", name)
+		}
+		r.tokens[name] = token
+	}
+	return nil
+}
+
+func (r *Runner) login(ctx context.Context, login *Login) (string, error) {
+	body, err := json.Marshal(map[string]string{"email": login.Email, "password": login.Password})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.scenario.BaseUrl+login.Path, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := r.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", errors.Errorf("the login responded with %d: %s", res.StatusCode, data)
+	}
+
+	var value any
+	if err = json.Unmarshal(data, &value); err != nil {
+		return "", errors.Wrap(err, "the login response is not json")
+	}
+	for _, field := range strings.Split(login.TokenField, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			break
+		}
+		value = object[field]
+	}
+	token, ok := value.(string)
+	if !ok || token == "" {
+		return "", errors.Errorf("the login response has no token in its %s field: %s", login.TokenField, data)
+	}
+	return token, nil
+}
+
+// errorKind describes the error of a request without its url, so the same errors of the endpoints are counted together.
+func errorKind(err error) string {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return "timeout"
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err.Error()
+	}
+	return err.Error()
+}
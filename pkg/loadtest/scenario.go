@@ -0,0 +1,264 @@
+// Package loadtest replays a scenario of weighted requests against a running server, at a target rate or with a fixed
+// number of concurrent clients, and reports the latency percentiles, the error rates and the throughput of its
+// endpoints.
+//
+// The scenarios are YAML files (JSON is valid YAML as well). The paths, the headers and the bodies of the endpoints
+// are Go templates, e.g. {{uuid}} or {{randInt 1 100}}, so the requests can create unique records:
+//
+//	base_url: http://localhost:8090
+//	rps: 50
+//	duration: 30s
+//	auth:
+//	  admin:
+//	    login: {path: /user/login, email: admin@example.com, password: admin}
+//	endpoints:
+//	  - name: health
+//	    path: /health
+//	    weight: 5
+//	  - name: list jobs
+//	    path: /api/v1/admin/jobs?page={{randInt 1 5}}
+//	    auth: admin
+//	thresholds:
+//	  max_error_rate: 0.01
+//	  max_dropped: 0
+//	  p99: 200ms
+package loadtest
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	DefaultBaseUrl     = "http://localhost:8090"
+	DefaultDuration    = 10 * time.Second
+	DefaultConcurrency = 10
+	DefaultTimeout     = 10 * time.Second
+	// MaxRps is the highest target rate, the interval between the requests is too short to be kept above it.
+	MaxRps = 1_000_000
+	// DefaultTokenField is the field of the login response which holds the token, see resp.Response.
+	DefaultTokenField = "data"
+)
+
+// Duration is a time.Duration which is written as a string in the scenarios, e.g. 30s or 250ms.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	var value string
+	if err := node.Decode(&value); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return errors.Errorf("line %d: invalid duration %q", node.Line, value)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// Scenario defines the requests of a load test and how they are sent.
+type Scenario struct {
+	// Name of the scenario in the report, the name of its file by default.
+	Name string `yaml:"name"`
+	// BaseUrl is the url of the server which the paths of the endpoints are relative to.
+	BaseUrl string `yaml:"base_url"`
+	// Rps is the target number of requests per second. The requests are sent at this rate regardless of the
+	// responses, with at most Concurrency requests in flight. Without a rate, Concurrency clients send the requests
+	// one after the other as fast as the server responds.
+	Rps float64 `yaml:"rps"`
+	// Concurrency is the number of concurrent clients, or the maximum number of requests in flight with a rate.
+	Concurrency int `yaml:"concurrency"`
+	// Duration of the test. The test runs for DefaultDuration unless it has a duration or a number of requests.
+	Duration Duration `yaml:"duration"`
+	// Requests is the total number of requests, after which the test stops.
+	Requests int `yaml:"requests"`
+	// Timeout of a single request.
+	Timeout Duration `yaml:"timeout"`
+	// Auth holds the credentials of the endpoints by name.
+	Auth map[string]*Auth `yaml:"auth"`
+	// Headers are sent with every request.
+	Headers   map[string]string `yaml:"headers"`
+	Endpoints []*Endpoint       `yaml:"endpoints"`
+	// Thresholds of the total of the endpoints.
+	Thresholds Thresholds `yaml:"thresholds"`
+}
+
+// Auth is either a fixed bearer token or a login which is sent once before the test.
+type Auth struct {
+	Token string `yaml:"token"`
+	Login *Login `yaml:"login"`
+}
+
+// Login posts the email and the password to the login endpoint and reads the token from the TokenField of its json
+// response, e.g. "data" or "data.token".
+type Login struct {
+	Path       string `yaml:"path"`
+	Email      string `yaml:"email"`
+	Password   string `yaml:"password"`
+	TokenField string `yaml:"token_field"`
+}
+
+// Endpoint is a request of the scenario. The endpoints are picked at random in proportion to their weights, which
+// are 1 by default.
+type Endpoint struct {
+	Name    string            `yaml:"name"`
+	Method  string            `yaml:"method"`
+	Path    string            `yaml:"path"`
+	Weight  int               `yaml:"weight"`
+	Auth    string            `yaml:"auth"`
+	Headers map[string]string `yaml:"headers"`
+	Body    string            `yaml:"body"`
+	// Expect lists the statuses of the successful responses. Any status below 400 is successful by default.
+	Expect []int `yaml:"expect"`
+	// Thresholds of the endpoint, e.g. for a slow endpoint which would hide the latencies of the others in the
+	// thresholds of the scenario.
+	Thresholds Thresholds `yaml:"thresholds"`
+}
+
+// Thresholds fail the test when the report exceeds them, so the regressions can be caught before a release.
+// The thresholds of the scenario are checked against the total of its endpoints. The zero values are not checked.
+type Thresholds struct {
+	MaxErrorRate *float64 `yaml:"max_error_rate"`
+	// MaxDropped is the number of requests which may be dropped at the target rate, see Report.Dropped. The requests
+	// are dropped before their endpoint is picked, so it is only a threshold of the scenario.
+	MaxDropped *int     `yaml:"max_dropped"`
+	MinRps     float64  `yaml:"min_rps"`
+	P50        Duration `yaml:"p50"`
+	P95        Duration `yaml:"p95"`
+	P99        Duration `yaml:"p99"`
+}
+
+// LoadScenario reads and validates the scenario of the file.
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the scenario")
+	}
+	s, err := ParseScenario(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the scenario of %s", path)
+	}
+	if s.Name == "" {
+		s.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return s, nil
+}
+
+// ParseScenario parses the YAML or JSON scenario, sets its defaults and validates it.
+// The unknown fields are rejected, so a misspelled setting is not silently ignored.
+func ParseScenario(data []byte) (*Scenario, error) {
+	s := &Scenario{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(s); err != nil {
+		return nil, err
+	}
+	s.setDefaults()
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Scenario) setDefaults() {
+	if s.BaseUrl == "" {
+		s.BaseUrl = DefaultBaseUrl
+	}
+	s.BaseUrl = strings.TrimSuffix(s.BaseUrl, "/")
+	if s.Concurrency == 0 {
+		s.Concurrency = DefaultConcurrency
+	}
+	if s.Duration == 0 && s.Requests == 0 {
+		s.Duration = Duration(DefaultDuration)
+	}
+	if s.Timeout == 0 {
+		s.Timeout = Duration(DefaultTimeout)
+	}
+	for _, auth := range s.Auth {
+		if auth != nil && auth.Login != nil && auth.Login.TokenField == "" {
+			auth.Login.TokenField = DefaultTokenField
+		}
+	}
+	for i, e := range s.Endpoints {
+		if e.Name == "" {
+			e.Name = fmt.Sprintf("endpoint %d", i+1)
+		}
+		if e.Method == "" {
+			e.Method = http.MethodGet
+		}
+		e.Method = strings.ToUpper(e.Method)
+		if e.Weight == 0 {
+			e.Weight = 1
+		}
+	}
+}
+
+// Validate checks the settings of the scenario, after its defaults are set.
+func (s *Scenario) Validate() error {
+	problems := make([]string, 0)
+	if u, err := url.Parse(s.BaseUrl); err != nil || u.Scheme == "" || u.Host == "" {
+		problems = append(problems, fmt.Sprintf("the base url %q is not an absolute url", s.BaseUrl))
+	}
+	if !(s.Rps >= 0 && s.Rps <= MaxRps) {
+		problems = append(problems, fmt.Sprintf("the rps must be between 0 and %d", MaxRps))
+	}
+	if s.Concurrency < 1 {
+		problems = append(problems, "the concurrency must be positive")
+	}
+	if s.Duration < 0 || s.Requests < 0 || s.Timeout < 0 {
+		problems = append(problems, "the duration, the requests and the timeout can't be negative")
+	}
+	if s.Thresholds.MaxDropped != nil && *s.Thresholds.MaxDropped < 0 {
+		problems = append(problems, "the max dropped requests can't be negative")
+	}
+	for _, name := range slices.Sorted(maps.Keys(s.Auth)) {
+		auth := s.Auth[name]
+		switch {
+		case auth == nil || (auth.Token == "") == (auth.Login == nil):
+			problems = append(problems, fmt.Sprintf("the %s auth must have either a token or a login", name))
+		case auth.Login != nil && (auth.Login.Path == "" || auth.Login.Email == ""):
+			problems = append(problems, fmt.Sprintf("the login of the %s auth must have a path and an email", name))
+		}
+	}
+	if len(s.Endpoints) == 0 {
+		problems = append(problems, "the scenario has no endpoints")
+	}
+	names := map[string]bool{}
+	for _, e := range s.Endpoints {
+		if names[e.Name] {
+			problems = append(problems, fmt.Sprintf("the %s endpoint is defined more than once", e.Name))
+		}
+		names[e.Name] = true
+		if !strings.HasPrefix(e.Path, "/") {
+			problems = append(problems, fmt.Sprintf("the path of the %s endpoint must start with /", e.Name))
+		}
+		if e.Weight < 1 {
+			problems = append(problems, fmt.Sprintf("the weight of the %s endpoint must be positive", e.Name))
+		}
+		if e.Thresholds.MaxDropped != nil {
+			problems = append(problems, fmt.Sprintf("the max dropped requests of the %s endpoint can only be set for the scenario", e.Name))
+		}
+		if _, ok := s.Auth[e.Auth]; e.Auth != "" && !ok {
+			problems = append(problems, fmt.Sprintf("the %s endpoint uses the undefined auth %q", e.Name, e.Auth))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -38,8 +38,8 @@ func ParseMessageLevel(str string) MessageLevel {
 }
 
 type Message struct {
-	Text  string
-	Level MessageLevel
+	Text  string       `json:"text"`
+	Level MessageLevel `json:"level" swaggertype:"string" enums:"Fatal,Error,Warning,Info"`
 }
 
 func (m *Message) MarshalJSON() ([]byte, error) {
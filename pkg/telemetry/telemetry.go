@@ -0,0 +1,90 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/amahdian/golang-gin-boilerplate/global/env"
+	"github.com/amahdian/golang-gin-boilerplate/version"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+)
+
+const (
+	TracesExporterNone   = "none"
+	TracesExporterStdout = "stdout"
+)
+
+// Telemetry holds the meter and tracer providers which are set as the global OpenTelemetry providers, so the
+// instrumentations (e.g. otelgin and the metrics plugin of the pg storage) are exported through them.
+type Telemetry struct {
+	meterProvider  *sdkmetric.MeterProvider
+	tracerProvider *sdktrace.TracerProvider
+	metricsHandler http.Handler
+}
+
+// ConfigureFromEnvs creates the providers of the envs and sets them as the global providers. The metrics are read by
+// the handler of MetricsHandler, and the spans are exported by the TRACES_EXPORTER.
+func ConfigureFromEnvs(envs *env.Envs) (*Telemetry, error) {
+	res := resource.NewSchemaless(
+		semconv.ServiceName(version.AppName),
+		semconv.ServiceVersion(version.AppVersion),
+	)
+
+	registry := prometheus.NewRegistry()
+	metricsExporter, err := otelprometheus.New(otelprometheus.WithRegisterer(registry))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the prometheus exporter")
+	}
+
+	traceOpts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(envs.Telemetry.TracesSampleRatio))),
+	}
+	switch strings.ToLower(envs.Telemetry.TracesExporter) {
+	case TracesExporterNone, "":
+		// the spans are still created, e.g. for the trace ids of the logs and the propagation to the other services
+	case TracesExporterStdout:
+		traceExporter, err := stdouttrace.New()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create the stdout trace exporter")
+		}
+		traceOpts = append(traceOpts, sdktrace.WithBatcher(traceExporter))
+	default:
+		return nil, errors.Errorf("unknown traces exporter %q", envs.Telemetry.TracesExporter)
+	}
+
+	t := &Telemetry{
+		meterProvider:  sdkmetric.NewMeterProvider(sdkmetric.WithResource(res), sdkmetric.WithReader(metricsExporter)),
+		tracerProvider: sdktrace.NewTracerProvider(traceOpts...),
+		metricsHandler: promhttp.HandlerFor(registry, promhttp.HandlerOpts{}),
+	}
+	otel.SetMeterProvider(t.meterProvider)
+	otel.SetTracerProvider(t.tracerProvider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return t, nil
+}
+
+// MetricsHandler serves the metrics of the meter provider in the Prometheus text format.
+func (t *Telemetry) MetricsHandler() http.Handler {
+	return t.metricsHandler
+}
+
+// Shutdown exports the pending spans and stops the providers.
+func (t *Telemetry) Shutdown(ctx context.Context) error {
+	err := t.tracerProvider.Shutdown(ctx)
+	if metricsErr := t.meterProvider.Shutdown(ctx); err == nil {
+		err = metricsErr
+	}
+	return err
+}
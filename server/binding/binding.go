@@ -1,13 +1,31 @@
 package binding
 
 import (
+	"context"
 	"reflect"
 	"strings"
 
+	"github.com/amahdian/golang-gin-boilerplate/storage"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
 )
 
+type storageCtx struct{}
+
+// WithStorage returns a context with the storage which the validators of the request check the values against,
+// e.g. the storage of the router which serves the request.
+func WithStorage(ctx context.Context, stg storage.Storage) context.Context {
+	return context.WithValue(ctx, storageCtx{}, stg)
+}
+
+func storageFromCtx(ctx context.Context) storage.Storage {
+	stg, _ := ctx.Value(storageCtx{}).(storage.Storage)
+	return stg
+}
+
+// Init registers the custom validators on the gin validator. The validators that check the values against the db use
+// the storage of the request context (see WithStorage), so the routers of different storages can share the global gin
+// validator. They only run when the request is bound with ShouldBindJSON, ShouldBindQuery or ShouldBindUri.
 func Init() {
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
 		// Ensure the Field() would return the json, uri or form tag instead of the Field name.
@@ -7,10 +7,10 @@ import (
 
 const paramSeparator = " "
 
-func extractTableAndColumnNames(param string) (tableName string, columnName string) {
+func extractTableAndColumnNames(tag, param string) (tableName string, columnName string) {
 	parts := strings.Split(param, paramSeparator)
 	if len(parts) != 2 {
-		panic(fmt.Sprintf("unsupported params format for 'exists' validator. the expected format is 'table_name,column_name'"))
+		panic(fmt.Sprintf("unsupported params format %q for '%s' validator. the expected format is 'table_name column_name'", param, tag))
 	}
 
 	tableName = parts[0]
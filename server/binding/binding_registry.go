@@ -4,11 +4,22 @@ import (
 	"github.com/go-playground/validator/v10"
 )
 
+// recordBindingColumns is the allowlist of the tables and columns that the "exists" and "unique" validators
+// can query. A request DTO referring to any other column is rejected by CheckRecordTags.
+var recordBindingColumns = map[string][]string{
+	"users": {"id", "email"},
+}
+
 func registerCustomBinding(v *validator.Validate) {
-	//registerCustomFieldValidator(v, &ExistsBinding{mStg: mStg})
+	registerCustomFieldValidator(v, NewExistsBinding(recordBindingColumns))
+	registerCustomFieldValidator(v, NewUniqueBinding(recordBindingColumns))
 }
 
 func registerCustomFieldValidator(v *validator.Validate, fieldValidator CustomFieldBinding) {
-	_ = v.RegisterValidation(fieldValidator.Tag(), fieldValidator.Validate)
+	if ctxValidator, ok := fieldValidator.(CustomFieldBindingCtx); ok {
+		_ = v.RegisterValidationCtx(fieldValidator.Tag(), ctxValidator.ValidateCtx)
+	} else {
+		_ = v.RegisterValidation(fieldValidator.Tag(), fieldValidator.Validate)
+	}
 	registerFieldTranslator(fieldValidator.Tag(), fieldValidator.Translate)
 }
@@ -1,9 +1,20 @@
 package binding
 
-import "github.com/go-playground/validator/v10"
+import (
+	"context"
+
+	"github.com/go-playground/validator/v10"
+)
 
 type CustomFieldBinding interface {
 	Tag() string
 	Translate(fe validator.FieldError) string
 	Validate(fl validator.FieldLevel) bool
 }
+
+// CustomFieldBindingCtx is a CustomFieldBinding that needs the context of the validated request,
+// e.g. to query the db within the request's deadline.
+type CustomFieldBindingCtx interface {
+	CustomFieldBinding
+	ValidateCtx(ctx context.Context, fl validator.FieldLevel) bool
+}
@@ -0,0 +1,144 @@
+package binding
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/go-playground/validator/v10"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+)
+
+const (
+	existsTag = "exists"
+	uniqueTag = "unique"
+)
+
+// RecordBinding is a binding validator that will validate the value against the records of a db table.
+// The "exists" validator expects a record with the value in the column, and the "unique" validator expects none.
+// The param is the table and the column separated by a space, and only the allowlisted columns can be used.
+// The soft deleted records are ignored and the empty values are skipped, pair it with "required" if needed.
+// Sample usage:
+//
+//	type Register struct {
+//	    Email   string `json:"email"   binding:"required,unique=users email"`
+//	    OwnerId string `json:"ownerId" binding:"omitempty,exists=users id"`
+//	}
+//
+// The db is queried with the storage and the context of the request (see WithStorage), so it only runs when the
+// request is bound with ShouldBindJSON, ShouldBindQuery or ShouldBindUri; the gin bindings (e.g. ctx.ShouldBindJSON)
+// skip it. If the db can't be queried, the binding fails with the error of the db instead of a validation error.
+// The tags of the request DTOs are checked against the allowlist by CheckRecordTags.
+type RecordBinding struct {
+	tag     string
+	unique  bool
+	columns map[string][]string
+}
+
+func NewExistsBinding(columns map[string][]string) *RecordBinding {
+	return &RecordBinding{tag: existsTag, columns: columns}
+}
+
+func NewUniqueBinding(columns map[string][]string) *RecordBinding {
+	return &RecordBinding{tag: uniqueTag, unique: true, columns: columns}
+}
+
+func (b *RecordBinding) Tag() string {
+	return b.tag
+}
+
+func (b *RecordBinding) Translate(fe validator.FieldError) string {
+	tableName, columnName := extractTableAndColumnNames(b.tag, fe.Param())
+	if b.unique {
+		return fmt.Sprintf("a %s record with %s '%v' already exists", tableName, columnName, fe.Value())
+	}
+	return fmt.Sprintf("no %s record found with %s '%v'", tableName, columnName, fe.Value())
+}
+
+// Validate skips the check, the db is only queried when the request is validated with its context.
+func (b *RecordBinding) Validate(fl validator.FieldLevel) bool {
+	return true
+}
+
+func (b *RecordBinding) ValidateCtx(ctx context.Context, fl validator.FieldLevel) bool {
+	validation := requestValidationFromCtx(ctx)
+	if validation == nil || fl.Field().IsZero() {
+		return true
+	}
+	if err := b.checkParam(fl.Param()); err != nil {
+		return validation.fail(errs.Newf(errs.Internal, err, "invalid binding of %s", fl.FieldName()))
+	}
+	stg := storageFromCtx(ctx)
+	if stg == nil {
+		return validation.fail(errs.Newf(errs.Internal, nil, "no storage to validate '%s=%s' with", b.tag, fl.Param()))
+	}
+	field := fl.Field()
+	if field.Kind() == reflect.Ptr {
+		field = field.Elem()
+	}
+
+	tableName, columnName := extractTableAndColumnNames(b.tag, fl.Param())
+	exists, err := stg.Metadata(ctx).RecordByValueExists(tableName, columnName, field.Interface())
+	if err != nil {
+		// fail closed, the request can't be trusted without the check
+		return validation.fail(errs.Wrapf(err, "failed to validate '%s=%s'", b.tag, fl.Param()))
+	}
+	return exists != b.unique
+}
+
+// checkParam checks that the param is a table and a column of the allowlist.
+func (b *RecordBinding) checkParam(param string) error {
+	if len(strings.Split(param, paramSeparator)) != 2 {
+		return fmt.Errorf("unsupported params format %q for '%s' validator. the expected format is 'table_name column_name'", param, b.tag)
+	}
+	tableName, columnName := extractTableAndColumnNames(b.tag, param)
+	if !lo.Contains(b.columns[tableName], columnName) {
+		return fmt.Errorf("column '%s.%s' is not allowed for '%s' validator", tableName, columnName, b.tag)
+	}
+	return nil
+}
+
+// CheckRecordTags checks the "exists" and "unique" tags of the request DTOs (and their nested structs) against the
+// allowlist, so a misconfigured DTO fails when the router is set up rather than on its first request.
+func CheckRecordTags(requests ...any) error {
+	bindings := []*RecordBinding{NewExistsBinding(recordBindingColumns), NewUniqueBinding(recordBindingColumns)}
+	problems := make([]string, 0)
+	for _, request := range requests {
+		walkBindingTags(reflect.TypeOf(request), map[reflect.Type]bool{}, func(t reflect.Type, field reflect.StructField, tag string) {
+			for _, b := range bindings {
+				param, ok := strings.CutPrefix(tag, b.tag+"=")
+				if !ok {
+					continue
+				}
+				if err := b.checkParam(param); err != nil {
+					problems = append(problems, fmt.Sprintf("%s.%s: %v", t.Name(), field.Name, err))
+				}
+			}
+		})
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// walkBindingTags calls fn with every validation of the binding tags of the struct fields, e.g. "unique=users email".
+func walkBindingTags(t reflect.Type, seen map[reflect.Type]bool, fn func(t reflect.Type, field reflect.StructField, tag string)) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return
+	}
+	seen[t] = true
+	for i := range t.NumField() {
+		field := t.Field(i)
+		for _, tag := range strings.Split(field.Tag.Get("binding"), ",") {
+			fn(t, field, tag)
+		}
+		walkBindingTags(field.Type, seen, fn)
+	}
+}
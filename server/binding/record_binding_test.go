@@ -0,0 +1,190 @@
+package binding
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/amahdian/golang-gin-boilerplate/global/test"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/gin-gonic/gin"
+	ginbinding "github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	test.SetupTestingEnv()
+	m.Run()
+}
+
+type ctxKey struct{}
+
+type fakeMetadataStg struct {
+	storage.MetadataStorage
+	ctx     context.Context
+	records map[string]bool
+	err     error
+}
+
+func (stg *fakeMetadataStg) RecordByValueExists(tableName, columnName string, value interface{}) (bool, error) {
+	return stg.records[tableName+"."+columnName+"="+value.(string)], stg.err
+}
+
+type fakeStorage struct {
+	storage.Storage
+	metadata *fakeMetadataStg
+}
+
+func (stg *fakeStorage) Metadata(ctx context.Context) storage.MetadataStorage {
+	stg.metadata.ctx = ctx
+	return stg.metadata
+}
+
+type registerRequest struct {
+	Email   string `json:"email" binding:"required,unique=users email"`
+	OwnerId string `json:"ownerId" binding:"omitempty,exists=users id"`
+}
+
+func bindRegisterRequest(t *testing.T, stg storage.Storage, body string) (*registerRequest, error) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+	c.Request = c.Request.WithContext(WithStorage(context.WithValue(c.Request.Context(), ctxKey{}, "request"), stg))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	request := &registerRequest{}
+	err := ShouldBindJSON(c, request)
+	return request, err
+}
+
+func TestRecordBinding(t *testing.T) {
+	metadata := &fakeMetadataStg{records: map[string]bool{
+		"users.email=taken@example.com": true,
+		"users.id=42":                   true,
+	}}
+	stg := &fakeStorage{metadata: metadata}
+	Init()
+
+	t.Run("accepts the unique and existing values", func(t *testing.T) {
+		_, err := bindRegisterRequest(t, stg, `{"email":"new@example.com","ownerId":"42"}`)
+		require.NoError(t, err)
+		assert.Equal(t, "request", metadata.ctx.Value(ctxKey{}))
+	})
+
+	t.Run("rejects the taken values", func(t *testing.T) {
+		_, err := bindRegisterRequest(t, stg, `{"email":"taken@example.com"}`)
+		var ve validator.ValidationErrors
+		require.ErrorAs(t, err, &ve)
+		assert.Equal(t, `'email': a users record with email 'taken@example.com' already exists`, mapValidationErrorsToString(ve))
+	})
+
+	t.Run("rejects the missing references", func(t *testing.T) {
+		_, err := bindRegisterRequest(t, stg, `{"email":"new@example.com","ownerId":"7"}`)
+		var ve validator.ValidationErrors
+		require.ErrorAs(t, err, &ve)
+		assert.Equal(t, `'ownerId': no users record found with id '7'`, mapValidationErrorsToString(ve))
+	})
+
+	t.Run("fails with the error of the db when it can't be queried", func(t *testing.T) {
+		metadata.err = errors.New("connection refused")
+		defer func() { metadata.err = nil }()
+
+		_, err := bindRegisterRequest(t, stg, `{"email":"new@example.com"}`)
+		require.Error(t, err)
+		var ve validator.ValidationErrors
+		assert.NotErrorAs(t, err, &ve)
+		assert.Equal(t, http.StatusInternalServerError, errs.Code(err).HttpStatus())
+		assert.Contains(t, err.Error(), "connection refused")
+	})
+
+	t.Run("fails without a storage", func(t *testing.T) {
+		_, err := bindRegisterRequest(t, nil, `{"email":"new@example.com"}`)
+		require.Error(t, err)
+		assert.Equal(t, errs.Internal, errs.Code(err))
+	})
+
+	t.Run("uses the storage of the request", func(t *testing.T) {
+		other := &fakeStorage{metadata: &fakeMetadataStg{}}
+		_, err := bindRegisterRequest(t, other, `{"email":"taken@example.com"}`)
+		require.NoError(t, err, "the email is only taken in the other storage")
+		assert.NotNil(t, other.metadata.ctx)
+	})
+
+	t.Run("rejects the malformed requests as invalid arguments", func(t *testing.T) {
+		_, err := bindRegisterRequest(t, stg, `{"email":`)
+		require.Error(t, err)
+		assert.Equal(t, errs.InvalidArgument, errs.Code(err))
+	})
+
+	t.Run("skips the db without a request", func(t *testing.T) {
+		metadata.ctx = nil
+		err := ginbinding.Validator.ValidateStruct(&registerRequest{Email: "taken@example.com"})
+		require.NoError(t, err)
+		assert.Nil(t, metadata.ctx)
+	})
+
+	t.Run("leaves the gin bindings as they are", func(t *testing.T) {
+		metadata.ctx = nil
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"email":"taken@example.com"}`))
+		c.Request = c.Request.WithContext(WithStorage(c.Request.Context(), stg))
+		require.NoError(t, c.ShouldBindJSON(&registerRequest{}))
+		assert.Nil(t, metadata.ctx)
+	})
+
+}
+
+func TestCheckRecordTags(t *testing.T) {
+	require.NoError(t, CheckRecordTags(&registerRequest{}))
+
+	type profile struct {
+		Password string `json:"password" binding:"unique=users password"`
+	}
+	type request struct {
+		OwnerId  string    `json:"ownerId" binding:"exists=users"`
+		Profiles []profile `json:"profiles" binding:"dive"`
+	}
+	err := CheckRecordTags(&registerRequest{}, &request{})
+	require.Error(t, err)
+	assert.Equal(t, "request.OwnerId: unsupported params format \"users\" for 'exists' validator. the expected format is 'table_name column_name'; "+
+		"profile.Password: column 'users.password' is not allowed for 'unique' validator", err.Error())
+}
+
+func TestShouldBindQueryAndUri(t *testing.T) {
+	type listRequest struct {
+		Page int `form:"page" binding:"min=1"`
+	}
+	type taskRequest struct {
+		Name string `uri:"name" binding:"required"`
+	}
+	gin.SetMode(gin.TestMode)
+	newCtx := func(target string, params ...gin.Param) *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, target, nil)
+		c.Params = params
+		return c
+	}
+
+	list := &listRequest{}
+	require.NoError(t, ShouldBindQuery(newCtx("/?page=2"), list))
+	assert.Equal(t, 2, list.Page)
+	task := &taskRequest{}
+	require.NoError(t, ShouldBindUri(newCtx("/", gin.Param{Key: "name", Value: "purge"}), task))
+	assert.Equal(t, "purge", task.Name)
+
+	err := ShouldBindQuery(newCtx("/?page=second"), &listRequest{})
+	assert.Equal(t, errs.InvalidArgument, errs.Code(err), "got %v", err)
+
+	var ve validator.ValidationErrors
+	assert.ErrorAs(t, ShouldBindQuery(newCtx("/?page=0"), &listRequest{}), &ve)
+	assert.ErrorAs(t, ShouldBindUri(newCtx("/"), &taskRequest{}), &ve)
+}
@@ -0,0 +1,134 @@
+package binding
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/pkg/errors"
+)
+
+type requestValidationCtx struct{}
+
+// requestValidation is the state of the validation of a request. The validators can only report whether a value is
+// valid, so the ones that fail to check the value (e.g. because the db is down) record their error here.
+type requestValidation struct {
+	err error
+}
+
+// fail records the error of a validator which could not check the value, and fails the validation.
+func (v *requestValidation) fail(err error) bool {
+	if v.err == nil {
+		v.err = err
+	}
+	return false
+}
+
+// requestValidationFromCtx returns the validation of the request, or nil if the struct is not validated with the
+// context of a request, which is required by the validators that query the db.
+func requestValidationFromCtx(ctx context.Context) *requestValidation {
+	validation, _ := ctx.Value(requestValidationCtx{}).(*requestValidation)
+	return validation
+}
+
+// ShouldBindJSON binds the json body of the request to obj like ctx.ShouldBindJSON, but validates obj once with the
+// context of the request, so the validators that query the db (see WithStorage) check the values as well.
+func ShouldBindJSON(ctx *gin.Context, obj any) error {
+	return ctx.ShouldBindWith(obj, jsonBinding)
+}
+
+// ShouldBindQuery binds the query of the request to obj like ctx.ShouldBindQuery, but validates obj once with the
+// context of the request.
+func ShouldBindQuery(ctx *gin.Context, obj any) error {
+	return ctx.ShouldBindWith(obj, queryBinding)
+}
+
+// ShouldBindUri binds the path params of the request to obj like ctx.ShouldBindUri, but validates obj once with the
+// context of the request.
+func ShouldBindUri(ctx *gin.Context, obj any) error {
+	params := make(map[string][]string, len(ctx.Params))
+	for _, param := range ctx.Params {
+		params[param.Key] = []string{param.Value}
+	}
+	if err := binding.MapFormWithTag(obj, params, "uri"); err != nil {
+		return bindingErr(err)
+	}
+	return validateRequest(ctx.Request.Context(), obj)
+}
+
+var (
+	jsonBinding  binding.Binding = requestBinding{name: "json", decode: decodeJSON}
+	queryBinding binding.Binding = requestBinding{name: "query", decode: decodeQuery}
+)
+
+// requestBinding decodes the request without the gin validation, and then validates the decoded struct with the
+// context of the request, so every struct is validated once.
+type requestBinding struct {
+	name   string
+	decode func(req *http.Request, obj any) error
+}
+
+func (b requestBinding) Name() string {
+	return b.name
+}
+
+func (b requestBinding) Bind(req *http.Request, obj any) error {
+	if err := b.decode(req, obj); err != nil {
+		return bindingErr(err)
+	}
+	return validateRequest(req.Context(), obj)
+}
+
+// decodeJSON decodes the body like the json binding of gin, including its decoder options.
+func decodeJSON(req *http.Request, obj any) error {
+	if req == nil || req.Body == nil {
+		return errors.New("invalid request")
+	}
+	decoder := json.NewDecoder(req.Body)
+	if binding.EnableDecoderUseNumber {
+		decoder.UseNumber()
+	}
+	if binding.EnableDecoderDisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	return decoder.Decode(obj)
+}
+
+func decodeQuery(req *http.Request, obj any) error {
+	return binding.MapFormWithTag(obj, req.URL.Query(), "form")
+}
+
+// bindingErr reports the requests which can't be decoded as InvalidArgument errors, so they are responded with 400.
+// The validation errors are kept as is.
+func bindingErr(err error) error {
+	var ve validator.ValidationErrors
+	if errors.As(err, &ve) {
+		return err
+	}
+	return errs.Newf(errs.InvalidArgument, err, "invalid request")
+}
+
+func validateRequest(ctx context.Context, obj any) error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return nil
+	}
+	value := reflect.ValueOf(obj)
+	for value.Kind() == reflect.Ptr && value.Elem().Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	validation := &requestValidation{}
+	err := v.StructCtx(context.WithValue(ctx, requestValidationCtx{}, validation), value.Interface())
+	if validation.err != nil {
+		return validation.err
+	}
+	return err
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/contracts/resp"
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/amahdian/golang-gin-boilerplate/svc/auth"
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+)
+
+// RequireAdmin only lets the authenticated users with one of the given emails through.
+// It must be used after VerifyAuth.
+func RequireAdmin(adminEmails []string) gin.HandlerFunc {
+	admins := lo.SliceToMap(adminEmails, func(email string) (string, bool) {
+		return strings.ToLower(strings.TrimSpace(email)), true
+	})
+
+	return func(c *gin.Context) {
+		userInfo := auth.UserInfoFromCtx(c.Request.Context())
+		if !admins[strings.ToLower(userInfo.Email)] {
+			resp.AbortWithError(c, errs.Newf(errs.PermissionDenied, nil, "admin access is required"))
+			return
+		}
+		c.Next()
+	}
+}
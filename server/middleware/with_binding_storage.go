@@ -0,0 +1,15 @@
+package middleware
+
+import (
+	"github.com/amahdian/golang-gin-boilerplate/server/binding"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// WithBindingStorage sets the storage which the request bindings check the values against, e.g. for the "unique" tag.
+func WithBindingStorage(stg storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(binding.WithStorage(c.Request.Context(), stg))
+		c.Next()
+	}
+}
@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// WithQueryTimeout overrides the default query timeout of the storages for the request.
+func WithQueryTimeout(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(storage.WithQueryTimeout(c.Request.Context(), timeout))
+		c.Next()
+	}
+}
@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/contracts/resp"
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/amahdian/golang-gin-boilerplate/pkg/logger"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// WithTransaction runs the rest of the handlers in a transaction (unit of work) that is propagated through the request context.
+//
+// The transaction is committed if the response status is below 400 and rolled back otherwise, or if a handler panics.
+// The response is buffered until the transaction is resolved so a failed commit can still be reported to the client.
+func WithTransaction(stg storage.Storage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, ses, err := stg.Begin(c.Request.Context())
+		if err != nil {
+			resp.AbortWithError(c, errs.Newf(errs.Unavailable, err, "failed to begin transaction"))
+			return
+		}
+		c.Request = c.Request.WithContext(ctx)
+
+		writer := c.Writer
+		buffer := newBufferedResponseWriter(writer)
+		c.Writer = buffer
+
+		resolved := false
+		defer func() {
+			c.Writer = writer
+			// the handlers have either failed or panicked. a panic is handled by the recovery middleware after the rollback.
+			if !resolved {
+				if rbErr := ses.Rollback(); rbErr != nil {
+					logger.Errorf("failed to rollback transaction: %v", rbErr)
+				}
+			}
+		}()
+
+		c.Next()
+
+		if buffer.Status() >= http.StatusBadRequest {
+			resolved = true
+			if rbErr := ses.Rollback(); rbErr != nil {
+				logger.Errorf("failed to rollback transaction: %v", rbErr)
+			}
+			buffer.flush()
+			return
+		}
+
+		resolved = true
+		if err = ses.Commit(); err != nil {
+			c.Writer = writer
+			resp.AbortWithError(c, errs.Newf(errs.Internal, err, "failed to commit transaction"))
+			return
+		}
+		buffer.flush()
+	}
+}
+
+// bufferedResponseWriter holds back the response until flush is called.
+type bufferedResponseWriter struct {
+	gin.ResponseWriter
+
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferedResponseWriter(w gin.ResponseWriter) *bufferedResponseWriter {
+	return &bufferedResponseWriter{
+		ResponseWriter: w,
+		status:         http.StatusOK,
+	}
+}
+
+func (w *bufferedResponseWriter) WriteHeader(code int) {
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *bufferedResponseWriter) WriteHeaderNow() {}
+
+func (w *bufferedResponseWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *bufferedResponseWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedResponseWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedResponseWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *bufferedResponseWriter) Written() bool {
+	return w.body.Len() > 0
+}
+
+// Flush is a no-op since the response can not be streamed before the transaction is resolved.
+func (w *bufferedResponseWriter) Flush() {}
+
+func (w *bufferedResponseWriter) flush() {
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.body.Len() == 0 {
+		w.ResponseWriter.WriteHeaderNow()
+		return
+	}
+	if _, err := w.ResponseWriter.Write(w.body.Bytes()); err != nil {
+		logger.Errorf("failed to write the buffered response: %v", err)
+	}
+}
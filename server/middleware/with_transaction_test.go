@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/amahdian/golang-gin-boilerplate/global/test"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	test.SetupTestingEnv()
+	m.Run()
+}
+
+type fakeSession struct {
+	committed  bool
+	rolledBack bool
+	commitErr  error
+}
+
+func (s *fakeSession) Begin() (storage.Session, error) { return s, nil }
+func (s *fakeSession) Rollback() error                 { s.rolledBack = true; return nil }
+func (s *fakeSession) Commit() error                   { s.committed = s.commitErr == nil; return s.commitErr }
+func (s *fakeSession) Close() error                    { return nil }
+
+type fakeStorage struct {
+	storage.Storage
+	ses *fakeSession
+}
+
+func (stg *fakeStorage) Begin(ctx context.Context) (context.Context, storage.Session, error) {
+	return storage.WithContext(ctx, stg.ses), stg.ses, nil
+}
+
+func serveWithTransaction(t *testing.T, ses *fakeSession, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(WithRecovery())
+	engine.GET("/", WithTransaction(&fakeStorage{ses: ses}), handler)
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	return w
+}
+
+func TestWithTransaction(t *testing.T) {
+	t.Run("commits successful responses", func(t *testing.T) {
+		ses := &fakeSession{}
+		w := serveWithTransaction(t, ses, func(c *gin.Context) {
+			require.NotNil(t, storage.FromContext(c.Request.Context()))
+			c.JSON(http.StatusCreated, gin.H{"ok": true})
+		})
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.JSONEq(t, `{"ok":true}`, w.Body.String())
+		assert.True(t, ses.committed)
+		assert.False(t, ses.rolledBack)
+	})
+
+	t.Run("rolls back failed responses", func(t *testing.T) {
+		ses := &fakeSession{}
+		w := serveWithTransaction(t, ses, func(c *gin.Context) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"ok": false})
+		})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"ok":false}`, w.Body.String())
+		assert.False(t, ses.committed)
+		assert.True(t, ses.rolledBack)
+	})
+
+	t.Run("rolls back on panics", func(t *testing.T) {
+		ses := &fakeSession{}
+		w := serveWithTransaction(t, ses, func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"ok": true})
+			panic("boom")
+		})
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.NotContains(t, w.Body.String(), `"ok":true`)
+		assert.False(t, ses.committed)
+		assert.True(t, ses.rolledBack)
+	})
+
+	t.Run("reports commit failures", func(t *testing.T) {
+		ses := &fakeSession{commitErr: errors.New("connection lost")}
+		w := serveWithTransaction(t, ses, func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"ok": true})
+		})
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "failed to commit transaction")
+	})
+}
@@ -0,0 +1,105 @@
+package router
+
+import (
+	"github.com/amahdian/golang-gin-boilerplate/domain/contracts/req"
+	"github.com/amahdian/golang-gin-boilerplate/domain/contracts/resp"
+	"github.com/amahdian/golang-gin-boilerplate/domain/model/common"
+	"github.com/amahdian/golang-gin-boilerplate/server/binding"
+	"github.com/gin-gonic/gin"
+)
+
+// listCronTasks list the recurring tasks.
+//
+//	@Summary	list the recurring tasks with their next run time and last run
+//	@Description
+//	@Tags		Admin
+//	@Produce	json
+//	@Success	200	{object}	resp.Response[[]cron.TaskInfo]
+//	@Failure	401	{object}	resp.ErrorResponse
+//	@Failure	403	{object}	resp.ErrorResponse
+//	@Failure	500	{object}	resp.ErrorResponse
+//	@Security	Bearer
+//	@Router		/api/v1/admin/cron/tasks [get]
+func (r *Router) listCronTasks(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+
+	dSvc := r.svc.NewCronSvc(reqCtx.Ctx)
+	res, err := dSvc.ListTasks()
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, res)
+}
+
+// listCronRuns list the run history of the recurring tasks.
+//
+//	@Summary	list the run history of the recurring tasks, the latest runs first
+//	@Description
+//	@Tags		Admin
+//	@Produce	json
+//	@Param		request	query		req.ListCronRuns	false	"task and pagination, sort and fields"
+//	@Success	200		{object}	resp.PaginatedResponse[model.CronRun]
+//	@Failure	400		{object}	resp.ErrorResponse
+//	@Failure	401		{object}	resp.ErrorResponse
+//	@Failure	403		{object}	resp.ErrorResponse
+//	@Failure	404		{object}	resp.ErrorResponse
+//	@Failure	500		{object}	resp.ErrorResponse
+//	@Security	Bearer
+//	@Router		/api/v1/admin/cron/runs [get]
+func (r *Router) listCronRuns(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+
+	request := &req.ListCronRuns{Pagination: *common.DefaultPagination()}
+	err := binding.ShouldBindQuery(ctx, request)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	fields := common.ParseFields(request.Fields)
+	dSvc := r.svc.NewCronSvc(reqCtx.Ctx)
+	res, err := dSvc.ListRuns(request.Task, &request.Pagination, fields)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.PaginatedOkWithFields(ctx, res, &request.Pagination, fields)
+}
+
+// triggerCronTask trigger a recurring task manually.
+//
+//	@Summary	run a recurring task immediately in the background
+//	@Description
+//	@Tags		Admin
+//	@Produce	json
+//	@Param		name	path		string	true	"task name"
+//	@Success	200		{object}	resp.Response[model.CronRun]
+//	@Failure	401		{object}	resp.ErrorResponse
+//	@Failure	403		{object}	resp.ErrorResponse
+//	@Failure	404		{object}	resp.ErrorResponse
+//	@Failure	409		{object}	resp.ErrorResponse
+//	@Failure	500		{object}	resp.ErrorResponse
+//	@Security	Bearer
+//	@Router		/api/v1/admin/cron/tasks/{name}/trigger [post]
+func (r *Router) triggerCronTask(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+
+	request := &req.CronTaskName{}
+	err := binding.ShouldBindUri(ctx, request)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewCronSvc(reqCtx.Ctx)
+	res, err := dSvc.Trigger(request.Name)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, res)
+}
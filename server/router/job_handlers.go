@@ -0,0 +1,79 @@
+package router
+
+import (
+	"github.com/amahdian/golang-gin-boilerplate/domain/contracts/req"
+	"github.com/amahdian/golang-gin-boilerplate/domain/contracts/resp"
+	"github.com/amahdian/golang-gin-boilerplate/domain/model/common"
+	"github.com/amahdian/golang-gin-boilerplate/server/binding"
+	"github.com/gin-gonic/gin"
+)
+
+// listJobs list the background jobs.
+//
+//	@Summary	list the background jobs, e.g. the failed ones
+//	@Description
+//	@Tags		Admin
+//	@Produce	json
+//	@Param		request	query		req.ListJobs	false	"status and pagination, sort and fields"
+//	@Success	200		{object}	resp.PaginatedResponse[model.Job]
+//	@Failure	400		{object}	resp.ErrorResponse
+//	@Failure	401		{object}	resp.ErrorResponse
+//	@Failure	403		{object}	resp.ErrorResponse
+//	@Failure	500		{object}	resp.ErrorResponse
+//	@Security	Bearer
+//	@Router		/api/v1/admin/jobs [get]
+func (r *Router) listJobs(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+
+	request := &req.ListJobs{Pagination: *common.DefaultPagination()}
+	err := binding.ShouldBindQuery(ctx, request)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	fields := common.ParseFields(request.Fields)
+	dSvc := r.svc.NewJobSvc(reqCtx.Ctx)
+	res, err := dSvc.List(request.Status, &request.Pagination, fields)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.PaginatedOkWithFields(ctx, res, &request.Pagination, fields)
+}
+
+// retryJob retry a failed background job.
+//
+//	@Summary	retry a failed background job with a fresh set of attempts
+//	@Description
+//	@Tags		Admin
+//	@Produce	json
+//	@Param		id	path		int	true	"job id"
+//	@Success	200	{object}	resp.Response[model.Job]
+//	@Failure	401	{object}	resp.ErrorResponse
+//	@Failure	403	{object}	resp.ErrorResponse
+//	@Failure	404	{object}	resp.ErrorResponse
+//	@Failure	409	{object}	resp.ErrorResponse
+//	@Failure	500	{object}	resp.ErrorResponse
+//	@Security	Bearer
+//	@Router		/api/v1/admin/jobs/{id}/retry [post]
+func (r *Router) retryJob(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+
+	request := &req.JobId{}
+	err := binding.ShouldBindUri(ctx, request)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewJobSvc(reqCtx.Ctx)
+	res, err := dSvc.Retry(request.ID)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, res)
+}
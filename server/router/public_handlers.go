@@ -20,7 +20,7 @@ var ginSwaggerHandler = ginSwagger.WrapHandler(swaggerFiles.Handler)
 //	@Tags		Public
 //	@Accept		json
 //	@Produce	json
-//	@Success	200	{object}	resp.HealthResponseDto
+//	@Success	200	{object}	resp.Response[resp.HealthResponseDto]
 //	@Router		/health [get]
 func (r *Router) healthCheck(ctx *gin.Context) {
 	response := resp.HealthResponseDto{
@@ -47,7 +47,7 @@ func (r *Router) swaggerHandler(ctx *gin.Context) {
 //	@Tags		Public
 //	@Accept		json
 //	@Produce	json
-//	@Success	200	{object}	string
+//	@Success	200	{object}	resp.Response[string]
 //	@Router		/server-time [get]
 func (r *Router) getServerTime(ctx *gin.Context) {
 	resp.Ok(ctx, time.Now())
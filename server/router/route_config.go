@@ -1,18 +1,23 @@
 package router
 
 import (
+	"time"
+
 	"github.com/gin-contrib/gzip"
 	"github.com/gin-gonic/gin"
 )
 
 type routeConfig struct {
 	RequireUserSettings bool
+	RequireTransaction  bool
+	QueryTimeout        time.Duration
 	Middlewares         []gin.HandlerFunc
 }
 
 func newRouteConfig() *routeConfig {
 	return &routeConfig{
 		RequireUserSettings: false,
+		RequireTransaction:  false,
 		Middlewares:         []gin.HandlerFunc{},
 	}
 }
@@ -24,6 +29,21 @@ func (rc *routeConfig) withUserSettings(flag bool) *routeConfig {
 
 }
 
+// withTransaction runs the handler in a transaction which is committed only if the response status is below 400.
+func (rc *routeConfig) withTransaction() *routeConfig {
+	clone := rc.clone()
+	clone.RequireTransaction = true
+	return clone
+}
+
+// withQueryTimeout overrides the default query timeout for the handler, e.g. for the slow reports.
+// The timeout is capped at the statement timeout of the db.
+func (rc *routeConfig) withQueryTimeout(timeout time.Duration) *routeConfig {
+	clone := rc.clone()
+	clone.QueryTimeout = timeout
+	return clone
+}
+
 func (rc *routeConfig) withMiddlewares(middlewares ...gin.HandlerFunc) *routeConfig {
 	clone := rc.clone()
 	clone.Middlewares = append(rc.Middlewares, middlewares...)
@@ -41,6 +61,9 @@ func (rc *routeConfig) clone() *routeConfig {
 	copy(middlewares, rc.Middlewares)
 
 	return &routeConfig{
-		Middlewares: middlewares,
+		RequireUserSettings: rc.RequireUserSettings,
+		RequireTransaction:  rc.RequireTransaction,
+		QueryTimeout:        rc.QueryTimeout,
+		Middlewares:         middlewares,
 	}
 }
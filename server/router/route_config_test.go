@@ -0,0 +1,65 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/amahdian/golang-gin-boilerplate/storage/memory"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteWithTransaction(t *testing.T) {
+	stg := memory.NewStg()
+	r := &Router{Engine: gin.New(), storage: stg}
+	// the handler creates the user and responds with the status of the query
+	createUser := func(ctx *gin.Context) {
+		user := &model.User{Email: ctx.Query("email")}
+		require.NoError(t, stg.User(ctx.Request.Context()).CreateOne(user))
+		status, _ := strconv.Atoi(ctx.Query("status"))
+		ctx.Status(status)
+	}
+	r.registerRoute(r.Group(""), http.MethodPost, "/users", createUser, newRouteConfig().withTransaction())
+
+	for _, status := range []int{http.StatusCreated, http.StatusBadRequest, http.StatusInternalServerError} {
+		t.Run(strconv.Itoa(status), func(t *testing.T) {
+			email := uuid.NewString() + "@example.com"
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/users?status="+strconv.Itoa(status)+"&email="+email, nil))
+			assert.Equal(t, status, w.Code)
+
+			user, err := stg.User(context.Background()).FindByEmail(email)
+			require.NoError(t, err)
+			if status < http.StatusBadRequest {
+				assert.NotNil(t, user, "the transaction of a successful response must be committed")
+			} else {
+				assert.Nil(t, user, "the transaction of a failed response must be rolled back")
+			}
+		})
+	}
+}
+
+func TestRouteWithQueryTimeout(t *testing.T) {
+	r := &Router{Engine: gin.New(), storage: memory.NewStg()}
+	// the handler responds with the query timeout of the request
+	respondTimeout := func(ctx *gin.Context) {
+		timeout, _ := storage.QueryTimeout(ctx.Request.Context())
+		ctx.String(http.StatusOK, timeout.String())
+	}
+	r.registerRoute(r.Group(""), http.MethodGet, "/default", respondTimeout, newRouteConfig())
+	r.registerRoute(r.Group(""), http.MethodGet, "/report", respondTimeout, newRouteConfig().withQueryTimeout(time.Minute))
+
+	for path, timeout := range map[string]time.Duration{"/default": 0, "/report": time.Minute} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, timeout.String(), w.Body.String(), path)
+	}
+}
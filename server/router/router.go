@@ -2,12 +2,14 @@ package router
 
 import (
 	"fmt"
+	"net/http"
 	"net/url"
 	"time"
 
 	"github.com/amahdian/golang-gin-boilerplate/svc/auth"
 
 	"github.com/amahdian/golang-gin-boilerplate/docs"
+	"github.com/amahdian/golang-gin-boilerplate/domain/contracts/req"
 	"github.com/amahdian/golang-gin-boilerplate/global/env"
 	"github.com/amahdian/golang-gin-boilerplate/pkg/logger"
 	"github.com/amahdian/golang-gin-boilerplate/server/binding"
@@ -29,25 +31,29 @@ type Router struct {
 
 	configs *env.Envs
 
-	authenticator auth.Authenticator
+	authenticator  auth.Authenticator
+	metricsHandler http.Handler
 
 	publicGroup *gin.RouterGroup
 	authGroup   *gin.RouterGroup
 	apiGroup    *gin.RouterGroup
+	adminGroup  *gin.RouterGroup
 }
 
 func NewRouter(
 	storage storage.Storage,
 	svc svc.Svc,
 	configs *env.Envs,
-	authenticator auth.Authenticator) *Router {
+	authenticator auth.Authenticator,
+	metricsHandler http.Handler) *Router {
 	gin.SetMode(configs.Server.GinMode)
 	router := &Router{
-		Engine:        gin.New(),
-		storage:       storage,
-		authenticator: authenticator,
-		svc:           svc,
-		configs:       configs,
+		Engine:         gin.New(),
+		storage:        storage,
+		authenticator:  authenticator,
+		metricsHandler: metricsHandler,
+		svc:            svc,
+		configs:        configs,
 	}
 	router.Use(otelgin.Middleware(version.AppName))
 	router.Use(
@@ -65,6 +71,10 @@ func NewRouter(
 
 func (r *Router) setupBindings() {
 	binding.Init()
+	if err := binding.CheckRecordTags(req.All()...); err != nil {
+		panic(fmt.Sprintf("invalid request bindings: %v", err))
+	}
+	r.Use(middleware.WithBindingStorage(r.storage))
 }
 
 func (r *Router) setupCors() {
@@ -72,7 +82,7 @@ func (r *Router) setupCors() {
 		AllowAllOrigins:  true,
 		AllowMethods:     []string{"GET", "PUT", "PATCH", "HEAD", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"*"},
-		ExposeHeaders:    []string{"Content-Length"},
+		ExposeHeaders:    []string{"Content-Length", "ETag"},
 		AllowCredentials: false,
 		MaxAge:           24 * time.Hour,
 	}))
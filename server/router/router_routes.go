@@ -2,12 +2,17 @@ package router
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/amahdian/golang-gin-boilerplate/global"
 	"github.com/amahdian/golang-gin-boilerplate/server/middleware"
 	"github.com/gin-gonic/gin"
 )
 
+// reportQueryTimeout is the query timeout of the admin lists and reports, which may scan large tables.
+// It is capped at DB_STATEMENT_TIMEOUT, which has to be raised as well for the reports to run longer than it.
+const reportQueryTimeout = 2 * time.Minute
+
 func (r *Router) setupRoutes() {
 	r.publicGroup = r.Group("")
 	r.authGroup = r.Group(
@@ -15,21 +20,54 @@ func (r *Router) setupRoutes() {
 		middleware.VerifyAuth(r.authenticator),
 	)
 	r.apiGroup = r.authGroup.Group(global.ApiPrefix)
+	r.adminGroup = r.apiGroup.Group(
+		"/admin",
+		middleware.RequireAdmin(r.configs.Server.AdminEmails),
+	)
 
 	r.registerPublicRoutes()
 	r.registerUserRoutes()
+	r.registerJobRoutes()
+	r.registerCronRoutes()
+	r.registerSchemaRoutes()
 }
 
 func (r *Router) registerPublicRoutes() {
 	config := newRouteConfig()
 	r.registerRoute(r.publicGroup, http.MethodGet, "/health", r.healthCheck, config)
+	r.registerRoute(r.publicGroup, http.MethodGet, "/server-time", r.getServerTime, config)
 	r.registerRoute(r.publicGroup, http.MethodGet, "/swagger/*any", r.swaggerHandler, config)
+	if r.metricsHandler != nil && r.configs.Telemetry.MetricsPath != "" {
+		// the metrics are scraped without a token, so the path should not be reachable from outside the cluster
+		r.registerRoute(r.publicGroup, http.MethodGet, r.configs.Telemetry.MetricsPath, gin.WrapH(r.metricsHandler), config)
+	}
 }
 
 func (r *Router) registerUserRoutes() {
 	config := newRouteConfig()
 	r.registerRoute(r.publicGroup, http.MethodPost, "/user/login", r.login, config)
-	r.registerRoute(r.publicGroup, http.MethodPost, "/user/register", r.register, config)
+	// the user is only created if the whole registration succeeds
+	r.registerRoute(r.publicGroup, http.MethodPost, "/user/register", r.register, config.withTransaction())
+	r.registerRoute(r.apiGroup, http.MethodGet, "/user/me", r.getProfile, config)
+	r.registerRoute(r.apiGroup, http.MethodPatch, "/user/me", r.updateProfile, config)
+}
+
+func (r *Router) registerJobRoutes() {
+	config := newRouteConfig()
+	r.registerRoute(r.adminGroup, http.MethodGet, "/jobs", r.listJobs, config.withQueryTimeout(reportQueryTimeout))
+	r.registerRoute(r.adminGroup, http.MethodPost, "/jobs/:id/retry", r.retryJob, config)
+}
+
+func (r *Router) registerCronRoutes() {
+	config := newRouteConfig()
+	r.registerRoute(r.adminGroup, http.MethodGet, "/cron/tasks", r.listCronTasks, config)
+	r.registerRoute(r.adminGroup, http.MethodPost, "/cron/tasks/:name/trigger", r.triggerCronTask, config)
+	r.registerRoute(r.adminGroup, http.MethodGet, "/cron/runs", r.listCronRuns, config.withQueryTimeout(reportQueryTimeout))
+}
+
+func (r *Router) registerSchemaRoutes() {
+	config := newRouteConfig()
+	r.registerRoute(r.adminGroup, http.MethodGet, "/schema", r.describeSchema, config.withQueryTimeout(reportQueryTimeout))
 }
 
 func (r *Router) registerRoute(routerGroup *gin.RouterGroup, method, path string, handler gin.HandlerFunc, configs ...*routeConfig) {
@@ -40,10 +78,18 @@ func (r *Router) registerRoute(routerGroup *gin.RouterGroup, method, path string
 
 	handlers := make([]gin.HandlerFunc, 0)
 
+	if config.QueryTimeout > 0 {
+		handlers = append(handlers, middleware.WithQueryTimeout(config.QueryTimeout))
+	}
+
 	if r.storage != nil && config.RequireUserSettings {
 		handlers = append(handlers, middleware.WithUserSettings(r.storage))
 	}
 
+	if r.storage != nil && config.RequireTransaction {
+		handlers = append(handlers, middleware.WithTransaction(r.storage))
+	}
+
 	if len(config.Middlewares) > 0 {
 		handlers = append(handlers, config.Middlewares...)
 	}
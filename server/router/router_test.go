@@ -0,0 +1,161 @@
+package router_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/contracts/req"
+	"github.com/amahdian/golang-gin-boilerplate/domain/contracts/resp"
+	"github.com/amahdian/golang-gin-boilerplate/global/test"
+	"github.com/amahdian/golang-gin-boilerplate/server/utils"
+	"github.com/amahdian/golang-gin-boilerplate/testutil"
+	"github.com/amahdian/golang-gin-boilerplate/testutil/factory"
+	"github.com/amahdian/golang-gin-boilerplate/testutil/openapi"
+	"github.com/amahdian/golang-gin-boilerplate/version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	test.SetupTestingEnv()
+	os.Exit(m.Run())
+}
+
+// undocumentedPrefixes are the routes which are not part of the api
+var undocumentedPrefixes = []string{"/swagger/", "/debug/pprof/", "/metrics"}
+
+func TestApiDocsCoverRoutes(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	spec, err := openapi.LoadDocs()
+	require.NoError(t, err)
+
+	routes := make([]openapi.Route, 0)
+	for _, route := range ts.Router.Routes() {
+		if !hasAnyPrefix(route.Path, undocumentedPrefixes) {
+			routes = append(routes, openapi.Route{Method: route.Method, Path: openapi.GinPath(route.Path)})
+		}
+	}
+	assert.NoError(t, spec.CheckRoutes(routes), "update the annotations of the handlers and run make docs")
+}
+
+func hasAnyPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestMetrics(t *testing.T) {
+	ts := testutil.NewTestServer(t, testutil.WithoutContractValidation())
+	require.Equal(t, http.StatusOK, ts.Get("/health").Code)
+
+	res := ts.Get("/metrics")
+	require.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Body.String(), "http_server_request_duration_seconds", "the requests are exported")
+	assert.Contains(t, res.Body.String(), fmt.Sprintf(`service_name=%q`, version.AppName))
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	credentials := req.Register{Email: test.UserEmail, Password: test.UserPassword}
+
+	token := testutil.RequireOk[string](t, ts.Post("/user/register", credentials))
+	assert.NotEmpty(t, token)
+
+	res := ts.Post("/user/register", credentials)
+	testutil.RequireError(t, res, http.StatusBadRequest)
+	testutil.AssertGoldenJSON(t, "register_duplicate", res.Body.Bytes())
+	testutil.RequireError(t, ts.Post("/user/register", "not an object"), http.StatusBadRequest)
+
+	res = ts.Post("/user/login", req.Login{Email: test.UserEmail, Password: test.UserPassword})
+	testutil.AssertGoldenJSON(t, "login", res.Body.Bytes(), testutil.Scrub("data"))
+	token = testutil.RequireOk[string](t, res)
+
+	// the token of the login authenticates the requests
+	res = ts.Get("/api/v1/admin/jobs", testutil.WithToken(token))
+	testutil.RequirePaginated[map[string]any](t, res)
+}
+
+func TestLoginFactoryUser(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	f := factory.New(t, ts.Storage)
+	user := factory.Create(f, factory.User, factory.Password("secret"))
+
+	token := testutil.RequireOk[string](t, ts.Post("/user/login", req.Login{Email: user.Email, Password: "secret"}))
+	// the user is not an admin
+	testutil.RequireError(t, ts.Get("/api/v1/admin/jobs", testutil.WithToken(token)), http.StatusForbidden)
+}
+
+func TestListJobsSortAndFields(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	f := factory.New(t, ts.Storage)
+	jobs := factory.CreateList(f, factory.Job, 3)
+
+	res := ts.Get("/api/v1/admin/jobs?sort=-id&fields=id,status", ts.AsAdmin())
+	page := testutil.RequirePaginated[map[string]any](t, res)
+	require.Len(t, page.Data, 3)
+	for i, job := range page.Data {
+		assert.Equal(t, map[string]any{"id": float64(jobs[2-i].ID), "status": "pending"}, job)
+	}
+
+	res = ts.Get("/api/v1/admin/jobs?sort=-unknown", ts.AsAdmin())
+	testutil.RequireError(t, res, http.StatusBadRequest)
+
+	res = ts.Get("/api/v1/admin/jobs?fields=id,unknown", ts.AsAdmin())
+	testutil.RequireError(t, res, http.StatusBadRequest)
+}
+
+func TestUpdateProfileIfMatch(t *testing.T) {
+	ts := testutil.NewTestServer(t)
+	user := factory.Create(factory.New(t, ts.Storage), factory.User, factory.Password("secret"))
+	token := testutil.RequireOk[string](t, ts.Post("/user/login", req.Login{Email: user.Email, Password: "secret"}))
+
+	res := ts.Get("/api/v1/user/me", testutil.WithToken(token))
+	profile := testutil.RequireOk[resp.UserProfile](t, res)
+	assert.Equal(t, user.Email, profile.Email)
+	etag := res.Header().Get(utils.ETagHeader)
+	assert.Equal(t, utils.FormatVersionETag(profile.Version), etag)
+
+	update := req.UpdateProfile{Email: "updated@example.com"}
+	res = ts.Patch("/api/v1/user/me", update, testutil.WithToken(token), testutil.WithHeader(utils.IfMatchHeader, etag))
+	profile = testutil.RequireOk[resp.UserProfile](t, res)
+	assert.Equal(t, update.Email, profile.Email)
+	assert.Equal(t, utils.FormatVersionETag(profile.Version), res.Header().Get(utils.ETagHeader))
+	assert.NotEqual(t, etag, res.Header().Get(utils.ETagHeader))
+	updated, err := ts.Storage.User(context.Background()).FindByUuid(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, &user.ID, updated.UpdatedBy, "the update is attributed to the authenticated user")
+
+	// the profile has been modified since the first ETag
+	res = ts.Patch("/api/v1/user/me", req.UpdateProfile{Email: "stale@example.com"}, testutil.WithToken(token), testutil.WithHeader(utils.IfMatchHeader, etag))
+	testutil.RequireError(t, res, http.StatusConflict)
+	res = ts.Get("/api/v1/user/me", testutil.WithToken(token))
+	profile = testutil.RequireOk[resp.UserProfile](t, res)
+	assert.Equal(t, update.Email, profile.Email)
+	latestETag := res.Header().Get(utils.ETagHeader)
+
+	// the weak entity tags never match the strong comparison of If-Match
+	weakETag := "W/" + latestETag
+	res = ts.Patch("/api/v1/user/me", req.UpdateProfile{Email: "weak@example.com"}, testutil.WithToken(token), testutil.WithHeader(utils.IfMatchHeader, weakETag))
+	testutil.RequireError(t, res, http.StatusPreconditionFailed)
+
+	// any entity tag of the list may match
+	update = req.UpdateProfile{Email: "listed@example.com"}
+	res = ts.Patch("/api/v1/user/me", update, testutil.WithToken(token), testutil.WithHeader(utils.IfMatchHeader, etag+", "+latestETag))
+	assert.Equal(t, update.Email, testutil.RequireOk[resp.UserProfile](t, res).Email)
+	res = ts.Patch("/api/v1/user/me", req.UpdateProfile{Email: "any@example.com"}, testutil.WithToken(token), testutil.WithHeader(utils.IfMatchHeader, "*"))
+	assert.Equal(t, "any@example.com", testutil.RequireOk[resp.UserProfile](t, res).Email)
+
+	// the latest version is updated without an If-Match header
+	res = ts.Patch("/api/v1/user/me", req.UpdateProfile{Email: "latest@example.com"}, testutil.WithToken(token))
+	assert.Equal(t, "latest@example.com", testutil.RequireOk[resp.UserProfile](t, res).Email)
+
+	res = ts.Patch("/api/v1/user/me", update, testutil.WithToken(token), testutil.WithHeader(utils.IfMatchHeader, "invalid"))
+	testutil.RequireError(t, res, http.StatusBadRequest)
+}
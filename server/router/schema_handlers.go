@@ -0,0 +1,42 @@
+package router
+
+import (
+	"github.com/amahdian/golang-gin-boilerplate/domain/contracts/req"
+	"github.com/amahdian/golang-gin-boilerplate/domain/contracts/resp"
+	"github.com/amahdian/golang-gin-boilerplate/server/binding"
+	"github.com/gin-gonic/gin"
+)
+
+// describeSchema describe the schema of the db.
+//
+//	@Summary	describe the tables of the db with their columns, keys and indexes
+//	@Description
+//	@Tags		Admin
+//	@Produce	json
+//	@Param		request	query		req.DescribeSchema	false	"cache options"
+//	@Success	200		{object}	resp.Response[storage.DbSchema]
+//	@Failure	400		{object}	resp.ErrorResponse
+//	@Failure	401		{object}	resp.ErrorResponse
+//	@Failure	403		{object}	resp.ErrorResponse
+//	@Failure	500		{object}	resp.ErrorResponse
+//	@Security	Bearer
+//	@Router		/api/v1/admin/schema [get]
+func (r *Router) describeSchema(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+
+	request := &req.DescribeSchema{}
+	err := binding.ShouldBindQuery(ctx, request)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	dSvc := r.svc.NewSchemaSvc(reqCtx.Ctx)
+	res, err := dSvc.Describe(request.Refresh)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.Ok(ctx, res)
+}
@@ -3,6 +3,8 @@ package router
 import (
 	"github.com/amahdian/golang-gin-boilerplate/domain/contracts/req"
 	"github.com/amahdian/golang-gin-boilerplate/domain/contracts/resp"
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/server/binding"
 	"github.com/gin-gonic/gin"
 )
 
@@ -17,13 +19,12 @@ import (
 //	@Success	200		{object}	resp.Response[string]
 //	@Failure	400		{object}	resp.ErrorResponse
 //	@Failure	500		{object}	resp.ErrorResponse
-//	@Security	Bearer
-//	@Router		/api/v1/user/login [post]
+//	@Router		/user/login [post]
 func (r *Router) login(ctx *gin.Context) {
 	reqCtx := req.GetRequestContext(ctx)
 
 	request := &req.Login{}
-	err := ctx.BindJSON(&request)
+	err := binding.ShouldBindJSON(ctx, request)
 	if err != nil {
 		resp.AbortWithError(ctx, err)
 		return
@@ -50,13 +51,13 @@ func (r *Router) login(ctx *gin.Context) {
 //	@Success	200		{object}	resp.Response[string]
 //	@Failure	400		{object}	resp.ErrorResponse
 //	@Failure	500		{object}	resp.ErrorResponse
-//	@Security	Bearer
-//	@Router		/api/v1/user/register [post]
+//	@Router		/user/register [post]
 func (r *Router) register(ctx *gin.Context) {
 	reqCtx := req.GetRequestContext(ctx)
 
 	request := &req.Register{}
-	err := ctx.BindJSON(&request)
+	// the unique email is checked against the db, so a failure of the db must not be responded with 400
+	err := binding.ShouldBindJSON(ctx, request)
 	if err != nil {
 		resp.AbortWithError(ctx, err)
 		return
@@ -71,3 +72,75 @@ func (r *Router) register(ctx *gin.Context) {
 
 	resp.Ok(ctx, res)
 }
+
+// getProfile get the profile of the current user.
+//
+//	@Summary	get the profile of the current user, with its version in the ETag header
+//	@Description
+//	@Tags		User
+//	@Produce	json
+//	@Success	200	{object}	resp.Response[resp.UserProfile]
+//	@Header		200	{string}	ETag	"the version of the profile, for the If-Match header of the updates"
+//	@Failure	401	{object}	resp.ErrorResponse
+//	@Failure	404	{object}	resp.ErrorResponse
+//	@Failure	500	{object}	resp.ErrorResponse
+//	@Security	Bearer
+//	@Router		/api/v1/user/me [get]
+func (r *Router) getProfile(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+
+	dSvc := r.svc.NewUserSvc(reqCtx.Ctx)
+	res, err := dSvc.Profile(reqCtx.UserInfo.ID)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.OkWithETag(ctx, resp.NewUserProfile(res), res.Version)
+}
+
+// updateProfile update the profile of the current user.
+//
+//	@Summary	update the profile of the current user
+//	@Description
+//	@Tags		User
+//	@Accept		json
+//	@Produce	json
+//	@Param		If-Match	header		string				false	"the ETag of the profile, the update fails with 409 if the profile has been modified since, and with 412 for the weak ETags"
+//	@Param		request		body		req.UpdateProfile	true	"profile data"
+//	@Success	200			{object}	resp.Response[resp.UserProfile]
+//	@Header		200			{string}	ETag	"the new version of the profile"
+//	@Failure	400			{object}	resp.ErrorResponse
+//	@Failure	401			{object}	resp.ErrorResponse
+//	@Failure	404			{object}	resp.ErrorResponse
+//	@Failure	409			{object}	resp.ErrorResponse
+//	@Failure	412			{object}	resp.ErrorResponse
+//	@Failure	500			{object}	resp.ErrorResponse
+//	@Security	Bearer
+//	@Router		/api/v1/user/me [patch]
+func (r *Router) updateProfile(ctx *gin.Context) {
+	reqCtx := req.GetRequestContext(ctx)
+
+	request := &req.UpdateProfile{}
+	err := binding.ShouldBindJSON(ctx, request)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	ifMatch, err := req.IfMatchVersions(ctx)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	update := &model.User{ID: reqCtx.UserInfo.ID, Email: request.Email}
+	dSvc := r.svc.NewUserSvc(reqCtx.Ctx)
+	res, err := dSvc.UpdateProfile(update, ifMatch)
+	if err != nil {
+		resp.AbortWithError(ctx, err)
+		return
+	}
+
+	resp.OkWithETag(ctx, resp.NewUserProfile(res), res.Version)
+}
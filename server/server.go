@@ -1,23 +1,27 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/amahdian/golang-gin-boilerplate/svc/auth"
 
-	"github.com/golang-migrate/migrate/v4"
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
-
 	"github.com/amahdian/golang-gin-boilerplate/server/router"
 
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
 	"github.com/amahdian/golang-gin-boilerplate/global/env"
 	"github.com/amahdian/golang-gin-boilerplate/pkg/logger"
+	"github.com/amahdian/golang-gin-boilerplate/pkg/telemetry"
 	"github.com/amahdian/golang-gin-boilerplate/storage"
 	"github.com/amahdian/golang-gin-boilerplate/storage/pg"
 	"github.com/amahdian/golang-gin-boilerplate/svc"
+	"github.com/amahdian/golang-gin-boilerplate/svc/cron"
+	"github.com/amahdian/golang-gin-boilerplate/svc/events"
+	"github.com/amahdian/golang-gin-boilerplate/svc/jobs"
 	"github.com/pkg/errors"
+	"gorm.io/gorm"
 )
 
 type Server struct {
@@ -26,26 +30,68 @@ type Server struct {
 	Authenticator auth.Authenticator
 	Storage       storage.Storage
 	Svc           svc.Svc
+	Events        *events.Dispatcher
+	Jobs          *jobs.Registry
+	Scheduler     *cron.Scheduler
 	Router        *router.Router
+	Telemetry     *telemetry.Telemetry
+
+	replicas           *pg.Replicas
+	stopBackgroundJobs context.CancelFunc
+	closeOnce          sync.Once
+	closeErr           error
+}
+
+// Option replaces a dependency of the server, e.g. the storage of the integration tests.
+type Option func(s *Server)
+
+// WithStorage uses the given storage instead of opening the db of the envs. The db is neither migrated nor checked
+// for schema drift.
+func WithStorage(stg storage.Storage) Option {
+	return func(s *Server) {
+		s.Storage = stg
+	}
 }
 
-func NewServer(envs *env.Envs) (*Server, error) {
+// WithAuthenticator uses the given authenticator instead of the jwt authenticator of the envs.
+func WithAuthenticator(authenticator auth.Authenticator) Option {
+	return func(s *Server) {
+		s.Authenticator = authenticator
+	}
+}
+
+func NewServer(envs *env.Envs, opts ...Option) (*Server, error) {
 	s := &Server{
 		Envs: envs,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
 	if err := s.setupLogger(); err != nil {
 		return nil, errors.Wrap(err, "failed to initialize logger")
 	}
-	if err := s.migrateDb(); err != nil {
-		return nil, errors.Wrap(err, "failed to migrate the db")
+	if err := s.setupTelemetry(); err != nil {
+		return nil, errors.Wrap(err, "failed to setup telemetry")
 	}
-	if err := s.setupStorage(); err != nil {
-		return nil, err
+	if s.Storage == nil {
+		if err := s.migrateDb(); err != nil {
+			return nil, errors.Wrap(err, "failed to migrate the db")
+		}
+		if err := s.setupStorage(); err != nil {
+			return nil, err
+		}
 	}
-	if err := s.setupAuthenticator(); err != nil {
-		return nil, errors.Wrap(err, "failed to setup authenticator")
+	if err := s.setupScheduler(); err != nil {
+		return nil, errors.Wrap(err, "failed to setup the cron scheduler")
+	}
+	if s.Authenticator == nil {
+		if err := s.setupAuthenticator(); err != nil {
+			return nil, errors.Wrap(err, "failed to setup authenticator")
+		}
 	}
 	s.setupServices()
+	s.setupEvents()
+	s.setupJobs()
 	s.setupRouter()
 	return s, nil
 }
@@ -58,11 +104,50 @@ func (s *Server) Run() (err error) {
 		}
 	}(s)
 
+	s.startBackgroundJobs()
+
 	err = s.Router.Run(fmt.Sprintf(":%s", s.Envs.Server.HttpPort))
 	return err
 }
 
+// RunWorker runs only the job workers (e.g. in a dedicated worker process) until ctx is canceled,
+// then waits for the running jobs to finish.
+func (s *Server) RunWorker(ctx context.Context) (err error) {
+	defer func(s *Server) {
+		err := s.Close()
+		if err != nil {
+			logger.Errorf("failed to gracefully shutdown the worker and release resources: %v", err)
+		}
+	}(s)
+
+	s.monitorReplicas(ctx)
+	jobs.NewWorker(s.Storage, s.Jobs, s.Envs).Run(ctx)
+	return nil
+}
+
+// Close stops the background jobs and releases the resources of the server. It can be called more than once, e.g. by
+// the shutdown of Run and the cleanup of a test, and only the first call releases them.
 func (s *Server) Close() error {
+	s.closeOnce.Do(func() {
+		s.closeErr = s.close()
+	})
+	return s.closeErr
+}
+
+func (s *Server) close() error {
+	if s.stopBackgroundJobs != nil {
+		s.stopBackgroundJobs()
+	}
+	if s.replicas != nil {
+		if err := s.replicas.Close(); err != nil {
+			logger.Errorf("failed to close the replica connections: %v", err)
+		}
+	}
+	if s.Telemetry != nil {
+		if err := s.Telemetry.Shutdown(context.Background()); err != nil {
+			logger.Errorf("failed to shutdown the telemetry: %v", err)
+		}
+	}
 	if err := logger.Close(); err != nil {
 		logger.Errorf("failed to close/sync the logger: %v", err) // can it actually log itself?
 		return err
@@ -75,27 +160,34 @@ func (s *Server) setupLogger() error {
 	return nil
 }
 
+// setupTelemetry sets the global meter and tracer providers before the storage and the router are instrumented.
+func (s *Server) setupTelemetry() (err error) {
+	s.Telemetry, err = telemetry.ConfigureFromEnvs(s.Envs)
+	return err
+}
+
 func (s *Server) migrateDb() error {
+	if !s.Envs.Db.MigrateOnStartup {
+		logger.Info("skipped the startup migrations")
+		return nil
+	}
+
 	err := pg.EnsureDatabaseExists(s.Envs.Db.Dsn)
 	if err != nil {
 		return errors.Wrap(err, "failed to create database")
 	}
-	migrationsDir := fmt.Sprintf("file://%s/migrations", s.Envs.Server.AssetsDir)
-	migrator, err := migrate.New(migrationsDir, s.Envs.Db.Dsn)
+	migrator, err := pg.NewMigrator(context.Background(), s.Envs.Db.Dsn)
 	if err != nil {
-		return errors.Wrap(err, "failed to open new migration instance")
+		return err
 	}
+	defer migrator.Close()
 
-	err = migrator.Up()
+	err = migrator.Up(0)
 	if err != nil {
-		if errors.Is(err, migrate.ErrNoChange) {
-			logger.Info("all migrations are already applied")
-			return nil
-		}
 		return errors.Wrap(err, "failed to run migrations")
 	}
 
-	logger.Info("applied migrations to the db")
+	logger.Info("the db is migrated to the latest version")
 
 	return nil
 }
@@ -103,16 +195,93 @@ func (s *Server) migrateDb() error {
 func (s *Server) setupStorage() error {
 	logLevelEnv := strings.ToLower(s.Envs.Db.LogLevel)
 	logLevel := pg.LogLevel(logLevelEnv)
-	db, err := pg.OpenGormDb(s.Envs.Db.Dsn, logLevel)
+	dbOpts := []pg.DbOption{
+		pg.WithPool(pg.PoolConfig{
+			MaxOpenConns:    s.Envs.Db.MaxOpenConns,
+			MaxIdleConns:    s.Envs.Db.MaxIdleConns,
+			ConnMaxLifetime: s.Envs.Db.ConnMaxLifetime,
+			ConnMaxIdleTime: s.Envs.Db.ConnMaxIdleTime,
+		}),
+		pg.WithStatementTimeout(s.Envs.Db.StatementTimeout),
+		pg.WithQueryTimeout(s.Envs.Db.QueryTimeout),
+		pg.WithSlowQueryThreshold(s.Envs.Db.SlowQueryThreshold),
+	}
+	db, err := pg.OpenGormDb(s.Envs.Db.Dsn, logLevel, dbOpts...)
 	if err != nil {
 		return errors.Wrap(err, "failed to open gorm connection")
 	}
-	s.Storage = pg.NewStg(db)
+	opts := []pg.StgOption{pg.WithSchemaCacheTTL(s.Envs.Db.SchemaCacheTTL)}
+	if len(s.Envs.Db.ReplicaDsns) > 0 {
+		s.replicas, err = pg.OpenReplicas(s.Envs.Db.ReplicaDsns, logLevel, s.Envs.Db.ReplicaMaxLag, s.Envs.Db.ReplicaHealthCheckInterval, dbOpts...)
+		if err != nil {
+			return errors.Wrap(err, "failed to open replica connections")
+		}
+		opts = append(opts, pg.WithReplicas(s.replicas))
+	}
+	if err = s.checkSchemaDrift(db); err != nil {
+		return err
+	}
+	s.Storage = pg.NewStg(db, opts...)
+	return nil
+}
+
+// checkSchemaDrift compares the schema of the models with the db, according to the DB_SCHEMA_CHECK mode.
+func (s *Server) checkSchemaDrift(db *gorm.DB) error {
+	mode := strings.ToLower(s.Envs.Db.SchemaCheck)
+	if mode == "off" {
+		return nil
+	}
+
+	report, err := pg.CheckSchemaDrift(context.Background(), db, model.All())
+	if err != nil {
+		return errors.Wrap(err, "failed to check the schema drift")
+	}
+	for _, issue := range report.Issues {
+		logger.Warnf("schema drift (%s) in %s.%s: %s", issue.Severity, issue.Table, issue.Column, issue.Message)
+	}
+	if mode == "strict" && report.HasErrors() {
+		return errors.New("the schema of the models does not match the db, run the check-schema command for the report")
+	}
+	return nil
+}
+
+// setupScheduler creates the cron scheduler. Recurring tasks should be registered here.
+func (s *Server) setupScheduler() error {
+	s.Scheduler = cron.NewScheduler(s.Storage, s.Envs)
+
+	if interval := s.Envs.Db.PurgeInterval; interval > 0 {
+		// the purge runs on the job workers, so it is retried if it fails
+		jobClient := jobs.NewClient(s.Storage, s.Envs)
+		err := s.Scheduler.Register("purge_deleted", fmt.Sprintf("@every %s", interval), func(ctx context.Context) error {
+			_, err := jobClient.Enqueue(ctx, svc.PurgeDeletedJob{}, jobs.Unique(svc.PurgeDeletedJob{}.Kind()))
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 func (s *Server) setupServices() {
-	s.Svc = svc.NewSvc(s.Storage, s.Envs)
+	s.Svc = svc.NewSvc(s.Storage, s.Scheduler, s.Envs)
+}
+
+// setupEvents creates the dispatcher of the domain events. Subscribers and sinks should be registered here.
+func (s *Server) setupEvents() {
+	s.Events = events.NewDispatcher()
+	if s.Envs.Events.WebhookUrl != "" {
+		s.Events.AddSink(events.NewWebhookSink(s.Envs.Events.WebhookUrl))
+	}
+}
+
+// setupJobs creates the registry of the background jobs. Job handlers should be registered here.
+func (s *Server) setupJobs() {
+	s.Jobs = jobs.NewRegistry()
+	jobs.Register(s.Jobs, func(ctx context.Context, _ svc.PurgeDeletedJob) error {
+		_, err := s.Svc.NewPurgeSvc(ctx).PurgeDeleted()
+		return err
+	})
 }
 
 func (s *Server) setupRouter() {
@@ -120,10 +289,30 @@ func (s *Server) setupRouter() {
 		s.Storage,
 		s.Svc,
 		s.Envs,
-		s.Authenticator)
+		s.Authenticator,
+		s.Telemetry.MetricsHandler())
 }
 
 func (s *Server) setupAuthenticator() error {
 	s.Authenticator = auth.NewAuthenticator(s.Envs)
 	return nil
 }
+
+func (s *Server) startBackgroundJobs() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.stopBackgroundJobs = cancel
+
+	s.monitorReplicas(ctx)
+	go s.Scheduler.Run(ctx)
+	go events.NewRelay(s.Storage, s.Events, s.Envs).Run(ctx)
+	if s.Envs.Jobs.RunInServer {
+		go jobs.NewWorker(s.Storage, s.Jobs, s.Envs).Run(ctx)
+	}
+}
+
+// monitorReplicas health checks the replicas in the background, if there are any.
+func (s *Server) monitorReplicas(ctx context.Context) {
+	if s.replicas != nil {
+		go s.replicas.Monitor(ctx)
+	}
+}
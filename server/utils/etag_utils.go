@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	ETagHeader    = "ETag"
+	IfMatchHeader = "If-Match"
+)
+
+// FormatVersionETag formats the version of a versioned model as a strong entity tag, e.g. "3".
+func FormatVersionETag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// ParseIfMatch parses the comma separated entity tags of an If-Match header, e.g. "3", "4", into their versions.
+// anyVersion is true for "*". The weak entity tags (e.g. W/"3") are skipped, since If-Match uses the strong comparison
+// (RFC 9110 §13.1.1) and they never match, so a header of only weak entity tags returns no versions.
+func ParseIfMatch(header string) (versions []int64, anyVersion bool, err error) {
+	if strings.TrimSpace(header) == "*" {
+		return nil, true, nil
+	}
+	for _, etag := range strings.Split(header, ",") {
+		etag = strings.TrimSpace(etag)
+		opaqueTag, weak := strings.CutPrefix(etag, "W/")
+		if len(opaqueTag) < 2 || !strings.HasPrefix(opaqueTag, `"`) || !strings.HasSuffix(opaqueTag, `"`) {
+			return nil, false, fmt.Errorf("entity tag %s is not quoted", etag)
+		}
+		if weak {
+			continue
+		}
+		version, err := strconv.ParseInt(opaqueTag[1:len(opaqueTag)-1], 10, 64)
+		if err != nil || version < 1 {
+			return nil, false, fmt.Errorf("entity tag %s is not a valid version", etag)
+		}
+		versions = append(versions, version)
+	}
+	return versions, false, nil
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	for _, test := range []struct {
+		header     string
+		want       []int64
+		anyVersion bool
+		wantErr    bool
+	}{
+		{FormatVersionETag(3), []int64{3}, false, false},
+		{` "7" `, []int64{7}, false, false},
+		{`"3", "4"`, []int64{3, 4}, false, false},
+		{`*`, nil, true, false},
+		// the weak entity tags never match with the strong comparison
+		{`W/"12"`, nil, false, false},
+		{`W/"12", "13"`, []int64{13}, false, false},
+		{`7`, nil, false, true},
+		{`"3", 4`, nil, false, true},
+		{`"abc"`, nil, false, true},
+		{`"0"`, nil, false, true},
+	} {
+		got, anyVersion, err := ParseIfMatch(test.header)
+		if (err != nil) != test.wantErr {
+			t.Errorf("ParseIfMatch(%q): unexpected error: %v", test.header, err)
+			continue
+		}
+		if !slices.Equal(got, test.want) || anyVersion != test.anyVersion {
+			t.Errorf("ParseIfMatch(%q): got %v, %t, want %v, %t", test.header, got, anyVersion, test.want, test.anyVersion)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+package storage
+
+import "context"
+
+// AdvisoryLock is a session level lock held by a dedicated db connection.
+// It is released when Release is called or when the connection is lost, e.g. because the process has crashed.
+type AdvisoryLock interface {
+	// Check returns an error if the lock is not held anymore, i.e. its connection is lost.
+	Check(ctx context.Context) error
+	Release(ctx context.Context) error
+}
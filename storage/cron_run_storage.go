@@ -0,0 +1,22 @@
+package storage
+
+import (
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/domain/model/common"
+)
+
+type CronRunStorage interface {
+	CrudStorage[*model.CronRun]
+
+	// Start records the start of the run. A scheduled occurrence can only be started once,
+	// so started is false if the occurrence has already been started by another replica.
+	Start(run *model.CronRun) (started bool, err error)
+	Finish(id int64, status model.CronRunStatus, runErr string) error
+	// FindLast returns the latest run of the task, or nil if it has never run.
+	FindLast(taskName string) (*model.CronRun, error)
+	// FindLastScheduled returns the run of the latest scheduled occurrence of the task, or nil if it has never been scheduled.
+	FindLastScheduled(taskName string) (*model.CronRun, error)
+	// ListByTask lists the runs of the task, or the runs of all the tasks if taskName is empty. The latest runs come first.
+	// Only the given json field names (or column names) are selected when fieldNames is not empty.
+	ListByTask(taskName string, pagination *common.Pagination, fieldNames []string) ([]*model.CronRun, error)
+}
@@ -1,25 +1,98 @@
 package storage
 
 import (
+	"errors"
+	"fmt"
+	"iter"
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model/common"
 	"gorm.io/gorm/schema"
 )
 
+// ErrStaleVersion is the cause of the conflicts of the versioned updates whose version does not match the stored
+// version, i.e. the model has been modified or deleted since it was read.
+var ErrStaleVersion = errors.New("stale version")
+
+// UpsertOptions configures the conflict handling of the upserts. The columns can be given by their column or json names.
+type UpsertOptions struct {
+	// ConflictColumns are the columns of the unique constraint the conflicts are detected by. Defaults to the primary key.
+	ConflictColumns []string
+	// ConflictWhere is the predicate of a partial unique index of the conflict columns, e.g. "deleted_at IS NULL" for
+	// the emails of the users. It must match the predicate of the index, otherwise the upsert is rejected.
+	ConflictWhere string
+	// UpdateColumns are the columns updated on conflict. Defaults to all the columns except the primary key, the
+	// generated columns, the creation time and user, the deletion time and the version.
+	// The version of the versioned models is incremented on conflict.
+	UpdateColumns []string
+	// DoNothing leaves the existing rows untouched on conflict.
+	DoNothing bool
+}
+
+// RowError is the error of a single row of a batch.
+type RowError struct {
+	// Index is the index of the row in the given models.
+	Index int
+	Err   error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Index, e.Err)
+}
+
+func (e RowError) Unwrap() error {
+	return e.Err
+}
+
 // CrudStorage is the base storage class that provides common functionalities which all stores can benefit from.
 // Please add your common storage logic here.
 type CrudStorage[M schema.Tabler] interface {
 	CreateOne(model M) error
 	CreateMany(models []M) error
+	// CreateInBatches inserts the models in batches of the given size. The failed rows are skipped and reported
+	// by their index, while the rest of the rows are inserted. err is only returned if the insert was aborted.
+	CreateInBatches(models []M, batchSize int) (rowErrs []RowError, err error)
+	// CopyFrom bulk loads the models with the COPY FROM protocol, which is much faster than the inserts for the very
+	// large imports. The gorm hooks are not run (but the timestamps and the audit users are filled), and it cannot be
+	// used inside a transaction.
+	CopyFrom(models []M) (copied int64, err error)
+
+	// UpsertOne inserts the model, or updates the existing model if it conflicts with the given options.
+	UpsertOne(model M, opts UpsertOptions) error
+	// UpsertMany inserts the models, or updates the existing models that conflict with the given options.
+	UpsertMany(models []M, opts UpsertOptions) error
 
 	FindById(id int64) (model M, err error)
 	ListByIds(ids []int64) (models []M, err error)
 
 	UpdateOne(model M, saveAssociations bool) error
 	UpdatePartial(model M, returnUpdated bool) error
+	// UpdateMany saves the models. The versioned models are updated with the same version check as UpdateOne, and a
+	// single stale model fails the whole batch.
 	UpdateMany(models []M) error
 
 	ExistsById(id int64) (exists bool, err error)
 	DeleteById(id int64) error
 	DeleteByIds(ids []int64) error
+	// Delete soft deletes the model, which is found by its primary key, and sets its deleted time. The models which
+	// do not support soft deletes are deleted permanently.
+	Delete(model M) error
+
+	// Restore undoes the soft delete of the model, which is found by its primary key, and fills the model with the
+	// restored row. The restore is an update, so the version of the versioned models is incremented.
+	// It fails if the model does not support soft deletes.
+	Restore(model M) error
+	// Purge permanently deletes the models that have been soft deleted before the given time.
+	// It fails if the model does not support soft deletes.
+	Purge(deletedBefore time.Time) (purged int64, err error)
 
 	ListAll() (models []M, err error)
+	// Search lists the models matching the filters, sort and field selection of the given search params.
+	// Only the selected fields are filled when a field selection is provided.
+	Search(params *common.SearchParams) (models []M, err error)
+	// Iterate streams the models matching the filters and field selection of the given search params (all the models
+	// if params is nil) in chunks of batchSize, so the large result sets do not have to be loaded into memory.
+	// The models are scanned by their primary key in ascending order, and the pagination of the params is ignored.
+	// A zero batch size uses the default batch size. The iteration stops at the first error.
+	Iterate(params *common.SearchParams, batchSize int) iter.Seq2[M, error]
 }
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// DbSchema describes the tables of the db.
+type DbSchema struct {
+	Tables []*TableSchema `json:"tables"`
+	// the time the schema has been read from the db
+	LoadedAt time.Time `json:"loaded_at"`
+}
+
+type TableSchema struct {
+	Name        string          `json:"name"`
+	Columns     []*ColumnSchema `json:"columns"`
+	PrimaryKey  []string        `json:"primary_key"`
+	ForeignKeys []*ForeignKey   `json:"foreign_keys"`
+	Indexes     []*IndexSchema  `json:"indexes"`
+}
+
+type ColumnSchema struct {
+	Name string `json:"name"`
+	// the name of the type in the db, e.g. int8 or timestamptz
+	Type string `json:"type"`
+	// the sql data type of the column, e.g. bigint or USER-DEFINED for the enums
+	DataType string  `json:"data_type"`
+	Nullable bool    `json:"nullable"`
+	Default  *string `json:"default,omitempty"`
+}
+
+type ForeignKey struct {
+	Name              string   `json:"name"`
+	Columns           []string `json:"columns"`
+	ReferencedTable   string   `json:"referenced_table"`
+	ReferencedColumns []string `json:"referenced_columns"`
+}
+
+type IndexSchema struct {
+	Name    string `json:"name"`
+	Unique  bool   `json:"unique"`
+	Primary bool   `json:"primary"`
+	// the create index statement of the index
+	Definition string `json:"definition"`
+}
+
+// Table returns the table of the given name, or nil if there is no such table.
+func (s *DbSchema) Table(name string) *TableSchema {
+	table, _ := lo.Find(s.Tables, func(t *TableSchema) bool {
+		return t.Name == name
+	})
+	return table
+}
+
+// Column returns the column of the given name, or nil if there is no such column.
+func (t *TableSchema) Column(name string) *ColumnSchema {
+	column, _ := lo.Find(t.Columns, func(c *ColumnSchema) bool {
+		return c.Name == name
+	})
+	return column
+}
+
+func (t *TableSchema) ColumnNames() []string {
+	return lo.Map(t.Columns, func(c *ColumnSchema, _ int) string {
+		return c.Name
+	})
+}
@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/domain/model/common"
+)
+
+type JobStorage interface {
+	CrudStorage[*model.Job]
+
+	// Enqueue inserts the job unless another pending or running job has the same unique key.
+	// In that case the existing job is returned and enqueued is false.
+	Enqueue(job *model.Job) (existing *model.Job, enqueued bool, err error)
+	// ClaimNext marks up to limit due jobs of the queue as running and returns them.
+	// The jobs locked by other workers are skipped, so multiple workers can poll the same queue concurrently.
+	ClaimNext(queue string, limit int) (jobs []*model.Job, err error)
+	MarkCompleted(id int64) error
+	// MarkFailed records a failed attempt. The job is retried at runAt unless it is dead.
+	MarkFailed(id int64, lastError string, runAt time.Time, dead bool) error
+	// Retry makes a failed job pending again with a fresh set of attempts.
+	Retry(id int64) error
+	// RescueStale makes the jobs that have been running since before lockedBefore pending again,
+	// e.g. the jobs of a worker which has crashed in the middle of the execution. The jobs which have used up their
+	// attempts are failed instead, so a job that crashes its worker every time is not retried forever.
+	RescueStale(lockedBefore time.Time) (rescued int64, err error)
+	// ListByStatus lists the jobs with the given status, or all the jobs if status is empty. Only the given json field
+	// names (or column names) are selected when fieldNames is not empty.
+	ListByStatus(status model.JobStatus, pagination *common.Pagination, fieldNames []string) (jobs []*model.Job, err error)
+}
@@ -0,0 +1,49 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+)
+
+type advisoryLocks struct {
+	mu   sync.Mutex
+	held map[string]bool
+}
+
+func (l *advisoryLocks) tryLock(name string) (storage.AdvisoryLock, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.held[name] {
+		return nil, false, nil
+	}
+	l.held[name] = true
+	return &advisoryLock{locks: l, name: name}, true, nil
+}
+
+type advisoryLock struct {
+	locks    *advisoryLocks
+	name     string
+	released bool
+}
+
+func (l *advisoryLock) Check(context.Context) error {
+	l.locks.mu.Lock()
+	defer l.locks.mu.Unlock()
+	if l.released {
+		return errs.Newf(errs.FailedPrecondition, nil, "%q advisory lock has been released", l.name)
+	}
+	return nil
+}
+
+func (l *advisoryLock) Release(context.Context) error {
+	l.locks.mu.Lock()
+	defer l.locks.mu.Unlock()
+	if !l.released {
+		l.released = true
+		delete(l.locks.held, l.name)
+	}
+	return nil
+}
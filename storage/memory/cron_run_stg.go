@@ -0,0 +1,77 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/domain/model/common"
+)
+
+type CronRunStg struct {
+	crudStg[*model.CronRun]
+}
+
+func NewCronRunStg(ctx context.Context, d *db) *CronRunStg {
+	return &CronRunStg{
+		crudStg: newCrudStg[*model.CronRun](ctx, d),
+	}
+}
+
+func (stg *CronRunStg) Start(run *model.CronRun) (started bool, err error) {
+	err = stg.db.update(func(ts *tableSet) error {
+		if stg.findConflict(ts, &uniqueIndexes[run.TableName()][0], run) != nil {
+			return nil
+		}
+		started = true
+		return stg.insert(ts, run, time.Now())
+	})
+	return started && err == nil, err
+}
+
+func (stg *CronRunStg) Finish(id int64, status model.CronRunStatus, runErr string) error {
+	return stg.updateById(id, func(run *model.CronRun) {
+		now := time.Now()
+		run.Status = status
+		run.Error = runErr
+		run.FinishedAt = &now
+	})
+}
+
+func (stg *CronRunStg) FindLast(taskName string) (*model.CronRun, error) {
+	runs := stg.listByTask(taskName)
+	if len(runs) == 0 {
+		return nil, nil
+	}
+	return runs[0], nil
+}
+
+func (stg *CronRunStg) FindLastScheduled(taskName string) (*model.CronRun, error) {
+	var last *model.CronRun
+	for _, run := range stg.listByTask(taskName) {
+		if run.Trigger == model.CronRunScheduled && (last == nil || run.ScheduledAt.After(last.ScheduledAt)) {
+			last = run
+		}
+	}
+	return last, nil
+}
+
+func (stg *CronRunStg) ListByTask(taskName string, pagination *common.Pagination, fieldNames []string) ([]*model.CronRun, error) {
+	runs, err := stg.paginate(stg.listByTask(taskName), pagination)
+	if err != nil {
+		return nil, err
+	}
+	return stg.selectFields(runs, fieldNames)
+}
+
+// listByTask lists the runs of the task (or of all the tasks if taskName is empty), the latest runs first.
+func (stg *CronRunStg) listByTask(taskName string) []*model.CronRun {
+	runs := stg.list(func(run *model.CronRun) bool {
+		return taskName == "" || run.TaskName == taskName
+	})
+	slices.SortStableFunc(runs, func(a, b *model.CronRun) int {
+		return b.StartedAt.Compare(a.StartedAt)
+	})
+	return runs
+}
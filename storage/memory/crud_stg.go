@@ -0,0 +1,853 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model/common"
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/amahdian/golang-gin-boilerplate/pkg/actor"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/gertd/go-pluralize"
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+	"gorm.io/gorm/schema"
+)
+
+var pluralizer = pluralize.NewClient()
+
+const (
+	softDeleteColumnName = "deleted_at"
+	createdByColumnName  = "created_by"
+	updatedByColumnName  = "updated_by"
+	versionColumnName    = "version"
+)
+
+type crudStg[M schema.Tabler] struct {
+	ctx context.Context
+	db  *db
+	// unscoped includes the soft deleted rows in the read-only queries
+	unscoped bool
+}
+
+func newCrudStg[M schema.Tabler](ctx context.Context, d *db) crudStg[M] {
+	return crudStg[M]{ctx: ctx, db: d, unscoped: storage.DeletedIncluded(ctx)}
+}
+
+func (stg *crudStg[M]) CreateOne(model M) error {
+	return stg.db.update(func(ts *tableSet) error {
+		return stg.insert(ts, model, time.Now())
+	})
+}
+
+func (stg *crudStg[M]) CreateMany(models []M) error {
+	return stg.db.update(func(ts *tableSet) error {
+		now := time.Now()
+		for _, model := range models {
+			if err := stg.insert(ts, model, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (stg *crudStg[M]) CreateInBatches(models []M, batchSize int) (rowErrs []storage.RowError, err error) {
+	if batchSize < 1 {
+		return nil, errs.Newf(errs.InvalidArgument, nil, "the batch size must be positive, got %d", batchSize)
+	}
+	// a failed batch is inserted row by row by the pg storage, which has the same outcome as inserting every row
+	// on its own
+	for i, model := range models {
+		if err = stg.ctx.Err(); err != nil {
+			return rowErrs, err
+		}
+		if err = stg.CreateOne(model); err != nil {
+			rowErrs = append(rowErrs, storage.RowError{Index: i, Err: err})
+		}
+	}
+	return rowErrs, nil
+}
+
+func (stg *crudStg[M]) CopyFrom(models []M) (copied int64, err error) {
+	if len(models) == 0 {
+		return 0, nil
+	}
+	if stg.db.inTx() {
+		return 0, errs.Newf(errs.FailedPrecondition, nil, "copy from cannot be used inside a transaction")
+	}
+	// the copied rows are not returned, so the models are left untouched
+	err = stg.db.update(func(ts *tableSet) error {
+		now := time.Now()
+		for _, model := range models {
+			if err := stg.insert(ts, cloneModel(model), now); err != nil {
+				return errs.Wrapf(err, "failed to copy %s", stg.tableName())
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(models)), nil
+}
+
+func (stg *crudStg[M]) UpsertOne(model M, opts storage.UpsertOptions) error {
+	return stg.UpsertMany([]M{model}, opts)
+}
+
+func (stg *crudStg[M]) UpsertMany(models []M, opts storage.UpsertOptions) error {
+	if len(models) == 0 {
+		return nil
+	}
+	conflictColumns, err := stg.toColumnNames(opts.ConflictColumns)
+	if err != nil {
+		return err
+	}
+	if len(conflictColumns) == 0 {
+		conflictColumns = stg.schema().PrimaryFieldDBNames
+	}
+	index, err := stg.conflictIndex(conflictColumns, opts.ConflictWhere)
+	if err != nil {
+		return err
+	}
+	updateColumns, err := stg.toColumnNames(opts.UpdateColumns)
+	if err != nil {
+		return err
+	}
+	if len(updateColumns) == 0 {
+		updateColumns = stg.upsertAllColumns()
+	}
+
+	return stg.db.update(func(ts *tableSet) error {
+		now := time.Now()
+		for _, model := range models {
+			candidate := cloneModel(model)
+			stg.prepareCreate(candidate, now)
+			existing := stg.findConflict(ts, index, candidate)
+			if existing == nil {
+				if err := stg.insert(ts, model, now); err != nil {
+					return err
+				}
+				continue
+			}
+			if opts.DoNothing {
+				continue
+			}
+
+			updated := cloneModel(existing.model.(M))
+			for _, column := range updateColumns {
+				stg.copyColumn(updated, candidate, column)
+			}
+			if versioned, ok := any(updated).(common.VersionedModel); ok && !lo.Contains(updateColumns, versionColumnName) {
+				versioned.SetVersion(any(existing.model).(common.VersionedModel).GetVersion() + 1)
+			}
+			if err := ts.put(stg.tableName(), stg.key(updated), &row{seq: existing.seq, model: updated}); err != nil {
+				return err
+			}
+			setModel(model, updated)
+		}
+		return nil
+	})
+}
+
+func (stg *crudStg[M]) FindById(id int64) (model M, err error) {
+	stg.db.read(func(ts *tableSet) {
+		if r, ok := ts.get(stg.tableName(), id); ok && stg.visible(r.model) {
+			model = cloneModel(r.model.(M))
+		}
+	})
+	if reflect.ValueOf(model).IsNil() {
+		entryName := pluralizer.Singular(stg.tableName())
+		return model, errs.Newf(errs.NotFound, nil, errs.DefaultEntryNotFoundMessage, entryName, id)
+	}
+	return model, nil
+}
+
+func (stg *crudStg[M]) ListByIds(ids []int64) (models []M, err error) {
+	return stg.list(func(m M) bool {
+		id, ok := stg.key(m).(int64)
+		return ok && lo.Contains(ids, id)
+	}), nil
+}
+
+func (stg *crudStg[M]) UpdateOne(model M, saveAssociations bool) error {
+	if versioned, ok := any(model).(common.VersionedModel); ok {
+		return stg.updateVersioned(model, versioned, false)
+	}
+	// the same semantics as Save, the model is inserted if it does not exist
+	return stg.db.update(func(ts *tableSet) error {
+		return stg.save(ts, model, time.Now())
+	})
+}
+
+func (stg *crudStg[M]) UpdatePartial(model M, returnUpdated bool) error {
+	if versioned, ok := any(model).(common.VersionedModel); ok {
+		return stg.updateVersioned(model, versioned, true)
+	}
+	return stg.db.update(func(ts *tableSet) error {
+		r, ok := ts.get(stg.tableName(), stg.key(model))
+		if !ok || !stg.writable(r.model) {
+			return nil
+		}
+		updated := stg.merge(r.model.(M), model, time.Now())
+		if err := ts.put(stg.tableName(), stg.key(updated), &row{seq: r.seq, model: updated}); err != nil {
+			return err
+		}
+		if returnUpdated {
+			setModel(model, updated)
+		}
+		return nil
+	})
+}
+
+// updateVersioned updates the model only if its version matches the stored version and increments the version.
+// The version of the model is left untouched if the update fails.
+func (stg *crudStg[M]) updateVersioned(model M, versioned common.VersionedModel, partial bool) error {
+	err := stg.db.update(func(ts *tableSet) error {
+		return stg.putVersioned(ts, model, partial, time.Now())
+	})
+	if err != nil {
+		return err
+	}
+	versioned.SetVersion(versioned.GetVersion() + 1)
+	return nil
+}
+
+// putVersioned stores the model with an incremented version if its version matches the stored version.
+// The version of the model itself is left untouched.
+func (stg *crudStg[M]) putVersioned(ts *tableSet, model M, partial bool, now time.Time) error {
+	entryName := pluralizer.Singular(stg.tableName())
+
+	version := any(model).(common.VersionedModel).GetVersion()
+	if version == 0 {
+		return errs.Newf(errs.InvalidArgument, nil, "the version of %q entry is required for the update", entryName)
+	}
+
+	r, ok := ts.get(stg.tableName(), stg.key(model))
+	if !ok || !stg.writable(r.model) || r.model.(common.VersionedModel).GetVersion() != version {
+		return errs.Newf(errs.Conflict, storage.ErrStaleVersion, "%q entry with version %d has been modified or deleted by another request", entryName, version)
+	}
+
+	var updated M
+	if partial {
+		updated = stg.merge(r.model.(M), model, now)
+	} else {
+		updated = cloneModel(model)
+		stg.prepareUpdate(updated, now)
+	}
+	any(updated).(common.VersionedModel).SetVersion(version + 1)
+	return ts.put(stg.tableName(), stg.key(updated), &row{seq: r.seq, model: updated})
+}
+
+func (stg *crudStg[M]) UpdateMany(models []M) error {
+	var zero M
+	_, versioned := any(zero).(common.VersionedModel)
+	err := stg.db.update(func(ts *tableSet) error {
+		now := time.Now()
+		for _, model := range models {
+			var err error
+			if versioned {
+				err = stg.putVersioned(ts, model, false, now)
+			} else {
+				err = stg.save(ts, model, now)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil || !versioned {
+		return err
+	}
+	for _, model := range models {
+		v := any(model).(common.VersionedModel)
+		v.SetVersion(v.GetVersion() + 1)
+	}
+	return nil
+}
+
+func (stg *crudStg[M]) ExistsById(id int64) (exists bool, err error) {
+	_, err = stg.FindById(id)
+	if errs.Code(err) == errs.NotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (stg *crudStg[M]) DeleteById(id int64) error {
+	deleted := false
+	err := stg.db.update(func(ts *tableSet) error {
+		var err error
+		deleted, err = stg.delete(ts, id)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		entryName := pluralizer.Singular(stg.tableName())
+		return errs.Newf(errs.NotFound, nil, errs.DefaultEntryNotFoundMessage, entryName, id)
+	}
+	return nil
+}
+
+func (stg *crudStg[M]) Delete(model M) error {
+	if err := stg.requirePrimaryKey(model, "deleted"); err != nil {
+		return err
+	}
+	key := stg.key(model)
+	return stg.db.update(func(ts *tableSet) error {
+		deleted, err := stg.delete(ts, key)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			entryName := pluralizer.Singular(stg.tableName())
+			return errs.Newf(errs.NotFound, nil, "%q entry by id %v could not be found", entryName, key)
+		}
+		// like gorm, the deleted time of the soft deleted model is set
+		if r, ok := ts.get(stg.tableName(), key); ok {
+			stg.copyColumn(model, r.model.(M), softDeleteColumnName)
+		}
+		return nil
+	})
+}
+
+func (stg *crudStg[M]) DeleteByIds(ids []int64) error {
+	return stg.db.update(func(ts *tableSet) error {
+		for _, id := range ids {
+			if _, err := stg.delete(ts, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (stg *crudStg[M]) Restore(model M) error {
+	if err := stg.ensureSoftDeletable(); err != nil {
+		return err
+	}
+
+	if err := stg.requirePrimaryKey(model, "restored"); err != nil {
+		return err
+	}
+	entryName := pluralizer.Singular(stg.tableName())
+	key := stg.key(model)
+	var restored M
+	err := stg.db.update(func(ts *tableSet) error {
+		r, ok := ts.get(stg.tableName(), key)
+		if !ok || columnValue(stg.ctx, r.model, softDeleteColumnName) == nil {
+			return errs.Newf(errs.NotFound, nil, "deleted %q entry by id %v could not be found", entryName, key)
+		}
+		// the restore is an update, so the update time and user are filled and the version is incremented
+		var err error
+		restored, err = stg.replace(ts, r, func(m M) {
+			stg.setColumn(m, softDeleteColumnName, nil)
+			if versioned, ok := any(m).(common.VersionedModel); ok {
+				versioned.SetVersion(versioned.GetVersion() + 1)
+			}
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	setModel(model, restored)
+	return nil
+}
+
+// requirePrimaryKey checks that the model has a single primary key which is set, to be deleted or restored by it.
+func (stg *crudStg[M]) requirePrimaryKey(model M, action string) error {
+	keyField, err := stg.keyField()
+	if err != nil {
+		return errs.Newf(errs.FailedPrecondition, nil, "%q table must have a single primary key to be %s", stg.tableName(), action)
+	}
+	if _, zero := keyField.ValueOf(stg.ctx, reflect.ValueOf(model)); zero {
+		entryName := pluralizer.Singular(stg.tableName())
+		return errs.Newf(errs.InvalidArgument, nil, "%q entry can't be %s without its primary key", entryName, action)
+	}
+	return nil
+}
+
+func (stg *crudStg[M]) Purge(deletedBefore time.Time) (purged int64, err error) {
+	if err = stg.ensureSoftDeletable(); err != nil {
+		return
+	}
+
+	err = stg.db.update(func(ts *tableSet) error {
+		purged = 0
+		for _, r := range ts.list(stg.tableName()) {
+			deletedAt, ok := columnValue(stg.ctx, r.model, softDeleteColumnName).(time.Time)
+			if ok && deletedAt.Before(deletedBefore) {
+				ts.delete(stg.tableName(), stg.key(r.model.(M)))
+				purged++
+			}
+		}
+		return nil
+	})
+	return
+}
+
+func (stg *crudStg[M]) ListAll() (models []M, err error) {
+	return stg.list(nil), nil
+}
+
+func (stg *crudStg[M]) Search(params *common.SearchParams) (models []M, err error) {
+	filter, err := stg.searchFilter(params.Filters)
+	if err != nil {
+		return nil, err
+	}
+	models = stg.list(filter)
+	if models, err = stg.paginate(models, params.Pagination); err != nil {
+		return nil, err
+	}
+	return stg.selectFields(models, params.FieldNames())
+}
+
+func (stg *crudStg[M]) Iterate(params *common.SearchParams, batchSize int) iter.Seq2[M, error] {
+	return func(yield func(M, error) bool) {
+		var zero M
+		var filters []*common.FieldFilter
+		var fieldNames []string
+		if params != nil {
+			filters = params.Filters
+			fieldNames = params.FieldNames()
+		}
+		keyField, err := stg.keyField()
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		filter, err := stg.searchFilter(filters)
+		if err != nil {
+			yield(zero, errs.Wrapf(err, "failed to iterate %s", stg.tableName()))
+			return
+		}
+		models := stg.list(filter)
+		slices.SortStableFunc(models, func(a, b M) int {
+			return compareValues(columnValue(stg.ctx, a, keyField.DBName), columnValue(stg.ctx, b, keyField.DBName))
+		})
+		if len(fieldNames) > 0 {
+			// the key is selected by the pg storage to query the next chunk
+			fieldNames = append(fieldNames, keyField.DBName)
+		}
+		if models, err = stg.selectFields(models, fieldNames); err != nil {
+			yield(zero, errs.Wrapf(err, "failed to iterate %s", stg.tableName()))
+			return
+		}
+		for _, model := range models {
+			if !yield(model, nil) {
+				return
+			}
+		}
+	}
+}
+
+// list returns copies of the visible models matching the filter in their insertion order.
+func (stg *crudStg[M]) list(filter func(m M) bool) []M {
+	models := make([]M, 0)
+	stg.db.read(func(ts *tableSet) {
+		for _, r := range ts.list(stg.tableName()) {
+			m := r.model.(M)
+			if stg.visible(m) && (filter == nil || filter(m)) {
+				models = append(models, cloneModel(m))
+			}
+		}
+	})
+	return models
+}
+
+// insert fills the generated columns of the model and stores a copy of it.
+func (stg *crudStg[M]) insert(ts *tableSet, model M, now time.Time) error {
+	stg.prepareCreate(model, now)
+	key := stg.key(model)
+	if _, ok := ts.get(stg.tableName(), key); ok {
+		return errs.Newf(errs.Conflict, nil, "duplicate key value violates unique constraint \"%s_pkey\"", stg.tableName())
+	}
+	return ts.put(stg.tableName(), key, &row{seq: stg.db.seqs.nextRowSeq(), model: cloneModel(model)})
+}
+
+// save replaces the stored model, or inserts it if it does not exist.
+func (stg *crudStg[M]) save(ts *tableSet, model M, now time.Time) error {
+	r, ok := ts.get(stg.tableName(), stg.key(model))
+	if !ok {
+		return stg.insert(ts, model, now)
+	}
+	stg.prepareUpdate(model, now)
+	return ts.put(stg.tableName(), stg.key(model), &row{seq: r.seq, model: cloneModel(model)})
+}
+
+// delete soft deletes the model of the key, or deletes it if the model does not support soft deletes. Like the other
+// writes, it ignores the soft deleted models even if the deleted models are included.
+func (stg *crudStg[M]) delete(ts *tableSet, id any) (deleted bool, err error) {
+	r, ok := ts.get(stg.tableName(), id)
+	if !ok || !stg.writable(r.model) {
+		return false, nil
+	}
+	if stg.ensureSoftDeletable() != nil {
+		ts.delete(stg.tableName(), id)
+		return true, nil
+	}
+	deletedModel := cloneModel(r.model.(M))
+	stg.setColumn(deletedModel, softDeleteColumnName, time.Now())
+	return true, ts.put(stg.tableName(), id, &row{seq: r.seq, model: deletedModel})
+}
+
+// merge returns a copy of the stored model with the non-zero fields of the model, like the partial updates of gorm.
+func (stg *crudStg[M]) merge(stored M, model M, now time.Time) M {
+	merged := cloneModel(stored)
+	modelValue := reflect.ValueOf(model)
+	for _, field := range stg.schema().Fields {
+		if field.DBName == "" || field.PrimaryKey {
+			continue
+		}
+		if value, isZero := field.ValueOf(stg.ctx, modelValue); !isZero {
+			_ = field.Set(stg.ctx, reflect.ValueOf(merged), value)
+		}
+	}
+	stg.prepareUpdate(merged, now)
+	return merged
+}
+
+// prepareCreate fills the columns that are generated on insert: the ids, the defaults, the timestamps and the audit
+// users.
+func (stg *crudStg[M]) prepareCreate(model M, now time.Time) {
+	modelValue := reflect.ValueOf(model)
+	userId := actor.UserIdFromCtx(stg.ctx)
+	for _, field := range stg.schema().Fields {
+		if field.DBName == "" {
+			continue
+		}
+		if _, isZero := field.ValueOf(stg.ctx, modelValue); !isZero {
+			continue
+		}
+		var value any
+		switch {
+		case field.PrimaryKey && field.AutoIncrement:
+			value = stg.db.seqs.nextId(stg.tableName())
+		case field.PrimaryKey && field.FieldType == reflect.TypeOf(uuid.UUID{}):
+			value = uuid.New()
+		case field.DefaultValueInterface != nil:
+			value = field.DefaultValueInterface
+		case field.AutoCreateTime > 0:
+			value = timestampValue(field, field.AutoCreateTime, now)
+		case field.AutoUpdateTime > 0:
+			value = timestampValue(field, field.AutoUpdateTime, now)
+		case (field.DBName == createdByColumnName || field.DBName == updatedByColumnName) && userId != uuid.Nil:
+			value = &userId
+		default:
+			continue
+		}
+		_ = field.Set(stg.ctx, modelValue, value)
+	}
+}
+
+// prepareUpdate fills the columns that are generated on update, i.e. the update time and user.
+func (stg *crudStg[M]) prepareUpdate(model M, now time.Time) {
+	modelValue := reflect.ValueOf(model)
+	userId := actor.UserIdFromCtx(stg.ctx)
+	for _, field := range stg.schema().Fields {
+		switch {
+		case field.AutoUpdateTime > 0:
+			_ = field.Set(stg.ctx, modelValue, timestampValue(field, field.AutoUpdateTime, now))
+		case field.DBName == updatedByColumnName && userId != uuid.Nil:
+			_ = field.Set(stg.ctx, modelValue, &userId)
+		}
+	}
+}
+
+// timestampValue returns now in the type of the auto create or update time field, the same way gorm fills them.
+func timestampValue(field *schema.Field, timeType schema.TimeType, now time.Time) any {
+	if field.DataType == schema.Time {
+		return now
+	}
+	switch timeType {
+	case schema.UnixNanosecond:
+		return now.UnixNano()
+	case schema.UnixMillisecond:
+		return now.UnixMilli()
+	default:
+		return now.Unix()
+	}
+}
+
+func (stg *crudStg[M]) searchFilter(filters []*common.FieldFilter) (func(m M) bool, error) {
+	tagToColumnNameMap := stg.getTagToColumnNameMap()
+	conditions := make([]func(m M) bool, 0, len(filters))
+	for _, f := range filters {
+		columnName, ok := tagToColumnNameMap[f.FieldName]
+		if !ok {
+			return nil, errs.NewInvalidSearchFieldErr(f.FieldName)
+		}
+		value := f.Value
+		switch f.Condition {
+		case common.SearchConditionContains:
+			conditions = append(conditions, func(m M) bool {
+				text, ok := textValue(columnValue(stg.ctx, m, columnName))
+				return ok && strings.Contains(strings.ToLower(text), strings.ToLower(value))
+			})
+		case common.SearchConditionEqual:
+			conditions = append(conditions, func(m M) bool {
+				text, ok := textValue(columnValue(stg.ctx, m, columnName))
+				return ok && text == value
+			})
+		case common.SearchConditionNotEqual:
+			// NOT (NULL = value) is NULL, so the NULLs are excluded as well
+			conditions = append(conditions, func(m M) bool {
+				text, ok := textValue(columnValue(stg.ctx, m, columnName))
+				return ok && text != value
+			})
+		default:
+			return nil, fmt.Errorf("unsupported search operation %q for field %q", f.Condition, f.FieldName)
+		}
+	}
+	return func(m M) bool {
+		return lo.EveryBy(conditions, func(condition func(m M) bool) bool {
+			return condition(m)
+		})
+	}, nil
+}
+
+func (stg *crudStg[M]) paginate(models []M, pagination *common.Pagination) ([]M, error) {
+	if pagination == nil || pagination == common.InternalPagination() {
+		return models, nil
+	}
+	pagination.TotalCount = int64(len(models))
+
+	tagToColumnNameMap := stg.getTagToColumnNameMap()
+	sortFields := pagination.SortFields()
+	columnNames := make([]string, 0, len(sortFields))
+	for _, sortField := range sortFields {
+		columnName, ok := tagToColumnNameMap[sortField.FieldName]
+		if !ok {
+			return nil, errs.NewInvalidSortFieldErr(sortField.FieldName)
+		}
+		columnNames = append(columnNames, columnName)
+	}
+	slices.SortStableFunc(models, func(a, b M) int {
+		for i, sortField := range sortFields {
+			c := compareValues(columnValue(stg.ctx, a, columnNames[i]), columnValue(stg.ctx, b, columnNames[i]))
+			if strings.EqualFold(sortField.Order, common.SortOrderDescending) {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+
+	offset := min(pagination.PageSize*pagination.Page, len(models))
+	end := min(offset+pagination.PageSize, len(models))
+	return models[offset:end], nil
+}
+
+// selectFields returns the models with only the given json field names (or column names) filled.
+// The models are returned as they are when no field is given.
+func (stg *crudStg[M]) selectFields(models []M, fieldNames []string) ([]M, error) {
+	if len(fieldNames) == 0 {
+		return models, nil
+	}
+	columnNames, err := stg.columnNamesOf(fieldNames, errs.NewInvalidSelectFieldErr)
+	if err != nil {
+		return nil, err
+	}
+	return lo.Map(models, func(m M, _ int) M {
+		selected := newModel[M]()
+		for _, columnName := range columnNames {
+			stg.copyColumn(selected, m, columnName)
+		}
+		return selected
+	}), nil
+}
+
+// findConflict returns the row which the model conflicts with on the index, or nil if there is none.
+func (stg *crudStg[M]) findConflict(ts *tableSet, index *uniqueIndex, model M) *row {
+	if index == nil {
+		// the primary key
+		r, _ := ts.get(stg.tableName(), stg.key(model))
+		return r
+	}
+	values, ok := indexValues(*index, model)
+	if !ok {
+		return nil
+	}
+	for _, r := range ts.list(stg.tableName()) {
+		if otherValues, ok := indexValues(*index, r.model); ok && reflect.DeepEqual(values, otherValues) {
+			return r
+		}
+	}
+	return nil
+}
+
+// conflictIndex returns the unique index of the conflict columns of an upsert, or nil for the primary key.
+// Like postgres, the upserts fail if no unique index matches the conflict columns, or if the predicate of a partial
+// index is not given. Unlike postgres, the predicates are compared as text rather than by their meaning.
+func (stg *crudStg[M]) conflictIndex(columns []string, where string) (*uniqueIndex, error) {
+	if slices.Equal(columns, stg.schema().PrimaryFieldDBNames) {
+		return nil, nil
+	}
+	for _, index := range uniqueIndexes[stg.tableName()] {
+		if slices.Equal(lo.Uniq(columns), index.columns) && (index.predicate == "" || normalizePredicate(where) == normalizePredicate(index.predicate)) {
+			return &index, nil
+		}
+	}
+	return nil, errs.Newf(errs.InvalidArgument, nil, "there is no unique index of %q matching the conflict columns %v", stg.tableName(), columns)
+}
+
+// upsertAllColumns returns the columns updated by the upserts by default. The primary key, the generated columns,
+// the creation time and user, the deletion time and the version of the existing rows are kept.
+func (stg *crudStg[M]) upsertAllColumns() []string {
+	keptColumns := []string{createdByColumnName, softDeleteColumnName, versionColumnName}
+	columns := make([]string, 0)
+	for _, field := range stg.schema().Fields {
+		if field.DBName == "" || !field.Updatable || field.PrimaryKey || field.AutoCreateTime > 0 || lo.Contains(keptColumns, field.DBName) {
+			continue
+		}
+		if field.HasDefaultValue && field.DefaultValueInterface == nil {
+			continue
+		}
+		columns = append(columns, field.DBName)
+	}
+	return columns
+}
+
+// visible reports whether the model is seen by the read-only queries.
+func (stg *crudStg[M]) visible(m any) bool {
+	return stg.unscoped || stg.writable(m)
+}
+
+// writable reports whether the model is seen by the writes, which ignore the soft deleted models.
+func (stg *crudStg[M]) writable(m any) bool {
+	if stg.ensureSoftDeletable() != nil {
+		return true
+	}
+	return columnValue(stg.ctx, m, softDeleteColumnName) == nil
+}
+
+func (stg *crudStg[M]) ensureSoftDeletable() error {
+	if stg.schema().LookUpField(softDeleteColumnName) == nil {
+		return errs.Newf(errs.FailedPrecondition, nil, "%q table does not support soft deletes", stg.tableName())
+	}
+	return nil
+}
+
+// key returns the primary key of the model, by which the rows are stored.
+func (stg *crudStg[M]) key(m M) any {
+	field := stg.schema().PrioritizedPrimaryField
+	if field == nil {
+		panic(fmt.Sprintf("the %q table must have a primary key", stg.tableName()))
+	}
+	return columnValue(stg.ctx, m, field.DBName)
+}
+
+// keyField returns the field the models are iterated by, i.e. the primary key.
+func (stg *crudStg[M]) keyField() (*schema.Field, error) {
+	s := stg.schema()
+	if len(s.PrimaryFields) != 1 {
+		return nil, errs.Newf(errs.FailedPrecondition, nil, "%q table must have a single primary key to be iterated", stg.tableName())
+	}
+	return s.PrimaryFields[0], nil
+}
+
+func (stg *crudStg[M]) setColumn(m M, column string, value any) {
+	if field := stg.schema().LookUpField(column); field != nil {
+		_ = field.Set(stg.ctx, reflect.ValueOf(m), value)
+	}
+}
+
+func (stg *crudStg[M]) copyColumn(dst M, src M, column string) {
+	if field := stg.schema().LookUpField(column); field != nil {
+		value := field.ReflectValueOf(stg.ctx, reflect.ValueOf(src))
+		field.ReflectValueOf(stg.ctx, reflect.ValueOf(dst)).Set(value)
+	}
+}
+
+// toColumnNames maps the given json field names (or column names) of an upsert to the column names.
+func (stg *crudStg[M]) toColumnNames(fieldNames []string) ([]string, error) {
+	return stg.columnNamesOf(fieldNames, errs.NewInvalidUpsertFieldErr)
+}
+
+func (stg *crudStg[M]) columnNamesOf(fieldNames []string, invalidFieldErr func(fieldName string) error) ([]string, error) {
+	tagToColumnNameMap := stg.getTagToColumnNameMap()
+	columnNames := make([]string, 0, len(fieldNames))
+	for _, fieldName := range fieldNames {
+		columnName, ok := tagToColumnNameMap[fieldName]
+		if !ok {
+			return nil, invalidFieldErr(fieldName)
+		}
+		columnNames = append(columnNames, columnName)
+	}
+	return lo.Uniq(columnNames), nil
+}
+
+func (stg *crudStg[M]) getTagToColumnNameMap() map[string]string {
+	m := make(map[string]string)
+	for _, field := range stg.schema().Fields {
+		if field.DBName == "" {
+			continue
+		}
+		jsonName := strings.Split(field.Tag.Get("json"), ",")[0]
+		m[jsonName] = field.DBName
+		m[field.DBName] = field.DBName
+	}
+	return m
+}
+
+func (stg *crudStg[M]) schema() *schema.Schema {
+	return getGormSchema(newModel[M]())
+}
+
+func (stg *crudStg[M]) tableName() string {
+	return newModel[M]().TableName()
+}
+
+// newModel returns a new zero model, M being a pointer to the model struct.
+func newModel[M any]() M {
+	var model M
+	return reflect.New(reflect.TypeOf(model).Elem()).Interface().(M)
+}
+
+// setModel overwrites the model with the stored model, e.g. to return the generated columns.
+func setModel[M any](model M, stored M) {
+	reflect.ValueOf(model).Elem().Set(reflect.ValueOf(cloneModel(stored)).Elem())
+}
+
+// rows returns the visible rows of the table matching the filter in their insertion order.
+func (stg *crudStg[M]) rows(ts *tableSet, filter func(m M) bool) []*row {
+	return lo.Filter(ts.list(stg.tableName()), func(r *row, _ int) bool {
+		return stg.visible(r.model) && (filter == nil || filter(r.model.(M)))
+	})
+}
+
+// replace stores a copy of the model of the row changed by fn, and returns another copy of it.
+func (stg *crudStg[M]) replace(ts *tableSet, r *row, fn func(m M)) (M, error) {
+	updated := cloneModel(r.model.(M))
+	fn(updated)
+	stg.prepareUpdate(updated, time.Now())
+	if err := ts.put(stg.tableName(), stg.key(updated), &row{seq: r.seq, model: updated}); err != nil {
+		var zero M
+		return zero, err
+	}
+	return cloneModel(updated), nil
+}
+
+// updateById changes the visible model of the id with fn. It is a no-op if there is no such model.
+func (stg *crudStg[M]) updateById(id int64, fn func(m M)) error {
+	return stg.db.update(func(ts *tableSet) error {
+		r, ok := ts.get(stg.tableName(), id)
+		if !ok || !stg.writable(r.model) {
+			return nil
+		}
+		_, err := stg.replace(ts, r, fn)
+		return err
+	})
+}
@@ -0,0 +1,149 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/domain/model/common"
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+)
+
+type JobStg struct {
+	crudStg[*model.Job]
+}
+
+func NewJobStg(ctx context.Context, d *db) *JobStg {
+	return &JobStg{
+		crudStg: newCrudStg[*model.Job](ctx, d),
+	}
+}
+
+func (stg *JobStg) Enqueue(job *model.Job) (existing *model.Job, enqueued bool, err error) {
+	if job.UniqueKey == nil {
+		return job, true, stg.CreateOne(job)
+	}
+	err = stg.db.update(func(ts *tableSet) error {
+		if r := stg.findConflict(ts, &uniqueIndexes[job.TableName()][0], job); r != nil {
+			existing = cloneModel(r.model.(*model.Job))
+			return nil
+		}
+		enqueued = true
+		return stg.insert(ts, job, time.Now())
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if enqueued {
+		return job, true, nil
+	}
+	return existing, false, nil
+}
+
+func (stg *JobStg) ClaimNext(queue string, limit int) (jobs []*model.Job, err error) {
+	jobs = make([]*model.Job, 0)
+	err = stg.db.update(func(ts *tableSet) error {
+		now := time.Now()
+		rows := stg.rows(ts, func(job *model.Job) bool {
+			return job.Queue == queue && job.Status == model.JobPending && !job.RunAt.After(now)
+		})
+		slices.SortStableFunc(rows, func(a, b *row) int {
+			jobA, jobB := a.model.(*model.Job), b.model.(*model.Job)
+			if c := jobA.RunAt.Compare(jobB.RunAt); c != 0 {
+				return c
+			}
+			return compareOrdered(jobA.ID, jobB.ID)
+		})
+		for _, r := range rows[:min(limit, len(rows))] {
+			job, err := stg.replace(ts, r, func(job *model.Job) {
+				job.Status = model.JobRunning
+				job.Attempts++
+				job.LockedAt = &now
+			})
+			if err != nil {
+				return err
+			}
+			jobs = append(jobs, job)
+		}
+		return nil
+	})
+	return
+}
+
+func (stg *JobStg) MarkCompleted(id int64) error {
+	return stg.updateById(id, func(job *model.Job) {
+		now := time.Now()
+		job.Status = model.JobCompleted
+		job.LastError = ""
+		job.LockedAt = nil
+		job.CompletedAt = &now
+	})
+}
+
+func (stg *JobStg) MarkFailed(id int64, lastError string, runAt time.Time, dead bool) error {
+	status := model.JobPending
+	if dead {
+		status = model.JobFailed
+	}
+	return stg.updateById(id, func(job *model.Job) {
+		job.Status = status
+		job.LastError = lastError
+		job.LockedAt = nil
+		job.RunAt = runAt
+	})
+}
+
+func (stg *JobStg) Retry(id int64) error {
+	return stg.db.update(func(ts *tableSet) error {
+		r, ok := ts.get(stg.tableName(), id)
+		if !ok || r.model.(*model.Job).Status != model.JobFailed {
+			return errs.Newf(errs.NotFound, nil, "failed job by id %d could not be found", id)
+		}
+		_, err := stg.replace(ts, r, func(job *model.Job) {
+			job.Status = model.JobPending
+			job.Attempts = 0
+			job.RunAt = time.Now()
+		})
+		if errs.Code(err) == errs.Conflict {
+			return errs.Newf(errs.Conflict, err, "another job with the same unique key of job %d is already enqueued", id)
+		}
+		return err
+	})
+}
+
+func (stg *JobStg) RescueStale(lockedBefore time.Time) (rescued int64, err error) {
+	err = stg.db.update(func(ts *tableSet) error {
+		rescued = 0
+		rows := stg.rows(ts, func(job *model.Job) bool {
+			return job.Status == model.JobRunning && job.LockedAt != nil && job.LockedAt.Before(lockedBefore)
+		})
+		for _, r := range rows {
+			_, err := stg.replace(ts, r, func(job *model.Job) {
+				job.Status = model.JobPending
+				if job.Attempts >= job.MaxAttempts {
+					job.Status = model.JobFailed
+				}
+				job.LastError = fmt.Sprintf("rescued after running since before %s", lockedBefore.Format(time.RFC3339))
+				job.LockedAt = nil
+			})
+			if err != nil {
+				return err
+			}
+			rescued++
+		}
+		return nil
+	})
+	return
+}
+
+func (stg *JobStg) ListByStatus(status model.JobStatus, pagination *common.Pagination, fieldNames []string) (jobs []*model.Job, err error) {
+	jobs = stg.list(func(job *model.Job) bool {
+		return status == "" || job.Status == status
+	})
+	if jobs, err = stg.paginate(jobs, pagination); err != nil {
+		return nil, err
+	}
+	return stg.selectFields(jobs, fieldNames)
+}
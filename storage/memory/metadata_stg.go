@@ -0,0 +1,154 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/samber/lo"
+	"gorm.io/gorm/schema"
+)
+
+// MetadataStg describes the schema of the models instead of the schema of a db, so the schema is always in sync
+// with the models and there is nothing to invalidate.
+type MetadataStg struct {
+	ctx context.Context
+	db  *db
+}
+
+func NewMetadataStg(ctx context.Context, d *db) *MetadataStg {
+	return &MetadataStg{ctx: ctx, db: d}
+}
+
+func (stg *MetadataStg) ListTablesAndColumns() (tableToColumnsMap map[string][]string, err error) {
+	dbSchema, _ := stg.DescribeSchema()
+	tableToColumnsMap = make(map[string][]string, len(dbSchema.Tables))
+	for _, table := range dbSchema.Tables {
+		tableToColumnsMap[table.Name] = table.ColumnNames()
+	}
+	return tableToColumnsMap, nil
+}
+
+func (stg *MetadataStg) ListTables() (tableNames []string, err error) {
+	dbSchema, _ := stg.DescribeSchema()
+	tableNames = lo.Map(dbSchema.Tables, func(table *storage.TableSchema, _ int) string {
+		return table.Name
+	})
+	return tableNames, nil
+}
+
+func (stg *MetadataStg) ListColumns(tableName string) (columnNames []string, err error) {
+	dbSchema, _ := stg.DescribeSchema()
+	table := dbSchema.Table(tableName)
+	if table == nil {
+		return nil, errs.Newf(errs.NotFound, nil, "table %q does not exist", tableName)
+	}
+	return table.ColumnNames(), nil
+}
+
+func (stg *MetadataStg) DescribeSchema() (*storage.DbSchema, error) {
+	return &storage.DbSchema{
+		Tables: lo.Map(model.All(), func(m schema.Tabler, _ int) *storage.TableSchema {
+			return describeTable(getGormSchema(m))
+		}),
+		LoadedAt: time.Now(),
+	}, nil
+}
+
+func (stg *MetadataStg) Invalidate() {}
+
+// RecordByValueExists reports whether the table has a record with the value in the column. The soft deleted
+// records are ignored, the same way the unique indexes on the soft deletable tables ignore them.
+func (stg *MetadataStg) RecordByValueExists(tableName, columnName string, value interface{}) (exists bool, err error) {
+	dbSchema, _ := stg.DescribeSchema()
+	table := dbSchema.Table(tableName)
+	if table == nil {
+		return false, errs.Newf(errs.NotFound, nil, "table %q does not exist", tableName)
+	}
+	if table.Column(columnName) == nil {
+		return false, errs.Newf(errs.NotFound, nil, "column %q does not exist in table %q", columnName, tableName)
+	}
+	if sqlValue(value) == nil {
+		// nothing equals NULL
+		return false, nil
+	}
+
+	stg.db.read(func(ts *tableSet) {
+		exists = lo.ContainsBy(ts.list(tableName), func(r *row) bool {
+			if columnValue(stg.ctx, r.model, softDeleteColumnName) != nil {
+				return false
+			}
+			return compareValues(columnValue(stg.ctx, r.model, columnName), value) == 0
+		})
+	})
+	return exists, nil
+}
+
+// describeTable describes the table of the model. The types are the pg types the migrations would use for the
+// fields, and the indexes are the primary key and the unique indexes known to this package.
+func describeTable(s *schema.Schema) *storage.TableSchema {
+	table := &storage.TableSchema{
+		Name:        s.Table,
+		PrimaryKey:  s.PrimaryFieldDBNames,
+		ForeignKeys: []*storage.ForeignKey{},
+		Indexes: []*storage.IndexSchema{{
+			Name:       s.Table + "_pkey",
+			Unique:     true,
+			Primary:    true,
+			Definition: fmt.Sprintf("CREATE UNIQUE INDEX %s_pkey ON %s (%s)", s.Table, s.Table, strings.Join(s.PrimaryFieldDBNames, ", ")),
+		}},
+	}
+	for _, field := range s.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		column := &storage.ColumnSchema{
+			Name:     field.DBName,
+			Type:     columnType(field),
+			DataType: string(field.DataType),
+			Nullable: !field.NotNull && !field.PrimaryKey,
+		}
+		if field.HasDefaultValue && field.DefaultValue != "" {
+			column.Default = lo.ToPtr(field.DefaultValue)
+		}
+		table.Columns = append(table.Columns, column)
+	}
+	for _, index := range uniqueIndexes[s.Table] {
+		definition := fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s (%s)", index.name, s.Table, strings.Join(index.columns, ", "))
+		if index.predicate != "" {
+			definition += " WHERE " + index.predicate
+		}
+		table.Indexes = append(table.Indexes, &storage.IndexSchema{
+			Name:       index.name,
+			Unique:     true,
+			Definition: definition,
+		})
+	}
+	return table
+}
+
+// columnType returns the pg type of the field, e.g. int8 or timestamptz.
+func columnType(field *schema.Field) string {
+	switch field.DataType {
+	case schema.Bool:
+		return "bool"
+	case schema.Int, schema.Uint:
+		if field.Size > 0 && field.Size <= 32 {
+			return "int4"
+		}
+		return "int8"
+	case schema.Float:
+		return "float8"
+	case schema.String:
+		return "text"
+	case schema.Time:
+		return "timestamptz"
+	case schema.Bytes:
+		return "bytea"
+	}
+	return strings.ToLower(string(field.DataType))
+}
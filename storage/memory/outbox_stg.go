@@ -0,0 +1,66 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+)
+
+type OutboxStg struct {
+	crudStg[*model.OutboxEvent]
+}
+
+func NewOutboxStg(ctx context.Context, d *db) *OutboxStg {
+	return &OutboxStg{
+		crudStg: newCrudStg[*model.OutboxEvent](ctx, d),
+	}
+}
+
+func (stg *OutboxStg) ClaimDue(limit int, lease time.Duration) (events []*model.OutboxEvent, err error) {
+	events = make([]*model.OutboxEvent, 0)
+	err = stg.db.update(func(ts *tableSet) error {
+		now := time.Now()
+		rows := stg.rows(ts, func(event *model.OutboxEvent) bool {
+			return event.Status == model.OutboxEventPending && !event.NextAttemptAt.After(now)
+		})
+		slices.SortStableFunc(rows, func(a, b *row) int {
+			return compareOrdered(a.model.(*model.OutboxEvent).ID, b.model.(*model.OutboxEvent).ID)
+		})
+		for _, r := range rows[:min(limit, len(rows))] {
+			event, err := stg.replace(ts, r, func(event *model.OutboxEvent) {
+				event.NextAttemptAt = now.Add(lease)
+			})
+			if err != nil {
+				return err
+			}
+			events = append(events, event)
+		}
+		return nil
+	})
+	return
+}
+
+func (stg *OutboxStg) MarkDelivered(id int64) error {
+	return stg.updateById(id, func(event *model.OutboxEvent) {
+		now := time.Now()
+		event.Status = model.OutboxEventDelivered
+		event.Attempts++
+		event.LastError = ""
+		event.DeliveredAt = &now
+	})
+}
+
+func (stg *OutboxStg) MarkFailed(id int64, lastError string, nextAttemptAt time.Time, dead bool) error {
+	status := model.OutboxEventPending
+	if dead {
+		status = model.OutboxEventDead
+	}
+	return stg.updateById(id, func(event *model.OutboxEvent) {
+		event.Status = status
+		event.Attempts++
+		event.LastError = lastError
+		event.NextAttemptAt = nextAttemptAt
+	})
+}
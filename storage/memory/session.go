@@ -0,0 +1,126 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+)
+
+// sequences generates the ids of the tables and the insertion order of the rows. Like the sequences of postgres,
+// they are shared by all the transactions and are not rolled back.
+type sequences struct {
+	mu     sync.Mutex
+	ids    map[string]int64
+	rowSeq int64
+}
+
+func (s *sequences) nextId(table string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ids[table]++
+	return s.ids[table]
+}
+
+func (s *sequences) nextRowSeq() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rowSeq++
+	return s.rowSeq
+}
+
+// db is the committed state of the tables, or the state of a transaction when it has a parent.
+// A transaction works on a snapshot of its parent taken when it begins, and its writes are replayed on the parent
+// when it is committed. The unique indexes and the versions of the versioned rows are checked again by the replay,
+// so the conflicting writes of the concurrent transactions fail the commit instead of being lost.
+type db struct {
+	seqs   *sequences
+	parent *db
+
+	mu       sync.Mutex
+	data     *tableSet
+	writes   []write
+	resolved bool
+}
+
+func newDb() *db {
+	return &db{
+		seqs: &sequences{ids: make(map[string]int64)},
+		data: newTableSet(),
+	}
+}
+
+// read runs fn on the current state.
+func (d *db) read(fn func(ts *tableSet)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	fn(d.data)
+}
+
+// update runs fn on a clone of the current state, which replaces the state only if fn succeeds.
+// Every statement is therefore atomic, the same way as in the db.
+func (d *db) update(fn func(ts *tableSet) error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.resolved {
+		return errs.Newf(errs.FailedPrecondition, nil, "the transaction has already been committed or rolled back")
+	}
+
+	next := d.data.clone()
+	if err := fn(next); err != nil {
+		return err
+	}
+	d.data = next
+	if d.parent != nil {
+		d.writes = append(d.writes, next.writes...)
+	}
+	return nil
+}
+
+func (d *db) inTx() bool {
+	return d.parent != nil
+}
+
+type session struct {
+	db *db
+}
+
+func (ses *session) Begin() (storage.Session, error) {
+	ses.db.mu.Lock()
+	defer ses.db.mu.Unlock()
+	return &session{db: &db{
+		seqs:   ses.db.seqs,
+		parent: ses.db,
+		data:   ses.db.data.clone(),
+	}}, nil
+}
+
+func (ses *session) Rollback() error {
+	ses.db.mu.Lock()
+	defer ses.db.mu.Unlock()
+	ses.db.resolved = true
+	return nil
+}
+
+func (ses *session) Commit() error {
+	child := ses.db
+	child.mu.Lock()
+	defer child.mu.Unlock()
+	if child.resolved || child.parent == nil {
+		return nil
+	}
+	child.resolved = true
+
+	return child.parent.update(func(ts *tableSet) error {
+		if err := ts.replay(child.writes); err != nil {
+			return errs.Wrapf(err, "failed to commit the transaction")
+		}
+		return nil
+	})
+}
+
+func (ses *session) Close() error {
+	return nil
+}
+
+var _ storage.Session = &session{}
@@ -0,0 +1,93 @@
+// Package memory implements storage.Storage in memory, for the unit tests that do not need a live db.
+//
+// It follows the contract of the pg storage (see storage/storagetest): the not found and conflict errors,
+// the unique indexes of the migrations, the soft deletes, the optimistic locking of the versioned models and
+// the transactions with their savepoints. The queries that depend on the db itself (e.g. the row locks of the
+// claims) are emulated by running every statement atomically.
+package memory
+
+import (
+	"context"
+
+	"github.com/amahdian/golang-gin-boilerplate/pkg/logger"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/pkg/errors"
+)
+
+type Stg struct {
+	root  *db
+	locks *advisoryLocks
+}
+
+func NewStg() storage.Storage {
+	return &Stg{
+		root:  newDb(),
+		locks: &advisoryLocks{held: make(map[string]bool)},
+	}
+}
+
+// RunInTx runs fn in a transaction which is propagated to fn through its context. Nested calls run in a savepoint.
+// Unlike the pg storage, the transactions are never retried since they can't fail on serialization failures.
+func (stg *Stg) RunInTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	txCtx, ses, err := stg.Begin(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = ses.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			if rbErr := ses.Rollback(); rbErr != nil {
+				logger.Errorf("failed to rollback transaction: %v", rbErr)
+			}
+			return
+		}
+		if err = ses.Commit(); err != nil {
+			err = errors.Wrap(err, "failed to commit transaction")
+		}
+	}()
+	err = fn(txCtx)
+	return
+}
+
+func (stg *Stg) Begin(ctx context.Context) (context.Context, storage.Session, error) {
+	ses, err := (&session{db: stg.db(ctx)}).Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	return storage.WithContext(ctx, ses), ses, nil
+}
+
+func (stg *Stg) TryAdvisoryLock(_ context.Context, name string) (lock storage.AdvisoryLock, acquired bool, err error) {
+	return stg.locks.tryLock(name)
+}
+
+func (stg *Stg) User(ctx context.Context) storage.UserStorage {
+	return NewUserStg(ctx, stg.db(ctx))
+}
+
+func (stg *Stg) Outbox(ctx context.Context) storage.OutboxStorage {
+	return NewOutboxStg(ctx, stg.db(ctx))
+}
+
+func (stg *Stg) Job(ctx context.Context) storage.JobStorage {
+	return NewJobStg(ctx, stg.db(ctx))
+}
+
+func (stg *Stg) CronRun(ctx context.Context) storage.CronRunStorage {
+	return NewCronRunStg(ctx, stg.db(ctx))
+}
+
+func (stg *Stg) Metadata(ctx context.Context) storage.MetadataStorage {
+	return NewMetadataStg(ctx, stg.db(ctx))
+}
+
+// db returns the transaction of ctx, or the committed state if ctx has no transaction.
+func (stg *Stg) db(ctx context.Context) *db {
+	if ses, ok := storage.FromContext(ctx).(*session); ok {
+		return ses.db
+	}
+	return stg.root
+}
@@ -0,0 +1,159 @@
+package memory
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/amahdian/golang-gin-boilerplate/global/test"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/amahdian/golang-gin-boilerplate/storage/storagetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	test.SetupTestingEnv()
+	os.Exit(m.Run())
+}
+
+func TestStgContract(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return NewStg()
+	})
+}
+
+func TestStgConcurrentWrites(t *testing.T) {
+	stg := NewStg()
+	ctx := context.Background()
+
+	// only one of the concurrent transactions may create the user of the email
+	var created atomic.Int32
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := stg.RunInTx(ctx, func(ctx context.Context) error {
+				return stg.User(ctx).CreateOne(&model.User{Email: test.UserEmail})
+			})
+			if err == nil {
+				created.Add(1)
+				return
+			}
+			assert.Equal(t, errs.Conflict, errs.Code(err))
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, int32(1), created.Load())
+	users, err := stg.User(ctx).ListAll()
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+}
+
+func TestStgConcurrentVersionedUpdates(t *testing.T) {
+	stg := NewStg()
+	ctx := context.Background()
+	user := &model.User{Email: test.UserEmail}
+	require.NoError(t, stg.User(ctx).CreateOne(user))
+
+	// both transactions read the same version, so only the first one to commit may update the user
+	update := func(email string) (context.Context, storage.Session) {
+		txCtx, ses, err := stg.Begin(ctx)
+		require.NoError(t, err)
+		stale := *user
+		stale.Email = email
+		require.NoError(t, stg.User(txCtx).UpdateOne(&stale, false))
+		return txCtx, ses
+	}
+	_, first := update("first@example.com")
+	_, second := update("second@example.com")
+
+	require.NoError(t, first.Commit())
+	err := second.Commit()
+	assert.Equal(t, errs.Conflict, errs.Code(err))
+	assert.ErrorIs(t, err, storage.ErrStaleVersion)
+
+	found, err := stg.User(ctx).FindByUuid(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "first@example.com", found.Email)
+	assert.Equal(t, user.Version+1, found.Version)
+}
+
+var (
+	createTableRe       = regexp.MustCompile(`(?is)CREATE TABLE (?:IF NOT EXISTS )?(\w+)\s*\((.*?)\n\);`)
+	uniqueColumnRe      = regexp.MustCompile(`(?im)^\s*(\w+)\s[^,]*\bUNIQUE\b`)
+	createUniqueIndexRe = regexp.MustCompile(`(?i)CREATE UNIQUE INDEX (?:IF NOT EXISTS )?(\w+) ON (\w+)\s*\(([^)]*)\)(?:\s+WHERE\s+([^;]+))?;`)
+	dropIndexRe         = regexp.MustCompile(`(?i)DROP (?:INDEX|CONSTRAINT) (?:IF EXISTS )?(\w+)`)
+)
+
+// TestUniqueIndexesMatchMigrations replays the unique indexes of the up migrations, so the unique indexes of the
+// memory storage can't drift from the ones of the pg storage.
+func TestUniqueIndexesMatchMigrations(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join(test.GetBasePath(), "assets", "migrations", "*.up.sql"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+	sort.Strings(files)
+
+	indexes := map[string]map[string]uniqueIndex{}
+	tables := map[string]string{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+		sql := string(data)
+		for _, match := range dropIndexRe.FindAllStringSubmatch(sql, -1) {
+			if table, ok := tables[match[1]]; ok {
+				delete(indexes[table], match[1])
+			}
+		}
+		add := func(table string, index uniqueIndex) {
+			if indexes[table] == nil {
+				indexes[table] = map[string]uniqueIndex{}
+			}
+			indexes[table][index.name] = index
+			tables[index.name] = table
+		}
+		for _, table := range createTableRe.FindAllStringSubmatch(sql, -1) {
+			for _, column := range uniqueColumnRe.FindAllStringSubmatch(table[2], -1) {
+				// the name of the constraint pg gives to a UNIQUE column
+				add(table[1], uniqueIndex{name: table[1] + "_" + column[1] + "_key", columns: []string{column[1]}})
+			}
+		}
+		for _, match := range createUniqueIndexRe.FindAllStringSubmatch(sql, -1) {
+			columns := strings.Split(match[3], ",")
+			for i := range columns {
+				columns[i] = strings.TrimSpace(columns[i])
+			}
+			add(match[2], uniqueIndex{name: match[1], columns: columns, predicate: strings.TrimSpace(match[4])})
+		}
+	}
+
+	expected := map[string][]uniqueIndex{}
+	for table, byName := range indexes {
+		for _, index := range byName {
+			expected[table] = append(expected[table], index)
+		}
+	}
+	actual := map[string][]uniqueIndex{}
+	for table, tableIndexes := range uniqueIndexes {
+		for _, index := range tableIndexes {
+			actual[table] = append(actual[table], uniqueIndex{name: index.name, columns: index.columns, predicate: index.predicate})
+		}
+	}
+	for _, tableIndexes := range expected {
+		sort.Slice(tableIndexes, func(i, j int) bool { return tableIndexes[i].name < tableIndexes[j].name })
+	}
+	for _, tableIndexes := range actual {
+		sort.Slice(tableIndexes, func(i, j int) bool { return tableIndexes[i].name < tableIndexes[j].name })
+	}
+	assert.Equal(t, expected, actual, "update the uniqueIndexes along with the migrations")
+}
@@ -0,0 +1,133 @@
+package memory
+
+import (
+	"maps"
+	"reflect"
+	"sort"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model/common"
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+)
+
+// row is a stored model. The model is a private copy, it is never modified in place but replaced by the writes,
+// so the rows can be shared by the snapshots of the tables.
+type row struct {
+	// seq is the insertion order of the row, the rows are listed in this order unless they are sorted
+	seq   int64
+	model any
+}
+
+// write is a change of a row, replayed on the parent when a transaction is committed. row is nil for deletes.
+type write struct {
+	table string
+	key   any
+	row   *row
+	// readVersion is the version of the versioned row which has been replaced by the write, or 0
+	readVersion int64
+}
+
+// tableSet is a copy-on-write snapshot of the tables. Cloning it is cheap, and a table is only copied
+// by the first write of the clone.
+type tableSet struct {
+	tables map[string]map[any]*row
+	owned  map[string]bool
+	writes []write
+}
+
+func newTableSet() *tableSet {
+	return &tableSet{tables: make(map[string]map[any]*row), owned: make(map[string]bool)}
+}
+
+func (ts *tableSet) clone() *tableSet {
+	return &tableSet{tables: maps.Clone(ts.tables), owned: make(map[string]bool)}
+}
+
+func (ts *tableSet) get(table string, key any) (*row, bool) {
+	r, ok := ts.tables[table][key]
+	return r, ok
+}
+
+// list returns the rows of the table in their insertion order.
+func (ts *tableSet) list(table string) []*row {
+	rows := make([]*row, 0, len(ts.tables[table]))
+	for _, r := range ts.tables[table] {
+		rows = append(rows, r)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].seq < rows[j].seq
+	})
+	return rows
+}
+
+// put inserts or replaces the row of the key. It fails without any change if the row violates a unique index.
+func (ts *tableSet) put(table string, key any, r *row) error {
+	if err := checkUniqueIndexes(ts, table, key, r.model); err != nil {
+		return err
+	}
+	w := write{table: table, key: key, row: r}
+	if existing, ok := ts.get(table, key); ok {
+		w.readVersion = rowVersion(existing)
+	}
+	ts.ownedTable(table)[key] = r
+	ts.writes = append(ts.writes, w)
+	return nil
+}
+
+func (ts *tableSet) delete(table string, key any) {
+	if _, ok := ts.tables[table][key]; !ok {
+		return
+	}
+	delete(ts.ownedTable(table), key)
+	ts.writes = append(ts.writes, write{table: table, key: key})
+}
+
+// replay applies the writes of a committed transaction. A write of a versioned row fails if the row has been
+// modified or deleted since the transaction has read it, the same way as the versioned updates of a concurrent
+// transaction fail in the db.
+func (ts *tableSet) replay(writes []write) error {
+	for _, w := range writes {
+		if w.row == nil {
+			ts.delete(w.table, w.key)
+			continue
+		}
+		if w.readVersion != 0 {
+			if current, ok := ts.get(w.table, w.key); !ok || rowVersion(current) != w.readVersion {
+				return errs.Newf(errs.Conflict, storage.ErrStaleVersion, "%q entry with version %d has been modified or deleted by another transaction",
+					pluralizer.Singular(w.table), w.readVersion)
+			}
+		}
+		if err := ts.put(w.table, w.key, w.row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ts *tableSet) ownedTable(table string) map[any]*row {
+	if !ts.owned[table] {
+		rows := maps.Clone(ts.tables[table])
+		if rows == nil {
+			rows = make(map[any]*row)
+		}
+		ts.tables[table] = rows
+		ts.owned[table] = true
+	}
+	return ts.tables[table]
+}
+
+// rowVersion returns the version of the row, or 0 if its model is not versioned.
+func rowVersion(r *row) int64 {
+	if versioned, ok := r.model.(common.VersionedModel); ok {
+		return versioned.GetVersion()
+	}
+	return 0
+}
+
+// cloneModel returns a shallow copy of the model, which is a pointer to a struct.
+func cloneModel[M any](m M) M {
+	v := reflect.ValueOf(m)
+	c := reflect.New(v.Type().Elem())
+	c.Elem().Set(v.Elem())
+	return c.Interface().(M)
+}
@@ -0,0 +1,94 @@
+package memory
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/samber/lo"
+)
+
+// uniqueIndex mirrors a unique index of the migrations. The rows with a NULL column never conflict.
+type uniqueIndex struct {
+	name    string
+	columns []string
+	// predicate is the WHERE clause of a partial index, which the upserts must give as their ConflictWhere
+	predicate string
+	// where evaluates the predicate of a partial index, nil if the index covers all the rows
+	where func(value func(column string) any) bool
+}
+
+// uniqueIndexes are the unique indexes of the tables by table name. They must be kept in sync with the migrations,
+// which TestUniqueIndexesMatchMigrations checks.
+var uniqueIndexes = map[string][]uniqueIndex{
+	"users": {{
+		name:      "idx_users_email",
+		columns:   []string{"email"},
+		predicate: "deleted_at IS NULL",
+		where: func(value func(column string) any) bool {
+			return value("deleted_at") == nil
+		},
+	}},
+	"jobs": {{
+		name:      "idx_jobs_unique_key",
+		columns:   []string{"unique_key"},
+		predicate: "status IN ('pending', 'running')",
+		where: func(value func(column string) any) bool {
+			return lo.Contains([]any{string(model.JobPending), string(model.JobRunning)}, value("status"))
+		},
+	}},
+	"cron_runs": {{
+		name:      "idx_cron_runs_occurrence",
+		columns:   []string{"task_name", "scheduled_at"},
+		predicate: "trigger = 'schedule'",
+		where: func(value func(column string) any) bool {
+			return value("trigger") == string(model.CronRunScheduled)
+		},
+	}},
+}
+
+// checkUniqueIndexes returns a Conflict error if the model conflicts with another row of the table.
+func checkUniqueIndexes(ts *tableSet, table string, key any, m any) error {
+	for _, index := range uniqueIndexes[table] {
+		values, ok := indexValues(index, m)
+		if !ok {
+			continue
+		}
+		for otherKey, other := range ts.tables[table] {
+			if otherKey == key {
+				continue
+			}
+			if otherValues, ok := indexValues(index, other.model); ok && reflect.DeepEqual(values, otherValues) {
+				return errs.Newf(errs.Conflict, nil, "duplicate key value violates unique constraint %q", index.name)
+			}
+		}
+	}
+	return nil
+}
+
+// normalizePredicate makes the predicates comparable regardless of their case and spacing.
+func normalizePredicate(predicate string) string {
+	return strings.ToLower(strings.Join(strings.Fields(predicate), " "))
+}
+
+// indexValues returns the values of the index columns of the model. ok is false if the model is not covered by the index.
+func indexValues(index uniqueIndex, m any) (values []any, ok bool) {
+	ctx := context.Background()
+	value := func(column string) any {
+		return columnValue(ctx, m, column)
+	}
+	if index.where != nil && !index.where(value) {
+		return nil, false
+	}
+	values = make([]any, 0, len(index.columns))
+	for _, column := range index.columns {
+		v := value(column)
+		if v == nil {
+			return nil, false
+		}
+		values = append(values, v)
+	}
+	return values, true
+}
@@ -0,0 +1,35 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+)
+
+type UserStg struct {
+	crudStg[*model.User]
+}
+
+func NewUserStg(ctx context.Context, d *db) *UserStg {
+	return &UserStg{
+		crudStg: newCrudStg[*model.User](ctx, d),
+	}
+}
+
+func (stg *UserStg) FindByEmail(email string) (*model.User, error) {
+	users := stg.list(func(user *model.User) bool {
+		return user.Email == email
+	})
+	user, _ := lo.First(users)
+	return user, nil
+}
+
+func (stg *UserStg) FindByUuid(id uuid.UUID) (*model.User, error) {
+	users := stg.list(func(user *model.User) bool {
+		return user.ID == id
+	})
+	user, _ := lo.First(users)
+	return user, nil
+}
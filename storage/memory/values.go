@@ -0,0 +1,155 @@
+package memory
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm/schema"
+)
+
+var schemaCache = &sync.Map{}
+
+func getGormSchema(model any) *schema.Schema {
+	s, err := schema.Parse(model, schemaCache, schema.NamingStrategy{})
+	if err != nil {
+		panic(fmt.Sprintf("failed to parse the schema of %T: %v", model, err))
+	}
+	return s
+}
+
+// columnValue returns the value of the column of the model the way it is stored in the db, see sqlValue.
+func columnValue(ctx context.Context, m any, column string) any {
+	field := getGormSchema(m).LookUpField(column)
+	if field == nil {
+		return nil
+	}
+	value, _ := field.ValueOf(ctx, reflect.ValueOf(m))
+	return sqlValue(value)
+}
+
+// sqlValue normalizes a field value into a comparable value: nil for NULL, int64, float64, bool, string or a UTC
+// time with the microsecond precision of postgres.
+func sqlValue(value any) any {
+	if valuer, ok := value.(driver.Valuer); ok {
+		v := reflect.ValueOf(value)
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			return nil
+		}
+		var err error
+		if value, err = valuer.Value(); err != nil {
+			return nil
+		}
+	}
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		return v.UTC().Truncate(time.Microsecond)
+	case json.RawMessage:
+		if v == nil {
+			return nil
+		}
+		return string(v)
+	case []byte:
+		if v == nil {
+			return nil
+		}
+		return string(v)
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return sqlValue(v.Elem().Interface())
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	}
+	return value
+}
+
+// textValue formats the value the way postgres casts it to text. ok is false for NULL.
+func textValue(value any) (text string, ok bool) {
+	switch v := sqlValue(value).(type) {
+	case nil:
+		return "", false
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05.999999") + "+00", true
+	case bool:
+		if v {
+			return "true", true
+		}
+		return "false", true
+	default:
+		return fmt.Sprint(v), true
+	}
+}
+
+// compareValues orders the values like postgres does, the NULLs come last.
+func compareValues(a, b any) int {
+	a, b = sqlValue(a), sqlValue(b)
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	switch av := a.(type) {
+	case int64:
+		if bv, ok := b.(int64); ok {
+			return compareOrdered(av, bv)
+		}
+	case float64:
+		if bv, ok := b.(float64); ok {
+			return compareOrdered(av, bv)
+		}
+	case string:
+		if bv, ok := b.(string); ok {
+			return strings.Compare(av, bv)
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			return compareOrdered(boolToInt(av), boolToInt(bv))
+		}
+	case time.Time:
+		if bv, ok := b.(time.Time); ok {
+			return av.Compare(bv)
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func compareOrdered[T int | int64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
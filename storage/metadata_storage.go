@@ -1,8 +1,14 @@
 package storage
 
+// MetadataStorage introspects the schema of the db. The schema is cached, and it is read again from the db
+// once the cache expires or is invalidated, or the version of the applied migrations changes.
 type MetadataStorage interface {
 	ListTablesAndColumns() (tableToColumnsMap map[string][]string, err error)
 	ListTables() (tableNames []string, err error)
 	ListColumns(tableName string) (columnNames []string, err error)
+	// DescribeSchema returns the tables of the db with their columns, keys and indexes.
+	DescribeSchema() (*DbSchema, error)
+	// Invalidate drops the cached schema.
+	Invalidate()
 	RecordByValueExists(tableName, columnName string, value interface{}) (exists bool, err error)
 }
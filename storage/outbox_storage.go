@@ -0,0 +1,20 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+)
+
+type OutboxStorage interface {
+	CrudStorage[*model.OutboxEvent]
+
+	// ClaimDue lists the pending events which are due for delivery, in order, and postpones their next attempt by the
+	// lease, so the other relays skip them while they are delivered outside the transaction. The events locked by
+	// other transactions are skipped, so multiple relays can claim concurrently. The events whose results are not
+	// recorded, e.g. after a crash, are delivered again after the lease.
+	ClaimDue(limit int, lease time.Duration) (events []*model.OutboxEvent, err error)
+	MarkDelivered(id int64) error
+	// MarkFailed records a failed delivery attempt. The event is retried at nextAttemptAt unless it is dead.
+	MarkFailed(id int64, lastError string, nextAttemptAt time.Time, dead bool) error
+}
@@ -0,0 +1,54 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/pkg/errors"
+)
+
+type advisoryLock struct {
+	conn *sql.Conn
+	key  int64
+}
+
+func (stg *Stg) TryAdvisoryLock(ctx context.Context, name string) (lock storage.AdvisoryLock, acquired bool, err error) {
+	sqlDb, err := stg.db.DB()
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to get the db connection pool")
+	}
+
+	// session level locks belong to a connection, so the connection is taken out of the pool while the lock is held
+	conn, err := sqlDb.Conn(ctx)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to get a db connection")
+	}
+
+	key := advisoryLockKey(name)
+	err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired)
+	if err != nil || !acquired {
+		_ = conn.Close()
+		return nil, false, errors.Wrapf(err, "failed to acquire %q advisory lock", name)
+	}
+	return &advisoryLock{conn: conn, key: key}, true, nil
+}
+
+func (l *advisoryLock) Check(ctx context.Context) error {
+	return l.conn.PingContext(ctx)
+}
+
+func (l *advisoryLock) Release(ctx context.Context) error {
+	// closing the connection releases the lock even if the unlock fails
+	defer l.conn.Close()
+	_, err := l.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", l.key)
+	return err
+}
+
+// advisoryLockKey hashes the lock name into the 64-bit key space of the advisory locks.
+func advisoryLockKey(name string) int64 {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(name))
+	return int64(hash.Sum64())
+}
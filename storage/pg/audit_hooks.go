@@ -0,0 +1,82 @@
+package pg
+
+import (
+	"reflect"
+
+	"github.com/amahdian/golang-gin-boilerplate/pkg/actor"
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+)
+
+const (
+	auditCreateHookName = "app_audit_create_hook"
+	auditUpdateHookName = "app_audit_update_hook"
+
+	createdByColumnName = "created_by"
+	updatedByColumnName = "updated_by"
+)
+
+// registerAuditHooks fills the created_by and updated_by columns of the models embedding common.Audit
+// from the actor of the statement context.
+func registerAuditHooks(db *gorm.DB) {
+	if db.Callback().Create().Get(auditCreateHookName) == nil {
+		_ = db.Callback().Create().Before("gorm:create").Register(auditCreateHookName, func(db *gorm.DB) {
+			userId, ok := auditUserId(db)
+			if !ok {
+				return
+			}
+			// keep the explicitly provided values e.g. the ones set by seeds or imports
+			setZeroAuditColumn(db, createdByColumnName, userId)
+			setZeroAuditColumn(db, updatedByColumnName, userId)
+		})
+	}
+
+	if db.Callback().Update().Get(auditUpdateHookName) == nil {
+		_ = db.Callback().Update().Before("gorm:update").Register(auditUpdateHookName, func(db *gorm.DB) {
+			userId, ok := auditUserId(db)
+			if !ok {
+				return
+			}
+			stmt := db.Statement
+			if stmt.Schema.LookUpField(updatedByColumnName) == nil {
+				return
+			}
+			stmt.SetColumn(updatedByColumnName, &userId, true)
+			// make sure the column is updated when only a subset of the columns are selected
+			if len(stmt.Selects) > 0 && !lo.Contains(stmt.Selects, "*") && !lo.Contains(stmt.Selects, updatedByColumnName) {
+				stmt.Selects = append(stmt.Selects, updatedByColumnName)
+			}
+		})
+	}
+}
+
+func auditUserId(db *gorm.DB) (uuid.UUID, bool) {
+	if db.Error != nil || db.Statement.Schema == nil || db.Statement.Context == nil {
+		return uuid.Nil, false
+	}
+	userId := actor.UserIdFromCtx(db.Statement.Context)
+	return userId, userId != uuid.Nil
+}
+
+func setZeroAuditColumn(db *gorm.DB, columnName string, userId uuid.UUID) {
+	field := db.Statement.Schema.LookUpField(columnName)
+	if field == nil {
+		return
+	}
+
+	setIfZero := func(rv reflect.Value) {
+		if _, isZero := field.ValueOf(db.Statement.Context, rv); isZero {
+			_ = db.AddError(field.Set(db.Statement.Context, rv, &userId))
+		}
+	}
+
+	switch db.Statement.ReflectValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < db.Statement.ReflectValue.Len(); i++ {
+			setIfZero(reflect.Indirect(db.Statement.ReflectValue.Index(i)))
+		}
+	case reflect.Struct:
+		setIfZero(db.Statement.ReflectValue)
+	}
+}
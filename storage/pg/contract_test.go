@@ -0,0 +1,21 @@
+package pg
+
+import (
+	"os"
+	"testing"
+
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/amahdian/golang-gin-boilerplate/storage/storagetest"
+)
+
+// TestStgContract runs the storage contract against the migrated db of TEST_DB_DSN, see the setup-test-db target of the
+// Makefile. It is skipped when there is no test db.
+func TestStgContract(t *testing.T) {
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DB_DSN is not set")
+	}
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return NewStg(OpenGormTestDb(t, dsn, Silent))
+	})
+}
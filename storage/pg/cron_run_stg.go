@@ -0,0 +1,85 @@
+package pg
+
+import (
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/domain/model/common"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CronRunStg struct {
+	crudStg[*model.CronRun]
+}
+
+func NewCronRunStg(ses *ormSession) *CronRunStg {
+	return &CronRunStg{
+		crudStg: crudStg[*model.CronRun]{db: ses.db, readDb: ses.readDb},
+	}
+}
+
+func (stg *CronRunStg) Start(run *model.CronRun) (started bool, err error) {
+	// the conflict target must match the partial unique index of the scheduled occurrences
+	db := stg.db.
+		Clauses(clause.OnConflict{
+			Columns:     []clause.Column{{Name: "task_name"}, {Name: "scheduled_at"}},
+			TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Eq{Column: "trigger", Value: model.CronRunScheduled}}},
+			DoNothing:   true,
+		}).
+		Create(run)
+	return db.RowsAffected > 0, db.Error
+}
+
+func (stg *CronRunStg) Finish(id int64, status model.CronRunStatus, runErr string) error {
+	return stg.db.
+		Model(&model.CronRun{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"status":      status,
+			"error":       runErr,
+			"finished_at": time.Now(),
+		}).
+		Error
+}
+
+func (stg *CronRunStg) FindLast(taskName string) (*model.CronRun, error) {
+	run := &model.CronRun{}
+	err := stg.reader().
+		Where("task_name = ?", taskName).
+		Order("started_at DESC").
+		First(run).
+		Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return run, err
+}
+
+func (stg *CronRunStg) FindLastScheduled(taskName string) (*model.CronRun, error) {
+	run := &model.CronRun{}
+	// the leader schedules the next occurrence from it, so it is not read from a lagging replica
+	err := stg.db.
+		Where("task_name = ? AND trigger = ?", taskName, model.CronRunScheduled).
+		Order("scheduled_at DESC").
+		First(run).
+		Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return run, err
+}
+
+func (stg *CronRunStg) ListByTask(taskName string, pagination *common.Pagination, fieldNames []string) (runs []*model.CronRun, err error) {
+	query := stg.reader().Model(&model.CronRun{})
+	if taskName != "" {
+		query = query.Where("task_name = ?", taskName)
+	}
+	if pagination == nil || len(pagination.SortFields()) == 0 {
+		query = query.Order("started_at DESC")
+	}
+	// the field selection must come after the pagination so the total count is not affected by it
+	err = query.Scopes(stg.withPagination(pagination), stg.withFields(fieldNames)).Find(&runs).Error
+	return
+}
@@ -1,15 +1,24 @@
 package pg
 
 import (
+	"context"
 	"fmt"
+	"iter"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/amahdian/golang-gin-boilerplate/domain/model/common"
 	"github.com/amahdian/golang-gin-boilerplate/global"
 	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/amahdian/golang-gin-boilerplate/pkg/actor"
 	"github.com/amahdian/golang-gin-boilerplate/pkg/logger"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
 	"github.com/gertd/go-pluralize"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/pkg/errors"
 	"github.com/samber/lo"
 	"gorm.io/gorm"
@@ -19,8 +28,17 @@ import (
 
 var pluralizer = pluralize.NewClient()
 
+const (
+	softDeleteColumnName = "deleted_at"
+	versionColumnName    = "version"
+
+	defaultIterateBatchSize = 1000
+)
+
 type crudStg[M schema.Tabler] struct {
 	db *gorm.DB
+	// readDb runs the read-only queries on a replica. It is nil if the reads must go to the primary.
+	readDb *gorm.DB
 
 	tableName          string
 	columnNames        []string
@@ -29,7 +47,7 @@ type crudStg[M schema.Tabler] struct {
 
 func (stg *crudStg[M]) CreateOne(model M) error {
 	err := stg.db.Create(model).Error
-	return err
+	return stg.translateWriteErr(err)
 }
 
 func (stg *crudStg[M]) CreateMany(models []M) error {
@@ -37,11 +55,123 @@ func (stg *crudStg[M]) CreateMany(models []M) error {
 		return nil
 	}
 	err := stg.db.Create(&models).Error
-	return err
+	return stg.translateWriteErr(err)
+}
+
+func (stg *crudStg[M]) CreateInBatches(models []M, batchSize int) (rowErrs []storage.RowError, err error) {
+	if batchSize < 1 {
+		return nil, errs.Newf(errs.InvalidArgument, nil, "the batch size must be positive, got %d", batchSize)
+	}
+
+	ctx := stg.db.Statement.Context
+	for start := 0; start < len(models); start += batchSize {
+		batch := models[start:min(start+batchSize, len(models))]
+		// every batch is inserted in its own transaction, or savepoint if it is already in a transaction,
+		// so a failed row only rolls back its own batch
+		err = stg.db.Transaction(func(tx *gorm.DB) error {
+			return tx.Create(&batch).Error
+		})
+		if err == nil {
+			continue
+		}
+		if ctx.Err() != nil {
+			return rowErrs, err
+		}
+
+		// find the failed rows by inserting the rows of the batch one by one
+		for i, model := range batch {
+			err = stg.db.Transaction(func(tx *gorm.DB) error {
+				return tx.Create(model).Error
+			})
+			if err == nil {
+				continue
+			}
+			if ctx.Err() != nil {
+				return rowErrs, err
+			}
+			rowErrs = append(rowErrs, storage.RowError{Index: start + i, Err: err})
+		}
+	}
+	return rowErrs, nil
+}
+
+func (stg *crudStg[M]) CopyFrom(models []M) (copied int64, err error) {
+	if len(models) == 0 {
+		return 0, nil
+	}
+	if _, ok := stg.db.Statement.ConnPool.(gorm.TxCommitter); ok {
+		return 0, errs.Newf(errs.FailedPrecondition, nil, "copy from cannot be used inside a transaction")
+	}
+
+	tableName := stg.getTableName()
+	fields, err := stg.getCopyFields(models)
+	if err != nil {
+		return 0, err
+	}
+	columnNames := lo.Map(fields, func(f *schema.Field, _ int) string {
+		return f.DBName
+	})
+
+	ctx := stg.db.Statement.Context
+	conn, ok := stg.db.Statement.ConnPool.(rawConn)
+	if !ok {
+		sqlDb, err := stg.db.DB()
+		if err != nil {
+			return 0, errors.Wrap(err, "failed to get underlying sql db from gorm")
+		}
+		sqlConn, err := sqlDb.Conn(ctx)
+		if err != nil {
+			return 0, errs.Wrapf(err, "failed to get a connection to copy %s", tableName)
+		}
+		defer sqlConn.Close()
+		conn = sqlConn
+	}
+
+	now := time.Now()
+	err = conn.Raw(func(driverConn any) error {
+		pgxConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return errors.Errorf("copy from is not supported by the %T driver", driverConn)
+		}
+		rows := pgx.CopyFromSlice(len(models), func(i int) ([]any, error) {
+			return copyRowValues(ctx, fields, reflect.ValueOf(models[i]), now), nil
+		})
+		copied, err = pgxConn.Conn().CopyFrom(ctx, pgx.Identifier{tableName}, columnNames, rows)
+		return err
+	})
+	if err != nil {
+		return copied, errs.Wrapf(err, "failed to copy %s", tableName)
+	}
+	return copied, nil
+}
+
+// rawConn gives access to the driver connection, e.g. *sql.Conn. The connection pools which are pinned to a single
+// connection (e.g. the test dbs in the transaction mode) implement it, so the rows are copied on that connection.
+type rawConn interface {
+	Raw(f func(driverConn any) error) error
+}
+
+func (stg *crudStg[M]) UpsertOne(model M, opts storage.UpsertOptions) error {
+	upsertClauses, err := stg.upsertClauses(opts)
+	if err != nil {
+		return err
+	}
+	return stg.translateWriteErr(stg.db.Clauses(upsertClauses...).Create(model).Error)
+}
+
+func (stg *crudStg[M]) UpsertMany(models []M, opts storage.UpsertOptions) error {
+	if len(models) == 0 {
+		return nil
+	}
+	upsertClauses, err := stg.upsertClauses(opts)
+	if err != nil {
+		return err
+	}
+	return stg.translateWriteErr(stg.db.Clauses(upsertClauses...).Create(&models).Error)
 }
 
 func (stg *crudStg[M]) FindById(id int64) (model M, err error) {
-	err = stg.db.First(&model, id).Error
+	err = stg.reader().First(&model, id).Error
 	if err != nil {
 		tableName := stg.getTableName()
 		entryName := pluralizer.Singular(tableName)
@@ -62,7 +192,7 @@ func (stg *crudStg[M]) ListByIds(ids []int64) (models []M, err error) {
 		return make([]M, 0), nil
 	}
 
-	err = stg.db.Where("id in ?", ids).Find(&models).Error
+	err = stg.reader().Where("id in ?", ids).Find(&models).Error
 	return
 }
 
@@ -71,8 +201,12 @@ func (stg *crudStg[M]) UpdateOne(model M, saveAssociations bool) error {
 	if saveAssociations {
 		query = query.Session(&gorm.Session{FullSaveAssociations: true})
 	}
+	if versioned, ok := any(model).(common.VersionedModel); ok {
+		// select all the fields to keep the same semantics as Save
+		return stg.updateVersioned(query.Model(model).Select("*"), model, versioned)
+	}
 	err := query.Debug().Save(model).Error
-	return err
+	return stg.translateWriteErr(err)
 }
 
 func (stg *crudStg[M]) UpdatePartial(model M, returnUpdated bool) error {
@@ -80,15 +214,68 @@ func (stg *crudStg[M]) UpdatePartial(model M, returnUpdated bool) error {
 	if returnUpdated {
 		query.Clauses(clause.Returning{})
 	}
+	if versioned, ok := any(model).(common.VersionedModel); ok {
+		return stg.updateVersioned(query, model, versioned)
+	}
 	err := query.Updates(model).Error
-	return err
+	return stg.translateWriteErr(err)
+}
+
+// updateVersioned updates the model only if its version matches the stored version and increments the version.
+// The version of the model is left untouched if the update fails.
+func (stg *crudStg[M]) updateVersioned(query *gorm.DB, model M, versioned common.VersionedModel) error {
+	entryName := pluralizer.Singular(stg.getTableName())
+
+	version := versioned.GetVersion()
+	if version == 0 {
+		return errs.Newf(errs.InvalidArgument, nil, "the version of %q entry is required for the update", entryName)
+	}
+
+	versioned.SetVersion(version + 1)
+	db := query.Where(fmt.Sprintf("%s = ?", versionColumnName), version).Updates(model)
+	if db.Error != nil {
+		versioned.SetVersion(version)
+		return stg.translateWriteErr(db.Error)
+	}
+	if db.RowsAffected < 1 {
+		versioned.SetVersion(version)
+		return errs.Newf(errs.Conflict, storage.ErrStaleVersion, "%q entry with version %d has been modified or deleted by another request", entryName, version)
+	}
+	return nil
 }
 
 func (stg *crudStg[M]) UpdateMany(models []M) error {
 	if len(models) == 0 {
 		return nil
 	}
+	var model M
+	if _, ok := any(model).(common.VersionedModel); ok {
+		return stg.updateManyVersioned(models)
+	}
 	err := stg.db.Save(&models).Error
+	return stg.translateWriteErr(err)
+}
+
+// updateManyVersioned updates the models one by one with their version checks in a transaction, so a stale model
+// fails the whole batch. The versions of the models are left untouched if the update fails.
+func (stg *crudStg[M]) updateManyVersioned(models []M) error {
+	versions := lo.Map(models, func(m M, _ int) int64 {
+		return any(m).(common.VersionedModel).GetVersion()
+	})
+	err := stg.db.Transaction(func(tx *gorm.DB) error {
+		for _, m := range models {
+			// select all the fields to keep the same semantics as Save
+			if err := stg.updateVersioned(tx.Model(m).Select("*"), m, any(m).(common.VersionedModel)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		for i, m := range models {
+			any(m).(common.VersionedModel).SetVersion(versions[i])
+		}
+	}
 	return err
 }
 
@@ -99,7 +286,7 @@ func (stg *crudStg[M]) ExistsById(id int64) (exists bool, err error) {
 
 	if err != nil {
 		exists = false
-		if errors.As(err, &errs.EntryNotFoundErr{}) {
+		if errs.Code(err) == errs.NotFound {
 			// Do not propagate the record not found error
 			err = nil
 		}
@@ -117,7 +304,7 @@ func (stg *crudStg[M]) DeleteById(id int64) error {
 	if db.RowsAffected < 1 {
 		tableName := stg.getTableName()
 		entryName := pluralizer.Singular(tableName)
-		return errs.NewEntryNotFoundErr(fmt.Sprintf(errs.DefaultEntryNotFoundMessage, entryName, id))
+		return errs.Newf(errs.NotFound, nil, errs.DefaultEntryNotFoundMessage, entryName, id)
 	}
 	return nil
 }
@@ -127,11 +314,176 @@ func (stg *crudStg[M]) DeleteByIds(ids []int64) error {
 	return stg.db.Where("id in ?", ids).Delete(&model).Error
 }
 
+func (stg *crudStg[M]) Delete(model M) error {
+	key, err := stg.primaryKey(model, "deleted")
+	if err != nil {
+		return err
+	}
+
+	db := stg.db.Delete(model)
+	if db.Error != nil {
+		return db.Error
+	}
+	if db.RowsAffected < 1 {
+		entryName := pluralizer.Singular(stg.getTableName())
+		return errs.Newf(errs.NotFound, nil, "%q entry by id %v could not be found", entryName, key)
+	}
+	return nil
+}
+
+func (stg *crudStg[M]) Restore(model M) error {
+	if err := stg.ensureSoftDeletable(); err != nil {
+		return err
+	}
+	key, err := stg.primaryKey(model, "restored")
+	if err != nil {
+		return err
+	}
+
+	entryName := pluralizer.Singular(stg.getTableName())
+	keyColumnName := getGormSchema(&model).PrimaryFields[0].DBName
+	restored := newModel[M]()
+	err = stg.db.
+		Unscoped().
+		Where(fmt.Sprintf("%s = ? AND %s IS NOT NULL", keyColumnName, softDeleteColumnName), key).
+		First(restored).
+		Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errs.Newf(errs.NotFound, nil, "deleted %q entry by id %v could not be found", entryName, key)
+	}
+	if err != nil {
+		return errs.Wrapf(err, "failed to get the deleted %s", entryName)
+	}
+
+	// the restore goes through the updates, so the update time and user are filled and the version is incremented
+	deletedAt := getGormSchema(&model).LookUpField(softDeleteColumnName)
+	deletedAt.ReflectValueOf(stg.db.Statement.Context, reflect.ValueOf(restored)).Set(reflect.Zero(deletedAt.FieldType))
+	query := stg.db.Unscoped().Model(restored)
+	if versioned, ok := any(restored).(common.VersionedModel); ok {
+		err = stg.updateVersioned(query.Select(softDeleteColumnName, versionColumnName), restored, versioned)
+	} else {
+		err = stg.translateWriteErr(query.Select(softDeleteColumnName).Updates(restored).Error)
+	}
+	if err != nil {
+		return err
+	}
+	reflect.ValueOf(model).Elem().Set(reflect.ValueOf(restored).Elem())
+	return nil
+}
+
+// primaryKey returns the value of the single primary key of the model, which the model is deleted or restored by.
+func (stg *crudStg[M]) primaryKey(model M, action string) (any, error) {
+	s := getGormSchema(&model)
+	if len(s.PrimaryFields) != 1 {
+		return nil, errs.Newf(errs.FailedPrecondition, nil, "%q table must have a single primary key to be %s", stg.getTableName(), action)
+	}
+	key, zero := s.PrimaryFields[0].ValueOf(stg.db.Statement.Context, reflect.ValueOf(model))
+	if zero {
+		entryName := pluralizer.Singular(stg.getTableName())
+		return nil, errs.Newf(errs.InvalidArgument, nil, "%q entry can't be %s without its primary key", entryName, action)
+	}
+	return key, nil
+}
+
+func (stg *crudStg[M]) Purge(deletedBefore time.Time) (purged int64, err error) {
+	if err = stg.ensureSoftDeletable(); err != nil {
+		return
+	}
+
+	var model M
+	db := stg.db.
+		Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", deletedBefore).
+		Delete(&model)
+	return db.RowsAffected, db.Error
+}
+
 func (stg *crudStg[M]) ListAll() (models []M, err error) {
-	err = stg.db.Find(&models).Error
+	err = stg.reader().Find(&models).Error
 	return
 }
 
+func (stg *crudStg[M]) Search(params *common.SearchParams) (models []M, err error) {
+	err = stg.reader().Scopes(stg.withSearch(params)).Find(&models).Error
+	return
+}
+
+func (stg *crudStg[M]) Iterate(params *common.SearchParams, batchSize int) iter.Seq2[M, error] {
+	return func(yield func(M, error) bool) {
+		var zero M
+		if batchSize <= 0 {
+			batchSize = defaultIterateBatchSize
+		}
+		keyField, err := stg.getKeysetField()
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+
+		var filters []*common.FieldFilter
+		var fieldNames []string
+		if params != nil {
+			filters = params.Filters
+			fieldNames = params.FieldNames()
+			if len(fieldNames) > 0 {
+				// the key of the last model of the chunk is needed to query the next chunk
+				fieldNames = append(fieldNames, keyField.DBName)
+			}
+		}
+
+		ctx := stg.db.Statement.Context
+		// the chunks are read from the same db, so a replica does not change in the middle of the iteration
+		reader := stg.reader()
+		var lastKey any
+		for {
+			query := reader.
+				Scopes(stg.withSearchFilters(filters), stg.withFields(fieldNames)).
+				Order(clause.OrderByColumn{Column: clause.Column{Name: keyField.DBName}}).
+				Limit(batchSize)
+			if lastKey != nil {
+				query = query.Where(clause.Gt{Column: clause.Column{Name: keyField.DBName}, Value: lastKey})
+			}
+
+			var models []M
+			if err = query.Find(&models).Error; err != nil {
+				yield(zero, errs.Wrapf(err, "failed to iterate %s", stg.getTableName()))
+				return
+			}
+			for _, model := range models {
+				if !yield(model, nil) {
+					return
+				}
+			}
+			if len(models) < batchSize {
+				return
+			}
+			lastKey, _ = keyField.ValueOf(ctx, reflect.ValueOf(models[len(models)-1]))
+		}
+	}
+}
+
+// translateWriteErr turns the unique violations into Conflict errors, and the upserts without a matching unique index
+// into InvalidArgument errors, so the callers don't depend on the pg errors.
+func (stg *crudStg[M]) translateWriteErr(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+		entryName := pluralizer.Singular(stg.getTableName())
+		return errs.Newf(errs.Conflict, err, "%q entry conflicts with an existing entry (%s)", entryName, pgErr.ConstraintName)
+	}
+	if errors.As(err, &pgErr) && pgErr.Code == invalidColumnReferenceCode {
+		return errs.Newf(errs.InvalidArgument, err, "there is no unique index of %q matching the conflict target", stg.getTableName())
+	}
+	return err
+}
+
+// reader returns the db of the read-only queries, i.e. a replica if there is one available, otherwise the primary.
+func (stg *crudStg[M]) reader() *gorm.DB {
+	if stg.readDb != nil {
+		return stg.readDb
+	}
+	return stg.db
+}
+
 func (stg *crudStg[M]) withPagination(pagination *common.Pagination, tableAlias ...string) gormScope {
 	return func(db *gorm.DB) *gorm.DB {
 		// disable pagination for internal API calls
@@ -148,15 +500,15 @@ func (stg *crudStg[M]) withPagination(pagination *common.Pagination, tableAlias
 
 		// count the result in a separate query session to prevent polluting the original query
 		db.Session(&gorm.Session{}).Count(&pagination.TotalCount)
-		if pagination.OrderBy != "" {
-			fieldName := pagination.OrderBy
-			tagToColumnNameMap := stg.getTagToColumnNameMap()
-			columnName, ok := tagToColumnNameMap[fieldName]
+
+		tagToColumnNameMap := stg.getTagToColumnNameMap()
+		for _, sortField := range pagination.SortFields() {
+			columnName, ok := tagToColumnNameMap[sortField.FieldName]
 			if !ok {
-				db.AddError(fmt.Errorf("invalid field %q for search condition", fieldName))
+				db.AddError(errs.NewInvalidSortFieldErr(sortField.FieldName))
 				return db
 			}
-			db.Order(fmt.Sprintf("%s%s %s", prefix, columnName, pagination.Order))
+			db.Order(fmt.Sprintf("%s%s %s", prefix, columnName, sortField.Order))
 		}
 
 		db.Limit(pagination.PageSize)
@@ -206,11 +558,38 @@ func (stg *crudStg[M]) withSearchFilters(filters []*common.FieldFilter, tableAli
 	}
 }
 
+// withFields limits the selected columns to the given json field names (or column names).
+// All columns are selected when no field is given.
+func (stg *crudStg[M]) withFields(fieldNames []string, tableAlias ...string) gormScope {
+	return func(db *gorm.DB) *gorm.DB {
+		if len(fieldNames) == 0 {
+			return db
+		}
+
+		prefix := getTablePrefix(tableAlias...)
+
+		tagToColumnNameMap := stg.getTagToColumnNameMap()
+		columnNames := make([]string, 0, len(fieldNames))
+		for _, fieldName := range fieldNames {
+			columnName, ok := tagToColumnNameMap[fieldName]
+			if !ok {
+				db.AddError(errs.NewInvalidSelectFieldErr(fieldName))
+				return db
+			}
+			columnNames = append(columnNames, prefix+columnName)
+		}
+
+		return db.Select(lo.Uniq(columnNames))
+	}
+}
+
 func (stg *crudStg[M]) withSearch(params *common.SearchParams, tableAlias ...string) gormScope {
 	return func(db *gorm.DB) *gorm.DB {
+		// the field selection must come after the pagination so the total count is not affected by it
 		return db.Scopes(
 			stg.withSearchFilters(params.Filters, tableAlias...),
 			stg.withPagination(params.Pagination, tableAlias...),
+			stg.withFields(params.FieldNames(), tableAlias...),
 		)
 	}
 }
@@ -232,6 +611,9 @@ func (stg *crudStg[M]) updateElements(elements any) error {
 
 	if elementType == modelType {
 		// since elementType and modelType are the same we don't need to do anything fancy. leave the rest to gorm
+		if models, ok := elements.([]M); ok {
+			return stg.UpdateMany(models)
+		}
 		err := stg.db.Save(elements).Error
 		return err
 	} else {
@@ -258,6 +640,10 @@ func (stg *crudStg[M]) updateElements(elements any) error {
 			return fmt.Errorf("cannot perform batch update on table %q because of invalid columns: %q", tableName, strings.Join(invalidColumnNames, ", "))
 		}
 
+		if _, ok := any(model).(common.VersionedModel); ok {
+			return stg.updateElementsVersioned(reflect.ValueOf(elements), elementSchema.Fields)
+		}
+
 		err := stg.db.
 			Table(tableName).
 			Select(queryColumnNames).
@@ -267,6 +653,231 @@ func (stg *crudStg[M]) updateElements(elements any) error {
 	}
 }
 
+// updateElementsVersioned updates the columns of the elements one by one with their version checks in a transaction,
+// so a stale element fails the whole batch. The versions of the elements are incremented if the update succeeds.
+func (stg *crudStg[M]) updateElementsVersioned(elements reflect.Value, fields []*schema.Field) error {
+	tableName := stg.getTableName()
+	entryName := pluralizer.Singular(tableName)
+	versionField, ok := lo.Find(fields, func(f *schema.Field) bool {
+		return f.DBName == versionColumnName
+	})
+	if !ok {
+		return fmt.Errorf("cannot perform batch update on the versioned table %q without %q column", tableName, versionColumnName)
+	}
+
+	ctx := stg.db.Statement.Context
+	versions := make([]int64, elements.Len())
+	err := stg.db.Transaction(func(tx *gorm.DB) error {
+		for i := 0; i < elements.Len(); i++ {
+			element := elements.Index(i)
+			values := make(map[string]any, len(fields))
+			for _, f := range fields {
+				values[f.DBName], _ = f.ValueOf(ctx, element)
+			}
+			id := values["id"]
+			version, _ := values[versionColumnName].(int64)
+			if version == 0 {
+				return errs.Newf(errs.InvalidArgument, nil, "the version of %q entry is required for the update", entryName)
+			}
+			versions[i] = version
+
+			delete(values, "id")
+			values[versionColumnName] = gorm.Expr(fmt.Sprintf("%s + 1", versionColumnName))
+			// the model (rather than the table) runs the hooks of the model, e.g. the update time and the audit columns
+			db := tx.
+				Model(newModel[M]()).
+				Where(fmt.Sprintf("id = ? AND %s = ?", versionColumnName), id, version).
+				Updates(values)
+			if db.Error != nil {
+				return stg.translateWriteErr(db.Error)
+			}
+			if db.RowsAffected < 1 {
+				return errs.Newf(errs.Conflict, storage.ErrStaleVersion, "%q entry with version %d has been modified or deleted by another request", entryName, version)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for i, version := range versions {
+		if err = versionField.Set(ctx, elements.Index(i), version+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getKeysetField returns the field the models are iterated by, i.e. the primary key.
+func (stg *crudStg[M]) getKeysetField() (*schema.Field, error) {
+	var model M
+	s := getGormSchema(&model)
+	if len(s.PrimaryFields) != 1 {
+		return nil, errs.Newf(errs.FailedPrecondition, nil, "%q table must have a single primary key to be iterated", stg.getTableName())
+	}
+	return s.PrimaryFields[0], nil
+}
+
+// upsertClauses builds the conflict clause of the upserts from the given options. The versions of the versioned
+// models are incremented on conflict, and returned so the models hold the stored versions.
+func (stg *crudStg[M]) upsertClauses(opts storage.UpsertOptions) ([]clause.Expression, error) {
+	var model M
+	s := getGormSchema(&model)
+	conflictColumnNames, err := stg.toColumnNames(opts.ConflictColumns)
+	if err != nil {
+		return nil, err
+	}
+	if len(conflictColumnNames) == 0 {
+		conflictColumnNames = s.PrimaryFieldDBNames
+	}
+
+	onConflict := clause.OnConflict{
+		Columns: lo.Map(conflictColumnNames, func(name string, _ int) clause.Column {
+			return clause.Column{Name: name}
+		}),
+	}
+	if opts.ConflictWhere != "" {
+		onConflict.TargetWhere = clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: opts.ConflictWhere}}}
+	}
+	if opts.DoNothing {
+		onConflict.DoNothing = true
+		return []clause.Expression{onConflict}, nil
+	}
+
+	updateColumnNames, err := stg.toColumnNames(opts.UpdateColumns)
+	if err != nil {
+		return nil, err
+	}
+	if len(updateColumnNames) == 0 {
+		updateColumnNames = stg.getUpsertColumnNames()
+	}
+	onConflict.DoUpdates = clause.AssignmentColumns(updateColumnNames)
+	if _, ok := any(model).(common.VersionedModel); !ok || lo.Contains(updateColumnNames, versionColumnName) {
+		return []clause.Expression{onConflict}, nil
+	}
+
+	// the existing row is referred to by the table name in the updates of the conflicts
+	onConflict.DoUpdates = append(onConflict.DoUpdates, clause.Assignment{
+		Column: clause.Column{Name: versionColumnName},
+		Value:  clause.Expr{SQL: "? + 1", Vars: []any{clause.Column{Table: stg.getTableName(), Name: versionColumnName}}},
+	})
+	returning := clause.Returning{Columns: []clause.Column{{Name: versionColumnName}}}
+	for _, field := range s.FieldsWithDefaultDBValue {
+		returning.Columns = append(returning.Columns, clause.Column{Name: field.DBName})
+	}
+	return []clause.Expression{onConflict, returning}, nil
+}
+
+// getUpsertColumnNames returns the columns updated by the upserts by default. The primary key, the generated columns,
+// the creation time and user, the deletion time and the version of the existing rows are kept.
+func (stg *crudStg[M]) getUpsertColumnNames() []string {
+	var model M
+	keptColumnNames := []string{createdByColumnName, softDeleteColumnName, versionColumnName}
+	columnNames := make([]string, 0)
+	for _, field := range getGormSchema(&model).Fields {
+		if field.DBName == "" || !field.Updatable || field.PrimaryKey || field.AutoCreateTime > 0 ||
+			hasDbFunctionDefault(field) || lo.Contains(keptColumnNames, field.DBName) {
+			continue
+		}
+		columnNames = append(columnNames, field.DBName)
+	}
+	return columnNames
+}
+
+// toColumnNames maps the given json field names (or column names) to the column names.
+func (stg *crudStg[M]) toColumnNames(fieldNames []string) ([]string, error) {
+	tagToColumnNameMap := stg.getTagToColumnNameMap()
+	columnNames := make([]string, 0, len(fieldNames))
+	for _, fieldName := range fieldNames {
+		columnName, ok := tagToColumnNameMap[fieldName]
+		if !ok {
+			return nil, errs.NewInvalidUpsertFieldErr(fieldName)
+		}
+		columnNames = append(columnNames, columnName)
+	}
+	return lo.Uniq(columnNames), nil
+}
+
+// getCopyFields returns the fields copied by CopyFrom. The fields with a db function default (e.g. a generated id)
+// are left to the db if they are not set on any of the models.
+func (stg *crudStg[M]) getCopyFields(models []M) ([]*schema.Field, error) {
+	var model M
+	s := getGormSchema(&model)
+	ctx := stg.db.Statement.Context
+
+	fields := make([]*schema.Field, 0, len(s.Fields))
+	for _, field := range s.Fields {
+		if field.DBName == "" || !field.Creatable {
+			continue
+		}
+		if hasDbFunctionDefault(field) {
+			setCount := lo.CountBy(models, func(m M) bool {
+				_, isZero := field.ValueOf(ctx, reflect.ValueOf(m))
+				return !isZero
+			})
+			if setCount == 0 {
+				continue
+			}
+			if setCount != len(models) {
+				return nil, errs.Newf(errs.InvalidArgument, nil, "the %q column must be set on all the %s or none of them", field.DBName, stg.getTableName())
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// copyRowValues returns the values of the fields of the model. The zero values are replaced by the default values
+// of the fields, the zero timestamps are set to now, and the zero audit users are set to the actor of ctx,
+// the same way as the audit hooks of the inserts.
+func copyRowValues(ctx context.Context, fields []*schema.Field, modelValue reflect.Value, now time.Time) []any {
+	userId := actor.UserIdFromCtx(ctx)
+	values := make([]any, 0, len(fields))
+	for _, field := range fields {
+		value, isZero := field.ValueOf(ctx, modelValue)
+		if isZero {
+			switch {
+			case (field.DBName == createdByColumnName || field.DBName == updatedByColumnName) && userId != uuid.Nil:
+				value = &userId
+			case field.DefaultValueInterface != nil:
+				value = field.DefaultValueInterface
+			case field.AutoCreateTime > 0:
+				value = timestampValue(field, field.AutoCreateTime, now)
+			case field.AutoUpdateTime > 0:
+				value = timestampValue(field, field.AutoUpdateTime, now)
+			}
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+// timestampValue returns now in the type of the auto create or update time field, the same way gorm fills them.
+func timestampValue(field *schema.Field, timeType schema.TimeType, now time.Time) any {
+	if field.DataType == schema.Time {
+		return now
+	}
+	switch timeType {
+	case schema.UnixNanosecond:
+		return now.UnixNano()
+	case schema.UnixMillisecond:
+		return now.UnixMilli()
+	default:
+		return now.Unix()
+	}
+}
+
+func hasDbFunctionDefault(field *schema.Field) bool {
+	return field.HasDefaultValue && field.DefaultValueInterface == nil
+}
+
+func (stg *crudStg[M]) ensureSoftDeletable() error {
+	if !lo.Contains(stg.getColumnNames(), softDeleteColumnName) {
+		return errs.Newf(errs.FailedPrecondition, nil, "%q table does not support soft deletes", stg.getTableName())
+	}
+	return nil
+}
+
 func (stg *crudStg[M]) getColumnNames() []string {
 	if stg.columnNames != nil {
 		return stg.columnNames
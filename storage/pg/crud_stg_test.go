@@ -0,0 +1,236 @@
+package pg
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/domain/model/common"
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/amahdian/golang-gin-boilerplate/pkg/actor"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/google/uuid"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+func TestCrudStgUpsert(t *testing.T) {
+	var sql string
+	db := openLazyDb(t)
+	require.NoError(t, db.Callback().Create().After("gorm:create").Register("test_capture_sql", func(db *gorm.DB) {
+		sql = db.Statement.SQL.String()
+	}))
+	stg := &crudStg[*model.User]{db: db.Session(&gorm.Session{DryRun: true, SkipDefaultTransaction: true})}
+
+	tests := []struct {
+		name    string
+		opts    storage.UpsertOptions
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "updates all the columns but the creation, deletion and version on primary key conflict by default",
+			opts: storage.UpsertOptions{},
+			want: `ON CONFLICT ("id") DO UPDATE SET "email"="excluded"."email","password_hash"="excluded"."password_hash",` +
+				`"updated_at"="excluded"."updated_at","updated_by"="excluded"."updated_by","version"="users"."version" + 1 ` +
+				`RETURNING "version","id"`,
+		},
+		{
+			name: "updates the given columns on the given partial index",
+			opts: storage.UpsertOptions{
+				ConflictColumns: []string{"email"},
+				ConflictWhere:   "deleted_at IS NULL",
+				UpdateColumns:   []string{"password_hash", "updated_at"},
+			},
+			want: `ON CONFLICT ("email")  WHERE deleted_at IS NULL DO UPDATE SET "password_hash"="excluded"."password_hash",` +
+				`"updated_at"="excluded"."updated_at","version"="users"."version" + 1`,
+		},
+		{
+			name: "does nothing on conflict",
+			opts: storage.UpsertOptions{ConflictColumns: []string{"email"}, ConflictWhere: "deleted_at IS NULL", DoNothing: true},
+			want: `ON CONFLICT ("email")  WHERE deleted_at IS NULL DO NOTHING`,
+		},
+		{
+			name:    "rejects unknown columns",
+			opts:    storage.UpsertOptions{ConflictColumns: []string{"unknown"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &model.User{Email: "john@example.com"}
+			err := stg.UpsertOne(user, tt.opts)
+			if tt.wantErr {
+				assert.Equal(t, errs.InvalidArgument, errs.Code(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Contains(t, sql, tt.want)
+		})
+	}
+}
+
+func TestCrudStgUpdateVersioned(t *testing.T) {
+	var sql string
+	db := openLazyDb(t)
+	require.NoError(t, db.Callback().Update().After("gorm:update").Register("test_capture_sql", func(db *gorm.DB) {
+		sql = db.Statement.SQL.String()
+	}))
+	stg := &crudStg[*model.User]{db: db.Session(&gorm.Session{DryRun: true, SkipDefaultTransaction: true})}
+
+	user := &model.User{ID: uuid.New(), Email: "john@example.com", Versioned: common.Versioned{Version: 3}}
+	err := stg.UpdateOne(user, false)
+	// the dry run does not affect any rows, like the update of a stale version
+	require.ErrorIs(t, err, storage.ErrStaleVersion)
+	assert.Equal(t, errs.Conflict, errs.Code(err))
+	assert.Contains(t, sql, `"version"=$`)
+	assert.Regexp(t, `WHERE version = \$\d+ AND "users"."deleted_at" IS NULL AND "id" = \$\d+`, sql)
+	assert.EqualValues(t, 3, user.Version, "the version of a failed update must be left untouched")
+
+	err = stg.UpdatePartial(&model.User{ID: uuid.New(), Email: "john@example.com"}, false)
+	assert.Equal(t, errs.InvalidArgument, errs.Code(err), "the version is required")
+}
+
+// dryRunTx is a fake transaction, so the dry run statements of stg.db.Transaction run in a savepoint
+// instead of beginning a transaction on the lazy db.
+type dryRunTx struct {
+	gorm.ConnPool
+}
+
+func (dryRunTx) Commit() error   { return nil }
+func (dryRunTx) Rollback() error { return nil }
+
+func TestCrudStgUpdateElementsVersioned(t *testing.T) {
+	var sql string
+	var vars []any
+	db := openLazyDb(t)
+	registerAuditHooks(db)
+	require.NoError(t, db.Callback().Update().After("gorm:update").Register("test_capture_sql", func(db *gorm.DB) {
+		sql, vars = db.Statement.SQL.String(), db.Statement.Vars
+	}))
+	userId := uuid.New()
+	ses := db.Session(&gorm.Session{DryRun: true, SkipDefaultTransaction: true, Context: actor.WithUserId(context.Background(), userId)})
+	ses.Statement.ConnPool = dryRunTx{}
+	stg := &crudStg[*model.User]{db: ses}
+
+	type userEmail struct {
+		ID      uuid.UUID
+		Email   string
+		Version int64
+	}
+	err := stg.updateElements([]*userEmail{{ID: uuid.New(), Email: "john@example.com", Version: 3}})
+	// the dry run does not affect any rows, like the update of a stale version
+	require.ErrorIs(t, err, storage.ErrStaleVersion)
+	assert.Regexp(t, `WHERE \(id = \$\d+ AND version = \$\d+\) AND "users"."deleted_at" IS NULL$`, sql)
+	for _, column := range []string{`"email"=$`, `"updated_at"=$`, `"updated_by"=$`, `"version"=version + 1`} {
+		assert.Contains(t, sql, column)
+	}
+	assert.Contains(t, vars, &userId, "the updater must be audited")
+}
+
+func TestCrudStgDelete(t *testing.T) {
+	var sql string
+	db := openLazyDb(t)
+	require.NoError(t, db.Callback().Delete().After("gorm:delete").Register("test_capture_sql", func(db *gorm.DB) {
+		sql = db.Statement.SQL.String()
+	}))
+	stg := &crudStg[*model.User]{db: db.Session(&gorm.Session{DryRun: true, SkipDefaultTransaction: true})}
+
+	user := &model.User{ID: uuid.New()}
+	err := stg.Delete(user)
+	// the dry run does not affect any rows, like the delete of a missing user
+	assert.Equal(t, errs.NotFound, errs.Code(err))
+	assert.Equal(t, `UPDATE "users" SET "deleted_at"=$1 WHERE "users"."id" = $2 AND "users"."deleted_at" IS NULL`, sql)
+
+	err = stg.Delete(&model.User{})
+	assert.Equal(t, errs.InvalidArgument, errs.Code(err), "the primary key is required")
+}
+
+func TestCrudStgCopyFields(t *testing.T) {
+	stg := &crudStg[*model.User]{db: openLazyDb(t)}
+	columnNames := func(fields []*schema.Field) []string {
+		return lo.Map(fields, func(f *schema.Field, _ int) string {
+			return f.DBName
+		})
+	}
+
+	t.Run("leaves the generated ids to the db", func(t *testing.T) {
+		fields, err := stg.getCopyFields([]*model.User{{Email: "a@example.com"}, {Email: "b@example.com"}})
+		require.NoError(t, err)
+		assert.NotContains(t, columnNames(fields), "id")
+		assert.Contains(t, columnNames(fields), "email")
+	})
+
+	t.Run("copies the ids set on all the models", func(t *testing.T) {
+		fields, err := stg.getCopyFields([]*model.User{{ID: uuid.New()}, {ID: uuid.New()}})
+		require.NoError(t, err)
+		assert.Contains(t, columnNames(fields), "id")
+	})
+
+	t.Run("rejects the ids set on some of the models", func(t *testing.T) {
+		_, err := stg.getCopyFields([]*model.User{{ID: uuid.New()}, {}})
+		assert.Equal(t, errs.InvalidArgument, errs.Code(err))
+	})
+
+	t.Run("fills the default values and timestamps", func(t *testing.T) {
+		fields, err := stg.getCopyFields([]*model.User{{}})
+		require.NoError(t, err)
+		now := time.Now()
+		values := copyRowValues(context.Background(), fields, reflect.ValueOf(&model.User{}), now)
+		valuesByColumn := lo.Associate(lo.Zip2(columnNames(fields), values), func(item lo.Tuple2[string, any]) (string, any) {
+			return item.A, item.B
+		})
+		assert.Equal(t, now, valuesByColumn["created_at"])
+		assert.Equal(t, now, valuesByColumn["updated_at"])
+		assert.EqualValues(t, 1, valuesByColumn["version"])
+		assert.Nil(t, valuesByColumn["created_by"], "the audit users of an anonymous context must be left empty")
+	})
+
+	t.Run("fills the audit users from the actor", func(t *testing.T) {
+		fields, err := stg.getCopyFields([]*model.User{{}})
+		require.NoError(t, err)
+		userId, importerId := uuid.New(), uuid.New()
+		ctx := actor.WithUserId(context.Background(), userId)
+		values := copyRowValues(ctx, fields, reflect.ValueOf(&model.User{Audit: common.Audit{CreatedBy: &importerId}}), time.Now())
+		valuesByColumn := lo.Associate(lo.Zip2(columnNames(fields), values), func(item lo.Tuple2[string, any]) (string, any) {
+			return item.A, item.B
+		})
+		assert.Equal(t, &importerId, valuesByColumn["created_by"], "the explicitly provided users must be kept")
+		assert.Equal(t, &userId, valuesByColumn["updated_by"])
+	})
+}
+
+func TestCrudStgIterate(t *testing.T) {
+	var sql string
+	db := openLazyDb(t)
+	require.NoError(t, db.Callback().Query().After("gorm:query").Register("test_capture_sql", func(db *gorm.DB) {
+		sql = db.Statement.SQL.String()
+	}))
+	stg := &crudStg[*model.User]{db: db.Session(&gorm.Session{DryRun: true})}
+
+	t.Run("scans the first chunk by the primary key", func(t *testing.T) {
+		params := &common.SearchParams{
+			Filters: []*common.FieldFilter{{FieldName: "email", Condition: common.SearchConditionEqual, Value: "john@example.com"}},
+			Fields:  "email",
+		}
+		for _, err := range stg.Iterate(params, 50) {
+			require.NoError(t, err)
+		}
+		assert.Equal(t, `SELECT "email","id" FROM "users" WHERE email::TEXT = 'john@example.com' AND "users"."deleted_at" IS NULL ORDER BY "id" LIMIT $1`, sql)
+	})
+
+	t.Run("stops at the first error", func(t *testing.T) {
+		params := &common.SearchParams{Filters: []*common.FieldFilter{{FieldName: "unknown", Condition: common.SearchConditionEqual}}}
+		var iterErrs []error
+		for _, err := range stg.Iterate(params, 0) {
+			iterErrs = append(iterErrs, err)
+		}
+		require.Len(t, iterErrs, 1)
+		assert.Error(t, iterErrs[0])
+	})
+}
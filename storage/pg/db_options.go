@@ -0,0 +1,80 @@
+package pg
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// PoolConfig configures the connection pool of the db. The zero values keep the defaults of database/sql.
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+type dbConfig struct {
+	pool               PoolConfig
+	statementTimeout   time.Duration
+	queryTimeout       time.Duration
+	poolName           string
+	slowQueryThreshold time.Duration
+}
+
+type DbOption func(config *dbConfig)
+
+func WithPool(pool PoolConfig) DbOption {
+	return func(config *dbConfig) {
+		config.pool = pool
+	}
+}
+
+// WithStatementTimeout makes postgres abort any statement that runs longer than the timeout.
+// It is the hard limit of all the statements, including the raw queries and the queries with a longer query timeout.
+func WithStatementTimeout(timeout time.Duration) DbOption {
+	return func(config *dbConfig) {
+		config.statementTimeout = timeout
+	}
+}
+
+// WithQueryTimeout sets the default deadline of the queries. It can be overridden per context with storage.WithQueryTimeout.
+func WithQueryTimeout(timeout time.Duration) DbOption {
+	return func(config *dbConfig) {
+		config.queryTimeout = timeout
+	}
+}
+
+// WithPoolName sets the name of the connection pool in the metrics and the slow query logs. Defaults to "primary".
+func WithPoolName(name string) DbOption {
+	return func(config *dbConfig) {
+		config.poolName = name
+	}
+}
+
+// WithSlowQueryThreshold logs the statements that run longer than the threshold. Zero disables the slow query logs.
+func WithSlowQueryThreshold(threshold time.Duration) DbOption {
+	return func(config *dbConfig) {
+		config.slowQueryThreshold = threshold
+	}
+}
+
+// withRuntimeParam adds a run-time parameter (e.g. statement_timeout) to the dsn, which is set on every new connection.
+// Both the url and the keyword/value formats of the dsn are supported.
+func withRuntimeParam(dsn, name, value string) (string, error) {
+	if !strings.Contains(dsn, "://") {
+		return fmt.Sprintf("%s %s=%s", dsn, name, value), nil
+	}
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse the dsn")
+	}
+	query := u.Query()
+	query.Set(name, value)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
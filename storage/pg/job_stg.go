@@ -0,0 +1,165 @@
+package pg
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/domain/model/common"
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxEnqueueRetries limits how many times a unique job is enqueued again when its conflicting job finishes between
+// the insert and the lookup of the existing job.
+const maxEnqueueRetries = 3
+
+type JobStg struct {
+	crudStg[*model.Job]
+}
+
+func NewJobStg(ses *ormSession) *JobStg {
+	return &JobStg{
+		crudStg: crudStg[*model.Job]{db: ses.db, readDb: ses.readDb},
+	}
+}
+
+const (
+	uniqueViolationCode        = "23505"
+	invalidColumnReferenceCode = "42P10"
+)
+
+var activeJobStatuses = []model.JobStatus{model.JobPending, model.JobRunning}
+
+func (stg *JobStg) Enqueue(job *model.Job) (existing *model.Job, enqueued bool, err error) {
+	if job.UniqueKey == nil {
+		return job, true, stg.CreateOne(job)
+	}
+	return stg.enqueue(job, maxEnqueueRetries)
+}
+
+func (stg *JobStg) enqueue(job *model.Job, retries int) (existing *model.Job, enqueued bool, err error) {
+	// the conflict target must match the partial unique index of the unique keys
+	db := stg.db.
+		Clauses(clause.OnConflict{
+			Columns:     []clause.Column{{Name: "unique_key"}},
+			TargetWhere: clause.Where{Exprs: []clause.Expression{clause.IN{Column: "status", Values: lo.ToAnySlice(activeJobStatuses)}}},
+			DoNothing:   true,
+		}).
+		Create(job)
+	if db.Error != nil {
+		return nil, false, db.Error
+	}
+	if db.RowsAffected > 0 {
+		return job, true, nil
+	}
+
+	err = stg.db.
+		Where("unique_key = ? AND status IN ?", *job.UniqueKey, activeJobStatuses).
+		First(&existing).
+		Error
+	if errors.Is(err, gorm.ErrRecordNotFound) && retries > 0 {
+		// the conflicting job has finished since the insert, so the job can be enqueued now
+		return stg.enqueue(job, retries-1)
+	}
+	return existing, false, err
+}
+
+func (stg *JobStg) ClaimNext(queue string, limit int) (jobs []*model.Job, err error) {
+	err = stg.db.
+		Raw(`UPDATE jobs SET status = ?, attempts = attempts + 1, locked_at = ?
+			WHERE id IN (
+				SELECT id FROM jobs
+				WHERE queue = ? AND status = ? AND run_at <= ?
+				ORDER BY run_at, id
+				LIMIT ?
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING *`,
+			model.JobRunning, time.Now(), queue, model.JobPending, time.Now(), limit).
+		Scan(&jobs).
+		Error
+	return
+}
+
+func (stg *JobStg) MarkCompleted(id int64) error {
+	return stg.db.
+		Model(&model.Job{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"status":       model.JobCompleted,
+			"last_error":   "",
+			"locked_at":    nil,
+			"completed_at": time.Now(),
+		}).
+		Error
+}
+
+func (stg *JobStg) MarkFailed(id int64, lastError string, runAt time.Time, dead bool) error {
+	status := model.JobPending
+	if dead {
+		status = model.JobFailed
+	}
+	return stg.db.
+		Model(&model.Job{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"status":     status,
+			"last_error": lastError,
+			"locked_at":  nil,
+			"run_at":     runAt,
+		}).
+		Error
+}
+
+func (stg *JobStg) Retry(id int64) error {
+	db := stg.db.
+		Model(&model.Job{}).
+		Where("id = ? AND status = ?", id, model.JobFailed).
+		Updates(map[string]any{
+			"status":   model.JobPending,
+			"attempts": 0,
+			"run_at":   time.Now(),
+		})
+	if db.Error != nil {
+		if isUniqueViolation(db.Error) {
+			return errs.Newf(errs.Conflict, db.Error, "another job with the same unique key of job %d is already enqueued", id)
+		}
+		return errs.Wrapf(db.Error, "failed to retry job %d", id)
+	}
+	if db.RowsAffected == 0 {
+		return errs.Newf(errs.NotFound, nil, "failed job by id %d could not be found", id)
+	}
+	return nil
+}
+
+func (stg *JobStg) RescueStale(lockedBefore time.Time) (rescued int64, err error) {
+	db := stg.db.
+		Model(&model.Job{}).
+		Where("status = ? AND locked_at < ?", model.JobRunning, lockedBefore).
+		Updates(map[string]any{
+			"status":     gorm.Expr("CASE WHEN attempts >= max_attempts THEN ? ELSE ? END", model.JobFailed, model.JobPending),
+			"last_error": fmt.Sprintf("rescued after running since before %s", lockedBefore.Format(time.RFC3339)),
+			"locked_at":  nil,
+		})
+	return db.RowsAffected, db.Error
+}
+
+func (stg *JobStg) ListByStatus(status model.JobStatus, pagination *common.Pagination, fieldNames []string) (jobs []*model.Job, err error) {
+	query := stg.reader().Model(&model.Job{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	// the field selection must come after the pagination so the total count is not affected by it
+	err = query.Scopes(stg.withPagination(pagination), stg.withFields(fieldNames)).Find(&jobs).Error
+	return
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode
+}
@@ -2,82 +2,96 @@ package pg
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
 	"github.com/samber/lo"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type MetadataStg struct {
-	db *gorm.DB
+	db    *gorm.DB
+	cache *dbSchemaCache
 }
 
-// Since the tables and columns do not change we can safely cache them
-var cachedTableToColumnsMap map[string][]string
-
-func NewMetadataStg(db *gorm.DB) *MetadataStg {
-	return &MetadataStg{db: db}
+func NewMetadataStg(ses *ormSession, cache *dbSchemaCache) *MetadataStg {
+	// the schema is always read from the primary, the replicas may not have the latest migrations yet
+	return &MetadataStg{db: ses.db, cache: cache}
 }
 
-const listTablesAndColumnsSql = `
-SELECT t.table_name, c.column_name
-FROM information_schema.tables t
-JOIN information_schema.columns c ON t.table_name = c.table_name
-WHERE t.table_schema = 'public'
-  AND t.table_type = 'BASE TABLE'
-ORDER BY t.table_name ASC, c.column_name ASC;
-`
-
 func (stg *MetadataStg) ListTablesAndColumns() (tableToColumnsMap map[string][]string, err error) {
-	if cachedTableToColumnsMap == nil {
-		type tableColumn struct {
-			TableName  string
-			ColumnName string
-		}
-		var tableColumns []tableColumn
-		err = stg.db.Raw(listTablesAndColumnsSql).Scan(&tableColumns).Error
-
-		if err == nil {
-			cachedTableToColumnsMap = make(map[string][]string, 0)
-			for _, tableColumn := range tableColumns {
-				if _, ok := cachedTableToColumnsMap[tableColumn.TableName]; !ok {
-					cachedTableToColumnsMap[tableColumn.TableName] = make([]string, 0)
-				}
-				cachedTableToColumnsMap[tableColumn.TableName] = append(cachedTableToColumnsMap[tableColumn.TableName], tableColumn.ColumnName)
-			}
-		}
+	schema, err := stg.DescribeSchema()
+	if err != nil {
+		return nil, err
 	}
 
-	tableToColumnsMap = cachedTableToColumnsMap
-
-	return
+	tableToColumnsMap = make(map[string][]string, len(schema.Tables))
+	for _, table := range schema.Tables {
+		tableToColumnsMap[table.Name] = table.ColumnNames()
+	}
+	return tableToColumnsMap, nil
 }
 
 func (stg *MetadataStg) ListTables() (tableNames []string, err error) {
-	tableToColumnsMap, err := stg.ListTablesAndColumns()
-
+	schema, err := stg.DescribeSchema()
 	if err != nil {
-		tableNames = make([]string, 0)
-		for tableName := range tableToColumnsMap {
-			tableNames = append(tableNames, tableName)
-		}
+		return nil, err
 	}
 
-	return
+	tableNames = lo.Map(schema.Tables, func(table *storage.TableSchema, _ int) string {
+		return table.Name
+	})
+	return tableNames, nil
 }
 
 func (stg *MetadataStg) ListColumns(tableName string) (columnNames []string, err error) {
-	tableToColumnsMap, err := stg.ListTablesAndColumns()
-
+	schema, err := stg.DescribeSchema()
 	if err != nil {
-		if _, ok := tableToColumnsMap[tableName]; !ok {
-			err = fmt.Errorf("table '%s' does not exists", tableName)
-			return
-		}
+		return nil, err
+	}
 
-		columnNames = tableToColumnsMap[tableName]
+	table := schema.Table(tableName)
+	if table == nil {
+		return nil, errs.Newf(errs.NotFound, nil, "table %q does not exist", tableName)
 	}
+	return table.ColumnNames(), nil
+}
 
-	return
+func (stg *MetadataStg) DescribeSchema() (*storage.DbSchema, error) {
+	return stg.cache.get(stg.migrationVersion, func() (*storage.DbSchema, error) {
+		return loadDbSchema(stg.db)
+	})
+}
+
+// migrationVersion returns the version of the applied migrations, e.g. "7" or "7 (dirty)". It is empty if the schema
+// has no migrations table, e.g. if the db is not migrated by the Migrator.
+func (stg *MetadataStg) migrationVersion(schema *storage.DbSchema) (string, error) {
+	if schema.Table(migrationsTableName) == nil {
+		return "", nil
+	}
+	var versions []struct {
+		Version int64
+		Dirty   bool
+	}
+	if err := stg.db.Raw(migrationVersionSql).Scan(&versions).Error; err != nil {
+		return "", errs.Wrapf(err, "failed to read the migration version")
+	}
+	if len(versions) == 0 {
+		return "", nil
+	}
+	if versions[0].Dirty {
+		return fmt.Sprintf("%d (dirty)", versions[0].Version), nil
+	}
+	return strconv.FormatInt(versions[0].Version, 10), nil
+}
+
+func (stg *MetadataStg) Invalidate() {
+	stg.cache.invalidate()
 }
 
 func (stg *MetadataStg) RecordByValueExists(tableName, columnName string, value interface{}) (exists bool, err error) {
@@ -85,27 +99,278 @@ func (stg *MetadataStg) RecordByValueExists(tableName, columnName string, value
 	return
 }
 
+// anyRowsByValue reports whether the table has a record with the value in the column. The soft deleted records
+// are ignored, the same way the unique indexes on the soft deletable tables ignore them.
 func (stg *MetadataStg) anyRowsByValue(tableName, columnName string, value interface{}) (exists bool, err error) {
-	tableColumnsMap, err := stg.ListTablesAndColumns()
+	schema, err := stg.DescribeSchema()
 	if err != nil {
 		return
 	}
-	if _, ok := tableColumnsMap[tableName]; !ok {
-		err = fmt.Errorf("table '%s' does not exists", tableName)
+	table := schema.Table(tableName)
+	if table == nil {
+		err = errs.Newf(errs.NotFound, nil, "table %q does not exist", tableName)
 		return
 	}
-	if !lo.Contains(tableColumnsMap[tableName], columnName) {
-		err = fmt.Errorf("column '%s' does not exists in table '%s'", columnName, tableName)
+	if table.Column(columnName) == nil {
+		err = errs.Newf(errs.NotFound, nil, "column %q does not exist in table %q", columnName, tableName)
 		return
 	}
 
-	res := stg.db.
+	query := stg.db.
 		Table(tableName).
-		Where(fmt.Sprintf("%s = ?", columnName), value).
-		Select(columnName).
+		Where(clause.Eq{Column: clause.Column{Name: columnName}, Value: value})
+	if table.Column(softDeleteColumnName) != nil {
+		query = query.Where(clause.Eq{Column: clause.Column{Name: softDeleteColumnName}, Value: nil})
+	}
+	res := query.
+		Select("?", clause.Column{Name: columnName}).
 		Limit(1).
 		Find(map[string]interface{}{})
-	err = res.Error
+	if res.Error != nil {
+		err = errs.Wrapf(res.Error, "failed to look up %s.%s", tableName, columnName)
+		return
+	}
 	exists = res.RowsAffected > 0
 	return
 }
+
+// schemaVersionCheckInterval is how often the cached schema is compared with the version of the applied migrations.
+const schemaVersionCheckInterval = 10 * time.Second
+
+// dbSchemaCache caches the schema of the db for all the sessions of a storage. A zero ttl never expires the schema.
+// The schema is keyed on the migration version, so it is loaded again once the migrations are run, even by another
+// process. The version is read at most once per version check interval, and the cache hits in between only take a
+// read lock.
+type dbSchemaCache struct {
+	ttl                  time.Duration
+	versionCheckInterval time.Duration
+
+	// refreshMu makes the concurrent callers wait for a single version check or load
+	refreshMu sync.Mutex
+
+	mu               sync.RWMutex
+	schema           *storage.DbSchema
+	version          string
+	versionCheckedAt time.Time
+}
+
+func newDbSchemaCache(ttl time.Duration) *dbSchemaCache {
+	return &dbSchemaCache{ttl: ttl, versionCheckInterval: schemaVersionCheckInterval}
+}
+
+// get returns the cached schema, or loads it if the cache is empty, expired or of another migration version.
+// The concurrent callers wait for a single load instead of loading the schema in parallel.
+func (c *dbSchemaCache) get(
+	version func(schema *storage.DbSchema) (string, error),
+	load func() (*storage.DbSchema, error),
+) (*storage.DbSchema, error) {
+	if schema, ok := c.cached(); ok {
+		return schema, nil
+	}
+
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	// another caller may have checked or loaded the schema while this one was waiting
+	if schema, ok := c.cached(); ok {
+		return schema, nil
+	}
+
+	c.mu.RLock()
+	schema, cachedVersion := c.schema, c.version
+	c.mu.RUnlock()
+
+	var current string
+	var err error
+	if schema != nil {
+		// the version is read before the schema is loaded again, so a migration in between loads it once more
+		if current, err = version(schema); err != nil {
+			return nil, err
+		}
+		if current == cachedVersion && !c.expired(schema) {
+			c.store(schema, current)
+			return schema, nil
+		}
+	}
+	loaded, err := load()
+	if err != nil {
+		return nil, err
+	}
+	if schema == nil {
+		if current, err = version(loaded); err != nil {
+			return nil, err
+		}
+	}
+	c.store(loaded, current)
+	return loaded, nil
+}
+
+// cached returns the cached schema unless it is expired or its version has to be checked.
+func (c *dbSchemaCache) cached() (*storage.DbSchema, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.schema == nil || c.expired(c.schema) || time.Since(c.versionCheckedAt) >= c.versionCheckInterval {
+		return nil, false
+	}
+	return c.schema, true
+}
+
+func (c *dbSchemaCache) expired(schema *storage.DbSchema) bool {
+	return c.ttl > 0 && time.Since(schema.LoadedAt) >= c.ttl
+}
+
+func (c *dbSchemaCache) store(schema *storage.DbSchema, version string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.schema, c.version, c.versionCheckedAt = schema, version, time.Now()
+}
+
+func (c *dbSchemaCache) invalidate() {
+	// a running load must not cache its schema after the invalidation
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.schema, c.version, c.versionCheckedAt = nil, "", time.Time{}
+}
+
+// migrationsTableName is the table in which the Migrator records the version of the applied migrations.
+const migrationsTableName = "schema_migrations"
+
+const migrationVersionSql = `SELECT version, dirty FROM schema_migrations LIMIT 1;`
+
+const listSchemaColumnsSql = `
+SELECT c.table_name, c.column_name, c.udt_name, c.data_type, c.is_nullable = 'YES' AS nullable, c.column_default
+FROM information_schema.tables t
+JOIN information_schema.columns c ON c.table_schema = t.table_schema AND c.table_name = t.table_name
+WHERE t.table_schema = current_schema()
+  AND t.table_type = 'BASE TABLE'
+ORDER BY c.table_name ASC, c.ordinal_position ASC;
+`
+
+const listSchemaConstraintsSql = `
+SELECT cl.relname AS table_name,
+       con.conname AS name,
+       con.contype AS type,
+       array_to_string(ARRAY(
+           SELECT a.attname FROM unnest(con.conkey) WITH ORDINALITY AS k(attnum, ord)
+           JOIN pg_attribute a ON a.attrelid = con.conrelid AND a.attnum = k.attnum
+           ORDER BY k.ord
+       ), ',') AS columns,
+       COALESCE(rcl.relname, '') AS referenced_table,
+       array_to_string(ARRAY(
+           SELECT a.attname FROM unnest(con.confkey) WITH ORDINALITY AS k(attnum, ord)
+           JOIN pg_attribute a ON a.attrelid = con.confrelid AND a.attnum = k.attnum
+           ORDER BY k.ord
+       ), ',') AS referenced_columns
+FROM pg_constraint con
+JOIN pg_class cl ON cl.oid = con.conrelid
+JOIN pg_namespace n ON n.oid = cl.relnamespace
+LEFT JOIN pg_class rcl ON rcl.oid = con.confrelid
+WHERE n.nspname = current_schema()
+  AND con.contype IN ('p', 'f')
+ORDER BY cl.relname ASC, con.conname ASC;
+`
+
+const listSchemaIndexesSql = `
+SELECT t.relname AS table_name,
+       i.relname AS name,
+       ix.indisunique AS is_unique,
+       ix.indisprimary AS is_primary,
+       pg_get_indexdef(ix.indexrelid) AS definition
+FROM pg_index ix
+JOIN pg_class i ON i.oid = ix.indexrelid
+JOIN pg_class t ON t.oid = ix.indrelid
+JOIN pg_namespace n ON n.oid = t.relnamespace
+WHERE n.nspname = current_schema()
+ORDER BY t.relname ASC, i.relname ASC;
+`
+
+// loadDbSchema reads the tables of the current schema with their columns, keys and indexes.
+func loadDbSchema(db *gorm.DB) (*storage.DbSchema, error) {
+	var columns []struct {
+		TableName     string
+		ColumnName    string
+		UdtName       string
+		DataType      string
+		Nullable      bool
+		ColumnDefault *string
+	}
+	if err := db.Raw(listSchemaColumnsSql).Scan(&columns).Error; err != nil {
+		return nil, errs.Wrapf(err, "failed to list the columns of the db")
+	}
+	var constraints []struct {
+		TableName         string
+		Name              string
+		Type              string
+		Columns           string
+		ReferencedTable   string
+		ReferencedColumns string
+	}
+	if err := db.Raw(listSchemaConstraintsSql).Scan(&constraints).Error; err != nil {
+		return nil, errs.Wrapf(err, "failed to list the constraints of the db")
+	}
+	var indexes []struct {
+		TableName  string
+		Name       string
+		IsUnique   bool
+		IsPrimary  bool
+		Definition string
+	}
+	if err := db.Raw(listSchemaIndexesSql).Scan(&indexes).Error; err != nil {
+		return nil, errs.Wrapf(err, "failed to list the indexes of the db")
+	}
+
+	schema := &storage.DbSchema{Tables: make([]*storage.TableSchema, 0), LoadedAt: time.Now()}
+	tables := make(map[string]*storage.TableSchema)
+	for _, column := range columns {
+		table, ok := tables[column.TableName]
+		if !ok {
+			table = &storage.TableSchema{
+				Name:        column.TableName,
+				Columns:     make([]*storage.ColumnSchema, 0),
+				PrimaryKey:  make([]string, 0),
+				ForeignKeys: make([]*storage.ForeignKey, 0),
+				Indexes:     make([]*storage.IndexSchema, 0),
+			}
+			tables[column.TableName] = table
+			schema.Tables = append(schema.Tables, table)
+		}
+		table.Columns = append(table.Columns, &storage.ColumnSchema{
+			Name:     column.ColumnName,
+			Type:     column.UdtName,
+			DataType: column.DataType,
+			Nullable: column.Nullable,
+			Default:  column.ColumnDefault,
+		})
+	}
+	for _, constraint := range constraints {
+		table, ok := tables[constraint.TableName]
+		if !ok {
+			continue
+		}
+		switch constraint.Type {
+		case "p":
+			table.PrimaryKey = strings.Split(constraint.Columns, ",")
+		case "f":
+			table.ForeignKeys = append(table.ForeignKeys, &storage.ForeignKey{
+				Name:              constraint.Name,
+				Columns:           strings.Split(constraint.Columns, ","),
+				ReferencedTable:   constraint.ReferencedTable,
+				ReferencedColumns: strings.Split(constraint.ReferencedColumns, ","),
+			})
+		}
+	}
+	for _, index := range indexes {
+		table, ok := tables[index.TableName]
+		if !ok {
+			continue
+		}
+		table.Indexes = append(table.Indexes, &storage.IndexSchema{
+			Name:       index.Name,
+			Unique:     index.IsUnique,
+			Primary:    index.IsPrimary,
+			Definition: index.Definition,
+		})
+	}
+	return schema, nil
+}
@@ -0,0 +1,157 @@
+package pg
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestDbSchemaCache(t *testing.T) {
+	var loads atomic.Int32
+	load := func() (*storage.DbSchema, error) {
+		loads.Add(1)
+		return &storage.DbSchema{LoadedAt: time.Now()}, nil
+	}
+
+	t.Run("loads the schema once for the concurrent callers", func(t *testing.T) {
+		loads.Store(0)
+		cache := newDbSchemaCache(0)
+		wg := sync.WaitGroup{}
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := cache.get(noVersion, load)
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+		assert.EqualValues(t, 1, loads.Load())
+	})
+
+	t.Run("loads the schema again after invalidation", func(t *testing.T) {
+		loads.Store(0)
+		cache := newDbSchemaCache(0)
+		first, err := cache.get(noVersion, load)
+		require.NoError(t, err)
+		cache.invalidate()
+		second, err := cache.get(noVersion, load)
+		require.NoError(t, err)
+		assert.NotSame(t, first, second)
+		assert.EqualValues(t, 2, loads.Load())
+	})
+
+	t.Run("loads the schema again once it is expired", func(t *testing.T) {
+		loads.Store(0)
+		cache := newDbSchemaCache(time.Millisecond)
+		_, err := cache.get(noVersion, load)
+		require.NoError(t, err)
+		time.Sleep(2 * time.Millisecond)
+		_, err = cache.get(noVersion, load)
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, loads.Load())
+	})
+
+	t.Run("loads the schema again once the migration version changes", func(t *testing.T) {
+		loads.Store(0)
+		cache := newDbSchemaCache(0)
+		cache.versionCheckInterval = 0
+		migrationVersion := "6"
+		version := func(*storage.DbSchema) (string, error) {
+			return migrationVersion, nil
+		}
+		first, err := cache.get(version, load)
+		require.NoError(t, err)
+		cached, err := cache.get(version, load)
+		require.NoError(t, err)
+		assert.Same(t, first, cached)
+
+		migrationVersion = "7"
+		migrated, err := cache.get(version, load)
+		require.NoError(t, err)
+		assert.NotSame(t, first, migrated)
+		assert.EqualValues(t, 2, loads.Load())
+	})
+
+	t.Run("checks the migration version once per interval", func(t *testing.T) {
+		loads.Store(0)
+		cache := newDbSchemaCache(0)
+		cache.versionCheckInterval = 20 * time.Millisecond
+		var checks atomic.Int32
+		migrationVersion := atomic.Value{}
+		migrationVersion.Store("6")
+		version := func(*storage.DbSchema) (string, error) {
+			checks.Add(1)
+			return migrationVersion.Load().(string), nil
+		}
+		first, err := cache.get(version, load)
+		require.NoError(t, err)
+		for range 10 {
+			cached, err := cache.get(version, load)
+			require.NoError(t, err)
+			assert.Same(t, first, cached)
+		}
+		assert.EqualValues(t, 1, checks.Load(), "the cache hits must not read the version")
+
+		migrationVersion.Store("7")
+		require.Eventually(t, func() bool {
+			migrated, err := cache.get(version, load)
+			return err == nil && migrated != first
+		}, time.Second, 5*time.Millisecond)
+		assert.EqualValues(t, 2, loads.Load())
+	})
+
+	t.Run("does not cache the errors", func(t *testing.T) {
+		cache := newDbSchemaCache(0)
+		_, err := cache.get(noVersion, func() (*storage.DbSchema, error) {
+			return nil, errors.New("connection refused")
+		})
+		assert.Error(t, err)
+		schema, err := cache.get(noVersion, load)
+		require.NoError(t, err)
+		assert.NotNil(t, schema)
+	})
+}
+
+// noVersion is the migration version of the dbs without a migrations table.
+func noVersion(*storage.DbSchema) (string, error) {
+	return "", nil
+}
+
+func TestMetadataStgRecordByValueExists(t *testing.T) {
+	var sql string
+	db := openLazyDb(t)
+	require.NoError(t, db.Callback().Query().After("gorm:query").Register("test_capture_sql", func(db *gorm.DB) {
+		sql = db.Statement.SQL.String()
+	}))
+	cache := newDbSchemaCache(0)
+	_, err := cache.get(noVersion, func() (*storage.DbSchema, error) {
+		schema := usersSchema()
+		schema.LoadedAt = time.Now()
+		return schema, nil
+	})
+	require.NoError(t, err)
+	stg := &MetadataStg{db: db.Session(&gorm.Session{DryRun: true}), cache: cache}
+
+	t.Run("ignores the soft deleted records", func(t *testing.T) {
+		_, err := stg.RecordByValueExists("users", "email", "john@example.com")
+		require.NoError(t, err)
+		assert.Equal(t, `SELECT "email" FROM "users" WHERE "email" = $1 AND "deleted_at" IS NULL LIMIT $2`, sql)
+	})
+
+	t.Run("rejects the unknown tables and columns", func(t *testing.T) {
+		_, err := stg.RecordByValueExists("unknown", "email", "john@example.com")
+		assert.Equal(t, errs.NotFound, errs.Code(err))
+
+		_, err = stg.RecordByValueExists("users", "unknown", "john@example.com")
+		assert.Equal(t, errs.NotFound, errs.Code(err))
+	})
+}
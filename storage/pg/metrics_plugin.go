@@ -0,0 +1,194 @@
+package pg
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/pkg/logger"
+	"github.com/amahdian/golang-gin-boilerplate/pkg/logger/logging"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const (
+	metricsPluginName       = "app_metrics"
+	metricsStartHookName    = "app_metrics_start_hook"
+	metricsEndHookName      = "app_metrics_end_hook"
+	queryStartInstanceKey   = "app:query_start"
+	meterInstrumentationKey = "github.com/amahdian/golang-gin-boilerplate/storage/pg"
+)
+
+// quotedLiteralRegex matches the string literals of the sql, e.g. the values inlined by the search filters.
+var quotedLiteralRegex = regexp.MustCompile(`'(?:[^']|'')*'`)
+
+// metricsPlugin records the duration, rows affected and errors of the statements by table and operation,
+// and the statistics of the connection pool, through the global OpenTelemetry meter provider.
+// The statements slower than the slow query threshold are logged with the trace id of their context.
+type metricsPlugin struct {
+	poolName           string
+	slowQueryThreshold time.Duration
+
+	duration     metric.Float64Histogram
+	rowsAffected metric.Int64Counter
+	errors       metric.Int64Counter
+}
+
+func newMetricsPlugin(poolName string, slowQueryThreshold time.Duration) *metricsPlugin {
+	return &metricsPlugin{
+		poolName:           poolName,
+		slowQueryThreshold: slowQueryThreshold,
+	}
+}
+
+func (p *metricsPlugin) Name() string {
+	return metricsPluginName
+}
+
+func (p *metricsPlugin) Initialize(db *gorm.DB) (err error) {
+	meter := otel.Meter(meterInstrumentationKey)
+	if p.duration, err = meter.Float64Histogram("db.client.operation.duration",
+		metric.WithDescription("Duration of the db statements"), metric.WithUnit("s")); err != nil {
+		return err
+	}
+	if p.rowsAffected, err = meter.Int64Counter("db.client.operation.rows_affected",
+		metric.WithDescription("Number of the rows affected or returned by the db statements")); err != nil {
+		return err
+	}
+	if p.errors, err = meter.Int64Counter("db.client.operation.errors",
+		metric.WithDescription("Number of the failed db statements")); err != nil {
+		return err
+	}
+	if err = p.registerPoolMetrics(db, meter); err != nil {
+		return err
+	}
+
+	callbacks := db.Callback()
+	_ = callbacks.Create().Before("*").Register(metricsStartHookName, p.start)
+	_ = callbacks.Create().After("*").Register(metricsEndHookName, p.end("create"))
+	_ = callbacks.Query().Before("*").Register(metricsStartHookName, p.start)
+	_ = callbacks.Query().After("*").Register(metricsEndHookName, p.end("query"))
+	_ = callbacks.Update().Before("*").Register(metricsStartHookName, p.start)
+	_ = callbacks.Update().After("*").Register(metricsEndHookName, p.end("update"))
+	_ = callbacks.Delete().Before("*").Register(metricsStartHookName, p.start)
+	_ = callbacks.Delete().After("*").Register(metricsEndHookName, p.end("delete"))
+	_ = callbacks.Row().Before("*").Register(metricsStartHookName, p.start)
+	_ = callbacks.Row().After("*").Register(metricsEndHookName, p.end("row"))
+	_ = callbacks.Raw().Before("*").Register(metricsStartHookName, p.start)
+	_ = callbacks.Raw().After("*").Register(metricsEndHookName, p.end("raw"))
+	return nil
+}
+
+func (p *metricsPlugin) registerPoolMetrics(db *gorm.DB, meter metric.Meter) error {
+	sqlDb, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	inUse, err := meter.Int64ObservableGauge("db.client.connections.in_use", metric.WithDescription("Number of the connections in use"))
+	if err != nil {
+		return err
+	}
+	idle, err := meter.Int64ObservableGauge("db.client.connections.idle", metric.WithDescription("Number of the idle connections"))
+	if err != nil {
+		return err
+	}
+	maxOpen, err := meter.Int64ObservableGauge("db.client.connections.max", metric.WithDescription("Maximum number of the open connections"))
+	if err != nil {
+		return err
+	}
+	waitCount, err := meter.Int64ObservableCounter("db.client.connections.wait_count",
+		metric.WithDescription("Number of the times a connection has been waited for"))
+	if err != nil {
+		return err
+	}
+	waitDuration, err := meter.Float64ObservableCounter("db.client.connections.wait_duration",
+		metric.WithDescription("Total time spent waiting for a connection"), metric.WithUnit("s"))
+	if err != nil {
+		return err
+	}
+
+	poolAttr := metric.WithAttributes(attribute.String("db.client.connections.pool.name", p.poolName))
+	_, err = meter.RegisterCallback(func(_ context.Context, observer metric.Observer) error {
+		stats := sqlDb.Stats()
+		observer.ObserveInt64(inUse, int64(stats.InUse), poolAttr)
+		observer.ObserveInt64(idle, int64(stats.Idle), poolAttr)
+		observer.ObserveInt64(maxOpen, int64(stats.MaxOpenConnections), poolAttr)
+		observer.ObserveInt64(waitCount, stats.WaitCount, poolAttr)
+		observer.ObserveFloat64(waitDuration, stats.WaitDuration.Seconds(), poolAttr)
+		return nil
+	}, inUse, idle, maxOpen, waitCount, waitDuration)
+	return err
+}
+
+func (p *metricsPlugin) start(db *gorm.DB) {
+	db.InstanceSet(queryStartInstanceKey, time.Now())
+}
+
+func (p *metricsPlugin) end(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		v, ok := db.InstanceGet(queryStartInstanceKey)
+		if !ok {
+			return
+		}
+		duration := time.Since(v.(time.Time))
+		ctx := db.Statement.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+
+		table := db.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+		attrs := metric.WithAttributes(
+			attribute.String("db.client.connections.pool.name", p.poolName),
+			attribute.String("db.collection.name", table),
+			attribute.String("db.operation.name", operation),
+		)
+		p.duration.Record(ctx, duration.Seconds(), attrs)
+		if db.RowsAffected > 0 {
+			p.rowsAffected.Add(ctx, db.RowsAffected, attrs)
+		}
+		if db.Error != nil && !isExpectedErr(db.Error) {
+			p.errors.Add(ctx, 1, attrs)
+		}
+
+		if p.slowQueryThreshold > 0 && duration >= p.slowQueryThreshold {
+			p.logSlowQuery(ctx, db, table, operation, duration)
+		}
+	}
+}
+
+func (p *metricsPlugin) logSlowQuery(ctx context.Context, db *gorm.DB, table, operation string, duration time.Duration) {
+	fields := logging.Fields{
+		"pool":        p.poolName,
+		"table":       table,
+		"operation":   operation,
+		"duration_ms": duration.Milliseconds(),
+		"rows":        db.RowsAffected,
+		// the bound parameters are never logged, only their number
+		"params": len(db.Statement.Vars),
+	}
+	if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.HasTraceID() {
+		fields["trace_id"] = spanCtx.TraceID().String()
+	}
+	if db.Error != nil {
+		fields["error"] = db.Error.Error()
+	}
+	logger.WithFields(fields).Warnf("slow query: %s", redactSql(db.Statement.SQL.String()))
+}
+
+// redactSql removes the string literals from the sql. The bound parameters are not part of the sql.
+func redactSql(sql string) string {
+	return quotedLiteralRegex.ReplaceAllString(sql, "'<redacted>'")
+}
+
+// isExpectedErr reports whether the error is part of the normal flow, e.g. a record that is not found.
+func isExpectedErr(err error) bool {
+	return errors.Is(err, gorm.ErrRecordNotFound)
+}
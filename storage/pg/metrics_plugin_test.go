@@ -0,0 +1,46 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactSql(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want string
+	}{
+		{
+			name: "keeps the placeholders",
+			sql:  `SELECT * FROM "users" WHERE email = $1 AND "users"."deleted_at" IS NULL`,
+			want: `SELECT * FROM "users" WHERE email = $1 AND "users"."deleted_at" IS NULL`,
+		},
+		{
+			name: "redacts the inlined literals",
+			sql:  `SELECT * FROM "users" WHERE name ILIKE '%john%' AND email = 'john@example.com'`,
+			want: `SELECT * FROM "users" WHERE name ILIKE '<redacted>' AND email = '<redacted>'`,
+		},
+		{
+			name: "redacts the literals with escaped quotes",
+			sql:  `SELECT * FROM "users" WHERE name = 'o''brien' LIMIT 10`,
+			want: `SELECT * FROM "users" WHERE name = '<redacted>' LIMIT 10`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, redactSql(tt.sql))
+		})
+	}
+}
+
+func TestMetricsPluginInitialize(t *testing.T) {
+	db := openLazyDb(t)
+	require.NoError(t, db.Use(newMetricsPlugin("primary", 0)))
+
+	assert.NotNil(t, db.Callback().Query().Get(metricsStartHookName))
+	assert.NotNil(t, db.Callback().Query().Get(metricsEndHookName))
+	assert.Error(t, db.Use(newMetricsPlugin("primary", 0)), "the plugin must not be registered twice")
+}
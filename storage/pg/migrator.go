@@ -0,0 +1,206 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/amahdian/golang-gin-boilerplate/assets"
+	"github.com/amahdian/golang-gin-boilerplate/pkg/logger"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/pkg/errors"
+)
+
+const migrationsLockName = "app:migrations"
+
+var migrationFileRegex = regexp.MustCompile(`^(\d+)_.*\.(up|down)\.sql$`)
+
+// Migrator runs the migrations embedded in the binary. It holds an advisory lock from its creation until it is
+// closed, so the replicas starting together (or a migrate command running next to them) wait for each other
+// instead of racing.
+type Migrator struct {
+	db      *sql.DB
+	conn    *sql.Conn
+	source  source.Driver
+	migrate *migrate.Migrate
+}
+
+// MigrationStatus is the status of a single migration.
+type MigrationStatus struct {
+	Version uint
+	Name    string
+	Applied bool
+}
+
+// NewMigrator connects to the db and waits until it acquires the migrations lock.
+func NewMigrator(ctx context.Context, dsn string) (m *Migrator, err error) {
+	m = &Migrator{}
+	defer func() {
+		if err != nil {
+			_ = m.Close()
+		}
+	}()
+
+	if m.db, err = sql.Open("pgx", dsn); err != nil {
+		return nil, errors.Wrap(err, "failed to open connection to the db")
+	}
+	// session level locks belong to a connection, so the same connection runs the migrations
+	if m.conn, err = m.db.Conn(ctx); err != nil {
+		return nil, errors.Wrap(err, "failed to get a db connection")
+	}
+	logger.Info("waiting for the migrations lock")
+	if _, err = m.conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", advisoryLockKey(migrationsLockName)); err != nil {
+		return nil, errors.Wrap(err, "failed to acquire the migrations lock")
+	}
+
+	if m.source, err = iofs.New(assets.Migrations, assets.MigrationsDir); err != nil {
+		return nil, errors.Wrap(err, "failed to read the embedded migrations")
+	}
+	driver, err := postgres.WithConnection(ctx, m.conn, &postgres.Config{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open the migrations driver")
+	}
+	if m.migrate, err = migrate.NewWithInstance("iofs", m.source, "postgres", driver); err != nil {
+		return nil, errors.Wrap(err, "failed to open new migration instance")
+	}
+	m.migrate.Log = migrateLogger{}
+	return m, nil
+}
+
+// Up applies the next n migrations, or all of them if n is not positive.
+func (m *Migrator) Up(n int) error {
+	if n > 0 {
+		return m.run(m.migrate.Steps(n))
+	}
+	return m.run(m.migrate.Up())
+}
+
+// Down rolls back the last n migrations, or all of them if n is not positive.
+func (m *Migrator) Down(n int) error {
+	if n > 0 {
+		return m.run(m.migrate.Steps(-n))
+	}
+	return m.run(m.migrate.Down())
+}
+
+// Goto migrates up or down to the given version.
+func (m *Migrator) Goto(version uint) error {
+	return m.run(m.migrate.Migrate(version))
+}
+
+// Force sets the version without running any migration and clears the dirty flag, e.g. after fixing a failed
+// migration manually. A version of -1 means no migration is applied.
+func (m *Migrator) Force(version int) error {
+	return m.run(m.migrate.Force(version))
+}
+
+// Version returns the current version of the db. The version is zero if no migration has been applied.
+func (m *Migrator) Version() (version uint, dirty bool, err error) {
+	version, dirty, err = m.migrate.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+// Status lists the embedded migrations and whether they are applied.
+func (m *Migrator) Status() ([]*MigrationStatus, error) {
+	current, _, err := m.Version()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get the current version")
+	}
+
+	statuses := make([]*MigrationStatus, 0)
+	version, err := m.source.First()
+	for err == nil {
+		status := &MigrationStatus{Version: version, Applied: version <= current}
+		if r, name, readErr := m.source.ReadUp(version); readErr == nil {
+			_ = r.Close()
+			status.Name = name
+		}
+		statuses = append(statuses, status)
+		version, err = m.source.Next(version)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, errors.Wrap(err, "failed to read the embedded migrations")
+	}
+	return statuses, nil
+}
+
+// Close releases the migrations lock and closes the db connection.
+func (m *Migrator) Close() error {
+	if m.conn != nil {
+		// closing the connection releases the lock even if the unlock fails
+		_, _ = m.conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", advisoryLockKey(migrationsLockName))
+	}
+	if m.migrate != nil {
+		// closes the source and the connection
+		_, _ = m.migrate.Close()
+	} else {
+		if m.source != nil {
+			_ = m.source.Close()
+		}
+		if m.conn != nil {
+			_ = m.conn.Close()
+		}
+	}
+	if m.db != nil {
+		return m.db.Close()
+	}
+	return nil
+}
+
+func (m *Migrator) run(err error) error {
+	if errors.Is(err, migrate.ErrNoChange) {
+		logger.Info("no migration to apply")
+		return nil
+	}
+	return err
+}
+
+// CreateMigration creates the empty up and down files of a new migration in dir, numbered after the last migration.
+func CreateMigration(dir, name string) (upFile, downFile string, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "failed to read the migrations directory %q", dir)
+	}
+	var lastVersion uint64
+	for _, entry := range entries {
+		matches := migrationFileRegex.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			continue
+		}
+		version, _ := strconv.ParseUint(matches[1], 10, 64)
+		lastVersion = max(lastVersion, version)
+	}
+
+	base := filepath.Join(dir, fmt.Sprintf("%06d_%s", lastVersion+1, name))
+	upFile, downFile = base+".up.sql", base+".down.sql"
+	for _, file := range []string{upFile, downFile} {
+		if err = os.WriteFile(file, []byte("BEGIN;\n\nCOMMIT;\n"), 0o644); err != nil {
+			return "", "", errors.Wrapf(err, "failed to create %q", file)
+		}
+	}
+	return upFile, downFile, nil
+}
+
+// migrateLogger writes the logs of the migrations to the app logger.
+type migrateLogger struct{}
+
+func (migrateLogger) Printf(format string, v ...interface{}) {
+	logger.Infof(strings.TrimSuffix(format, "\n"), v...)
+}
+
+func (migrateLogger) Verbose() bool {
+	return true
+}
@@ -0,0 +1,44 @@
+package pg
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/amahdian/golang-gin-boilerplate/assets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	files, err := fs.Glob(assets.Migrations, assets.MigrationsDir+"/*.sql")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	versions := map[string][]string{}
+	for _, file := range files {
+		matches := migrationFileRegex.FindStringSubmatch(filepath.Base(file))
+		require.NotNil(t, matches, "unexpected migration file name %q", file)
+		versions[matches[1]] = append(versions[matches[1]], matches[2])
+	}
+	for version, directions := range versions {
+		assert.ElementsMatch(t, []string{"up", "down"}, directions, "migration %s must have an up and a down file", version)
+	}
+}
+
+func TestCreateMigration(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "000009_add-users.up.sql"), nil, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "000009_add-users.down.sql"), nil, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), nil, 0o644))
+
+	upFile, downFile, err := CreateMigration(dir, "add-orders")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "000010_add-orders.up.sql"), upFile)
+	assert.Equal(t, filepath.Join(dir, "000010_add-orders.down.sql"), downFile)
+
+	content, err := os.ReadFile(upFile)
+	require.NoError(t, err)
+	assert.Equal(t, "BEGIN;\n\nCOMMIT;\n", string(content))
+}
@@ -0,0 +1,72 @@
+package pg
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"gorm.io/gorm"
+)
+
+type OutboxStg struct {
+	crudStg[*model.OutboxEvent]
+}
+
+func NewOutboxStg(ses *ormSession) *OutboxStg {
+	return &OutboxStg{
+		crudStg: crudStg[*model.OutboxEvent]{db: ses.db, readDb: ses.readDb},
+	}
+}
+
+func (stg *OutboxStg) ClaimDue(limit int, lease time.Duration) (events []*model.OutboxEvent, err error) {
+	now := time.Now()
+	err = stg.db.
+		Raw(`UPDATE outbox_events SET next_attempt_at = ?
+			WHERE id IN (
+				SELECT id FROM outbox_events
+				WHERE status = ? AND next_attempt_at <= ?
+				ORDER BY id
+				LIMIT ?
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING *`,
+			now.Add(lease), model.OutboxEventPending, now, limit).
+		Scan(&events).
+		Error
+	// the returned rows are not ordered
+	slices.SortFunc(events, func(a, b *model.OutboxEvent) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return
+}
+
+func (stg *OutboxStg) MarkDelivered(id int64) error {
+	return stg.db.
+		Model(&model.OutboxEvent{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"status":       model.OutboxEventDelivered,
+			"attempts":     gorm.Expr("attempts + 1"),
+			"last_error":   "",
+			"delivered_at": time.Now(),
+		}).
+		Error
+}
+
+func (stg *OutboxStg) MarkFailed(id int64, lastError string, nextAttemptAt time.Time, dead bool) error {
+	status := model.OutboxEventPending
+	if dead {
+		status = model.OutboxEventDead
+	}
+	return stg.db.
+		Model(&model.OutboxEvent{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"status":          status,
+			"attempts":        gorm.Expr("attempts + 1"),
+			"last_error":      lastError,
+			"next_attempt_at": nextAttemptAt,
+		}).
+		Error
+}
@@ -1,9 +1,11 @@
 package pg
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -24,8 +26,21 @@ const (
 	Info            = "info"
 )
 
-func OpenGormDb(dsn string, logLevel LogLevel) (*gorm.DB, error) {
+func OpenGormDb(dsn string, logLevel LogLevel, opts ...DbOption) (*gorm.DB, error) {
+	config := &dbConfig{poolName: "primary"}
+	for _, opt := range opts {
+		opt(config)
+	}
+
 	logger.Infof("trying to open connection to database %q", dsn)
+	if config.statementTimeout > 0 {
+		var err error
+		dsn, err = withRuntimeParam(dsn, "statement_timeout", strconv.FormatInt(config.statementTimeout.Milliseconds(), 10))
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// create db object
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
 		Logger: gormLogger.Default.LogMode(gormLogLevel(logLevel)),
@@ -39,6 +54,18 @@ func OpenGormDb(dsn string, logLevel LogLevel) (*gorm.DB, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get underlying sql db from gorm")
 	}
+	sqlDb.SetMaxOpenConns(config.pool.MaxOpenConns)
+	if config.pool.MaxIdleConns > 0 {
+		// zero would disable the idle connections instead of keeping the default
+		sqlDb.SetMaxIdleConns(config.pool.MaxIdleConns)
+	}
+	sqlDb.SetConnMaxLifetime(config.pool.ConnMaxLifetime)
+	sqlDb.SetConnMaxIdleTime(config.pool.ConnMaxIdleTime)
+	registerQueryTimeoutHooks(db, config.queryTimeout, config.statementTimeout)
+	if err = db.Use(newMetricsPlugin(config.poolName, config.slowQueryThreshold)); err != nil {
+		return nil, errors.Wrap(err, "failed to register the metrics plugin")
+	}
+
 	err = sqlDb.Ping()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to ping database")
@@ -74,23 +101,91 @@ func EnsureDatabaseExists(dsn string) error {
 	return nil
 }
 
-func OpenGormTestDb(t *testing.T, dsn string, logLevel LogLevel) *gorm.DB {
+// TestDbOption configures how the changes of a test are undone, see OpenGormTestDb.
+type TestDbOption func(config *testDbConfig)
+
+type testDbConfig struct {
+	txIsolation bool
+	templateDb  bool
+}
+
+// WithTxIsolation runs the test in a transaction which is rolled back when the test ends, instead of deleting the
+// entities created by the test. Unlike the deletes, the rollback undoes every change of the test, including the raw
+// sql statements, the updates and the cascades. The subtests can run in savepoints of their own, see SubTestDb.
+//
+// All the statements run on a single connection, so the changes of the transactions of the test are visible
+// outside the transactions before they are committed, and the db must not be used by concurrent goroutines.
+func WithTxIsolation() TestDbOption {
+	return func(config *testDbConfig) {
+		config.txIsolation = true
+	}
+}
+
+// WithTemplateDb runs the test on a db of its own, cloned from a template db which has all the migrations applied.
+// The dsn is only used to connect to the server, so the tests and the test packages can run in parallel against one
+// postgres. The clones are reused by the later tests, therefore it implies WithTxIsolation.
+func WithTemplateDb() TestDbOption {
+	return func(config *testDbConfig) {
+		config.txIsolation = true
+		config.templateDb = true
+	}
+}
+
+// OpenGormTestDb opens the test db and undoes the changes of the test when it ends. By default, the entities created
+// by the test are deleted, see the options for the other strategies.
+func OpenGormTestDb(t *testing.T, dsn string, logLevel LogLevel, opts ...TestDbOption) *gorm.DB {
+	config := &testDbConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	if config.templateDb {
+		pool, err := getTemplateDbPool(context.Background(), dsn)
+		if err != nil {
+			t.Fatalf("could not prepare the template test db: %v", err)
+		}
+		var release func()
+		dsn, release, err = pool.acquire(context.Background())
+		if err != nil {
+			t.Fatalf("could not acquire a test db: %v", err)
+		}
+		// registered first to be run after the db is closed
+		t.Cleanup(release)
+	}
+
 	db, err := OpenGormDb(dsn, logLevel)
 	if err != nil {
 		t.Fatalf("could not connect to the test db: %v", err)
 	}
-	cleanup := attachDeleteCreatedEntitiesHook(db)
-
-	t.Cleanup(func() {
-		// t.Log("cleaning up and closing test db...")
-		cleanup()
-
+	closeDb := func() {
 		// t.Log("closing the db connection...")
 		sqlDb, err := db.DB()
 		if err != nil {
 			t.Fatalf("could close connection to test db: %v", err)
 		}
 		sqlDb.Close()
+	}
+
+	if config.txIsolation {
+		txDb, rollback, err := beginTestTx(db)
+		if err != nil {
+			closeDb()
+			t.Fatalf("could not begin the test transaction: %v", err)
+		}
+		t.Cleanup(func() {
+			if err := rollback(); err != nil {
+				t.Errorf("could not roll back the test transaction: %v", err)
+			}
+			closeDb()
+		})
+		return txDb
+	}
+
+	cleanup := attachDeleteCreatedEntitiesHook(db)
+	t.Cleanup(func() {
+		// t.Log("cleaning up and closing test db...")
+		cleanup()
+		closeDb()
 	})
 
 	return db
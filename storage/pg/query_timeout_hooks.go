@@ -0,0 +1,89 @@
+package pg
+
+import (
+	"context"
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+const (
+	queryTimeoutStartHookName = "app_query_timeout_start_hook"
+	queryTimeoutEndHookName   = "app_query_timeout_end_hook"
+	queryDeadlineInstanceKey  = "app:query_deadline"
+
+	// the error code of the statements canceled by postgres, e.g. because of the statement timeout
+	queryCanceledCode = "57014"
+)
+
+type queryDeadline struct {
+	parent context.Context
+	cancel context.CancelFunc
+}
+
+// registerQueryTimeoutHooks runs every statement with a deadline, which is the query timeout of the statement context
+// (see storage.WithQueryTimeout) or the default timeout. The timeouts are reported as errs.DeadlineExceeded.
+//
+// The query timeouts of the contexts are capped at the statement timeout, since postgres aborts the statements which
+// run longer than it anyway. The row statements only get their errors reported, because their rows are scanned after
+// the hooks have run. They are still limited by the statement timeout of the db.
+func registerQueryTimeoutHooks(db *gorm.DB, defaultTimeout, statementTimeout time.Duration) {
+	start := func(db *gorm.DB) {
+		timeout := defaultTimeout
+		if ctxTimeout, ok := storage.QueryTimeout(db.Statement.Context); ok {
+			timeout = ctxTimeout
+			if statementTimeout > 0 && (timeout <= 0 || timeout > statementTimeout) {
+				timeout = statementTimeout
+			}
+		}
+		if timeout <= 0 || db.Statement.Context == nil {
+			return
+		}
+		ctx, cancel := context.WithTimeout(db.Statement.Context, timeout)
+		db.InstanceSet(queryDeadlineInstanceKey, &queryDeadline{parent: db.Statement.Context, cancel: cancel})
+		db.Statement.Context = ctx
+	}
+	end := func(db *gorm.DB) {
+		if v, ok := db.InstanceGet(queryDeadlineInstanceKey); ok {
+			deadline := v.(*queryDeadline)
+			deadline.cancel()
+			db.Statement.Context = deadline.parent
+		}
+		if db.Error != nil && isQueryTimeout(db.Error) {
+			db.Error = errs.Newf(errs.DeadlineExceeded, db.Error, "the query has timed out")
+		}
+	}
+
+	if db.Callback().Query().Get(queryTimeoutStartHookName) != nil {
+		return
+	}
+	callbacks := db.Callback()
+	_ = callbacks.Create().Before("*").Register(queryTimeoutStartHookName, start)
+	_ = callbacks.Create().After("*").Register(queryTimeoutEndHookName, end)
+	_ = callbacks.Query().Before("*").Register(queryTimeoutStartHookName, start)
+	_ = callbacks.Query().After("*").Register(queryTimeoutEndHookName, end)
+	_ = callbacks.Update().Before("*").Register(queryTimeoutStartHookName, start)
+	_ = callbacks.Update().After("*").Register(queryTimeoutEndHookName, end)
+	_ = callbacks.Delete().Before("*").Register(queryTimeoutStartHookName, start)
+	_ = callbacks.Delete().After("*").Register(queryTimeoutEndHookName, end)
+	_ = callbacks.Raw().Before("*").Register(queryTimeoutStartHookName, start)
+	_ = callbacks.Raw().After("*").Register(queryTimeoutEndHookName, end)
+	_ = callbacks.Row().After("*").Register(queryTimeoutEndHookName, end)
+}
+
+func isQueryTimeout(err error) bool {
+	var customErr *errs.Error
+	if errors.As(err, &customErr) {
+		// already reported
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == queryCanceledCode
+}
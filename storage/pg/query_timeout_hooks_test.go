@@ -0,0 +1,106 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// blockingConnPool blocks every statement until its context is done, or fails it with err if err is set.
+type blockingConnPool struct {
+	err      error
+	deadline time.Time
+}
+
+func (p *blockingConnPool) wait(ctx context.Context) error {
+	p.deadline, _ = ctx.Deadline()
+	if p.err != nil {
+		return p.err
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (p *blockingConnPool) PrepareContext(ctx context.Context, _ string) (*sql.Stmt, error) {
+	return nil, p.wait(ctx)
+}
+
+func (p *blockingConnPool) ExecContext(ctx context.Context, _ string, _ ...interface{}) (sql.Result, error) {
+	return nil, p.wait(ctx)
+}
+
+func (p *blockingConnPool) QueryContext(ctx context.Context, _ string, _ ...interface{}) (*sql.Rows, error) {
+	return nil, p.wait(ctx)
+}
+
+func (p *blockingConnPool) QueryRowContext(context.Context, string, ...interface{}) *sql.Row {
+	return nil
+}
+
+func openBlockingDb(t *testing.T, pool *blockingConnPool, defaultTimeout time.Duration, statementTimeout ...time.Duration) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: pool}), &gorm.Config{DisableAutomaticPing: true})
+	require.NoError(t, err)
+	registerQueryTimeoutHooks(db, defaultTimeout, lo.FirstOrEmpty(statementTimeout))
+	return db
+}
+
+func TestQueryTimeoutHooks(t *testing.T) {
+	t.Run("reports the default timeout as deadline exceeded", func(t *testing.T) {
+		pool := &blockingConnPool{}
+		db := openBlockingDb(t, pool, 10*time.Millisecond)
+
+		err := db.WithContext(context.Background()).Exec("SELECT pg_sleep(10)").Error
+		assert.Equal(t, errs.DeadlineExceeded, errs.Code(err), "got %v", err)
+	})
+
+	t.Run("uses the query timeout of the context", func(t *testing.T) {
+		pool := &blockingConnPool{}
+		db := openBlockingDb(t, pool, time.Hour)
+
+		ctx := storage.WithQueryTimeout(context.Background(), 10*time.Millisecond)
+		var count int64
+		err := db.WithContext(ctx).Table("users").Count(&count).Error
+		assert.Equal(t, errs.DeadlineExceeded, errs.Code(err), "got %v", err)
+		assert.WithinDuration(t, time.Now(), pool.deadline, time.Second)
+	})
+
+	t.Run("caps the query timeout of the context at the statement timeout", func(t *testing.T) {
+		pool := &blockingConnPool{}
+		db := openBlockingDb(t, pool, time.Hour, 10*time.Millisecond)
+
+		ctx := storage.WithQueryTimeout(context.Background(), time.Hour)
+		err := db.WithContext(ctx).Exec("SELECT pg_sleep(10)").Error
+		assert.Equal(t, errs.DeadlineExceeded, errs.Code(err), "got %v", err)
+		assert.WithinDuration(t, time.Now(), pool.deadline, time.Second)
+	})
+
+	t.Run("reports the statement timeout of postgres as deadline exceeded", func(t *testing.T) {
+		pool := &blockingConnPool{err: &pgconn.PgError{Code: queryCanceledCode}}
+		db := openBlockingDb(t, pool, 0)
+
+		err := db.Exec("SELECT pg_sleep(10)").Error
+		assert.Equal(t, errs.DeadlineExceeded, errs.Code(err), "got %v", err)
+		assert.True(t, pool.deadline.IsZero(), "no deadline must be set without a timeout")
+	})
+}
+
+func TestWithRuntimeParam(t *testing.T) {
+	dsn, err := withRuntimeParam("postgres://u:p@localhost:5432/app_db?sslmode=disable", "statement_timeout", "5000")
+	require.NoError(t, err)
+	assert.Equal(t, "postgres://u:p@localhost:5432/app_db?sslmode=disable&statement_timeout=5000", dsn)
+
+	dsn, err = withRuntimeParam("host=localhost dbname=app_db", "statement_timeout", "5000")
+	require.NoError(t, err)
+	assert.Equal(t, "host=localhost dbname=app_db statement_timeout=5000", dsn)
+}
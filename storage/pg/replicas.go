@@ -0,0 +1,137 @@
+package pg
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/pkg/logger"
+	"github.com/pkg/errors"
+	"github.com/xo/dburl"
+	"gorm.io/gorm"
+)
+
+// replicationLagQuery returns the replication lag of a replica in seconds.
+// An idle replica which has replayed everything it has received is not lagging, even if its last replay is old.
+const replicationLagQuery = `SELECT CASE
+	WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE COALESCE(EXTRACT(EPOCH FROM NOW() - pg_last_xact_replay_timestamp()), 0)
+END`
+
+type replica struct {
+	name    string
+	db      *gorm.DB
+	healthy atomic.Bool
+}
+
+// Replicas is the set of read replicas that run the read-only queries. The replicas are health checked
+// periodically, and the failed or lagging ones are taken out of rotation until they recover.
+type Replicas struct {
+	replicas []*replica
+	next     atomic.Uint64
+
+	maxLag              time.Duration
+	healthCheckInterval time.Duration
+}
+
+// OpenReplicas opens the connections to the replicas and checks their health.
+// A replica which is not healthy at startup is still monitored and joins the rotation once it recovers.
+func OpenReplicas(dsns []string, logLevel LogLevel, maxLag, healthCheckInterval time.Duration, opts ...DbOption) (*Replicas, error) {
+	r := &Replicas{
+		maxLag:              maxLag,
+		healthCheckInterval: healthCheckInterval,
+	}
+	for _, dsn := range dsns {
+		db, err := OpenGormDb(dsn, logLevel, append(opts, WithPoolName(replicaName(dsn)))...)
+		if err != nil {
+			_ = r.Close()
+			return nil, errors.Wrapf(err, "failed to open replica %q", replicaName(dsn))
+		}
+		r.replicas = append(r.replicas, &replica{name: replicaName(dsn), db: db})
+	}
+	r.checkAll(context.Background())
+	return r, nil
+}
+
+// Monitor health checks the replicas periodically until ctx is canceled.
+func (r *Replicas) Monitor(ctx context.Context) {
+	if len(r.replicas) == 0 || r.healthCheckInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(r.healthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.checkAll(ctx)
+		}
+	}
+}
+
+func (r *Replicas) checkAll(ctx context.Context) {
+	for _, rep := range r.replicas {
+		err := r.check(ctx, rep)
+		healthy := err == nil
+		if wasHealthy := rep.healthy.Swap(healthy); wasHealthy != healthy {
+			if healthy {
+				logger.Infof("replica %q is back in rotation", rep.name)
+			} else {
+				logger.Warnf("replica %q is taken out of rotation: %v", rep.name, err)
+			}
+		}
+	}
+}
+
+func (r *Replicas) check(ctx context.Context, rep *replica) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var lagSeconds float64
+	if err := rep.db.WithContext(ctx).Raw(replicationLagQuery).Scan(&lagSeconds).Error; err != nil {
+		return errors.Wrap(err, "health check failed")
+	}
+	if lag := time.Duration(lagSeconds * float64(time.Second)); r.maxLag > 0 && lag > r.maxLag {
+		return errors.Errorf("replication lag of %s exceeds %s", lag.Round(time.Millisecond), r.maxLag)
+	}
+	return nil
+}
+
+// pick returns one of the healthy replicas in round-robin order, or nil if none of them is healthy.
+func (r *Replicas) pick() *gorm.DB {
+	healthy := make([]*replica, 0, len(r.replicas))
+	for _, rep := range r.replicas {
+		if rep.healthy.Load() {
+			healthy = append(healthy, rep)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+	return healthy[r.next.Add(1)%uint64(len(healthy))].db
+}
+
+func (r *Replicas) Close() error {
+	var closeErr error
+	for _, rep := range r.replicas {
+		sqlDb, err := rep.db.DB()
+		if err == nil {
+			err = sqlDb.Close()
+		}
+		if err != nil {
+			closeErr = errors.Wrapf(err, "failed to close replica %q", rep.name)
+		}
+	}
+	return closeErr
+}
+
+// replicaName identifies the replica in the logs without exposing its credentials.
+func replicaName(dsn string) string {
+	u, err := dburl.Parse(dsn)
+	if err != nil {
+		return "<invalid dsn>"
+	}
+	return u.Host + u.Path
+}
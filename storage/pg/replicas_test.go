@@ -0,0 +1,75 @@
+package pg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// openLazyDb opens a db handle without connecting to the db.
+func openLazyDb(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.Open("postgres://localhost:1/none"), &gorm.Config{DisableAutomaticPing: true})
+	require.NoError(t, err)
+	return db
+}
+
+func TestReplicasPick(t *testing.T) {
+	first, second, third := openLazyDb(t), openLazyDb(t), openLazyDb(t)
+	r := &Replicas{replicas: []*replica{{name: "first", db: first}, {name: "second", db: second}, {name: "third", db: third}}}
+	assert.Nil(t, r.pick(), "no replica must be picked when none of them is healthy")
+
+	r.replicas[0].healthy.Store(true)
+	r.replicas[2].healthy.Store(true)
+	picked := map[*gorm.DB]int{}
+	for range 10 {
+		picked[r.pick()]++
+	}
+	assert.Equal(t, 5, picked[first])
+	assert.Equal(t, 5, picked[third])
+	assert.Zero(t, picked[second], "unhealthy replicas must be out of rotation")
+}
+
+func TestQuerySessionRouting(t *testing.T) {
+	primary, replicaDb := openLazyDb(t), openLazyDb(t)
+	r := &Replicas{replicas: []*replica{{name: "replica", db: replicaDb}}}
+	r.replicas[0].healthy.Store(true)
+	stg := &Stg{db: primary, replicas: r}
+
+	t.Run("reads go to the replicas", func(t *testing.T) {
+		ses := stg.querySession(context.Background())
+		require.NotNil(t, ses.readDb)
+		assert.True(t, ses.readDb.Statement.ConnPool == replicaDb.ConnPool, "the replica must be used")
+	})
+
+	t.Run("reads go to the primary when it is required", func(t *testing.T) {
+		ses := stg.querySession(storage.UsePrimary(context.Background()))
+		assert.Nil(t, ses.readDb)
+	})
+
+	t.Run("reads go to the primary inside transactions", func(t *testing.T) {
+		txCtx := storage.WithContext(context.Background(), &ormSession{db: primary, cur: &ormTxn{txn: primary}})
+		ses := stg.querySession(txCtx)
+		assert.Nil(t, ses.readDb)
+	})
+
+	t.Run("only the reads include the deleted records", func(t *testing.T) {
+		ses := stg.querySession(storage.IncludeDeleted(storage.UsePrimary(context.Background())))
+		require.NotNil(t, ses.readDb)
+		assert.True(t, ses.readDb.Statement.Unscoped)
+		assert.True(t, ses.readDb.Statement.ConnPool == primary.ConnPool, "the reads must go to the primary")
+		assert.False(t, ses.db.Statement.Unscoped, "the writes must stay scoped")
+	})
+
+	t.Run("reads go to the primary when no replica is healthy", func(t *testing.T) {
+		r.replicas[0].healthy.Store(false)
+		defer r.replicas[0].healthy.Store(true)
+		ses := stg.querySession(context.Background())
+		assert.Nil(t, ses.readDb)
+	})
+}
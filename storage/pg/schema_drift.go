@@ -0,0 +1,225 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+type DriftSeverity string
+
+const (
+	// DriftError is a drift that breaks the queries of the model, e.g. a missing column.
+	DriftError DriftSeverity = "error"
+	// DriftWarning is a drift that does not break the queries, e.g. a nullable column which the model does not have yet.
+	DriftWarning DriftSeverity = "warning"
+)
+
+// DriftIssue is a single mismatch between the schema of a model and the db.
+type DriftIssue struct {
+	Severity DriftSeverity
+	Table    string
+	Column   string
+	Message  string
+}
+
+// SchemaDriftReport lists the mismatches between the schema of the models and the db.
+type SchemaDriftReport struct {
+	Issues []*DriftIssue
+}
+
+func (r *SchemaDriftReport) HasErrors() bool {
+	return lo.SomeBy(r.Issues, func(issue *DriftIssue) bool {
+		return issue.Severity == DriftError
+	})
+}
+
+// Print writes the issues of the report as a table.
+func (r *SchemaDriftReport) Print(w io.Writer) error {
+	if len(r.Issues) == 0 {
+		_, err := fmt.Fprintln(w, "no schema drift detected")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "SEVERITY\tTABLE\tCOLUMN\tISSUE")
+	for _, issue := range r.Issues {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", issue.Severity, issue.Table, issue.Column, issue.Message)
+	}
+	return tw.Flush()
+}
+
+func (r *SchemaDriftReport) add(severity DriftSeverity, table, column, format string, args ...any) {
+	r.Issues = append(r.Issues, &DriftIssue{
+		Severity: severity,
+		Table:    table,
+		Column:   column,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// CheckSchemaDrift compares the gorm schema of the models with the tables of the db. It reports the missing and
+// extra columns, the type and nullability mismatches and the missing indexes of the models.
+func CheckSchemaDrift(ctx context.Context, db *gorm.DB, models []schema.Tabler) (*SchemaDriftReport, error) {
+	dbSchema, err := loadDbSchema(db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	report := &SchemaDriftReport{}
+	for _, model := range models {
+		if err = compareModelSchema(report, model, dbSchema); err != nil {
+			return nil, err
+		}
+	}
+	// keep the report stable for the same drift
+	slices.SortStableFunc(report.Issues, func(a, b *DriftIssue) int {
+		return strings.Compare(a.Table+"."+a.Column, b.Table+"."+b.Column)
+	})
+	return report, nil
+}
+
+func compareModelSchema(report *SchemaDriftReport, model schema.Tabler, dbSchema *storage.DbSchema) error {
+	s, err := schema.Parse(model, schemaCache, schema.NamingStrategy{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse the schema of %T", model)
+	}
+	tableName := model.TableName()
+	table := dbSchema.Table(tableName)
+	if table == nil {
+		report.add(DriftError, tableName, "", "the table does not exist")
+		return nil
+	}
+
+	for _, field := range s.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		column := table.Column(field.DBName)
+		if column == nil {
+			report.add(DriftError, tableName, field.DBName, "the column of the %s field does not exist", field.Name)
+			continue
+		}
+		if !isCompatibleType(field, column) {
+			report.add(DriftError, tableName, field.DBName, "the %s column type does not match the %s type of the %s field",
+				column.Type, field.FieldType, field.Name)
+		}
+		switch {
+		case field.PrimaryKey:
+		case column.Nullable && !canHoldNull(field.FieldType):
+			report.add(DriftError, tableName, field.DBName, "the column is nullable but the %s type of the %s field cannot hold NULL",
+				field.FieldType, field.Name)
+		case !column.Nullable && field.FieldType.Kind() == reflect.Pointer:
+			report.add(DriftError, tableName, field.DBName, "the column is not nullable but the %s field is a pointer which can be nil",
+				field.Name)
+		}
+	}
+
+	for _, column := range table.Columns {
+		if s.LookUpField(column.Name) != nil {
+			continue
+		}
+		if column.Nullable || column.Default != nil {
+			report.add(DriftWarning, tableName, column.Name, "the model does not have a field for the column")
+		} else {
+			report.add(DriftError, tableName, column.Name, "the model does not have a field for the column, which is not nullable and has no default")
+		}
+	}
+
+	dbIndexNames := lo.Map(table.Indexes, func(index *storage.IndexSchema, _ int) string {
+		return index.Name
+	})
+	for _, index := range s.ParseIndexes() {
+		if !slices.Contains(dbIndexNames, index.Name) {
+			report.add(DriftError, tableName, "", "the %s index does not exist", index.Name)
+		}
+	}
+	return nil
+}
+
+var typeSizeRegex = regexp.MustCompile(`\(.*\)`)
+
+// udtNameAliases maps the type names of the model tags to the type names of the db.
+var udtNameAliases = map[string]string{
+	"bigint":                      "int8",
+	"bigserial":                   "int8",
+	"serial8":                     "int8",
+	"integer":                     "int4",
+	"int":                         "int4",
+	"serial":                      "int4",
+	"serial4":                     "int4",
+	"smallint":                    "int2",
+	"smallserial":                 "int2",
+	"serial2":                     "int2",
+	"boolean":                     "bool",
+	"character varying":           "varchar",
+	"character":                   "bpchar",
+	"char":                        "bpchar",
+	"decimal":                     "numeric",
+	"double precision":            "float8",
+	"real":                        "float4",
+	"timestamp with time zone":    "timestamptz",
+	"timestamp without time zone": "timestamp",
+	"time with time zone":         "timetz",
+	"time without time zone":      "time",
+}
+
+// compatibleUdtNames lists the db types each gorm data type can be read from and written to.
+var compatibleUdtNames = map[schema.DataType][]string{
+	schema.Bool:   {"bool"},
+	schema.Int:    {"int2", "int4", "int8"},
+	schema.Uint:   {"int2", "int4", "int8", "numeric"},
+	schema.Float:  {"float4", "float8", "numeric"},
+	schema.String: {"text", "varchar", "bpchar", "citext", "uuid", "name", "json", "jsonb", "inet", "cidr"},
+	schema.Time:   {"timestamptz", "timestamp", "date"},
+	schema.Bytes:  {"bytea", "json", "jsonb"},
+}
+
+func normalizeUdtName(typeName string) string {
+	typeName = strings.TrimSpace(strings.ToLower(typeSizeRegex.ReplaceAllString(typeName, "")))
+	if alias, ok := udtNameAliases[typeName]; ok {
+		return alias
+	}
+	return typeName
+}
+
+func isCompatibleType(field *schema.Field, column *storage.ColumnSchema) bool {
+	if column.DataType == "USER-DEFINED" && field.DataType == schema.String {
+		// e.g. an enum type
+		return true
+	}
+	if field.DataType == "" {
+		// the type of the field is unknown to gorm, e.g. a custom type without a type tag
+		return true
+	}
+	if compatible, ok := compatibleUdtNames[field.DataType]; ok {
+		if field.DataType == schema.Int && field.Size > 0 && field.Size <= 32 {
+			compatible = []string{"int2", "int4"}
+		}
+		return slices.Contains(compatible, column.Type)
+	}
+	return normalizeUdtName(string(field.DataType)) == column.Type
+}
+
+var scannerType = reflect.TypeFor[sql.Scanner]()
+
+// canHoldNull reports whether a NULL value can be read into a field of the given type.
+func canHoldNull(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface:
+		return true
+	}
+	// e.g. sql.NullString and gorm.DeletedAt
+	return t.Kind() == reflect.Struct && reflect.PointerTo(t).Implements(scannerType)
+}
@@ -0,0 +1,108 @@
+package pg
+
+import (
+	"testing"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// usersSchema is the schema of the users table as it is created by the migrations.
+func usersSchema() *storage.DbSchema {
+	defaultValue := "default"
+	return &storage.DbSchema{Tables: []*storage.TableSchema{{
+		Name: "users",
+		Columns: []*storage.ColumnSchema{
+			{Name: "id", Type: "uuid", Default: &defaultValue},
+			{Name: "email", Type: "text"},
+			{Name: "password_hash", Type: "text"},
+			{Name: "created_at", Type: "timestamptz", Default: &defaultValue},
+			{Name: "updated_at", Type: "timestamptz", Default: &defaultValue},
+			{Name: "created_by", Type: "uuid", Nullable: true},
+			{Name: "updated_by", Type: "uuid", Nullable: true},
+			{Name: "deleted_at", Type: "timestamptz", Nullable: true},
+			{Name: "version", Type: "int8", Default: &defaultValue},
+		},
+		PrimaryKey: []string{"id"},
+		Indexes:    []*storage.IndexSchema{{Name: "users_pkey", Primary: true, Unique: true}, {Name: "idx_users_email", Unique: true}},
+	}}}
+}
+
+func TestCompareModelSchema(t *testing.T) {
+	tests := []struct {
+		name       string
+		alter      func(table *storage.TableSchema)
+		wantIssues []DriftIssue
+	}{
+		{
+			name:  "matches the migrations",
+			alter: func(*storage.TableSchema) {},
+		},
+		{
+			name: "reports a missing column",
+			alter: func(table *storage.TableSchema) {
+				table.Columns = table.Columns[:len(table.Columns)-1]
+			},
+			wantIssues: []DriftIssue{{Severity: DriftError, Table: "users", Column: "version"}},
+		},
+		{
+			name: "reports a type mismatch",
+			alter: func(table *storage.TableSchema) {
+				table.Column("email").Type = "int4"
+			},
+			wantIssues: []DriftIssue{{Severity: DriftError, Table: "users", Column: "email"}},
+		},
+		{
+			name: "reports a nullable column of a non-nullable field",
+			alter: func(table *storage.TableSchema) {
+				table.Column("password_hash").Nullable = true
+			},
+			wantIssues: []DriftIssue{{Severity: DriftError, Table: "users", Column: "password_hash"}},
+		},
+		{
+			name: "reports a non-nullable column of a pointer field",
+			alter: func(table *storage.TableSchema) {
+				table.Column("created_by").Nullable = false
+			},
+			wantIssues: []DriftIssue{{Severity: DriftError, Table: "users", Column: "created_by"}},
+		},
+		{
+			name: "warns about an extra nullable column",
+			alter: func(table *storage.TableSchema) {
+				table.Columns = append(table.Columns, &storage.ColumnSchema{Name: "nickname", Type: "text", Nullable: true})
+			},
+			wantIssues: []DriftIssue{{Severity: DriftWarning, Table: "users", Column: "nickname"}},
+		},
+		{
+			name: "reports an extra required column",
+			alter: func(table *storage.TableSchema) {
+				table.Columns = append(table.Columns, &storage.ColumnSchema{Name: "nickname", Type: "text"})
+			},
+			wantIssues: []DriftIssue{{Severity: DriftError, Table: "users", Column: "nickname"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbSchema := usersSchema()
+			tt.alter(dbSchema.Table("users"))
+
+			report := &SchemaDriftReport{}
+			require.NoError(t, compareModelSchema(report, &model.User{}, dbSchema))
+
+			issues := make([]DriftIssue, 0)
+			for _, issue := range report.Issues {
+				issues = append(issues, DriftIssue{Severity: issue.Severity, Table: issue.Table, Column: issue.Column})
+			}
+			assert.ElementsMatch(t, tt.wantIssues, issues, "issues: %v", report.Issues)
+		})
+	}
+
+	t.Run("reports a missing table", func(t *testing.T) {
+		report := &SchemaDriftReport{}
+		require.NoError(t, compareModelSchema(report, &model.Job{}, usersSchema()))
+		require.Len(t, report.Issues, 1)
+		assert.True(t, report.HasErrors())
+	})
+}
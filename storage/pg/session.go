@@ -21,8 +21,8 @@ type ormTxn struct {
 
 func newOrmTxn(db *gorm.DB) (*ormTxn, error) {
 	txn := db.Begin()
-	if db.Error != nil {
-		return nil, db.Error
+	if txn.Error != nil {
+		return nil, txn.Error
 	}
 	return &ormTxn{
 		id:  0,
@@ -32,22 +32,28 @@ func newOrmTxn(db *gorm.DB) (*ormTxn, error) {
 
 func newNestOrmTxn(cur *ormTxn) (*ormTxn, error) {
 	id := cur.id + 1
-	sp := fmt.Sprintf("sp%d", id)
-	txn := cur.txn.SavePoint(sp)
-	if cur.txn.Error != nil {
-		return nil, cur.txn.Error
+	// run the savepoint statements in a separate session so their errors do not stick to the transaction
+	if err := cur.txn.Session(&gorm.Session{}).SavePoint(savePointName(id)).Error; err != nil {
+		return nil, err
 	}
 	next := &ormTxn{
 		id:     id,
-		txn:    txn,
+		txn:    cur.txn,
 		parent: cur,
 	}
 	cur.next = next
 	return next, nil
 }
 
+func savePointName(id int) string {
+	return fmt.Sprintf("sp%d", id)
+}
+
 type ormSession struct {
 	db *gorm.DB
+	// readDb is the replica that runs the read-only queries of the session, or nil if they must go to the primary.
+	// The transactional sessions never have a replica.
+	readDb *gorm.DB
 
 	cur *ormTxn
 
@@ -91,18 +97,14 @@ func (ses *ormSession) Rollback() error {
 	cur.resolved = true
 
 	if ses.cur.parent == nil {
-		ses.db.Rollback()
-		if ses.db.Error != nil {
-			return ses.db.Error
-		}
-		return nil
+		return ses.db.Session(&gorm.Session{}).Rollback().Error
 	}
-	sp := fmt.Sprintf("sp%d", ses.cur.id)
-	ses.db.RollbackTo(sp)
-	if ses.db.Error != nil {
-		return ses.db.Error
+	sp := savePointName(ses.cur.id)
+	if err := ses.db.Session(&gorm.Session{}).RollbackTo(sp).Error; err != nil {
+		return err
 	}
-	return nil
+	// the savepoint is not needed anymore after rolling back to it
+	return ses.db.Session(&gorm.Session{}).Exec("RELEASE SAVEPOINT " + sp).Error
 }
 
 func (ses *ormSession) Commit() error {
@@ -118,16 +120,11 @@ func (ses *ormSession) Commit() error {
 	cur.resolved = true
 
 	if ses.cur.parent != nil {
-		// do nothing, gorm does not provide a way to release the savepoint.
-		// we just pop out the last element of the savepoint by decrement the
-		// right boundary of savepoints
-		return nil
+		// gorm does not provide a way to release the savepoint, therefore we release it manually.
+		// releasing a savepoint keeps its changes as part of the parent transaction.
+		return ses.db.Session(&gorm.Session{}).Exec("RELEASE SAVEPOINT " + savePointName(ses.cur.id)).Error
 	}
-	ses.db.Commit()
-	if ses.db.Error != nil {
-		return ses.db.Error
-	}
-	return nil
+	return ses.db.Session(&gorm.Session{}).Commit().Error
 }
 
 func (ses *ormSession) Close() error {
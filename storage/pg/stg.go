@@ -4,22 +4,49 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"time"
 
 	"github.com/amahdian/golang-gin-boilerplate/storage"
 	"gorm.io/gorm"
 )
 
 type Stg struct {
-	db *gorm.DB
+	db       *gorm.DB
+	replicas *Replicas
+	schema   *dbSchemaCache
 }
 
-func NewStg(db *gorm.DB) storage.Storage {
-	return &Stg{db: db}
+type StgOption func(stg *Stg)
+
+// WithReplicas routes the read-only queries outside the transactions to the healthy replicas.
+func WithReplicas(replicas *Replicas) StgOption {
+	return func(stg *Stg) {
+		stg.replicas = replicas
+	}
+}
+
+// WithSchemaCacheTTL expires the cached schema of the db after the ttl. The schema is cached until it is
+// invalidated by default.
+func WithSchemaCacheTTL(ttl time.Duration) StgOption {
+	return func(stg *Stg) {
+		stg.schema.ttl = ttl
+	}
+}
+
+func NewStg(db *gorm.DB, opts ...StgOption) storage.Storage {
+	registerAuditHooks(db)
+	stg := &Stg{db: db, schema: newDbSchemaCache(0)}
+	for _, opt := range opts {
+		opt(stg)
+	}
+	return stg
 }
 
 func (stg *Stg) WithContext(ctx context.Context) storage.Storage {
 	return &Stg{
-		db: stg.mustOrmSession(ctx).db,
+		db:       stg.mustOrmSession(ctx).db,
+		replicas: stg.replicas,
+		schema:   stg.schema,
 	}
 }
 
@@ -44,7 +71,8 @@ func (stg *Stg) Atomic(fn func(atomicStorage storage.Storage) error) (err error)
 	}()
 
 	txStorage := &Stg{
-		db: tx,
+		db:     tx,
+		schema: stg.schema,
 	}
 
 	err = fn(txStorage)
@@ -101,6 +129,50 @@ func (stg *Stg) mustOrmSession(ctx context.Context) *ormSession {
 	return ses
 }
 
+// querySession returns the session of ctx with the query options of ctx (e.g. including the deleted records) applied.
+// The read-only queries of the session go to a replica unless the session is transactional or ctx requires the primary.
+// Only the read-only queries include the deleted records, the writes stay scoped so a delete is never a hard delete.
+func (stg *Stg) querySession(ctx context.Context) *ormSession {
+	ses := stg.mustOrmSession(ctx)
+	readDb := stg.replicaDb(ctx, ses)
+	if storage.DeletedIncluded(ctx) {
+		if readDb == nil {
+			readDb = ses.db
+		}
+		return &ormSession{db: ses.db, readDb: readDb.Unscoped(), cur: ses.cur}
+	}
+	if readDb != nil {
+		return &ormSession{db: ses.db, readDb: readDb, cur: ses.cur}
+	}
+	return ses
+}
+
+func (stg *Stg) replicaDb(ctx context.Context, ses *ormSession) *gorm.DB {
+	if stg.replicas == nil || ses.cur != nil || storage.PrimaryRequired(ctx) {
+		return nil
+	}
+	if replicaDb := stg.replicas.pick(); replicaDb != nil {
+		return replicaDb.WithContext(ctx)
+	}
+	return nil
+}
+
 func (stg *Stg) User(ctx context.Context) storage.UserStorage {
-	return NewUserStg(stg.mustOrmSession(ctx))
+	return NewUserStg(stg.querySession(ctx))
+}
+
+func (stg *Stg) Outbox(ctx context.Context) storage.OutboxStorage {
+	return NewOutboxStg(stg.querySession(ctx))
+}
+
+func (stg *Stg) Job(ctx context.Context) storage.JobStorage {
+	return NewJobStg(stg.querySession(ctx))
+}
+
+func (stg *Stg) CronRun(ctx context.Context) storage.CronRunStorage {
+	return NewCronRunStg(stg.querySession(ctx))
+}
+
+func (stg *Stg) Metadata(ctx context.Context) storage.MetadataStorage {
+	return NewMetadataStg(stg.mustOrmSession(ctx), stg.schema)
 }
@@ -2,6 +2,7 @@ package pg
 
 import (
 	"fmt"
+	"reflect"
 	"sync"
 
 	"gorm.io/gorm"
@@ -28,6 +29,12 @@ func getGormSchema(model interface{}) *schema.Schema {
 	return s
 }
 
+// newModel returns a new zero model, M being a pointer to the model struct.
+func newModel[M any]() M {
+	var model M
+	return reflect.New(reflect.TypeOf(model).Elem()).Interface().(M)
+}
+
 func withAlias(table schema.Tabler, alias string) string {
 	return fmt.Sprintf("%s AS %s", table.TableName(), alias)
 }
@@ -0,0 +1,353 @@
+package pg
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/amahdian/golang-gin-boilerplate/assets"
+	"github.com/amahdian/golang-gin-boilerplate/pkg/logger"
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+)
+
+// adminDbName is the maintenance db the template dbs are created from.
+const adminDbName = "postgres"
+
+// templateInfix separates the name of the test db and the migrations hash in the names of the template dbs.
+const templateInfix = "_tmpl_"
+
+// templateDbPool lends the test dbs cloned from a template db which has all the migrations applied.
+//
+// The clones are created once and reused by the later tests and test runs, since every test runs in a transaction
+// which is rolled back at the end. A clone is owned by a single process at a time: the process holds an advisory lock
+// of the clone on its admin connection until it exits, so the test packages running in parallel never share a clone.
+type templateDbPool struct {
+	dsn      *url.URL
+	template string
+	admin    *sql.Conn
+
+	// free has the clones owned by the process which are not used by a test
+	free chan string
+	mu   sync.Mutex
+	// owned are the clones owned by the process, at most cap(free)
+	owned map[string]bool
+}
+
+var templateDbPools = struct {
+	sync.Mutex
+	pools map[string]*templateDbPool
+}{pools: map[string]*templateDbPool{}}
+
+// getTemplateDbPool returns the pool of the server of the dsn, and creates its template db on the first use.
+// The name of the template db has the hash of the migrations, so a new template is created when they change.
+func getTemplateDbPool(ctx context.Context, dsn string) (*templateDbPool, error) {
+	templateDbPools.Lock()
+	defer templateDbPools.Unlock()
+	if pool, ok := templateDbPools.pools[dsn]; ok {
+		return pool, nil
+	}
+
+	if !strings.Contains(dsn, "://") {
+		return nil, errors.New("the template dbs need a dsn in the url format")
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse the dsn")
+	}
+	hash, err := migrationsHash()
+	if err != nil {
+		return nil, err
+	}
+	pool := &templateDbPool{
+		dsn:      u,
+		template: strings.TrimPrefix(u.Path, "/") + templateInfix + hash,
+		free:     make(chan string, runtime.GOMAXPROCS(0)),
+		owned:    map[string]bool{},
+	}
+
+	// the admin db is never closed, since the advisory locks of the clones must be held until the process exits
+	adminDb, err := sql.Open("pgx", pool.dbDsn(adminDbName))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open connection to the admin db")
+	}
+	if pool.admin, err = adminDb.Conn(ctx); err != nil {
+		_ = adminDb.Close()
+		return nil, errors.Wrap(err, "failed to get a connection to the admin db")
+	}
+	if err = pool.createTemplate(ctx); err != nil {
+		_ = pool.admin.Close()
+		_ = adminDb.Close()
+		return nil, err
+	}
+	templateDbPools.pools[dsn] = pool
+	return pool, nil
+}
+
+// acquire returns the dsn of a clone which is not used by any other test. It waits for a clone to be released if the
+// process already owns as many clones as it can run tests in parallel.
+func (p *templateDbPool) acquire(ctx context.Context) (dsn string, release func(), err error) {
+	var name string
+	select {
+	case name = <-p.free:
+	default:
+		if name, err = p.claim(ctx); err != nil {
+			return "", nil, err
+		}
+		if name == "" {
+			select {
+			case name = <-p.free:
+			case <-ctx.Done():
+				return "", nil, ctx.Err()
+			}
+		}
+	}
+	release = func() {
+		p.free <- name
+	}
+	return p.dbDsn(name), release, nil
+}
+
+// claim takes the ownership of a new clone and creates it if it does not exist yet. The name is empty if the process
+// already owns all the clones it may own.
+func (p *templateDbPool) claim(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.owned) >= cap(p.free) {
+		return "", nil
+	}
+
+	for i := 1; ; i++ {
+		name := fmt.Sprintf("%s_%d", p.template, i)
+		if p.owned[name] {
+			// the advisory locks are reentrant, so the clones of the process must be skipped explicitly
+			continue
+		}
+		// the lock is kept until the process exits
+		unlock, acquired, err := p.tryLock(ctx, name)
+		if err != nil {
+			return "", err
+		}
+		if !acquired {
+			// owned by another process
+			continue
+		}
+		if err = p.createDb(ctx, name, p.template); err != nil {
+			unlock()
+			return "", err
+		}
+		p.owned[name] = true
+		return name, nil
+	}
+}
+
+// createTemplate creates the template db and applies the migrations, unless another process has already created it.
+// The db is migrated under a temporary name, so a template is never used before all the migrations are applied.
+func (p *templateDbPool) createTemplate(ctx context.Context) error {
+	exists, err := p.dbExists(ctx, p.template)
+	if err != nil || exists {
+		return err
+	}
+
+	building := p.template + "_building"
+	unlock, err := p.lockTemplate(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if exists, err = p.dbExists(ctx, p.template); err != nil || exists {
+		return err
+	}
+	// the leftover of a process which has crashed in the middle of the migrations
+	if _, err = p.admin.ExecContext(ctx, "DROP DATABASE IF EXISTS "+quoteIdent(building)); err != nil {
+		return errors.Wrapf(err, "failed to drop the test db %s", building)
+	}
+	if _, err = p.admin.ExecContext(ctx, "CREATE DATABASE "+quoteIdent(building)); err != nil {
+		return errors.Wrapf(err, "failed to create the test db %s", building)
+	}
+
+	migrator, err := NewMigrator(ctx, p.dbDsn(building))
+	if err != nil {
+		return err
+	}
+	err = migrator.Up(0)
+	_ = migrator.Close()
+	if err != nil {
+		return errors.Wrap(err, "failed to migrate the template test db")
+	}
+
+	_, err = p.admin.ExecContext(ctx, fmt.Sprintf("ALTER DATABASE %s RENAME TO %s", quoteIdent(building), quoteIdent(p.template)))
+	if err != nil {
+		return errors.Wrapf(err, "failed to rename the template test db %s", building)
+	}
+
+	// the migrations have changed, so the dbs of the previous migrations are not used anymore
+	if err = p.dropStaleTemplates(ctx); err != nil {
+		logger.Warnf("failed to drop the stale template test dbs: %v", err)
+	}
+	return nil
+}
+
+// dropStaleTemplates drops the template dbs of the other migrations hashes with their clones, so they don't pile up
+// on the server. The clones owned by another process (e.g. a test run of another branch) are left for a later run,
+// and so is their template, since the process may still clone it.
+func (p *templateDbPool) dropStaleTemplates(ctx context.Context) error {
+	prefix := p.template[:strings.LastIndex(p.template, templateInfix)+len(templateInfix)]
+	rows, err := p.admin.QueryContext(ctx,
+		"SELECT datname FROM pg_database WHERE starts_with(datname, $1) AND NOT starts_with(datname, $2) ORDER BY datname",
+		prefix, p.template)
+	if err != nil {
+		return errors.Wrap(err, "failed to list the template test dbs")
+	}
+	defer rows.Close()
+	// the dbs of every template, i.e. the template itself, its clones and the template being built
+	dbsByTemplate := map[string][]string{}
+	var templates []string
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return errors.Wrap(err, "failed to list the template test dbs")
+		}
+		template, _, _ := strings.Cut(name[len(prefix):], "_")
+		template = prefix + template
+		if _, ok := dbsByTemplate[template]; !ok {
+			templates = append(templates, template)
+		}
+		dbsByTemplate[template] = append(dbsByTemplate[template], name)
+	}
+	if err = rows.Err(); err != nil {
+		return errors.Wrap(err, "failed to list the template test dbs")
+	}
+
+	for _, template := range templates {
+		// the template lock is held while the template is built or cloned
+		unlock, acquired, err := p.tryLock(ctx, template)
+		if err != nil {
+			return err
+		}
+		if !acquired {
+			continue
+		}
+		err = p.dropTemplate(ctx, template, dbsByTemplate[template])
+		unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropTemplate drops the clones of the template which are not owned by a process, then the template itself if all
+// its clones are dropped. The template lock must be held.
+func (p *templateDbPool) dropTemplate(ctx context.Context, template string, dbs []string) error {
+	inUse := false
+	for _, name := range dbs {
+		if name == template {
+			continue
+		}
+		unlock := func() {}
+		if name != template+"_building" {
+			var acquired bool
+			var err error
+			if unlock, acquired, err = p.tryLock(ctx, name); err != nil {
+				return err
+			}
+			if !acquired {
+				inUse = true
+				continue
+			}
+		}
+		_, err := p.admin.ExecContext(ctx, "DROP DATABASE IF EXISTS "+quoteIdent(name))
+		unlock()
+		if err != nil {
+			return errors.Wrapf(err, "failed to drop the test db %s", name)
+		}
+	}
+	if inUse || !lo.Contains(dbs, template) {
+		return nil
+	}
+	_, err := p.admin.ExecContext(ctx, "DROP DATABASE IF EXISTS "+quoteIdent(template))
+	return errors.Wrapf(err, "failed to drop the template test db %s", template)
+}
+
+// createDb creates the db as a clone of the template unless it already exists.
+func (p *templateDbPool) createDb(ctx context.Context, name, template string) error {
+	exists, err := p.dbExists(ctx, name)
+	if err != nil || exists {
+		return err
+	}
+	// the template must not be cloned by two sessions at the same time
+	unlock, err := p.lockTemplate(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	_, err = p.admin.ExecContext(ctx, fmt.Sprintf("CREATE DATABASE %s TEMPLATE %s", quoteIdent(name), quoteIdent(template)))
+	return errors.Wrapf(err, "failed to create the test db %s", name)
+}
+
+// tryLock takes the advisory lock of the db without waiting, e.g. the lock of a clone held by its owner process.
+func (p *templateDbPool) tryLock(ctx context.Context, name string) (unlock func(), acquired bool, err error) {
+	key := advisoryLockKey(name)
+	if err = p.admin.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired); err != nil {
+		return nil, false, errors.Wrapf(err, "failed to lock the test db %s", name)
+	}
+	return func() {
+		_, _ = p.admin.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+	}, acquired, nil
+}
+
+func (p *templateDbPool) lockTemplate(ctx context.Context) (unlock func(), err error) {
+	key := advisoryLockKey(p.template)
+	if _, err = p.admin.ExecContext(ctx, "SELECT pg_advisory_lock($1)", key); err != nil {
+		return nil, errors.Wrapf(err, "failed to lock the template test db %s", p.template)
+	}
+	return func() {
+		_, _ = p.admin.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+	}, nil
+}
+
+func (p *templateDbPool) dbExists(ctx context.Context, name string) (exists bool, err error) {
+	err = p.admin.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1)", name).Scan(&exists)
+	return exists, errors.Wrapf(err, "failed to look up the test db %s", name)
+}
+
+// dbDsn returns the dsn of the given db on the server of the pool.
+func (p *templateDbPool) dbDsn(name string) string {
+	u := *p.dsn
+	u.Path = "/" + name
+	return u.String()
+}
+
+// migrationsHash returns a short hash of the embedded migrations.
+func migrationsHash() (string, error) {
+	hash := sha256.New()
+	err := fs.WalkDir(assets.Migrations, assets.MigrationsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := fs.ReadFile(assets.Migrations, path)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(hash, "%s\n%d\n", path, len(content))
+		_, _ = hash.Write(content)
+		return nil
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read the embedded migrations")
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:12], nil
+}
+
+func quoteIdent(name string) string {
+	return pgx.Identifier{name}.Sanitize()
+}
@@ -0,0 +1,273 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+const stmtSavepointName = "test_stmt"
+
+// testConn is the connection of a test db in the transaction mode. The statements of the test and of its subtests
+// all run in a single transaction of this connection, which is rolled back when the test ends.
+type testConn struct {
+	db   *sql.DB
+	conn *sql.Conn
+
+	mu sync.Mutex
+	// stmtSavepoint is true while the savepoint of the last query is open, see txTestPool.
+	stmtSavepoint bool
+	savepoints    int
+}
+
+// beginTestTx returns a db which runs all its statements in a transaction of a dedicated connection, and a function
+// which rolls the transaction back and returns the connection to the pool.
+func beginTestTx(db *gorm.DB) (*gorm.DB, func() error, error) {
+	sqlDb, err := db.DB()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get underlying sql db from gorm")
+	}
+	ctx := context.Background()
+	conn, err := sqlDb.Conn(ctx)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get a connection for the test transaction")
+	}
+	if _, err = conn.ExecContext(ctx, "BEGIN"); err != nil {
+		_ = conn.Close()
+		return nil, nil, errors.Wrap(err, "failed to begin the test transaction")
+	}
+
+	tc := &testConn{db: sqlDb, conn: conn}
+	rollback := func() error {
+		tc.mu.Lock()
+		defer tc.mu.Unlock()
+		defer conn.Close()
+		_, err := conn.ExecContext(ctx, "ROLLBACK")
+		return err
+	}
+	return withConnPool(db, &txTestPool{tc: tc}), rollback, nil
+}
+
+// SubTestDb returns a db for a subtest of a test which runs in the transaction mode (see WithTxIsolation).
+// The subtest runs in a savepoint of the transaction of the test, which is rolled back when the subtest ends.
+// The subtests sharing the transaction of their test cannot run in parallel, use WithTemplateDb instead.
+func SubTestDb(t *testing.T, db *gorm.DB) *gorm.DB {
+	pool, ok := db.Statement.ConnPool.(*txTestPool)
+	if !ok {
+		t.Fatalf("the db of the subtest must be a test db in the transaction mode, see WithTxIsolation")
+	}
+	tc := pool.tc
+	ctx := context.Background()
+
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	savepoint, err := tc.savepoint(ctx, "test_sub")
+	if err != nil {
+		t.Fatalf("could not begin the savepoint of the subtest: %v", err)
+	}
+	t.Cleanup(func() {
+		tc.mu.Lock()
+		defer tc.mu.Unlock()
+		if err := tc.rollbackTo(ctx, savepoint); err != nil {
+			t.Errorf("could not roll back the savepoint of the subtest: %v", err)
+		}
+	})
+	return withConnPool(db, &txTestPool{tc: tc})
+}
+
+func withConnPool(db *gorm.DB, pool gorm.ConnPool) *gorm.DB {
+	tx := db.Session(&gorm.Session{NewDB: true})
+	tx.Statement.ConnPool = pool
+	return tx
+}
+
+// savepoint starts a new savepoint with a unique name and returns its name. The caller must hold tc.mu.
+func (tc *testConn) savepoint(ctx context.Context, prefix string) (string, error) {
+	if err := tc.closeStmtSavepoint(ctx); err != nil {
+		return "", err
+	}
+	tc.savepoints++
+	name := fmt.Sprintf("%s%d", prefix, tc.savepoints)
+	_, err := tc.conn.ExecContext(ctx, "SAVEPOINT "+name)
+	return name, err
+}
+
+// rollbackTo undoes the changes of the savepoint and releases it. The caller must hold tc.mu.
+func (tc *testConn) rollbackTo(ctx context.Context, name string) error {
+	if err := tc.closeStmtSavepoint(ctx); err != nil {
+		return err
+	}
+	if _, err := tc.conn.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); err != nil {
+		return err
+	}
+	_, err := tc.conn.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+	return err
+}
+
+// openStmtSavepoint starts the savepoint of the next statement. The caller must hold tc.mu.
+func (tc *testConn) openStmtSavepoint(ctx context.Context) error {
+	if err := tc.closeStmtSavepoint(ctx); err != nil {
+		return err
+	}
+	if _, err := tc.conn.ExecContext(ctx, "SAVEPOINT "+stmtSavepointName); err != nil {
+		return err
+	}
+	tc.stmtSavepoint = true
+	return nil
+}
+
+// closeStmtSavepoint releases the savepoint of the last statement. The savepoint is rolled back instead if the
+// statement has failed after it returned, e.g. while its rows were read. The caller must hold tc.mu.
+func (tc *testConn) closeStmtSavepoint(ctx context.Context) error {
+	if !tc.stmtSavepoint {
+		return nil
+	}
+	tc.stmtSavepoint = false
+	if _, err := tc.conn.ExecContext(ctx, "RELEASE SAVEPOINT "+stmtSavepointName); err == nil {
+		return nil
+	}
+	if _, err := tc.conn.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+stmtSavepointName); err != nil {
+		return errors.Wrap(err, "failed to roll back the savepoint of the failed statement")
+	}
+	_, err := tc.conn.ExecContext(ctx, "RELEASE SAVEPOINT "+stmtSavepointName)
+	return err
+}
+
+// txTestPool is the connection pool of a test db in the transaction mode. Every statement runs in a savepoint of its
+// own, so a failed statement only undoes itself instead of aborting the transaction of the test, the same way it
+// fails outside a transaction. The transactions of the test (e.g. storage.Storage.RunInTx) run in savepoints too.
+//
+// The savepoint of a query is released before the next statement, since the connection can't run another statement
+// while the rows of the query are read.
+type txTestPool struct {
+	tc *testConn
+}
+
+func (p *txTestPool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return p.tc.conn.PrepareContext(ctx, query)
+}
+
+func (p *txTestPool) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	p.tc.mu.Lock()
+	defer p.tc.mu.Unlock()
+	if err := p.tc.openStmtSavepoint(ctx); err != nil {
+		return nil, err
+	}
+	result, err := p.tc.conn.ExecContext(ctx, query, args...)
+	if closeErr := p.tc.closeStmtSavepoint(ctx); err == nil {
+		err = closeErr
+	}
+	return result, err
+}
+
+func (p *txTestPool) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	p.tc.mu.Lock()
+	defer p.tc.mu.Unlock()
+	if err := p.tc.openStmtSavepoint(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := p.tc.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		_ = p.tc.closeStmtSavepoint(ctx)
+	}
+	return rows, err
+}
+
+func (p *txTestPool) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	p.tc.mu.Lock()
+	defer p.tc.mu.Unlock()
+	// the row can't carry the error of the savepoint, but the query fails the same way if the connection is broken
+	_ = p.tc.openStmtSavepoint(ctx)
+	return p.tc.conn.QueryRowContext(ctx, query, args...)
+}
+
+// BeginTx starts a savepoint in place of a transaction.
+func (p *txTestPool) BeginTx(ctx context.Context, _ *sql.TxOptions) (gorm.ConnPool, error) {
+	p.tc.mu.Lock()
+	defer p.tc.mu.Unlock()
+	name, err := p.tc.savepoint(ctx, "test_tx")
+	if err != nil {
+		return nil, err
+	}
+	return &txTestSavepoint{tc: p.tc, name: name}, nil
+}
+
+// GetDBConn returns the db of the connection, which is used by the statements that need a connection of their own,
+// e.g. the advisory locks.
+func (p *txTestPool) GetDBConn() (*sql.DB, error) {
+	return p.tc.db, nil
+}
+
+// Raw runs f on the driver connection of the test transaction, e.g. to copy the rows in the transaction.
+func (p *txTestPool) Raw(f func(driverConn any) error) error {
+	p.tc.mu.Lock()
+	defer p.tc.mu.Unlock()
+	if err := p.tc.openStmtSavepoint(context.Background()); err != nil {
+		return err
+	}
+	err := p.tc.conn.Raw(f)
+	if closeErr := p.tc.closeStmtSavepoint(context.Background()); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// txTestSavepoint is a transaction of a test in the transaction mode. A failed statement aborts it like any other
+// transaction.
+type txTestSavepoint struct {
+	tc   *testConn
+	name string
+}
+
+func (s *txTestSavepoint) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return s.tc.conn.PrepareContext(ctx, query)
+}
+
+func (s *txTestSavepoint) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	s.tc.mu.Lock()
+	defer s.tc.mu.Unlock()
+	return s.tc.conn.ExecContext(ctx, query, args...)
+}
+
+func (s *txTestSavepoint) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	s.tc.mu.Lock()
+	defer s.tc.mu.Unlock()
+	return s.tc.conn.QueryContext(ctx, query, args...)
+}
+
+func (s *txTestSavepoint) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	s.tc.mu.Lock()
+	defer s.tc.mu.Unlock()
+	return s.tc.conn.QueryRowContext(ctx, query, args...)
+}
+
+func (s *txTestSavepoint) Commit() error {
+	s.tc.mu.Lock()
+	defer s.tc.mu.Unlock()
+	ctx := context.Background()
+	if err := s.tc.closeStmtSavepoint(ctx); err != nil {
+		return err
+	}
+	_, err := s.tc.conn.ExecContext(ctx, "RELEASE SAVEPOINT "+s.name)
+	return err
+}
+
+func (s *txTestSavepoint) Rollback() error {
+	s.tc.mu.Lock()
+	defer s.tc.mu.Unlock()
+	return s.tc.rollbackTo(context.Background(), s.name)
+}
+
+func (s *txTestSavepoint) GetDBConn() (*sql.DB, error) {
+	return s.tc.db, nil
+}
+
+var (
+	_ gorm.ConnPoolBeginner = &txTestPool{}
+	_ gorm.TxCommitter      = &txTestSavepoint{}
+)
@@ -0,0 +1,214 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// recordingConn records the statements and emulates the aborted transactions of postgres: once a statement fails,
+// every statement fails until the transaction is rolled back, or rolled back to a savepoint.
+type recordingConn struct {
+	mu         sync.Mutex
+	statements []string
+	aborted    bool
+}
+
+func (c *recordingConn) run(query string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.statements = append(c.statements, query)
+	switch {
+	case strings.HasPrefix(query, "ROLLBACK"):
+		c.aborted = false
+	case c.aborted:
+		return errors.New("current transaction is aborted")
+	case strings.Contains(query, "fail"):
+		c.aborted = true
+		return errors.New("failed")
+	}
+	return nil
+}
+
+func (c *recordingConn) recorded() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	statements := c.statements
+	c.statements = nil
+	return statements
+}
+
+func (c *recordingConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if err := c.run(query); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *recordingConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	if err := c.run(query); err != nil {
+		return nil, err
+	}
+	return emptyRows{}, nil
+}
+
+func (c *recordingConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c *recordingConn) Close() error {
+	return nil
+}
+
+func (c *recordingConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string {
+	return nil
+}
+
+func (emptyRows) Close() error {
+	return nil
+}
+
+func (emptyRows) Next([]driver.Value) error {
+	return io.EOF
+}
+
+type recordingConnector struct {
+	conn *recordingConn
+}
+
+func (c recordingConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.conn, nil
+}
+
+func (c recordingConnector) Driver() driver.Driver {
+	return nil
+}
+
+func openRecordingTestTx(t *testing.T) (*gorm.DB, *recordingConn, func() error) {
+	t.Helper()
+	conn := &recordingConn{}
+	sqlDb := sql.OpenDB(recordingConnector{conn: conn})
+	t.Cleanup(func() {
+		_ = sqlDb.Close()
+	})
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDb}), &gorm.Config{DisableAutomaticPing: true})
+	require.NoError(t, err)
+
+	txDb, rollback, err := beginTestTx(db)
+	require.NoError(t, err)
+	require.Equal(t, []string{"BEGIN"}, conn.recorded())
+	return txDb, conn, rollback
+}
+
+func TestTestTxStatements(t *testing.T) {
+	db, conn, rollback := openRecordingTestTx(t)
+
+	require.NoError(t, db.Exec("INSERT ok").Error)
+	assert.Equal(t, []string{"SAVEPOINT test_stmt", "INSERT ok", "RELEASE SAVEPOINT test_stmt"}, conn.recorded())
+
+	// a failed statement must not abort the transaction of the test
+	require.Error(t, db.Exec("INSERT fail").Error)
+	assert.Equal(t, []string{
+		"SAVEPOINT test_stmt",
+		"INSERT fail",
+		"RELEASE SAVEPOINT test_stmt",
+		"ROLLBACK TO SAVEPOINT test_stmt",
+		"RELEASE SAVEPOINT test_stmt",
+	}, conn.recorded())
+	require.NoError(t, db.Exec("INSERT ok").Error)
+	conn.recorded()
+
+	// the savepoint of a query is released by the next statement
+	rows, err := db.Raw("SELECT ok").Rows()
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+	assert.Equal(t, []string{"SAVEPOINT test_stmt", "SELECT ok"}, conn.recorded())
+	require.NoError(t, db.Exec("INSERT ok").Error)
+	assert.Equal(t, []string{
+		"RELEASE SAVEPOINT test_stmt",
+		"SAVEPOINT test_stmt",
+		"INSERT ok",
+		"RELEASE SAVEPOINT test_stmt",
+	}, conn.recorded())
+
+	require.NoError(t, rollback())
+	assert.Equal(t, []string{"ROLLBACK"}, conn.recorded())
+}
+
+func TestTestTxTransactions(t *testing.T) {
+	db, conn, _ := openRecordingTestTx(t)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return tx.Exec("INSERT ok").Error
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"SAVEPOINT test_tx1", "INSERT ok", "RELEASE SAVEPOINT test_tx1"}, conn.recorded())
+
+	// a failed statement aborts the transaction, the same way it does outside the tests
+	err = db.Transaction(func(tx *gorm.DB) error {
+		_ = tx.Exec("INSERT fail")
+		return tx.Exec("INSERT ok").Error
+	})
+	require.Error(t, err)
+	assert.Equal(t, []string{
+		"SAVEPOINT test_tx2",
+		"INSERT fail",
+		"INSERT ok",
+		"ROLLBACK TO SAVEPOINT test_tx2",
+		"RELEASE SAVEPOINT test_tx2",
+	}, conn.recorded())
+}
+
+func TestSubTestDb(t *testing.T) {
+	db, conn, _ := openRecordingTestTx(t)
+
+	t.Run("subtest", func(t *testing.T) {
+		subDb := SubTestDb(t, db)
+		require.NoError(t, subDb.Exec("INSERT ok").Error)
+	})
+	assert.Equal(t, []string{
+		"SAVEPOINT test_sub1",
+		"SAVEPOINT test_stmt",
+		"INSERT ok",
+		"RELEASE SAVEPOINT test_stmt",
+		"ROLLBACK TO SAVEPOINT test_sub1",
+		"RELEASE SAVEPOINT test_sub1",
+	}, conn.recorded())
+}
+
+func TestTestTxStorage(t *testing.T) {
+	db, conn, _ := openRecordingTestTx(t)
+	stg := NewStg(db)
+
+	// the transactions of the storage and their savepoints run in the transaction of the test
+	err := stg.RunInTx(context.Background(), func(ctx context.Context) error {
+		return stg.RunInTx(ctx, func(ctx context.Context) error {
+			return errors.New("failed")
+		})
+	})
+	require.Error(t, err)
+	assert.Equal(t, []string{
+		"SAVEPOINT test_tx1",
+		"SAVEPOINT sp1",
+		"ROLLBACK TO SAVEPOINT sp1",
+		"RELEASE SAVEPOINT sp1",
+		"ROLLBACK TO SAVEPOINT test_tx1",
+		"RELEASE SAVEPOINT test_tx1",
+	}, conn.recorded())
+}
@@ -0,0 +1,80 @@
+package pg
+
+import (
+	"context"
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/pkg/logger"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+)
+
+const (
+	maxTxAttempts  = 3
+	txRetryBackoff = 50 * time.Millisecond
+
+	serializationFailureCode = "40001"
+	deadlockDetectedCode     = "40P01"
+)
+
+// RunInTx runs fn in a transaction which is propagated to fn through its context.
+//
+// The transaction is committed if fn returns nil and is rolled back if fn returns an error or panics.
+// Nested calls run in a savepoint of the outer transaction. The outermost transaction is retried
+// when it fails because of a serialization failure or a deadlock, so fn must be safe to run multiple times.
+func (stg *Stg) RunInTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	// a serialization failure aborts the whole transaction, so only the outermost transaction can be retried
+	if storage.FromContext(ctx) != nil {
+		return stg.runInTx(ctx, fn)
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = stg.runInTx(ctx, fn)
+		if err == nil || attempt >= maxTxAttempts || !isRetryableTxErr(err) {
+			return err
+		}
+
+		logger.Warnf("retrying transaction after attempt %d failed: %v", attempt, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(txRetryBackoff * time.Duration(attempt)):
+		}
+	}
+}
+
+func (stg *Stg) runInTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	txCtx, ses, err := stg.Begin(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = ses.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			if rbErr := ses.Rollback(); rbErr != nil {
+				err = errors.Wrapf(err, "failed to rollback transaction: %v", rbErr)
+			}
+			return
+		}
+		if err = ses.Commit(); err != nil {
+			err = errors.Wrap(err, "failed to commit transaction")
+		}
+	}()
+
+	err = fn(txCtx)
+	return
+}
+
+func isRetryableTxErr(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == serializationFailureCode || pgErr.Code == deadlockDetectedCode
+	}
+	return false
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 
 	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -13,12 +14,12 @@ type UserStg struct {
 
 func NewUserStg(ses *ormSession) *UserStg {
 	return &UserStg{
-		crudStg: crudStg[*model.User]{db: ses.db},
+		crudStg: crudStg[*model.User]{db: ses.db, readDb: ses.readDb},
 	}
 }
 
 func (stg *UserStg) FindByEmail(email string) (user *model.User, err error) {
-	err = stg.db.
+	err = stg.reader().
 		Where("email = ?", email).
 		First(&user).
 		Error
@@ -32,3 +33,19 @@ func (stg *UserStg) FindByEmail(email string) (user *model.User, err error) {
 
 	return
 }
+
+func (stg *UserStg) FindByUuid(id uuid.UUID) (user *model.User, err error) {
+	err = stg.reader().
+		Where("id = ?", id).
+		First(&user).
+		Error
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return
+}
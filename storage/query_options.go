@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+type deletedIncludedCtx struct{}
+
+// IncludeDeleted returns a context that makes the storages created with it include the soft deleted records in their
+// read-only queries. The writes (e.g. the deletes) still exclude them.
+func IncludeDeleted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, deletedIncludedCtx{}, true)
+}
+
+// DeletedIncluded reports whether the soft deleted records should be included in the queries.
+func DeletedIncluded(ctx context.Context) bool {
+	included, _ := ctx.Value(deletedIncludedCtx{}).(bool)
+	return included
+}
+
+type primaryRequiredCtx struct{}
+
+// UsePrimary returns a context that makes the storages created with it run the read-only queries on the primary
+// instead of the replicas, e.g. to read the changes that have just been written.
+func UsePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryRequiredCtx{}, true)
+}
+
+// PrimaryRequired reports whether the read-only queries should run on the primary.
+func PrimaryRequired(ctx context.Context) bool {
+	required, _ := ctx.Value(primaryRequiredCtx{}).(bool)
+	return required
+}
+
+type queryTimeoutCtx struct{}
+
+// WithQueryTimeout returns a context that makes the storages created with it run every query with the given timeout
+// instead of the default query timeout. The statement timeout of the db still applies.
+func WithQueryTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, queryTimeoutCtx{}, timeout)
+}
+
+// QueryTimeout returns the query timeout of the context, if there is one.
+func QueryTimeout(ctx context.Context) (time.Duration, bool) {
+	timeout, ok := ctx.Value(queryTimeoutCtx{}).(time.Duration)
+	return timeout, ok
+}
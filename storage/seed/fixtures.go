@@ -0,0 +1,114 @@
+package seed
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// refKey names a record so the records loaded after it can refer to it.
+	refKey = "_ref"
+	// refPrefix marks the values referring to a named record: "@admin" is the id of the record and "@admin.email"
+	// is its email. "@@" escapes a literal "@".
+	refPrefix = "@"
+)
+
+// Record is the fields of a fixture record by their json name.
+type Record map[string]any
+
+// Fixtures is the content of a fixture file: the records to seed by table name.
+type Fixtures map[string][]Record
+
+// ParseFixtures parses the YAML or JSON fixtures by the extension of the file name.
+func ParseFixtures(name string, data []byte) (Fixtures, error) {
+	fixtures := Fixtures{}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &fixtures); err != nil {
+			return nil, errs.Newf(errs.InvalidArgument, err, "failed to parse the fixtures of %s", name)
+		}
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&fixtures); err != nil {
+			return nil, errs.Newf(errs.InvalidArgument, err, "failed to parse the fixtures of %s", name)
+		}
+	default:
+		return nil, errs.Newf(errs.InvalidArgument, nil, "unsupported fixture file %s, expected a .yaml, .yml or .json file", name)
+	}
+	return fixtures, nil
+}
+
+func isFixtureFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// resolveRefs returns a copy of the record without its ref name, where the references to the named records
+// are replaced by their values.
+func resolveRefs(record Record, refs map[string]map[string]any) (Record, error) {
+	resolved := make(Record, len(record))
+	for field, value := range record {
+		if field == refKey {
+			continue
+		}
+		value, err := resolveValue(value, refs)
+		if err != nil {
+			return nil, errs.Wrapf(err, "failed to resolve field %q", field)
+		}
+		resolved[field] = value
+	}
+	return resolved, nil
+}
+
+func resolveValue(value any, refs map[string]map[string]any) (any, error) {
+	switch v := value.(type) {
+	case string:
+		if strings.HasPrefix(v, refPrefix+refPrefix) {
+			return v[len(refPrefix):], nil
+		}
+		if !strings.HasPrefix(v, refPrefix) {
+			return v, nil
+		}
+		name, field, ok := strings.Cut(v[len(refPrefix):], ".")
+		if !ok {
+			field = "id"
+		}
+		fields, ok := refs[name]
+		if !ok {
+			return nil, errs.Newf(errs.InvalidArgument, nil, "unknown ref %q, the records can only refer to the records loaded before them", name)
+		}
+		resolved, ok := fields[field]
+		if !ok {
+			return nil, errs.Newf(errs.InvalidArgument, nil, "ref %q has no field %q", name, field)
+		}
+		return resolved, nil
+	case []any:
+		resolved := make([]any, len(v))
+		for i, item := range v {
+			var err error
+			if resolved[i], err = resolveValue(item, refs); err != nil {
+				return nil, err
+			}
+		}
+		return resolved, nil
+	case map[string]any:
+		resolved := make(map[string]any, len(v))
+		for key, item := range v {
+			var err error
+			if resolved[key], err = resolveValue(item, refs); err != nil {
+				return nil, err
+			}
+		}
+		return resolved, nil
+	}
+	return value, nil
+}
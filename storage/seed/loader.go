@@ -0,0 +1,191 @@
+// Package seed loads the YAML or JSON fixture files into the db through the storage layer.
+//
+// A fixture file lists the records to seed by table name. A record can be named with "_ref", and the records loaded
+// after it can refer to its fields with "@name.field" ("@name" is its id):
+//
+//	users:
+//	  - _ref: admin
+//	    email: admin@example.com
+//	    password: admin
+//
+// The existing records are matched by the natural key of their table and updated, so the fixtures can be loaded
+// again without duplicating the records.
+package seed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/samber/lo"
+)
+
+// Result counts the seeded records by what happened to them.
+type Result struct {
+	Created   int
+	Updated   int
+	Unchanged int
+}
+
+func (r Result) String() string {
+	return fmt.Sprintf("%d created, %d updated, %d unchanged", r.Created, r.Updated, r.Unchanged)
+}
+
+// Loader loads the fixtures and keeps the named records, so the fixtures loaded later can refer to them.
+type Loader struct {
+	stg storage.Storage
+
+	models map[string]any
+	refs   map[string]map[string]any
+}
+
+func NewLoader(stg storage.Storage) *Loader {
+	return &Loader{
+		stg:    stg,
+		models: make(map[string]any),
+		refs:   make(map[string]map[string]any),
+	}
+}
+
+// Sets lists the seed sets of fsys, i.e. its directories, e.g. dev, demo and testing.
+func Sets(fsys fs.FS) ([]string, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to list the seed sets")
+	}
+	sets := make([]string, 0)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			sets = append(sets, entry.Name())
+		}
+	}
+	return sets, nil
+}
+
+// LoadSet loads the fixture files of the set directory of fsys in the order of their names.
+func (l *Loader) LoadSet(ctx context.Context, fsys fs.FS, set string) (Result, error) {
+	entries, err := fs.ReadDir(fsys, set)
+	if err != nil {
+		return Result{}, errs.Newf(errs.NotFound, err, "unknown seed set %q", set)
+	}
+	names := make([]string, 0)
+	for _, entry := range entries {
+		if !entry.IsDir() && isFixtureFile(entry.Name()) {
+			names = append(names, path.Join(set, entry.Name()))
+		}
+	}
+	return l.load(ctx, names, func(name string) ([]byte, error) {
+		return fs.ReadFile(fsys, name)
+	})
+}
+
+// LoadFiles loads the fixture files in the given order.
+func (l *Loader) LoadFiles(ctx context.Context, paths ...string) (Result, error) {
+	return l.load(ctx, paths, os.ReadFile)
+}
+
+// load loads the files in a single transaction, so a failed load does not leave half of the fixtures behind.
+func (l *Loader) load(ctx context.Context, names []string, read func(name string) ([]byte, error)) (Result, error) {
+	fixtures := make([]Fixtures, 0, len(names))
+	for _, name := range names {
+		data, err := read(name)
+		if err != nil {
+			return Result{}, errs.Wrapf(err, "failed to read %s", name)
+		}
+		f, err := ParseFixtures(name, data)
+		if err != nil {
+			return Result{}, err
+		}
+		fixtures = append(fixtures, f)
+	}
+
+	result := Result{}
+	models := make(map[string]any)
+	refs := make(map[string]map[string]any)
+	err := l.stg.RunInTx(ctx, func(ctx context.Context) error {
+		for i, f := range fixtures {
+			if err := l.loadFixtures(ctx, f, &result, models, refs); err != nil {
+				return errs.Wrapf(err, "failed to load %s", names[i])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	// the refs are only kept once the transaction is committed
+	for name, m := range models {
+		l.models[name] = m
+		l.refs[name] = refs[name]
+	}
+	return result, nil
+}
+
+func (l *Loader) loadFixtures(ctx context.Context, fixtures Fixtures, result *Result, models map[string]any, refs map[string]map[string]any) error {
+	unknown := lo.Without(lo.Keys(fixtures), lo.Map(tables, func(t tableSeeder, _ int) string { return t.tableName() })...)
+	if len(unknown) > 0 {
+		slices.Sort(unknown)
+		return errs.Newf(errs.InvalidArgument, nil, "unknown tables %v", unknown)
+	}
+
+	for _, table := range tables {
+		for i, record := range fixtures[table.tableName()] {
+			resolved, err := resolveRefs(record, lo.Assign(l.refs, refs))
+			if err != nil {
+				return errs.Wrapf(err, "invalid %s record #%d", table.tableName(), i+1)
+			}
+			saved, o, err := table.seed(ctx, l.stg, resolved)
+			if err != nil {
+				return errs.Wrapf(err, "failed to seed %s record #%d", table.tableName(), i+1)
+			}
+			switch o {
+			case created:
+				result.Created++
+			case updated:
+				result.Updated++
+			case unchanged:
+				result.Unchanged++
+			}
+
+			ref, ok := record[refKey]
+			if !ok {
+				continue
+			}
+			name, ok := ref.(string)
+			if !ok || name == "" {
+				return errs.Newf(errs.InvalidArgument, nil, "the %s of %s record #%d must be a name", refKey, table.tableName(), i+1)
+			}
+			fields, err := toFields(saved)
+			if err != nil {
+				return err
+			}
+			models[name] = saved
+			refs[name] = fields
+		}
+	}
+	return nil
+}
+
+// Ref returns the model of the record named ref by the loaded fixtures.
+func Ref[M any](l *Loader, name string) (m M, ok bool) {
+	m, ok = l.models[name].(M)
+	return
+}
+
+func toFields(m any) (map[string]any, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to encode the record")
+	}
+	fields := make(map[string]any)
+	if err = json.Unmarshal(data, &fields); err != nil {
+		return nil, errs.Wrapf(err, "failed to decode the record")
+	}
+	return fields, nil
+}
@@ -0,0 +1,178 @@
+package seed
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/domain/model/common"
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type fakeUserStg struct {
+	storage.UserStorage
+	users   []*model.User
+	updates int
+}
+
+func (stg *fakeUserStg) Search(params *common.SearchParams) ([]*model.User, error) {
+	found := make([]*model.User, 0)
+	for _, user := range stg.users {
+		if user.Email == params.Filters[0].Value {
+			clone := *user
+			found = append(found, &clone)
+		}
+	}
+	return found, nil
+}
+
+func (stg *fakeUserStg) CreateOne(user *model.User) error {
+	user.ID = uuid.New()
+	clone := *user
+	stg.users = append(stg.users, &clone)
+	return nil
+}
+
+func (stg *fakeUserStg) UpdateOne(user *model.User, _ bool) error {
+	stg.updates++
+	for i, existing := range stg.users {
+		if existing.ID == user.ID {
+			clone := *user
+			stg.users[i] = &clone
+		}
+	}
+	return nil
+}
+
+type fakeStorage struct {
+	storage.Storage
+	users *fakeUserStg
+}
+
+func (stg *fakeStorage) RunInTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	// roll back by restoring the users on errors
+	users := append([]*model.User(nil), stg.users.users...)
+	if err := fn(ctx); err != nil {
+		stg.users.users = users
+		return err
+	}
+	return nil
+}
+
+func (stg *fakeStorage) User(context.Context) storage.UserStorage {
+	return stg.users
+}
+
+func TestParseFixtures(t *testing.T) {
+	t.Run("parses yaml and json", func(t *testing.T) {
+		for _, name := range []string{"users.yaml", "users.json"} {
+			fixtures, err := ParseFixtures(name, []byte(`{"users": [{"email": "admin@example.com"}]}`))
+			require.NoError(t, err)
+			assert.Equal(t, "admin@example.com", fixtures["users"][0]["email"])
+		}
+	})
+
+	t.Run("rejects the unknown extensions", func(t *testing.T) {
+		_, err := ParseFixtures("users.txt", []byte(`{}`))
+		assert.Equal(t, errs.InvalidArgument, errs.Code(err))
+	})
+}
+
+func TestResolveRefs(t *testing.T) {
+	refs := map[string]map[string]any{"admin": {"id": "42", "email": "admin@example.com"}}
+
+	resolved, err := resolveRefs(Record{
+		"_ref":     "other",
+		"owner_id": "@admin",
+		"emails":   []any{"@admin.email"},
+		"handle":   "@@admin",
+	}, refs)
+	require.NoError(t, err)
+	assert.Equal(t, Record{"owner_id": "42", "emails": []any{"admin@example.com"}, "handle": "@admin"}, resolved)
+
+	_, err = resolveRefs(Record{"owner_id": "@unknown"}, refs)
+	assert.Equal(t, errs.InvalidArgument, errs.Code(err))
+	_, err = resolveRefs(Record{"owner_id": "@admin.unknown"}, refs)
+	assert.Equal(t, errs.InvalidArgument, errs.Code(err))
+}
+
+func TestLoader(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("loads the files and resolves the references", func(t *testing.T) {
+		stg := &fakeStorage{users: &fakeUserStg{}}
+		loader := NewLoader(stg)
+
+		// the second user refers to the email of the first one, so it is the same user
+		result, err := loader.LoadFiles(ctx, filepath.Join("testdata", "users.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, Result{Created: 1, Unchanged: 1}, result)
+
+		result, err = loader.LoadFiles(ctx, filepath.Join("testdata", "users.json"))
+		require.NoError(t, err)
+		assert.Equal(t, Result{Created: 1}, result)
+		assert.Len(t, stg.users.users, 2)
+
+		admin, ok := Ref[*model.User](loader, "admin")
+		require.True(t, ok)
+		same, ok := Ref[*model.User](loader, "same")
+		require.True(t, ok)
+		assert.Equal(t, admin.ID, same.ID)
+
+		support, ok := Ref[*model.User](loader, "support")
+		require.True(t, ok)
+		assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(support.PasswordHash), []byte("support")))
+	})
+
+	t.Run("is idempotent by the natural keys", func(t *testing.T) {
+		users := &fakeUserStg{}
+		seeds := fstest.MapFS{
+			"dev/users.yaml": {Data: []byte("users:\n  - _ref: admin\n    email: admin@example.com\n    password: admin\n")},
+		}
+		loader := NewLoader(&fakeStorage{users: users})
+
+		result, err := loader.LoadSet(ctx, seeds, "dev")
+		require.NoError(t, err)
+		assert.Equal(t, Result{Created: 1}, result)
+
+		result, err = loader.LoadSet(ctx, seeds, "dev")
+		require.NoError(t, err)
+		assert.Equal(t, Result{Unchanged: 1}, result)
+		assert.Len(t, users.users, 1)
+		assert.Zero(t, users.updates)
+
+		seeds["dev/users.yaml"].Data = []byte("users:\n  - email: admin@example.com\n    password: changed\n")
+		result, err = loader.LoadSet(ctx, seeds, "dev")
+		require.NoError(t, err)
+		assert.Equal(t, Result{Updated: 1}, result)
+		assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(users.users[0].PasswordHash), []byte("changed")))
+	})
+
+	t.Run("rejects the unknown tables and fields", func(t *testing.T) {
+		loader := NewLoader(&fakeStorage{users: &fakeUserStg{}})
+		seeds := fstest.MapFS{
+			"dev/tables.yaml":  {Data: []byte("unknown:\n  - name: x\n")},
+			"demo/fields.yaml": {Data: []byte("users:\n  - email: admin@example.com\n    nickname: x\n")},
+		}
+
+		_, err := loader.LoadSet(ctx, seeds, "dev")
+		assert.Equal(t, errs.InvalidArgument, errs.Code(err))
+		_, err = loader.LoadSet(ctx, seeds, "demo")
+		assert.Equal(t, errs.InvalidArgument, errs.Code(err))
+		_, err = loader.LoadSet(ctx, seeds, "unknown")
+		assert.Equal(t, errs.NotFound, errs.Code(err))
+	})
+
+	t.Run("lists the seed sets", func(t *testing.T) {
+		sets, err := Sets(fstest.MapFS{"dev/users.yaml": {}, "demo/users.yaml": {}, "README.md": {}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"demo", "dev"}, sets)
+	})
+}
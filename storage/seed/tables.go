@@ -0,0 +1,158 @@
+package seed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/domain/model/common"
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm/schema"
+)
+
+// tables lists the seedable tables in the order they are loaded, so the records can only refer to the records of the
+// tables above them. New models must be added here to be seeded.
+var tables = []tableSeeder{
+	&modelSeeder[*model.User]{
+		table:      "users",
+		naturalKey: []string{"email"},
+		storage: func(stg storage.Storage, ctx context.Context) storage.CrudStorage[*model.User] {
+			return stg.User(ctx)
+		},
+		prepare: prepareUser,
+	},
+}
+
+type outcome int
+
+const (
+	created outcome = iota
+	updated
+	unchanged
+)
+
+type tableSeeder interface {
+	tableName() string
+	// seed creates the record, or updates the existing record with the same natural key.
+	seed(ctx context.Context, stg storage.Storage, record Record) (saved any, o outcome, err error)
+}
+
+// modelSeeder seeds the records of a model through its storage. The existing records are found by the natural key,
+// a set of fields that identify a record regardless of its generated id, so loading the same fixtures again does not
+// duplicate them.
+type modelSeeder[M schema.Tabler] struct {
+	table      string
+	naturalKey []string
+	storage    func(stg storage.Storage, ctx context.Context) storage.CrudStorage[M]
+	// prepare converts the fixture fields that are not stored as they are, e.g. it hashes the passwords.
+	// existing is nil when the record is created.
+	prepare func(record Record, existing M) error
+}
+
+func (s *modelSeeder[M]) tableName() string {
+	return s.table
+}
+
+func (s *modelSeeder[M]) seed(ctx context.Context, stg storage.Storage, record Record) (any, outcome, error) {
+	crud := s.storage(stg, ctx)
+
+	filters := make([]*common.FieldFilter, 0, len(s.naturalKey))
+	for _, field := range s.naturalKey {
+		value, ok := record[field]
+		if !ok || value == nil {
+			return nil, 0, errs.Newf(errs.InvalidArgument, nil, "the %s records require the %q field", s.table, field)
+		}
+		filters = append(filters, &common.FieldFilter{
+			FieldName: field,
+			Condition: common.SearchConditionEqual,
+			Value:     fmt.Sprint(value),
+		})
+	}
+	existing, err := crud.Search(&common.SearchParams{Filters: filters})
+	if err != nil {
+		return nil, 0, errs.Wrapf(err, "failed to find the existing %s record", s.table)
+	}
+	if len(existing) > 1 {
+		return nil, 0, errs.Newf(errs.FailedPrecondition, nil, "the natural key %v of %s matches %d records", s.naturalKey, s.table, len(existing))
+	}
+
+	var m, zero M
+	var before []byte
+	if len(existing) == 1 {
+		m = existing[0]
+		if before, err = json.Marshal(m); err != nil {
+			return nil, 0, errs.Wrapf(err, "failed to encode the existing %s record", s.table)
+		}
+	} else {
+		m = reflect.New(reflect.TypeOf(m).Elem()).Interface().(M)
+	}
+
+	if s.prepare != nil {
+		existingOrZero := zero
+		if before != nil {
+			existingOrZero = m
+		}
+		if err = s.prepare(record, existingOrZero); err != nil {
+			return nil, 0, err
+		}
+	}
+	if err = decodeRecord(record, m); err != nil {
+		return nil, 0, errs.Newf(errs.InvalidArgument, err, "invalid %s record", s.table)
+	}
+
+	if before == nil {
+		if err = crud.CreateOne(m); err != nil {
+			return nil, 0, errs.Wrapf(err, "failed to create the %s record", s.table)
+		}
+		return m, created, nil
+	}
+	after, err := json.Marshal(m)
+	if err != nil {
+		return nil, 0, errs.Wrapf(err, "failed to encode the %s record", s.table)
+	}
+	if bytes.Equal(before, after) {
+		return m, unchanged, nil
+	}
+	if err = crud.UpdateOne(m, false); err != nil {
+		return nil, 0, errs.Wrapf(err, "failed to update the %s record", s.table)
+	}
+	return m, updated, nil
+}
+
+// decodeRecord sets the fields of the record on the model by their json name. The unknown fields are rejected
+// to catch the typos in the fixtures.
+func decodeRecord(record Record, m any) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(m)
+}
+
+// prepareUser hashes the plain "password" of the fixture into "password_hash". The existing hash is kept if it
+// matches the password, so the unchanged users are not updated.
+func prepareUser(record Record, existing *model.User) error {
+	password, ok := record["password"]
+	if !ok {
+		return nil
+	}
+	delete(record, "password")
+
+	if existing != nil && bcrypt.CompareHashAndPassword([]byte(existing.PasswordHash), []byte(fmt.Sprint(password))) == nil {
+		record["password_hash"] = existing.PasswordHash
+		return nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(fmt.Sprint(password)), 10)
+	if err != nil {
+		return errs.Wrapf(err, "failed to hash the password")
+	}
+	record["password_hash"] = string(hash)
+	return nil
+}
@@ -3,7 +3,26 @@ package storage
 import "context"
 
 type Storage interface {
+	// RunInTx runs fn in a transaction. The transaction is propagated through the context passed to fn,
+	// therefore the storages created with that context (e.g. User(ctx)) take part in the transaction.
+	//
+	// The transaction is committed if fn returns nil and rolled back if fn returns an error or panics.
+	// Nested calls use savepoints, and the outermost transaction is retried on serialization failures.
+	RunInTx(ctx context.Context, fn func(ctx context.Context) error) error
+	// Begin starts a transactional session (or a savepoint if ctx already has one) and returns a context carrying it.
+	// The storages created with the returned context take part in the transaction.
+	//
+	// Either Rollback or Commit MUST be called on the returned session to avoid transaction leak.
+	Begin(ctx context.Context) (context.Context, Session, error)
+	// TryAdvisoryLock tries to acquire the advisory lock of the given name without waiting.
+	// acquired is false if the lock is held by another session, e.g. by another replica.
+	TryAdvisoryLock(ctx context.Context, name string) (lock AdvisoryLock, acquired bool, err error)
+
 	User(ctx context.Context) UserStorage
+	Outbox(ctx context.Context) OutboxStorage
+	Job(ctx context.Context) JobStorage
+	CronRun(ctx context.Context) CronRunStorage
+	Metadata(ctx context.Context) MetadataStorage
 }
 
 type Session interface {
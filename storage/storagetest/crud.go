@@ -0,0 +1,297 @@
+package storagetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/domain/model/common"
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+// The id based methods are run on the jobs, since the ids of the users are uuids.
+
+func testCreateAndFind(t *testing.T, stg storage.Storage) {
+	jobStg := stg.Job(context.Background())
+	queue := uniqueName("crud")
+
+	job := newJob(queue)
+	require.NoError(t, jobStg.CreateOne(job))
+	require.NotZero(t, job.ID)
+	require.WithinDuration(t, time.Now(), job.CreatedAt, time.Minute, "the creation time must be filled")
+
+	found, err := jobStg.FindById(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, job.Queue, found.Queue)
+	require.Equal(t, job.Status, found.Status)
+	require.JSONEq(t, string(job.Payload), string(found.Payload))
+	require.True(t, job.RunAt.Round(time.Millisecond).Equal(found.RunAt.Round(time.Millisecond)))
+
+	_, err = jobStg.FindById(-1)
+	requireCode(t, errs.NotFound, err)
+
+	exists, err := jobStg.ExistsById(job.ID)
+	require.NoError(t, err)
+	require.True(t, exists)
+	exists, err = jobStg.ExistsById(-1)
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	others := []*model.Job{newJob(queue), newJob(queue)}
+	require.NoError(t, jobStg.CreateMany(others))
+	require.NotZero(t, others[0].ID)
+	require.NotEqual(t, others[0].ID, others[1].ID)
+	require.NoError(t, jobStg.CreateMany(nil))
+
+	listed, err := jobStg.ListByIds([]int64{job.ID, others[1].ID, -1})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []int64{job.ID, others[1].ID}, jobIds(listed))
+	listed, err = jobStg.ListByIds(nil)
+	require.NoError(t, err)
+	require.Empty(t, listed)
+}
+
+func testUpdate(t *testing.T, stg storage.Storage) {
+	jobStg := stg.Job(context.Background())
+	queue := uniqueName("update")
+
+	job := newJob(queue)
+	require.NoError(t, jobStg.CreateOne(job))
+
+	job.LastError = "failed"
+	require.NoError(t, jobStg.UpdateOne(job, false))
+	found, err := jobStg.FindById(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, "failed", found.LastError)
+
+	// the zero fields are not updated by the partial updates
+	partial := &model.Job{ID: job.ID, Attempts: 2}
+	require.NoError(t, jobStg.UpdatePartial(partial, true))
+	require.Equal(t, queue, partial.Queue, "the updated model must be returned")
+	found, err = jobStg.FindById(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, 2, found.Attempts)
+	require.Equal(t, "failed", found.LastError)
+
+	other := newJob(queue)
+	require.NoError(t, jobStg.CreateOne(other))
+	job.Kind, other.Kind = "first", "second"
+	require.NoError(t, jobStg.UpdateMany([]*model.Job{job, other}))
+	listed, err := jobStg.ListByIds([]int64{job.ID, other.ID})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"first", "second"}, lo.Map(listed, func(job *model.Job, _ int) string {
+		return job.Kind
+	}))
+}
+
+func testDelete(t *testing.T, stg storage.Storage) {
+	jobStg := stg.Job(context.Background())
+	queue := uniqueName("delete")
+
+	jobs := []*model.Job{newJob(queue), newJob(queue), newJob(queue)}
+	require.NoError(t, jobStg.CreateMany(jobs))
+
+	require.NoError(t, jobStg.DeleteById(jobs[0].ID))
+	_, err := jobStg.FindById(jobs[0].ID)
+	requireCode(t, errs.NotFound, err)
+	requireCode(t, errs.NotFound, jobStg.DeleteById(jobs[0].ID))
+
+	require.NoError(t, jobStg.DeleteByIds([]int64{jobs[1].ID, jobs[2].ID}))
+	listed, err := jobStg.ListByIds(jobIds(jobs))
+	require.NoError(t, err)
+	require.Empty(t, listed)
+
+	job := newJob(queue)
+	require.NoError(t, jobStg.CreateOne(job))
+	require.NoError(t, jobStg.Delete(job))
+	requireCode(t, errs.NotFound, jobStg.Delete(job), "the jobs are deleted permanently")
+
+	// the jobs are not soft deletable
+	require.Error(t, jobStg.Restore(jobs[0]))
+	_, err = jobStg.Purge(time.Now())
+	require.Error(t, err)
+}
+
+func testSoftDelete(t *testing.T, stg storage.Storage) {
+	userStg := stg.User(context.Background())
+
+	user := newUser()
+	require.NoError(t, userStg.CreateOne(user))
+	requireCode(t, errs.NotFound, userStg.Restore(user), "only the soft deleted users can be restored")
+	requireCode(t, errs.InvalidArgument, userStg.Restore(&model.User{}))
+	requireCode(t, errs.InvalidArgument, userStg.Delete(&model.User{}))
+
+	require.NoError(t, userStg.Delete(user))
+	require.True(t, user.IsDeleted(), "the deleted time must be set")
+	requireCode(t, errs.NotFound, userStg.Delete(user), "the soft deleted users can't be deleted again")
+	found, err := userStg.FindByEmail(user.Email)
+	require.NoError(t, err)
+	require.Nil(t, found, "the soft deleted users must be excluded")
+	found, err = stg.User(storage.IncludeDeleted(context.Background())).FindByEmail(user.Email)
+	require.NoError(t, err)
+	require.NotNil(t, found, "the soft deleted users must be included on request")
+	require.True(t, found.IsDeleted())
+
+	requireCode(t, errs.NotFound, stg.User(storage.IncludeDeleted(context.Background())).Delete(found),
+		"the writes must exclude the soft deleted users even if they are included in the queries")
+	found, err = stg.User(storage.IncludeDeleted(context.Background())).FindByEmail(user.Email)
+	require.NoError(t, err)
+	require.NotNil(t, found, "the soft deleted users must not be deleted permanently")
+
+	stale := *found
+	require.NoError(t, userStg.Restore(user))
+	require.False(t, user.IsDeleted())
+	require.Equal(t, stale.Version+1, user.Version, "the restore must increment the version")
+	found, err = userStg.FindByEmail(user.Email)
+	require.NoError(t, err)
+	require.NotNil(t, found, "the restored users must be found again")
+	require.False(t, found.IsDeleted())
+	require.Equal(t, user.Version, found.Version)
+	stale.DeletedAt.Valid = false
+	requireCode(t, errs.Conflict, userStg.UpdateOne(&stale, false), "the restore must invalidate the versions read before it")
+
+	require.NoError(t, userStg.Delete(found))
+	// the unique index of the emails ignores the soft deleted users
+	require.NoError(t, userStg.CreateOne(&model.User{Email: user.Email}))
+	requireCode(t, errs.Conflict, userStg.Restore(user), "the email of the restored user is taken")
+
+	purged, err := userStg.Purge(time.Now())
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, purged, int64(1))
+	found, err = userStg.FindByEmail(user.Email)
+	require.NoError(t, err)
+	require.NotNil(t, found, "only the soft deleted users must be purged")
+	requireCode(t, errs.NotFound, userStg.Restore(user), "the purged users are gone")
+}
+
+func testUpsert(t *testing.T, stg storage.Storage) {
+	jobStg := stg.Job(context.Background())
+	queue := uniqueName("upsert")
+
+	job := newJob(queue)
+	require.NoError(t, jobStg.UpsertOne(job, storage.UpsertOptions{}))
+	require.NotZero(t, job.ID)
+
+	update := newJob(queue)
+	update.ID, update.Kind, update.LastError = job.ID, "updated", "failed"
+	require.NoError(t, jobStg.UpsertOne(update, storage.UpsertOptions{UpdateColumns: []string{"kind"}}))
+	found, err := jobStg.FindById(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, "updated", found.Kind)
+	require.Empty(t, found.LastError, "only the update columns must be updated")
+
+	ignored := newJob(queue)
+	ignored.ID, ignored.Kind = job.ID, "ignored"
+	require.NoError(t, jobStg.UpsertMany([]*model.Job{ignored}, storage.UpsertOptions{DoNothing: true}))
+	found, err = jobStg.FindById(job.ID)
+	require.NoError(t, err)
+	require.Equal(t, "updated", found.Kind)
+
+	// the conflict columns must match a unique index
+	err = jobStg.UpsertOne(newJob(queue), storage.UpsertOptions{ConflictColumns: []string{"queue"}})
+	require.Error(t, err)
+}
+
+func testBatches(t *testing.T, stg storage.Storage) {
+	ctx := context.Background()
+	jobStg := stg.Job(ctx)
+	queue := uniqueName("batches")
+
+	uniqueKey := uniqueName("key")
+	jobs := []*model.Job{newJob(queue), newJob(queue), newJob(queue), newJob(queue)}
+	jobs[1].UniqueKey, jobs[2].UniqueKey = &uniqueKey, &uniqueKey
+	rowErrs, err := jobStg.CreateInBatches(jobs, 3)
+	require.NoError(t, err)
+	require.Len(t, rowErrs, 1)
+	require.Equal(t, 2, rowErrs[0].Index)
+	requireCode(t, errs.Conflict, rowErrs[0].Err)
+	listed, err := jobStg.ListByIds(jobIds(jobs))
+	require.NoError(t, err)
+	require.Len(t, listed, 3)
+
+	_, err = jobStg.CreateInBatches(jobs, 0)
+	requireCode(t, errs.InvalidArgument, err)
+
+	copyQueue := uniqueName("copy")
+	copied, err := jobStg.CopyFrom([]*model.Job{newJob(copyQueue), newJob(copyQueue)})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), copied)
+	copiedJobs := searchQueue(t, jobStg, copyQueue)
+	require.Len(t, copiedJobs, 2)
+	// the copied rows are not tracked by the test cleanups
+	t.Cleanup(func() {
+		_ = stg.Job(context.Background()).DeleteByIds(jobIds(copiedJobs))
+	})
+
+	err = stg.RunInTx(ctx, func(ctx context.Context) error {
+		_, err := stg.Job(ctx).CopyFrom([]*model.Job{newJob(copyQueue)})
+		return err
+	})
+	require.Error(t, err, "copy from must not be used inside a transaction")
+}
+
+func testSearchAndIterate(t *testing.T, stg storage.Storage) {
+	jobStg := stg.Job(context.Background())
+	queue := uniqueName("search")
+
+	jobs := make([]*model.Job, 5)
+	for i := range jobs {
+		jobs[i] = newJob(queue)
+		jobs[i].Attempts = i
+	}
+	require.NoError(t, jobStg.CreateMany(jobs))
+
+	params := &common.SearchParams{
+		Filters: []*common.FieldFilter{
+			{FieldName: "queue", Condition: common.SearchConditionEqual, Value: queue},
+			{FieldName: "attempts", Condition: common.SearchConditionNotEqual, Value: "0"},
+		},
+		Pagination: &common.Pagination{PageSize: 2, Page: 1, Sort: "-attempts"},
+	}
+	found, err := jobStg.Search(params)
+	require.NoError(t, err)
+	require.Equal(t, []int64{jobs[2].ID, jobs[1].ID}, jobIds(found))
+	require.Equal(t, int64(4), params.TotalCount)
+
+	params = &common.SearchParams{
+		Filters: []*common.FieldFilter{{FieldName: "queue", Condition: common.SearchConditionEqual, Value: queue}},
+		Fields:  "id,attempts",
+	}
+	found, err = jobStg.Search(params)
+	require.NoError(t, err)
+	require.Len(t, found, 5)
+	require.Empty(t, found[0].Queue, "only the selected fields must be filled")
+
+	_, err = jobStg.Search(&common.SearchParams{
+		Filters: []*common.FieldFilter{{FieldName: "unknown", Condition: common.SearchConditionEqual, Value: "x"}},
+	})
+	require.Error(t, err)
+
+	var iterated []*model.Job
+	for job, err := range jobStg.Iterate(params, 2) {
+		require.NoError(t, err)
+		iterated = append(iterated, job)
+	}
+	require.Equal(t, jobIds(jobs), jobIds(iterated), "the jobs must be iterated by their ids")
+}
+
+// searchQueue lists the jobs of the queue by their ids.
+func searchQueue(t *testing.T, jobStg storage.JobStorage, queue string) []*model.Job {
+	t.Helper()
+	jobs, err := jobStg.Search(&common.SearchParams{
+		Filters:    []*common.FieldFilter{{FieldName: "queue", Condition: common.SearchConditionEqual, Value: queue}},
+		Pagination: &common.Pagination{PageSize: common.DefaultPageSize, Sort: "id"},
+	})
+	require.NoError(t, err)
+	return jobs
+}
+
+func jobIds(jobs []*model.Job) []int64 {
+	return lo.Map(jobs, func(job *model.Job, _ int) int64 {
+		return job.ID
+	})
+}
@@ -0,0 +1,43 @@
+package storagetest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func testMetadata(t *testing.T, stg storage.Storage) {
+	metadataStg := stg.Metadata(context.Background())
+
+	tables, err := metadataStg.ListTables()
+	require.NoError(t, err)
+	require.Subset(t, tables, []string{"users", "jobs", "outbox_events", "cron_runs"})
+
+	columns, err := metadataStg.ListColumns("users")
+	require.NoError(t, err)
+	require.Subset(t, columns, []string{"id", "email", "password_hash", "deleted_at", "version"})
+	_, err = metadataStg.ListColumns("unknown")
+	requireCode(t, errs.NotFound, err)
+
+	tableToColumns, err := metadataStg.ListTablesAndColumns()
+	require.NoError(t, err)
+	require.ElementsMatch(t, columns, tableToColumns["users"])
+
+	dbSchema, err := metadataStg.DescribeSchema()
+	require.NoError(t, err)
+	require.Equal(t, []string{"id"}, dbSchema.Table("users").PrimaryKey)
+
+	user := newUser()
+	require.NoError(t, stg.User(context.Background()).CreateOne(user))
+	exists, err := metadataStg.RecordByValueExists("users", "email", user.Email)
+	require.NoError(t, err)
+	require.True(t, exists)
+	exists, err = metadataStg.RecordByValueExists("users", "email", uniqueName("unknown"))
+	require.NoError(t, err)
+	require.False(t, exists)
+	_, err = metadataStg.RecordByValueExists("users", "unknown", user.Email)
+	requireCode(t, errs.NotFound, err)
+}
@@ -0,0 +1,285 @@
+package storagetest
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/domain/model/common"
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func testJob(t *testing.T, stg storage.Storage) {
+	jobStg := stg.Job(context.Background())
+
+	t.Run("enqueue", func(t *testing.T) {
+		queue := uniqueName("enqueue")
+		uniqueKey := uniqueName("key")
+		first := newJob(queue)
+		first.UniqueKey = &uniqueKey
+		existing, enqueued, err := jobStg.Enqueue(first)
+		require.NoError(t, err)
+		require.True(t, enqueued)
+		require.Equal(t, first.ID, existing.ID)
+
+		second := newJob(queue)
+		second.UniqueKey = &uniqueKey
+		existing, enqueued, err = jobStg.Enqueue(second)
+		require.NoError(t, err)
+		require.False(t, enqueued)
+		require.Equal(t, first.ID, existing.ID)
+
+		// the unique key is released once the job is completed
+		require.NoError(t, jobStg.MarkCompleted(first.ID))
+		_, enqueued, err = jobStg.Enqueue(second)
+		require.NoError(t, err)
+		require.True(t, enqueued)
+
+		_, enqueued, err = jobStg.Enqueue(newJob(queue))
+		require.NoError(t, err)
+		require.True(t, enqueued, "the jobs without a unique key are always enqueued")
+	})
+
+	t.Run("claim", func(t *testing.T) {
+		queue := uniqueName("claim")
+		jobs := []*model.Job{newJob(queue), newJob(queue), newJob(queue), newJob(queue)}
+		jobs[0].RunAt = time.Now().Add(-time.Second)
+		jobs[1].RunAt = time.Now().Add(-time.Hour)
+		jobs[3].RunAt = time.Now().Add(time.Hour)
+		require.NoError(t, jobStg.CreateMany(jobs))
+
+		claimed, err := jobStg.ClaimNext(queue, 2)
+		require.NoError(t, err)
+		require.ElementsMatch(t, []int64{jobs[1].ID, jobs[2].ID}, jobIds(claimed), "the earliest due jobs must be claimed")
+		for _, job := range claimed {
+			require.Equal(t, model.JobRunning, job.Status)
+			require.Equal(t, 1, job.Attempts)
+			require.NotNil(t, job.LockedAt)
+		}
+
+		claimed, err = jobStg.ClaimNext(queue, 10)
+		require.NoError(t, err)
+		require.Equal(t, []int64{jobs[0].ID}, jobIds(claimed), "the running and the future jobs must not be claimed")
+
+		claimed, err = jobStg.ClaimNext(queue, 10)
+		require.NoError(t, err)
+		require.Empty(t, claimed)
+	})
+
+	t.Run("failures", func(t *testing.T) {
+		queue := uniqueName("failures")
+		job := newJob(queue)
+		require.NoError(t, jobStg.CreateOne(job))
+		_, err := jobStg.ClaimNext(queue, 1)
+		require.NoError(t, err)
+
+		runAt := time.Now().Add(time.Hour)
+		require.NoError(t, jobStg.MarkFailed(job.ID, "failed", runAt, false))
+		found, err := jobStg.FindById(job.ID)
+		require.NoError(t, err)
+		require.Equal(t, model.JobPending, found.Status)
+		require.Equal(t, "failed", found.LastError)
+		require.Nil(t, found.LockedAt)
+		require.WithinDuration(t, runAt, found.RunAt, time.Millisecond)
+
+		requireCode(t, errs.NotFound, jobStg.Retry(job.ID))
+
+		require.NoError(t, jobStg.MarkFailed(job.ID, "failed again", runAt, true))
+		require.NoError(t, jobStg.Retry(job.ID))
+		found, err = jobStg.FindById(job.ID)
+		require.NoError(t, err)
+		require.Equal(t, model.JobPending, found.Status)
+		require.Zero(t, found.Attempts)
+
+		requireCode(t, errs.NotFound, jobStg.Retry(-1))
+	})
+
+	t.Run("retry conflict", func(t *testing.T) {
+		queue := uniqueName("retry")
+		uniqueKey := uniqueName("key")
+		failed := newJob(queue)
+		failed.UniqueKey, failed.Status = &uniqueKey, model.JobFailed
+		require.NoError(t, jobStg.CreateOne(failed))
+		pending := newJob(queue)
+		pending.UniqueKey = &uniqueKey
+		require.NoError(t, jobStg.CreateOne(pending))
+
+		requireCode(t, errs.Conflict, jobStg.Retry(failed.ID))
+	})
+
+	t.Run("rescue stale", func(t *testing.T) {
+		queue := uniqueName("stale")
+		job := newJob(queue)
+		require.NoError(t, jobStg.CreateOne(job))
+		_, err := jobStg.ClaimNext(queue, 1)
+		require.NoError(t, err)
+
+		_, err = jobStg.RescueStale(time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		found, err := jobStg.FindById(job.ID)
+		require.NoError(t, err)
+		require.Equal(t, model.JobRunning, found.Status, "a recently locked job must not be rescued")
+
+		rescued, err := jobStg.RescueStale(time.Now().Add(time.Second))
+		require.NoError(t, err)
+		require.GreaterOrEqual(t, rescued, int64(1))
+		found, err = jobStg.FindById(job.ID)
+		require.NoError(t, err)
+		require.Equal(t, model.JobPending, found.Status)
+		require.Nil(t, found.LockedAt)
+		require.NotEmpty(t, found.LastError)
+
+		job.Attempts, job.MaxAttempts = 0, 1
+		require.NoError(t, jobStg.UpdateOne(job, false))
+		_, err = jobStg.ClaimNext(queue, 1)
+		require.NoError(t, err)
+		_, err = jobStg.RescueStale(time.Now().Add(time.Second))
+		require.NoError(t, err)
+		found, err = jobStg.FindById(job.ID)
+		require.NoError(t, err)
+		require.Equal(t, model.JobFailed, found.Status, "a job which has used up its attempts must fail")
+		require.Nil(t, found.LockedAt)
+	})
+
+	t.Run("list by status", func(t *testing.T) {
+		queue := uniqueName("list")
+		job := newJob(queue)
+		job.Status = model.JobFailed
+		require.NoError(t, jobStg.CreateOne(job))
+
+		jobs, err := jobStg.ListByStatus(model.JobFailed, common.InternalPagination(), nil)
+		require.NoError(t, err)
+		require.Contains(t, jobIds(jobs), job.ID)
+		for _, job := range jobs {
+			require.Equal(t, model.JobFailed, job.Status)
+		}
+
+		pagination := &common.Pagination{PageSize: 1, Sort: "-id"}
+		jobs, err = jobStg.ListByStatus("", pagination, nil)
+		require.NoError(t, err)
+		require.Len(t, jobs, 1)
+		require.GreaterOrEqual(t, pagination.TotalCount, int64(1))
+
+		jobs, err = jobStg.ListByStatus(model.JobFailed, common.InternalPagination(), []string{"id", "status"})
+		require.NoError(t, err)
+		selected, ok := lo.Find(jobs, func(found *model.Job) bool { return found.ID == job.ID })
+		require.True(t, ok)
+		require.Equal(t, &model.Job{ID: job.ID, Status: model.JobFailed}, selected, "only the selected fields are loaded")
+
+		_, err = jobStg.ListByStatus("", common.InternalPagination(), []string{"id", "unknown"})
+		requireCode(t, errs.InvalidArgument, err)
+	})
+}
+
+func testOutbox(t *testing.T, stg storage.Storage) {
+	outboxStg := stg.Outbox(context.Background())
+
+	newEvent := func(nextAttemptAt time.Time) *model.OutboxEvent {
+		return &model.OutboxEvent{
+			EventType:     uniqueName("event"),
+			Payload:       json.RawMessage(`{}`),
+			Status:        model.OutboxEventPending,
+			NextAttemptAt: nextAttemptAt,
+		}
+	}
+	due, later := newEvent(time.Now().Add(-time.Minute)), newEvent(time.Now().Add(time.Hour))
+	require.NoError(t, outboxStg.CreateMany([]*model.OutboxEvent{due, later}))
+
+	eventIds := func(events []*model.OutboxEvent) []int64 {
+		return lo.Map(events, func(event *model.OutboxEvent, _ int) int64 {
+			return event.ID
+		})
+	}
+	claimed, err := outboxStg.ClaimDue(1000, time.Minute)
+	require.NoError(t, err)
+	claimedIds := eventIds(claimed)
+	require.Contains(t, claimedIds, due.ID)
+	require.NotContains(t, claimedIds, later.ID)
+	require.IsIncreasing(t, claimedIds, "the events must be claimed in order")
+
+	claimed, err = outboxStg.ClaimDue(1000, time.Minute)
+	require.NoError(t, err)
+	require.NotContains(t, eventIds(claimed), due.ID, "a claimed event must not be claimed again during its lease")
+	found, err := outboxStg.FindById(due.ID)
+	require.NoError(t, err)
+	require.Equal(t, model.OutboxEventPending, found.Status)
+	require.True(t, found.NextAttemptAt.After(time.Now()), "the next attempt must be postponed by the lease")
+
+	require.NoError(t, outboxStg.MarkDelivered(due.ID))
+	found, err = outboxStg.FindById(due.ID)
+	require.NoError(t, err)
+	require.Equal(t, model.OutboxEventDelivered, found.Status)
+	require.Equal(t, 1, found.Attempts)
+	require.NotNil(t, found.DeliveredAt)
+
+	require.NoError(t, outboxStg.MarkFailed(later.ID, "failed", time.Now(), true))
+	found, err = outboxStg.FindById(later.ID)
+	require.NoError(t, err)
+	require.Equal(t, model.OutboxEventDead, found.Status)
+	require.Equal(t, 1, found.Attempts)
+	require.Equal(t, "failed", found.LastError)
+}
+
+func testCronRun(t *testing.T, stg storage.Storage) {
+	cronRunStg := stg.CronRun(context.Background())
+	taskName := uniqueName("task")
+
+	newRun := func(trigger model.CronRunTrigger, scheduledAt time.Time) *model.CronRun {
+		return &model.CronRun{
+			TaskName:    taskName,
+			Trigger:     trigger,
+			Status:      model.CronRunRunning,
+			ScheduledAt: scheduledAt,
+			StartedAt:   time.Now(),
+		}
+	}
+
+	last, err := cronRunStg.FindLast(taskName)
+	require.NoError(t, err)
+	require.Nil(t, last)
+
+	occurrence := time.Now().Truncate(time.Minute)
+	first := newRun(model.CronRunScheduled, occurrence)
+	started, err := cronRunStg.Start(first)
+	require.NoError(t, err)
+	require.True(t, started)
+
+	started, err = cronRunStg.Start(newRun(model.CronRunScheduled, occurrence))
+	require.NoError(t, err)
+	require.False(t, started, "an occurrence must only be run once")
+
+	manual := newRun(model.CronRunManual, occurrence)
+	manual.StartedAt = first.StartedAt.Add(time.Second)
+	started, err = cronRunStg.Start(manual)
+	require.NoError(t, err)
+	require.True(t, started, "the manual runs are not unique")
+
+	require.NoError(t, cronRunStg.Finish(first.ID, model.CronRunFailed, "failed"))
+
+	last, err = cronRunStg.FindLast(taskName)
+	require.NoError(t, err)
+	require.Equal(t, manual.ID, last.ID)
+
+	missed := newRun(model.CronRunScheduled, occurrence.Add(-time.Hour))
+	missed.StartedAt = manual.StartedAt.Add(time.Second)
+	started, err = cronRunStg.Start(missed)
+	require.NoError(t, err)
+	require.True(t, started)
+
+	last, err = cronRunStg.FindLastScheduled(taskName)
+	require.NoError(t, err)
+	require.Equal(t, first.ID, last.ID, "the latest occurrence must be found, regardless of the manual runs and the start times")
+
+	runs, err := cronRunStg.ListByTask(taskName, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, runs, 3)
+	require.Equal(t, missed.ID, runs[0].ID, "the latest runs must come first")
+	require.Equal(t, model.CronRunFailed, runs[2].Status)
+	require.Equal(t, "failed", runs[2].Error)
+	require.NotNil(t, runs[2].FinishedAt)
+}
@@ -0,0 +1,75 @@
+// Package storagetest is the contract of storage.Storage. Every backend runs the same suite, so the in-memory
+// storage of the unit tests keeps behaving like the pg storage of the application.
+//
+// The suite only relies on the records it creates, and names them uniquely, so it can run against a shared db
+// which has other records in it.
+package storagetest
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// NewStorage returns the storage under test. The storage must have the schema of the migrations.
+type NewStorage func(t *testing.T) storage.Storage
+
+// Run runs the contract suite against the storages returned by newStorage, a new one for every test.
+func Run(t *testing.T, newStorage NewStorage) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, stg storage.Storage)
+	}{
+		{"RunInTx", testRunInTx},
+		{"Begin", testBegin},
+		{"AdvisoryLock", testAdvisoryLock},
+		{"CreateAndFind", testCreateAndFind},
+		{"Update", testUpdate},
+		{"Delete", testDelete},
+		{"SoftDelete", testSoftDelete},
+		{"Upsert", testUpsert},
+		{"UserUpsert", testUserUpsert},
+		{"Batches", testBatches},
+		{"SearchAndIterate", testSearchAndIterate},
+		{"User", testUser},
+		{"Audit", testAudit},
+		{"Job", testJob},
+		{"Outbox", testOutbox},
+		{"CronRun", testCronRun},
+		{"Metadata", testMetadata},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStorage(t))
+		})
+	}
+}
+
+// uniqueName returns a name which no other test has used, e.g. for the queues and the emails.
+func uniqueName(prefix string) string {
+	return prefix + "-" + uuid.NewString()
+}
+
+// newJob returns a due pending job of the queue.
+func newJob(queue string) *model.Job {
+	return &model.Job{
+		Queue:       queue,
+		Kind:        "test",
+		Payload:     json.RawMessage(`{}`),
+		Status:      model.JobPending,
+		MaxAttempts: 3,
+		RunAt:       time.Now().Add(-time.Minute),
+	}
+}
+
+func requireCode(t *testing.T, code errs.ErrorCode, err error, msgAndArgs ...any) {
+	t.Helper()
+	require.Error(t, err, msgAndArgs...)
+	require.Equal(t, code, errs.Code(err), "unexpected error: %v", err)
+}
@@ -0,0 +1,149 @@
+package storagetest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func testRunInTx(t *testing.T, stg storage.Storage) {
+	ctx := context.Background()
+	queue := uniqueName("tx")
+
+	t.Run("commit", func(t *testing.T) {
+		job := newJob(queue)
+		err := stg.RunInTx(ctx, func(ctx context.Context) error {
+			require.NoError(t, stg.Job(ctx).CreateOne(job))
+
+			// the uncommitted changes are only visible inside the transaction
+			_, err := stg.Job(context.Background()).FindById(job.ID)
+			requireCode(t, errs.NotFound, err)
+			_, err = stg.Job(ctx).FindById(job.ID)
+			return err
+		})
+		require.NoError(t, err)
+
+		_, err = stg.Job(ctx).FindById(job.ID)
+		require.NoError(t, err)
+	})
+
+	t.Run("rollback on error", func(t *testing.T) {
+		job := newJob(queue)
+		fnErr := errors.New("failed")
+		err := stg.RunInTx(ctx, func(ctx context.Context) error {
+			require.NoError(t, stg.Job(ctx).CreateOne(job))
+			return fnErr
+		})
+		require.ErrorIs(t, err, fnErr)
+
+		_, err = stg.Job(ctx).FindById(job.ID)
+		requireCode(t, errs.NotFound, err)
+	})
+
+	t.Run("rollback on panic", func(t *testing.T) {
+		job := newJob(queue)
+		require.Panics(t, func() {
+			_ = stg.RunInTx(ctx, func(ctx context.Context) error {
+				require.NoError(t, stg.Job(ctx).CreateOne(job))
+				panic("failed")
+			})
+		})
+
+		_, err := stg.Job(ctx).FindById(job.ID)
+		requireCode(t, errs.NotFound, err)
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		outer, inner := newJob(queue), newJob(queue)
+		err := stg.RunInTx(ctx, func(ctx context.Context) error {
+			require.NoError(t, stg.Job(ctx).CreateOne(outer))
+			err := stg.RunInTx(ctx, func(ctx context.Context) error {
+				require.NoError(t, stg.Job(ctx).CreateOne(inner))
+				return errors.New("failed")
+			})
+			require.Error(t, err)
+
+			// only the savepoint is rolled back
+			_, err = stg.Job(ctx).FindById(inner.ID)
+			requireCode(t, errs.NotFound, err)
+			return nil
+		})
+		require.NoError(t, err)
+
+		_, err = stg.Job(ctx).FindById(outer.ID)
+		require.NoError(t, err)
+		_, err = stg.Job(ctx).FindById(inner.ID)
+		requireCode(t, errs.NotFound, err)
+	})
+
+	t.Run("failed statement", func(t *testing.T) {
+		user := newUser()
+		err := stg.RunInTx(ctx, func(ctx context.Context) error {
+			require.NoError(t, stg.User(ctx).CreateOne(user))
+			return stg.User(ctx).CreateOne(&model.User{Email: user.Email})
+		})
+		requireCode(t, errs.Conflict, err)
+
+		found, err := stg.User(ctx).FindByEmail(user.Email)
+		require.NoError(t, err)
+		require.Nil(t, found)
+	})
+}
+
+func testBegin(t *testing.T, stg storage.Storage) {
+	ctx := context.Background()
+	queue := uniqueName("begin")
+
+	txCtx, ses, err := stg.Begin(ctx)
+	require.NoError(t, err)
+	rolledBack := newJob(queue)
+	require.NoError(t, stg.Job(txCtx).CreateOne(rolledBack))
+	require.NoError(t, ses.Rollback())
+
+	_, err = stg.Job(ctx).FindById(rolledBack.ID)
+	requireCode(t, errs.NotFound, err)
+
+	txCtx, ses, err = stg.Begin(ctx)
+	require.NoError(t, err)
+	committed := newJob(queue)
+	require.NoError(t, stg.Job(txCtx).CreateOne(committed))
+
+	// a nested session is a savepoint of the transaction
+	savepoint, err := ses.Begin()
+	require.NoError(t, err)
+	require.NoError(t, savepoint.Commit())
+	require.NoError(t, ses.Commit())
+
+	_, err = stg.Job(ctx).FindById(committed.ID)
+	require.NoError(t, err)
+}
+
+func testAdvisoryLock(t *testing.T, stg storage.Storage) {
+	ctx := context.Background()
+	name := uniqueName("lock")
+
+	lock, acquired, err := stg.TryAdvisoryLock(ctx, name)
+	require.NoError(t, err)
+	require.True(t, acquired)
+	require.NoError(t, lock.Check(ctx))
+
+	_, acquired, err = stg.TryAdvisoryLock(ctx, name)
+	require.NoError(t, err)
+	require.False(t, acquired, "the lock must not be acquired twice")
+
+	other, acquired, err := stg.TryAdvisoryLock(ctx, uniqueName("lock"))
+	require.NoError(t, err)
+	require.True(t, acquired)
+	require.NoError(t, other.Release(ctx))
+
+	require.NoError(t, lock.Release(ctx))
+	lock, acquired, err = stg.TryAdvisoryLock(ctx, name)
+	require.NoError(t, err)
+	require.True(t, acquired)
+	require.NoError(t, lock.Release(ctx))
+}
@@ -0,0 +1,168 @@
+package storagetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/amahdian/golang-gin-boilerplate/pkg/actor"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func newUser() *model.User {
+	return &model.User{Email: uniqueName("user") + "@example.com"}
+}
+
+func testUser(t *testing.T, stg storage.Storage) {
+	userStg := stg.User(context.Background())
+
+	user := newUser()
+	require.NoError(t, userStg.CreateOne(user))
+	require.NotEqual(t, uuid.Nil, user.ID)
+	require.Equal(t, int64(1), user.Version)
+
+	found, err := userStg.FindByEmail(user.Email)
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	require.Equal(t, user.ID, found.ID)
+
+	unknown, err := userStg.FindByEmail(uniqueName("unknown"))
+	require.NoError(t, err)
+	require.Nil(t, unknown, "an unknown email is not an error")
+
+	t.Run("unique email", func(t *testing.T) {
+		err := userStg.CreateOne(&model.User{Email: user.Email})
+		requireCode(t, errs.Conflict, err)
+
+		other := newUser()
+		require.NoError(t, userStg.CreateOne(other))
+		other.Email = user.Email
+		requireCode(t, errs.Conflict, userStg.UpdateOne(other, false))
+	})
+
+	t.Run("optimistic locking", func(t *testing.T) {
+		stale := *found
+		user.PasswordHash = "hash"
+		require.NoError(t, userStg.UpdateOne(user, false))
+		require.Equal(t, int64(2), user.Version)
+
+		stale.PasswordHash = "stale"
+		err := userStg.UpdatePartial(&stale, false)
+		requireCode(t, errs.Conflict, err)
+		require.ErrorIs(t, err, storage.ErrStaleVersion)
+		require.Equal(t, int64(1), stale.Version, "the version of a failed update must be left untouched")
+
+		found, err := userStg.FindByEmail(user.Email)
+		require.NoError(t, err)
+		require.Equal(t, "hash", found.PasswordHash)
+
+		requireCode(t, errs.InvalidArgument, userStg.UpdateOne(&model.User{ID: user.ID, Email: user.Email}, false))
+	})
+
+	t.Run("optimistic locking of batches", func(t *testing.T) {
+		first, second := newUser(), newUser()
+		require.NoError(t, userStg.CreateMany([]*model.User{first, second}))
+		stale := *second
+
+		first.PasswordHash, second.PasswordHash = "first", "second"
+		require.NoError(t, userStg.UpdateMany([]*model.User{first, second}))
+		require.Equal(t, []int64{2, 2}, []int64{first.Version, second.Version})
+
+		first.PasswordHash, stale.PasswordHash = "updated", "stale"
+		err := userStg.UpdateMany([]*model.User{first, &stale})
+		require.ErrorIs(t, err, storage.ErrStaleVersion)
+		require.Equal(t, []int64{2, 1}, []int64{first.Version, stale.Version}, "the versions of a failed batch must be left untouched")
+
+		found, err := userStg.FindByUuid(first.ID)
+		require.NoError(t, err)
+		require.Equal(t, "first", found.PasswordHash, "a stale model must fail the whole batch")
+		require.Equal(t, int64(2), found.Version)
+
+		unknown, err := userStg.FindByUuid(uuid.New())
+		require.NoError(t, err)
+		require.Nil(t, unknown, "an unknown id is not an error")
+	})
+}
+
+func testAudit(t *testing.T, stg storage.Storage) {
+	creator, updater := uuid.New(), uuid.New()
+
+	user := newUser()
+	require.NoError(t, stg.User(actor.WithUserId(context.Background(), creator)).CreateOne(user))
+	require.Equal(t, &creator, user.CreatedBy)
+	require.Equal(t, &creator, user.UpdatedBy)
+
+	userStg := stg.User(actor.WithUserId(context.Background(), updater))
+	user.PasswordHash = "updated"
+	require.NoError(t, userStg.UpdateOne(user, false))
+	require.NoError(t, userStg.UpdatePartial(&model.User{ID: user.ID, PasswordHash: "partial", Versioned: user.Versioned}, false))
+
+	found, err := userStg.FindByUuid(user.ID)
+	require.NoError(t, err)
+	require.Equal(t, &creator, found.CreatedBy, "the creator must be kept")
+	require.Equal(t, &updater, found.UpdatedBy)
+
+	anonymous := newUser()
+	require.NoError(t, stg.User(context.Background()).CreateOne(anonymous))
+	require.Nil(t, anonymous.CreatedBy, "the changes of the anonymous contexts are not attributed")
+	require.Nil(t, anonymous.UpdatedBy)
+
+	// the explicitly provided users are kept, e.g. the ones of the seeds or the imports
+	imported := newUser()
+	imported.CreatedBy = &updater
+	require.NoError(t, stg.User(actor.WithUserId(context.Background(), creator)).CreateOne(imported))
+	require.Equal(t, &updater, imported.CreatedBy)
+	require.Equal(t, &creator, imported.UpdatedBy)
+}
+
+func testUserUpsert(t *testing.T, stg storage.Storage) {
+	creator, updater := uuid.New(), uuid.New()
+	// the emails are only unique among the users which are not deleted
+	byEmail := storage.UpsertOptions{ConflictColumns: []string{"email"}, ConflictWhere: "deleted_at IS NULL"}
+
+	user := newUser()
+	require.NoError(t, stg.User(actor.WithUserId(context.Background(), creator)).UpsertOne(user, byEmail))
+	require.Equal(t, int64(1), user.Version)
+
+	userStg := stg.User(actor.WithUserId(context.Background(), updater))
+	changed := &model.User{Email: user.Email, PasswordHash: "changed"}
+	require.NoError(t, userStg.UpsertOne(changed, byEmail))
+	require.Equal(t, user.ID, changed.ID, "the existing user must be updated")
+	require.Equal(t, int64(2), changed.Version, "the version must be incremented on conflict")
+	found, err := userStg.FindByUuid(user.ID)
+	require.NoError(t, err)
+	require.Equal(t, "changed", found.PasswordHash)
+	require.Equal(t, int64(2), found.Version)
+	require.Equal(t, &creator, found.CreatedBy, "the creator must be kept")
+	require.Equal(t, &updater, found.UpdatedBy)
+	require.WithinDuration(t, user.CreatedAt, found.CreatedAt, time.Millisecond, "the creation time must be kept")
+
+	ignored := &model.User{Email: user.Email, PasswordHash: "ignored"}
+	require.NoError(t, userStg.UpsertOne(ignored, storage.UpsertOptions{
+		ConflictColumns: byEmail.ConflictColumns,
+		ConflictWhere:   byEmail.ConflictWhere,
+		UpdateColumns:   []string{"updated_at"},
+	}))
+	found, err = userStg.FindByUuid(user.ID)
+	require.NoError(t, err)
+	require.Equal(t, "changed", found.PasswordHash, "only the update columns must be updated")
+	require.Equal(t, int64(3), found.Version)
+
+	// the deleted users are kept deleted by the upserts of their primary key, and their emails can be taken
+	found.DeletedAt.Time, found.DeletedAt.Valid = time.Now(), true
+	require.NoError(t, userStg.UpdateOne(found, false))
+	require.NoError(t, userStg.UpsertOne(&model.User{ID: user.ID, Email: user.Email, PasswordHash: "deleted"}, storage.UpsertOptions{}))
+	deleted, err := userStg.FindByUuid(user.ID)
+	require.NoError(t, err)
+	require.Nil(t, deleted, "the deleted user must not be restored")
+	retaken := &model.User{Email: user.Email}
+	require.NoError(t, userStg.UpsertOne(retaken, byEmail))
+	require.NotEqual(t, user.ID, retaken.ID, "the email of a deleted user must be free")
+
+	err = userStg.UpsertOne(newUser(), storage.UpsertOptions{ConflictColumns: []string{"email"}})
+	requireCode(t, errs.InvalidArgument, err, "the predicate of the partial index must be given")
+}
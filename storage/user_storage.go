@@ -2,10 +2,13 @@ package storage
 
 import (
 	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/google/uuid"
 )
 
 type UserStorage interface {
 	CrudStorage[*model.User]
 
 	FindByEmail(email string) (*model.User, error)
+	// FindByUuid finds the user by its uuid primary key. It returns nil if the user does not exist.
+	FindByUuid(id uuid.UUID) (*model.User, error)
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/amahdian/golang-gin-boilerplate/domain/model"
 	"github.com/amahdian/golang-gin-boilerplate/global/env"
 	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/amahdian/golang-gin-boilerplate/pkg/actor"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
@@ -55,10 +56,13 @@ func (a *authenticator) Verify(request *http.Request) (context.Context, error) {
 	})
 
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		ctx = context.WithValue(ctx, userInfoCtx{}, UserInfo{
+		userInfo := UserInfo{
 			ID:    uuid.MustParse(claims["id"].(string)),
 			Email: claims["email"].(string),
-		})
+		}
+		ctx = context.WithValue(ctx, userInfoCtx{}, userInfo)
+		// the changes of the request are attributed to the user in the audit columns
+		ctx = actor.WithUserId(ctx, userInfo.ID)
 		return ctx, nil
 	} else {
 		return ctx, errs.Newf(errs.Unauthenticated, err, "Auth failed.")
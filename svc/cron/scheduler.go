@@ -0,0 +1,373 @@
+package cron
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/domain/model/common"
+	"github.com/amahdian/golang-gin-boilerplate/global/env"
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/amahdian/golang-gin-boilerplate/pkg/logger"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/pkg/errors"
+	robfig "github.com/robfig/cron/v3"
+)
+
+const leaderLockName = "cron_scheduler_leader"
+
+// taskLockPrefix prefixes the advisory lock names of the tasks, which are held while the tasks run
+const taskLockPrefix = "cron_task_"
+
+// specParser parses the standard 5 field cron expressions (e.g. "0 3 * * *") and the descriptors (e.g. "@daily", "@every 1h").
+var specParser = robfig.NewParser(robfig.Minute | robfig.Hour | robfig.Dom | robfig.Month | robfig.Dow | robfig.Descriptor)
+
+// TaskFunc is a recurring task. The ctx is canceled when the scheduler stops.
+type TaskFunc func(ctx context.Context) error
+
+type task struct {
+	name     string
+	spec     string
+	schedule robfig.Schedule
+	run      TaskFunc
+
+	// a task does not overlap with its previous run on the same replica,
+	// and the task advisory lock prevents the overlaps with the runs of the other replicas
+	running atomic.Bool
+}
+
+// TaskInfo describes a registered task for the admins.
+type TaskInfo struct {
+	Name     string `json:"name"`
+	Schedule string `json:"schedule"`
+	// the next occurrence of the schedule, which is only run if there is a leader at that time
+	NextRunAt time.Time      `json:"next_run_at"`
+	LastRun   *model.CronRun `json:"last_run"`
+}
+
+// Scheduler runs the registered tasks at the occurrences of their cron schedules.
+//
+// All replicas run a scheduler, but only the leader (the replica holding the leader advisory lock) runs the
+// scheduled occurrences. The others wait to take over if the leader stops or loses its db connection.
+// The manual triggers run on the replica which receives them.
+type Scheduler struct {
+	stg  storage.Storage
+	host string
+
+	mu    sync.RWMutex
+	tasks map[string]*task
+
+	// runs tracks all the runs, including the manual ones, so the scheduler waits for them when it stops.
+	// runsCtx is canceled and no run is started anymore once stopped is set
+	runsMu   sync.Mutex
+	runs     sync.WaitGroup
+	runsCtx  context.Context
+	stopRuns context.CancelFunc
+	stopped  bool
+
+	leaderPollInterval time.Duration
+}
+
+func NewScheduler(stg storage.Storage, envs *env.Envs) *Scheduler {
+	host, _ := os.Hostname()
+	runsCtx, stopRuns := context.WithCancel(context.Background())
+	return &Scheduler{
+		stg:                stg,
+		host:               host,
+		tasks:              make(map[string]*task),
+		runsCtx:            runsCtx,
+		stopRuns:           stopRuns,
+		leaderPollInterval: envs.Cron.LeaderPollInterval,
+	}
+}
+
+// Register registers a task with a cron expression (e.g. "0 3 * * *") or a descriptor (e.g. "@hourly", "@every 10m").
+// It should be called at startup.
+func (s *Scheduler) Register(name, spec string, run TaskFunc) error {
+	schedule, err := specParser.Parse(spec)
+	if err != nil {
+		return errors.Wrapf(err, "invalid schedule %q of %q task", spec, name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tasks[name]; ok {
+		return fmt.Errorf("%q task is already registered", name)
+	}
+	s.tasks[name] = &task{name: name, spec: spec, schedule: schedule, run: run}
+	return nil
+}
+
+// Run competes for the leadership until ctx is canceled, and runs the scheduled occurrences while it is the leader.
+// Once ctx is canceled, it cancels the running tasks (including the manual runs) and waits for them to finish.
+func (s *Scheduler) Run(ctx context.Context) {
+	defer s.stop()
+
+	if s.leaderPollInterval <= 0 {
+		logger.Info("cron scheduler is disabled")
+		// the tasks can still be triggered manually
+		<-ctx.Done()
+		return
+	}
+
+	ticker := time.NewTicker(s.leaderPollInterval)
+	defer ticker.Stop()
+	for {
+		lock, acquired, err := s.stg.TryAdvisoryLock(ctx, leaderLockName)
+		if err != nil {
+			logger.Errorf("failed to acquire the cron leader lock: %v", err)
+		} else if acquired {
+			logger.Infof("%s became the cron scheduler leader", s.host)
+			s.lead(ctx, lock)
+			if err := lock.Release(context.WithoutCancel(ctx)); err != nil {
+				logger.Warnf("failed to release the cron leader lock: %v", err)
+			}
+			logger.Infof("%s stepped down as the cron scheduler leader", s.host)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// lead runs the scheduled occurrences until ctx is canceled or the leader lock is lost,
+// then waits for the running tasks to finish.
+func (s *Scheduler) lead(ctx context.Context, lock storage.AdvisoryLock) {
+	running := &sync.WaitGroup{}
+	defer running.Wait()
+
+	lockCheck := time.NewTicker(s.leaderPollInterval)
+	defer lockCheck.Stop()
+
+	nextRuns := make(map[string]time.Time)
+	for {
+		now := time.Now()
+		var nextRunAt time.Time
+		for _, t := range s.listTasks() {
+			if _, ok := nextRuns[t.name]; !ok {
+				nextRuns[t.name] = s.firstRun(ctx, t, now)
+			}
+			if nextRunAt.IsZero() || nextRuns[t.name].Before(nextRunAt) {
+				nextRunAt = nextRuns[t.name]
+			}
+		}
+		if nextRunAt.IsZero() {
+			// no task is registered yet
+			nextRunAt = now.Add(s.leaderPollInterval)
+		}
+
+		timer := time.NewTimer(time.Until(nextRunAt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-lockCheck.C:
+			timer.Stop()
+			if err := lock.Check(ctx); err != nil {
+				logger.Errorf("lost the cron leader lock: %v", err)
+				return
+			}
+		case <-timer.C:
+			now = time.Now()
+			for _, t := range s.listTasks() {
+				occurrence := nextRuns[t.name]
+				if occurrence.After(now) {
+					continue
+				}
+				nextRuns[t.name] = t.schedule.Next(now)
+				if _, err := s.start(ctx, running, t, model.CronRunScheduled, occurrence); err != nil {
+					logger.Warnf("skipped %q task occurrence at %s: %v", t.name, occurrence.Format(time.RFC3339), err)
+				}
+			}
+		}
+	}
+}
+
+// firstRun returns the first occurrence of the task to run as the new leader. It follows the last scheduled occurrence
+// of the storage rather than now, so the leadership changes don't restart the schedules (e.g. "@every 1h" would never
+// run if the leader changed more often). An occurrence which has been missed since then is run right away, once.
+func (s *Scheduler) firstRun(ctx context.Context, t *task, now time.Time) time.Time {
+	lastRun, err := s.stg.CronRun(ctx).FindLastScheduled(t.name)
+	if err != nil {
+		logger.Warnf("failed to find the last scheduled run of %q task: %v", t.name, err)
+	}
+	if lastRun == nil {
+		return t.schedule.Next(now)
+	}
+	return t.schedule.Next(lastRun.ScheduledAt)
+}
+
+// Trigger runs the task immediately in the background, regardless of its schedule and the leadership.
+func (s *Scheduler) Trigger(ctx context.Context, name string) (*model.CronRun, error) {
+	t, ok := s.findTask(name)
+	if !ok {
+		return nil, errs.Newf(errs.NotFound, nil, "%q task could not be found", name)
+	}
+	// the task must outlive the request which has triggered it
+	return s.start(context.WithoutCancel(ctx), nil, t, model.CronRunManual, time.Now())
+}
+
+// start records the run and runs the task in the background. The run is tracked by running too, if it is not nil.
+func (s *Scheduler) start(ctx context.Context, running *sync.WaitGroup, t *task, trigger model.CronRunTrigger, scheduledAt time.Time) (_ *model.CronRun, err error) {
+	if !t.running.CompareAndSwap(false, true) {
+		return nil, errs.Newf(errs.Conflict, nil, "%q task is already running", t.name)
+	}
+	defer func() {
+		if err != nil {
+			t.running.Store(false)
+		}
+	}()
+
+	lock, acquired, err := s.stg.TryAdvisoryLock(ctx, taskLockPrefix+t.name)
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to lock %q task", t.name)
+	}
+	if !acquired {
+		return nil, errs.Newf(errs.Conflict, nil, "%q task is already running on another replica", t.name)
+	}
+	releaseLock := func() {
+		if err := lock.Release(context.WithoutCancel(ctx)); err != nil {
+			logger.Warnf("failed to release the lock of %q task: %v", t.name, err)
+		}
+	}
+
+	if !s.addRun() {
+		releaseLock()
+		return nil, errs.Newf(errs.FailedPrecondition, nil, "the cron scheduler has been stopped")
+	}
+	defer func() {
+		if err != nil {
+			releaseLock()
+			s.runs.Done()
+		}
+	}()
+
+	run := &model.CronRun{
+		TaskName:    t.name,
+		Trigger:     trigger,
+		Status:      model.CronRunRunning,
+		ScheduledAt: scheduledAt,
+		StartedAt:   time.Now(),
+		Host:        s.host,
+	}
+	started, err := s.stg.CronRun(ctx).Start(run)
+	if err != nil || !started {
+		if err == nil {
+			err = errs.Newf(errs.Conflict, nil, "the occurrence of %q task has already been started", t.name)
+		}
+		return nil, errs.Wrapf(err, "failed to start %q task", t.name)
+	}
+
+	// the run is canceled when the scheduler stops, even if ctx isn't (e.g. for the manual runs)
+	runCtx, cancel := context.WithCancel(ctx)
+	stopCancel := context.AfterFunc(s.runsCtx, cancel)
+	if running != nil {
+		running.Add(1)
+	}
+	go func() {
+		defer s.runs.Done()
+		if running != nil {
+			defer running.Done()
+		}
+		defer t.running.Store(false)
+		defer releaseLock()
+		defer stopCancel()
+		defer cancel()
+
+		status, runErr := model.CronRunSucceeded, ""
+		if err := safeRun(runCtx, t.run); err != nil {
+			logger.Errorf("%q task failed: %v", t.name, err)
+			status, runErr = model.CronRunFailed, err.Error()
+		}
+		if err := s.stg.CronRun(context.WithoutCancel(ctx)).Finish(run.ID, status, runErr); err != nil {
+			logger.Errorf("failed to record the end of %q task run %d: %v", t.name, run.ID, err)
+		}
+	}()
+	return run, nil
+}
+
+// addRun adds a run to the runs of the scheduler, unless it has been stopped.
+func (s *Scheduler) addRun() bool {
+	s.runsMu.Lock()
+	defer s.runsMu.Unlock()
+	if s.stopped {
+		return false
+	}
+	s.runs.Add(1)
+	return true
+}
+
+// stop cancels the runs and waits for them to finish.
+func (s *Scheduler) stop() {
+	s.runsMu.Lock()
+	s.stopped = true
+	s.runsMu.Unlock()
+
+	s.stopRuns()
+	s.runs.Wait()
+}
+
+// Tasks lists the registered tasks with their next occurrence and last run.
+func (s *Scheduler) Tasks(ctx context.Context) ([]*TaskInfo, error) {
+	now := time.Now()
+	infos := make([]*TaskInfo, 0)
+	for _, t := range s.listTasks() {
+		lastRun, err := s.stg.CronRun(ctx).FindLast(t.name)
+		if err != nil {
+			return nil, errs.Wrapf(err, "failed to find the last run of %q task", t.name)
+		}
+		infos = append(infos, &TaskInfo{
+			Name:      t.name,
+			Schedule:  t.spec,
+			NextRunAt: t.schedule.Next(now),
+			LastRun:   lastRun,
+		})
+	}
+	return infos, nil
+}
+
+// Runs lists the run history of the task, or of all the tasks if name is empty. Only the given fields are selected
+// when fields is not empty.
+func (s *Scheduler) Runs(ctx context.Context, name string, pagination *common.Pagination, fields []string) ([]*model.CronRun, error) {
+	if _, ok := s.findTask(name); name != "" && !ok {
+		return nil, errs.Newf(errs.NotFound, nil, "%q task could not be found", name)
+	}
+	return s.stg.CronRun(ctx).ListByTask(name, pagination, fields)
+}
+
+func (s *Scheduler) findTask(name string) (*task, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, ok := s.tasks[name]
+	return t, ok
+}
+
+// listTasks returns the registered tasks sorted by name.
+func (s *Scheduler) listTasks() []*task {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tasks := make([]*task, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		tasks = append(tasks, t)
+	}
+	slices.SortFunc(tasks, func(a, b *task) int { return strings.Compare(a.name, b.name) })
+	return tasks
+}
+
+func safeRun(ctx context.Context, run TaskFunc) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return run(ctx)
+}
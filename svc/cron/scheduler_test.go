@@ -0,0 +1,171 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/global/env"
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/amahdian/golang-gin-boilerplate/global/test"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/amahdian/golang-gin-boilerplate/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	test.SetupTestingEnv()
+	m.Run()
+}
+
+type fakeCronRunStorage struct {
+	storage.CronRunStorage
+	mu       sync.Mutex
+	started  []*model.CronRun
+	finished chan model.CronRunStatus
+	// the last scheduled run of every task
+	lastScheduled map[string]*model.CronRun
+}
+
+func (stg *fakeCronRunStorage) Start(run *model.CronRun) (bool, error) {
+	stg.mu.Lock()
+	defer stg.mu.Unlock()
+	run.ID = int64(len(stg.started) + 1)
+	stg.started = append(stg.started, run)
+	return true, nil
+}
+
+func (stg *fakeCronRunStorage) Finish(_ int64, status model.CronRunStatus, _ string) error {
+	stg.finished <- status
+	return nil
+}
+
+func (stg *fakeCronRunStorage) FindLastScheduled(taskName string) (*model.CronRun, error) {
+	return stg.lastScheduled[taskName], nil
+}
+
+// fakeStorage keeps the advisory locks of the memory storage
+type fakeStorage struct {
+	storage.Storage
+	cronRunStg *fakeCronRunStorage
+}
+
+func (stg *fakeStorage) CronRun(context.Context) storage.CronRunStorage {
+	return stg.cronRunStg
+}
+
+func newTestScheduler() (*Scheduler, *fakeCronRunStorage) {
+	cronRunStg := &fakeCronRunStorage{
+		finished:      make(chan model.CronRunStatus, 1),
+		lastScheduled: make(map[string]*model.CronRun),
+	}
+	return NewScheduler(&fakeStorage{Storage: memory.NewStg(), cronRunStg: cronRunStg}, &env.Envs{}), cronRunStg
+}
+
+func TestRegister(t *testing.T) {
+	s, _ := newTestScheduler()
+	noop := func(context.Context) error { return nil }
+
+	require.NoError(t, s.Register("nightly", "0 3 * * *", noop))
+	require.NoError(t, s.Register("frequent", "@every 10m", noop))
+	assert.Error(t, s.Register("nightly", "@daily", noop), "duplicate task names must be rejected")
+	assert.Error(t, s.Register("invalid", "61 * * * *", noop))
+
+	nightly, _ := s.findTask("nightly")
+	from := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC), nightly.schedule.Next(from))
+}
+
+func TestTrigger(t *testing.T) {
+	t.Run("runs the task in the background and records the outcome", func(t *testing.T) {
+		s, cronRunStg := newTestScheduler()
+		release := make(chan struct{})
+		require.NoError(t, s.Register("report", "@daily", func(context.Context) error {
+			<-release
+			return errors.New("boom")
+		}))
+
+		run, err := s.Trigger(context.Background(), "report")
+		require.NoError(t, err)
+		assert.Equal(t, model.CronRunManual, run.Trigger)
+		assert.Equal(t, model.CronRunRunning, run.Status)
+
+		_, err = s.Trigger(context.Background(), "report")
+		assert.True(t, errs.Code(err) == errs.Conflict, "a running task must not overlap: %v", err)
+
+		close(release)
+		assert.Equal(t, model.CronRunFailed, <-cronRunStg.finished)
+	})
+
+	t.Run("does not overlap with the runs of the other replicas", func(t *testing.T) {
+		s, _ := newTestScheduler()
+		require.NoError(t, s.Register("report", "@daily", func(context.Context) error { return nil }))
+
+		lock, acquired, err := s.stg.TryAdvisoryLock(context.Background(), taskLockPrefix+"report")
+		require.NoError(t, err)
+		require.True(t, acquired)
+
+		_, err = s.Trigger(context.Background(), "report")
+		assert.True(t, errs.Code(err) == errs.Conflict, "got %v", err)
+		task, _ := s.findTask("report")
+		assert.False(t, task.running.Load(), "a skipped run must not be left running")
+
+		require.NoError(t, lock.Release(context.Background()))
+		_, err = s.Trigger(context.Background(), "report")
+		assert.NoError(t, err)
+	})
+
+	t.Run("cancels and waits for the manual runs when the scheduler stops", func(t *testing.T) {
+		s, cronRunStg := newTestScheduler()
+		require.NoError(t, s.Register("report", "@daily", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		_, err := s.Trigger(ctx, "report")
+		require.NoError(t, err)
+		cancel()
+		select {
+		case status := <-cronRunStg.finished:
+			t.Fatalf("the run must outlive the request, but it has %s", status)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		stopCtx, stop := context.WithCancel(context.Background())
+		stop()
+		s.Run(stopCtx)
+		assert.Equal(t, model.CronRunFailed, <-cronRunStg.finished, "the run must be canceled before Run returns")
+
+		_, err = s.Trigger(context.Background(), "report")
+		assert.True(t, errs.Code(err) == errs.FailedPrecondition, "got %v", err)
+	})
+
+	t.Run("fails for unknown tasks", func(t *testing.T) {
+		s, _ := newTestScheduler()
+		_, err := s.Trigger(context.Background(), "unknown")
+		assert.True(t, errs.Code(err) == errs.NotFound, "got %v", err)
+	})
+}
+
+func TestFirstRun(t *testing.T) {
+	s, cronRunStg := newTestScheduler()
+	noop := func(context.Context) error { return nil }
+	require.NoError(t, s.Register("frequent", "@every 1h", noop))
+	frequent, _ := s.findTask("frequent")
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, now.Add(time.Hour), s.firstRun(context.Background(), frequent, now), "a task which has never run starts from now")
+
+	cronRunStg.lastScheduled["frequent"] = &model.CronRun{ScheduledAt: now.Add(-40 * time.Minute)}
+	assert.Equal(t, now.Add(20*time.Minute), s.firstRun(context.Background(), frequent, now),
+		"a new leader must not restart the schedule")
+
+	cronRunStg.lastScheduled["frequent"] = &model.CronRun{ScheduledAt: now.Add(-3 * time.Hour)}
+	assert.Equal(t, now.Add(-2*time.Hour), s.firstRun(context.Background(), frequent, now),
+		"a missed occurrence must be run right away")
+}
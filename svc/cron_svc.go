@@ -0,0 +1,43 @@
+package svc
+
+import (
+	"context"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/domain/model/common"
+	"github.com/amahdian/golang-gin-boilerplate/svc/cron"
+)
+
+type CronSvc interface {
+	// ListTasks lists the recurring tasks with their next run time and last run.
+	ListTasks() ([]*cron.TaskInfo, error)
+	// ListRuns lists the run history of the task, or of all the tasks if taskName is empty. Only the given fields are
+	// selected when fields is not empty.
+	ListRuns(taskName string, pagination *common.Pagination, fields []string) ([]*model.CronRun, error)
+	// Trigger runs the task immediately in the background.
+	Trigger(taskName string) (*model.CronRun, error)
+}
+
+type cronSvc struct {
+	ctx       context.Context
+	scheduler *cron.Scheduler
+}
+
+func newCronSvc(ctx context.Context, scheduler *cron.Scheduler) CronSvc {
+	return &cronSvc{
+		ctx:       ctx,
+		scheduler: scheduler,
+	}
+}
+
+func (s *cronSvc) ListTasks() ([]*cron.TaskInfo, error) {
+	return s.scheduler.Tasks(s.ctx)
+}
+
+func (s *cronSvc) ListRuns(taskName string, pagination *common.Pagination, fields []string) ([]*model.CronRun, error) {
+	return s.scheduler.Runs(s.ctx, taskName, pagination, fields)
+}
+
+func (s *cronSvc) Trigger(taskName string) (*model.CronRun, error) {
+	return s.scheduler.Trigger(s.ctx, taskName)
+}
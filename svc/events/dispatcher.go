@@ -0,0 +1,83 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+)
+
+// Handler is an in-process subscriber of an event type.
+// Events are delivered at least once, so the handlers must be idempotent.
+type Handler func(ctx context.Context, envelope *Envelope) error
+
+// Sink receives all the events, e.g. to forward them to a webhook or a message broker.
+// Events are delivered at least once, so the sinks must be idempotent.
+type Sink interface {
+	Name() string
+	Deliver(ctx context.Context, envelope *Envelope) error
+}
+
+// Dispatcher delivers the events relayed from the outbox to the subscribers of their type and to all the sinks.
+type Dispatcher struct {
+	mu          sync.RWMutex
+	subscribers map[string][]Handler
+	sinks       []Sink
+}
+
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{
+		subscribers: make(map[string][]Handler),
+		sinks:       make([]Sink, 0),
+	}
+}
+
+// Subscribe registers a handler for the events of the given type. It should be called at startup.
+func (d *Dispatcher) Subscribe(eventType string, handler Handler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.subscribers[eventType] = append(d.subscribers[eventType], handler)
+}
+
+// AddSink registers a sink for all the events. It should be called at startup.
+func (d *Dispatcher) AddSink(sink Sink) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sinks = append(d.sinks, sink)
+}
+
+// Dispatch delivers the envelope to all its subscribers and sinks. A failure does not stop the delivery
+// to the others, and the whole envelope is redelivered later if any of them fails.
+func (d *Dispatcher) Dispatch(ctx context.Context, envelope *Envelope) error {
+	d.mu.RLock()
+	handlers := d.subscribers[envelope.Type]
+	sinks := d.sinks
+	d.mu.RUnlock()
+
+	failures := make([]error, 0)
+	for i, handler := range handlers {
+		if err := safeDeliver(func() error { return handler(ctx, envelope) }); err != nil {
+			failures = append(failures, fmt.Errorf("subscriber #%d of %q failed: %w", i, envelope.Type, err))
+		}
+	}
+	for _, sink := range sinks {
+		if err := safeDeliver(func() error { return sink.Deliver(ctx, envelope) }); err != nil {
+			failures = append(failures, fmt.Errorf("sink %q failed: %w", sink.Name(), err))
+		}
+	}
+
+	if len(failures) > 0 {
+		return errs.Errors(failures)
+	}
+	return nil
+}
+
+func safeDeliver(deliver func() error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return deliver()
+}
@@ -0,0 +1,58 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingSink struct {
+	delivered []*Envelope
+	err       error
+}
+
+func (s *recordingSink) Name() string { return "recording" }
+
+func (s *recordingSink) Deliver(_ context.Context, envelope *Envelope) error {
+	s.delivered = append(s.delivered, envelope)
+	return s.err
+}
+
+func TestDispatch(t *testing.T) {
+	d := NewDispatcher()
+	sink := &recordingSink{}
+	d.AddSink(sink)
+
+	var received UserRegistered
+	d.Subscribe(UserRegisteredType, func(_ context.Context, envelope *Envelope) error {
+		return envelope.Decode(&received)
+	})
+	d.Subscribe("other.event", func(context.Context, *Envelope) error {
+		t.Fatal("subscriber of another event type must not be called")
+		return nil
+	})
+
+	envelope := &Envelope{ID: 1, Type: UserRegisteredType, Payload: []byte(`{"email":"a@b.c"}`)}
+	require.NoError(t, d.Dispatch(context.Background(), envelope))
+	assert.Equal(t, "a@b.c", received.Email)
+	assert.Len(t, sink.delivered, 1)
+}
+
+func TestDispatchCollectsFailures(t *testing.T) {
+	d := NewDispatcher()
+	sink := &recordingSink{err: errors.New("unreachable")}
+	d.AddSink(sink)
+	d.Subscribe(UserRegisteredType, func(context.Context, *Envelope) error {
+		panic("boom")
+	})
+
+	err := d.Dispatch(context.Background(), &Envelope{ID: 1, Type: UserRegisteredType})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "panic: boom")
+	assert.Contains(t, err.Error(), "unreachable")
+	// the sink is still called when a subscriber fails
+	assert.Len(t, sink.delivered, 1)
+}
@@ -0,0 +1,32 @@
+package events
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Event is a domain event. Events are serialized as json into the outbox and delivered to the
+// subscribers and sinks of their type after the transaction which has published them is committed.
+type Event interface {
+	EventType() string
+}
+
+// Envelope wraps a published event when it is delivered to the subscribers and sinks.
+type Envelope struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+	// Attempt starts from 1 and is incremented every time the delivery is retried.
+	Attempt int `json:"attempt"`
+}
+
+// Decode unmarshals the payload of the envelope into the given event.
+func (e *Envelope) Decode(event Event) error {
+	if err := json.Unmarshal(e.Payload, event); err != nil {
+		return errors.Wrapf(err, "failed to decode %q event payload", e.Type)
+	}
+	return nil
+}
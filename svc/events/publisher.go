@@ -0,0 +1,57 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/samber/lo"
+)
+
+type Publisher interface {
+	// Publish stores the events in the outbox as part of the transaction of ctx, therefore the events are only
+	// delivered if the transaction is committed. It fails if ctx does not carry a transaction.
+	Publish(ctx context.Context, events ...Event) error
+}
+
+type publisher struct {
+	stg storage.Storage
+}
+
+func NewPublisher(stg storage.Storage) Publisher {
+	return &publisher{stg: stg}
+}
+
+func (p *publisher) Publish(ctx context.Context, events ...Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	if storage.FromContext(ctx) == nil {
+		return errs.Newf(errs.Internal, nil, "events must be published inside a transaction, see storage.Storage.RunInTx")
+	}
+
+	now := time.Now()
+	outboxEvents := make([]*model.OutboxEvent, 0, len(events))
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return errs.Newf(errs.Internal, err, "failed to marshal %q event", event.EventType())
+		}
+		outboxEvents = append(outboxEvents, &model.OutboxEvent{
+			EventType:     event.EventType(),
+			Payload:       payload,
+			Status:        model.OutboxEventPending,
+			NextAttemptAt: now,
+		})
+	}
+
+	err := p.stg.Outbox(ctx).CreateMany(outboxEvents)
+	if err != nil {
+		eventTypes := lo.Map(events, func(e Event, _ int) string { return e.EventType() })
+		return errs.Wrapf(err, "failed to publish %v events", eventTypes)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/global/env"
+	"github.com/amahdian/golang-gin-boilerplate/pkg/backoff"
+	"github.com/amahdian/golang-gin-boilerplate/pkg/logger"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/pkg/errors"
+)
+
+const (
+	baseRetryBackoff = time.Second
+	maxRetryBackoff  = time.Hour
+)
+
+// Relay delivers the committed outbox events to the dispatcher. Failed deliveries are retried with
+// exponential backoff, and the events that still fail after the maximum attempts are dead-lettered.
+type Relay struct {
+	stg        storage.Storage
+	dispatcher *Dispatcher
+
+	interval    time.Duration
+	batchSize   int
+	claimLease  time.Duration
+	maxAttempts int
+}
+
+func NewRelay(stg storage.Storage, dispatcher *Dispatcher, envs *env.Envs) *Relay {
+	return &Relay{
+		stg:         stg,
+		dispatcher:  dispatcher,
+		interval:    envs.Events.RelayInterval,
+		batchSize:   envs.Events.RelayBatchSize,
+		claimLease:  envs.Events.ClaimLease,
+		maxAttempts: envs.Events.MaxAttempts,
+	}
+}
+
+// Run relays the events periodically until ctx is canceled.
+func (r *Relay) Run(ctx context.Context) {
+	if r.interval <= 0 {
+		logger.Info("outbox relay is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// keep relaying while there are full batches to catch up with bursts
+			for {
+				relayed, err := r.RelayOnce(ctx)
+				if err != nil {
+					logger.Errorf("outbox relay failed: %v", err)
+				}
+				if err != nil || relayed < r.batchSize || ctx.Err() != nil {
+					break
+				}
+			}
+		}
+	}
+}
+
+// RelayOnce delivers a batch of due events and returns the number of events that have been processed.
+// The events are claimed for the claim lease and delivered outside any transaction, so a slow sink does not hold the
+// row locks, and their results are recorded in a single transaction afterwards. If the results can't be recorded,
+// the events are delivered again after the lease, so the sinks must tolerate duplicates.
+func (r *Relay) RelayOnce(ctx context.Context) (relayed int, err error) {
+	outboxEvents, err := r.stg.Outbox(ctx).ClaimDue(r.batchSize, r.claimLease)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to claim due outbox events")
+	}
+
+	deliveryErrs := make([]error, len(outboxEvents))
+	for i, outboxEvent := range outboxEvents {
+		deliveryErrs[i] = r.dispatcher.Dispatch(ctx, newEnvelope(outboxEvent))
+	}
+
+	err = r.stg.RunInTx(ctx, func(txCtx context.Context) error {
+		outboxStg := r.stg.Outbox(txCtx)
+		for i, outboxEvent := range outboxEvents {
+			var err error
+			if deliveryErrs[i] == nil {
+				err = outboxStg.MarkDelivered(outboxEvent.ID)
+			} else {
+				err = r.markFailed(outboxStg, outboxEvent, deliveryErrs[i])
+			}
+			if err != nil {
+				return errors.Wrapf(err, "failed to update outbox event %d", outboxEvent.ID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(outboxEvents), nil
+}
+
+func (r *Relay) markFailed(outboxStg storage.OutboxStorage, outboxEvent *model.OutboxEvent, deliveryErr error) error {
+	attempt := outboxEvent.Attempts + 1
+	dead := attempt >= r.maxAttempts
+	if dead {
+		logger.Errorf("dead-lettering outbox event %d of type %q after %d attempts: %v", outboxEvent.ID, outboxEvent.EventType, attempt, deliveryErr)
+	} else {
+		logger.Warnf("failed to deliver outbox event %d of type %q (attempt %d): %v", outboxEvent.ID, outboxEvent.EventType, attempt, deliveryErr)
+	}
+
+	nextAttemptAt := time.Now().Add(backoff.Exponential(attempt, baseRetryBackoff, maxRetryBackoff))
+	return outboxStg.MarkFailed(outboxEvent.ID, deliveryErr.Error(), nextAttemptAt, dead)
+}
+
+func newEnvelope(outboxEvent *model.OutboxEvent) *Envelope {
+	return &Envelope{
+		ID:        outboxEvent.ID,
+		Type:      outboxEvent.EventType,
+		Payload:   outboxEvent.Payload,
+		CreatedAt: outboxEvent.CreatedAt,
+		Attempt:   outboxEvent.Attempts + 1,
+	}
+}
@@ -0,0 +1,14 @@
+package events
+
+import "github.com/google/uuid"
+
+const UserRegisteredType = "user.registered"
+
+type UserRegistered struct {
+	UserID uuid.UUID `json:"user_id"`
+	Email  string    `json:"email"`
+}
+
+func (UserRegistered) EventType() string {
+	return UserRegisteredType
+}
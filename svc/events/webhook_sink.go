@@ -0,0 +1,57 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const webhookTimeout = 10 * time.Second
+
+// WebhookSink posts every event as json to a webhook url. Any non 2xx response is treated as a failed delivery.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{
+		url:    url,
+		client: &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+func (s *WebhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *WebhookSink) Deliver(ctx context.Context, envelope *Envelope) error {
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal the event")
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "failed to create the webhook request")
+	}
+	request.Header.Set("Content-Type", "application/json")
+	// lets the receivers deduplicate the redelivered events
+	request.Header.Set("Idempotency-Key", fmt.Sprintf("event-%d", envelope.ID))
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return errors.Wrap(err, "failed to call the webhook")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", response.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package svc
+
+import (
+	"context"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/domain/model/common"
+	"github.com/amahdian/golang-gin-boilerplate/global/env"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+)
+
+type JobSvc interface {
+	// List lists the jobs with the given status, or all the jobs if status is empty. Only the given fields are selected
+	// when fields is not empty.
+	List(status model.JobStatus, pagination *common.Pagination, fields []string) ([]*model.Job, error)
+	// Retry makes a failed job pending again with a fresh set of attempts.
+	Retry(id int64) (*model.Job, error)
+}
+
+type jobSvc struct {
+	ctx context.Context
+	stg storage.Storage
+
+	envs *env.Envs
+}
+
+func newJobSvc(ctx context.Context, stg storage.Storage, envs *env.Envs) JobSvc {
+	return &jobSvc{
+		ctx:  ctx,
+		stg:  stg,
+		envs: envs,
+	}
+}
+
+func (s *jobSvc) List(status model.JobStatus, pagination *common.Pagination, fields []string) ([]*model.Job, error) {
+	return s.stg.Job(s.ctx).ListByStatus(status, pagination, fields)
+}
+
+func (s *jobSvc) Retry(id int64) (*model.Job, error) {
+	// read the retried job from the primary, the replicas may not have the change yet
+	jobStg := s.stg.Job(storage.UsePrimary(s.ctx))
+	if err := jobStg.Retry(id); err != nil {
+		return nil, err
+	}
+	return jobStg.FindById(id)
+}
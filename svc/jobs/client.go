@@ -0,0 +1,109 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/global/env"
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+)
+
+const DefaultQueue = "default"
+
+type enqueueOptions struct {
+	queue       string
+	runAt       time.Time
+	uniqueKey   *string
+	maxAttempts int
+}
+
+type EnqueueOption func(options *enqueueOptions)
+
+// OnQueue enqueues the job on the given queue instead of the default one.
+func OnQueue(queue string) EnqueueOption {
+	return func(options *enqueueOptions) {
+		options.queue = queue
+	}
+}
+
+// RunAt schedules the job to run at the given time instead of immediately.
+func RunAt(runAt time.Time) EnqueueOption {
+	return func(options *enqueueOptions) {
+		options.runAt = runAt
+	}
+}
+
+// RunIn schedules the job to run after the given delay instead of immediately.
+func RunIn(delay time.Duration) EnqueueOption {
+	return func(options *enqueueOptions) {
+		options.runAt = time.Now().Add(delay)
+	}
+}
+
+// Unique deduplicates the job by the given key, i.e. it is not enqueued while another pending or running job has the key.
+func Unique(key string) EnqueueOption {
+	return func(options *enqueueOptions) {
+		options.uniqueKey = &key
+	}
+}
+
+// MaxAttempts overrides the configured maximum attempts of the job.
+func MaxAttempts(maxAttempts int) EnqueueOption {
+	return func(options *enqueueOptions) {
+		options.maxAttempts = maxAttempts
+	}
+}
+
+type Client interface {
+	// Enqueue stores a job with the given args. If ctx carries a transaction the job takes part in it,
+	// therefore it is only executed if the transaction is committed.
+	//
+	// If the job is deduplicated by its unique key, the already enqueued job is returned.
+	Enqueue(ctx context.Context, args Args, opts ...EnqueueOption) (*model.Job, error)
+}
+
+type client struct {
+	stg         storage.Storage
+	maxAttempts int
+}
+
+func NewClient(stg storage.Storage, envs *env.Envs) Client {
+	return &client{
+		stg:         stg,
+		maxAttempts: envs.Jobs.MaxAttempts,
+	}
+}
+
+func (c *client) Enqueue(ctx context.Context, args Args, opts ...EnqueueOption) (*model.Job, error) {
+	options := &enqueueOptions{
+		queue:       DefaultQueue,
+		runAt:       time.Now(),
+		maxAttempts: c.maxAttempts,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	payload, err := json.Marshal(args)
+	if err != nil {
+		return nil, errs.Newf(errs.Internal, err, "failed to marshal %q job args", args.Kind())
+	}
+
+	job := &model.Job{
+		Queue:       options.queue,
+		Kind:        args.Kind(),
+		Payload:     payload,
+		Status:      model.JobPending,
+		MaxAttempts: options.maxAttempts,
+		UniqueKey:   options.uniqueKey,
+		RunAt:       options.runAt,
+	}
+	job, _, err = c.stg.Job(ctx).Enqueue(job)
+	if err != nil {
+		return nil, errs.Wrapf(err, "failed to enqueue %q job", args.Kind())
+	}
+	return job, nil
+}
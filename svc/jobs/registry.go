@@ -0,0 +1,59 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Args are the arguments of a job kind. They are serialized as json into the job payload and decoded
+// into the same type before they are passed to the handler of the kind.
+//
+// Kind is called on the zero value of the type when its handler is registered, so it must not depend on the fields.
+type Args interface {
+	Kind() string
+}
+
+type handlerFunc func(ctx context.Context, payload json.RawMessage) error
+
+// Registry maps the job kinds to their handlers.
+type Registry struct {
+	mu       sync.RWMutex
+	handlers map[string]handlerFunc
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		handlers: make(map[string]handlerFunc),
+	}
+}
+
+// Register registers the handler of the jobs of kind T. It should be called at startup.
+// Jobs are executed at least once, so the handlers must be idempotent.
+func Register[T Args](registry *Registry, handle func(ctx context.Context, args T) error) {
+	var zero T
+	kind := zero.Kind()
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if _, ok := registry.handlers[kind]; ok {
+		panic(fmt.Sprintf("a handler is already registered for %q jobs", kind))
+	}
+	registry.handlers[kind] = func(ctx context.Context, payload json.RawMessage) error {
+		var args T
+		if err := json.Unmarshal(payload, &args); err != nil {
+			return errors.Wrapf(err, "failed to decode %q job args", kind)
+		}
+		return handle(ctx, args)
+	}
+}
+
+func (r *Registry) handler(kind string) (handlerFunc, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	handler, ok := r.handlers[kind]
+	return handler, ok
+}
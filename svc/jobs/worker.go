@@ -0,0 +1,170 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/global/env"
+	"github.com/amahdian/golang-gin-boilerplate/pkg/backoff"
+	"github.com/amahdian/golang-gin-boilerplate/pkg/logger"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+)
+
+const (
+	baseRetryBackoff = time.Second
+	maxRetryBackoff  = time.Hour
+
+	// the running jobs are rescued once they exceed the job timeout by the grace period
+	staleJobGracePeriod = time.Minute
+)
+
+// Worker polls the queues for due jobs and executes them with their registered handlers. Each queue has its own
+// concurrency limit. Failed jobs are retried with exponential backoff until they run out of attempts.
+//
+// Multiple workers (e.g. in different processes) can serve the same queues concurrently.
+type Worker struct {
+	stg      storage.Storage
+	registry *Registry
+
+	queues       map[string]int
+	pollInterval time.Duration
+	timeout      time.Duration
+}
+
+func NewWorker(stg storage.Storage, registry *Registry, envs *env.Envs) *Worker {
+	return &Worker{
+		stg:          stg,
+		registry:     registry,
+		queues:       envs.Jobs.Queues,
+		pollInterval: envs.Jobs.PollInterval,
+		timeout:      envs.Jobs.Timeout,
+	}
+}
+
+// Run executes the jobs until ctx is canceled, then waits for the running jobs to finish.
+func (w *Worker) Run(ctx context.Context) {
+	if w.pollInterval <= 0 {
+		logger.Info("job worker is disabled")
+		return
+	}
+
+	wg := &sync.WaitGroup{}
+	for queue, concurrency := range w.queues {
+		if concurrency <= 0 {
+			logger.Warnf("skipping %q job queue with concurrency %d", queue, concurrency)
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.runQueue(ctx, queue, concurrency)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.rescueStaleJobs(ctx)
+	}()
+	wg.Wait()
+}
+
+func (w *Worker) runQueue(ctx context.Context, queue string, concurrency int) {
+	slots := make(chan struct{}, concurrency)
+	running := &sync.WaitGroup{}
+	defer running.Wait()
+
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			free := concurrency - len(slots)
+			if free == 0 {
+				continue
+			}
+			jobs, err := w.stg.Job(ctx).ClaimNext(queue, free)
+			if err != nil {
+				logger.Errorf("failed to claim jobs of %q queue: %v", queue, err)
+				continue
+			}
+			for _, job := range jobs {
+				slots <- struct{}{}
+				running.Add(1)
+				go func() {
+					defer running.Done()
+					defer func() { <-slots }()
+					w.execute(ctx, job)
+				}()
+			}
+		}
+	}
+}
+
+// execute runs the handler of the job and records the outcome. The outcome is recorded even if ctx is canceled
+// in the meantime, so the jobs interrupted by a shutdown are retried.
+func (w *Worker) execute(ctx context.Context, job *model.Job) {
+	handler, ok := w.registry.handler(job.Kind)
+	if !ok {
+		w.markFailed(ctx, job, fmt.Errorf("no handler is registered for %q jobs", job.Kind), true)
+		return
+	}
+
+	jobCtx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+	if err := safeHandle(func() error { return handler(jobCtx, job.Payload) }); err != nil {
+		w.markFailed(ctx, job, err, job.Attempts >= job.MaxAttempts)
+		return
+	}
+
+	if err := w.stg.Job(context.WithoutCancel(ctx)).MarkCompleted(job.ID); err != nil {
+		logger.Errorf("failed to mark job %d as completed: %v", job.ID, err)
+	}
+}
+
+func (w *Worker) markFailed(ctx context.Context, job *model.Job, jobErr error, dead bool) {
+	if dead {
+		logger.Errorf("%q job %d failed permanently after %d attempts: %v", job.Kind, job.ID, job.Attempts, jobErr)
+	} else {
+		logger.Warnf("%q job %d failed (attempt %d): %v", job.Kind, job.ID, job.Attempts, jobErr)
+	}
+
+	runAt := time.Now().Add(backoff.Exponential(job.Attempts, baseRetryBackoff, maxRetryBackoff))
+	if err := w.stg.Job(context.WithoutCancel(ctx)).MarkFailed(job.ID, jobErr.Error(), runAt, dead); err != nil {
+		logger.Errorf("failed to mark job %d as failed: %v", job.ID, err)
+	}
+}
+
+// rescueStaleJobs periodically makes the jobs that are stuck in running status (e.g. because their worker
+// has crashed) pending again, or fails them if they have used up their attempts.
+func (w *Worker) rescueStaleJobs(ctx context.Context) {
+	ticker := time.NewTicker(staleJobGracePeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			lockedBefore := time.Now().Add(-w.timeout - staleJobGracePeriod)
+			rescued, err := w.stg.Job(ctx).RescueStale(lockedBefore)
+			if err != nil {
+				logger.Errorf("failed to rescue stale jobs: %v", err)
+			} else if rescued > 0 {
+				logger.Warnf("rescued %d stale jobs", rescued)
+			}
+		}
+	}
+}
+
+func safeHandle(handle func() error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return handle()
+}
@@ -0,0 +1,122 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/global/test"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	test.SetupTestingEnv()
+	m.Run()
+}
+
+type greetJob struct {
+	Name string `json:"name"`
+}
+
+func (greetJob) Kind() string { return "greet" }
+
+type fakeJobStorage struct {
+	storage.JobStorage
+	completed []int64
+	failed    []int64
+	dead      []int64
+	lastError string
+}
+
+func (stg *fakeJobStorage) MarkCompleted(id int64) error {
+	stg.completed = append(stg.completed, id)
+	return nil
+}
+
+func (stg *fakeJobStorage) MarkFailed(id int64, lastError string, _ time.Time, dead bool) error {
+	stg.failed = append(stg.failed, id)
+	if dead {
+		stg.dead = append(stg.dead, id)
+	}
+	stg.lastError = lastError
+	return nil
+}
+
+type fakeStorage struct {
+	storage.Storage
+	jobStg *fakeJobStorage
+}
+
+func (stg *fakeStorage) Job(context.Context) storage.JobStorage {
+	return stg.jobStg
+}
+
+func newTestWorker(handle func(ctx context.Context, args greetJob) error) (*Worker, *fakeJobStorage) {
+	registry := NewRegistry()
+	Register(registry, handle)
+	jobStg := &fakeJobStorage{}
+	return &Worker{stg: &fakeStorage{jobStg: jobStg}, registry: registry, timeout: time.Minute}, jobStg
+}
+
+func newGreetJob(attempts int) *model.Job {
+	return &model.Job{ID: 1, Kind: "greet", Payload: []byte(`{"name":"gopher"}`), Attempts: attempts, MaxAttempts: 3}
+}
+
+func TestExecute(t *testing.T) {
+	t.Run("completes the successful jobs with the decoded args", func(t *testing.T) {
+		var received greetJob
+		w, jobStg := newTestWorker(func(_ context.Context, args greetJob) error {
+			received = args
+			return nil
+		})
+
+		w.execute(context.Background(), newGreetJob(1))
+		assert.Equal(t, "gopher", received.Name)
+		assert.Equal(t, []int64{1}, jobStg.completed)
+		assert.Empty(t, jobStg.failed)
+	})
+
+	t.Run("retries the failed jobs until they run out of attempts", func(t *testing.T) {
+		w, jobStg := newTestWorker(func(context.Context, greetJob) error {
+			return errors.New("boom")
+		})
+
+		w.execute(context.Background(), newGreetJob(1))
+		assert.Equal(t, []int64{1}, jobStg.failed)
+		assert.Empty(t, jobStg.dead)
+		assert.Equal(t, "boom", jobStg.lastError)
+
+		w.execute(context.Background(), newGreetJob(3))
+		assert.Equal(t, []int64{1}, jobStg.dead)
+	})
+
+	t.Run("recovers the panics of the handlers", func(t *testing.T) {
+		w, jobStg := newTestWorker(func(context.Context, greetJob) error {
+			panic("boom")
+		})
+
+		w.execute(context.Background(), newGreetJob(1))
+		assert.Equal(t, []int64{1}, jobStg.failed)
+		assert.Equal(t, "panic: boom", jobStg.lastError)
+	})
+
+	t.Run("fails the jobs of unknown kinds permanently", func(t *testing.T) {
+		w, jobStg := newTestWorker(func(context.Context, greetJob) error { return nil })
+
+		job := newGreetJob(1)
+		job.Kind = "unknown"
+		w.execute(context.Background(), job)
+		assert.Equal(t, []int64{1}, jobStg.dead)
+	})
+}
+
+func TestRegisterTwicePanics(t *testing.T) {
+	registry := NewRegistry()
+	Register(registry, func(context.Context, greetJob) error { return nil })
+	assert.Panics(t, func() {
+		Register(registry, func(context.Context, greetJob) error { return nil })
+	})
+}
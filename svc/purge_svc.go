@@ -0,0 +1,51 @@
+package svc
+
+import (
+	"context"
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/global/env"
+	"github.com/amahdian/golang-gin-boilerplate/pkg/logger"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/pkg/errors"
+)
+
+type PurgeSvc interface {
+	// PurgeDeleted permanently deletes the soft deleted records that are older than the configured retention period.
+	PurgeDeleted() (purged int64, err error)
+}
+
+// PurgeDeletedJob are the args of the background job which runs PurgeSvc.PurgeDeleted.
+type PurgeDeletedJob struct{}
+
+func (PurgeDeletedJob) Kind() string {
+	return "purge_deleted"
+}
+
+type purgeSvc struct {
+	ctx context.Context
+	stg storage.Storage
+
+	envs *env.Envs
+}
+
+func newPurgeSvc(ctx context.Context, stg storage.Storage, envs *env.Envs) PurgeSvc {
+	return &purgeSvc{
+		ctx:  ctx,
+		stg:  stg,
+		envs: envs,
+	}
+}
+
+func (s *purgeSvc) PurgeDeleted() (purged int64, err error) {
+	deletedBefore := time.Now().Add(-s.envs.Db.SoftDeleteRetention)
+
+	purgedUsers, err := s.stg.User(s.ctx).Purge(deletedBefore)
+	if err != nil {
+		return purged, errors.Wrap(err, "failed to purge deleted users")
+	}
+	purged += purgedUsers
+
+	logger.Infof("purged %d records deleted before %s", purged, deletedBefore.Format(time.RFC3339))
+	return purged, nil
+}
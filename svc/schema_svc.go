@@ -0,0 +1,32 @@
+package svc
+
+import (
+	"context"
+
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+)
+
+type SchemaSvc interface {
+	// Describe returns the schema of the db. The cached schema is read again from the db if refresh is true.
+	Describe(refresh bool) (*storage.DbSchema, error)
+}
+
+type schemaSvc struct {
+	ctx context.Context
+	stg storage.Storage
+}
+
+func newSchemaSvc(ctx context.Context, stg storage.Storage) SchemaSvc {
+	return &schemaSvc{
+		ctx: ctx,
+		stg: stg,
+	}
+}
+
+func (s *schemaSvc) Describe(refresh bool) (*storage.DbSchema, error) {
+	metadataStg := s.stg.Metadata(s.ctx)
+	if refresh {
+		metadataStg.Invalidate()
+	}
+	return metadataStg.DescribeSchema()
+}
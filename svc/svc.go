@@ -6,24 +6,50 @@ import (
 	"github.com/amahdian/golang-gin-boilerplate/global/env"
 
 	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/amahdian/golang-gin-boilerplate/svc/cron"
+	"github.com/amahdian/golang-gin-boilerplate/svc/events"
 )
 
 type Svc interface {
 	NewUserSvc(ctx context.Context) UserSvc
+	NewPurgeSvc(ctx context.Context) PurgeSvc
+	NewJobSvc(ctx context.Context) JobSvc
+	NewCronSvc(ctx context.Context) CronSvc
+	NewSchemaSvc(ctx context.Context) SchemaSvc
 }
 
 type svcImpl struct {
-	stg  storage.Storage
-	Envs *env.Envs
+	stg       storage.Storage
+	publisher events.Publisher
+	scheduler *cron.Scheduler
+	Envs      *env.Envs
 }
 
-func NewSvc(stg storage.Storage, envs *env.Envs) Svc {
+func NewSvc(stg storage.Storage, scheduler *cron.Scheduler, envs *env.Envs) Svc {
 	return &svcImpl{
 		stg,
+		events.NewPublisher(stg),
+		scheduler,
 		envs,
 	}
 }
 
 func (s *svcImpl) NewUserSvc(ctx context.Context) UserSvc {
-	return newUserSvc(ctx, s.stg, s.Envs)
+	return newUserSvc(ctx, s.stg, s.publisher, s.Envs)
+}
+
+func (s *svcImpl) NewPurgeSvc(ctx context.Context) PurgeSvc {
+	return newPurgeSvc(ctx, s.stg, s.Envs)
+}
+
+func (s *svcImpl) NewJobSvc(ctx context.Context) JobSvc {
+	return newJobSvc(ctx, s.stg, s.Envs)
+}
+
+func (s *svcImpl) NewCronSvc(ctx context.Context) CronSvc {
+	return newCronSvc(ctx, s.scheduler)
+}
+
+func (s *svcImpl) NewSchemaSvc(ctx context.Context) SchemaSvc {
+	return newSchemaSvc(ctx, s.stg)
 }
@@ -3,33 +3,44 @@ package svc
 import (
 	"context"
 	"errors"
+	"slices"
 	"time"
 
 	"github.com/amahdian/golang-gin-boilerplate/domain/model"
 	"github.com/amahdian/golang-gin-boilerplate/global/env"
+	"github.com/amahdian/golang-gin-boilerplate/global/errs"
 	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/amahdian/golang-gin-boilerplate/svc/events"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type UserSvc interface {
 	Login(email, password string) (string, error)
 	Register(email, password string) (string, error)
+	Profile(id uuid.UUID) (*model.User, error)
+	// UpdateProfile updates the email of the user of the given model. The update fails with a conflict unless the user
+	// is at one of the ifMatch versions, or if the user is modified concurrently. Any version is updated if ifMatch
+	// is empty.
+	UpdateProfile(update *model.User, ifMatch []int64) (*model.User, error)
 }
 
 type userSvc struct {
-	ctx context.Context
-	stg storage.Storage
+	ctx       context.Context
+	stg       storage.Storage
+	publisher events.Publisher
 
 	envs *env.Envs
 }
 
-func newUserSvc(ctx context.Context, stg storage.Storage, envs *env.Envs) UserSvc {
+func newUserSvc(ctx context.Context, stg storage.Storage, publisher events.Publisher, envs *env.Envs) UserSvc {
 	return &userSvc{
-		ctx:  ctx,
-		stg:  stg,
-		envs: envs,
+		ctx:       ctx,
+		stg:       stg,
+		publisher: publisher,
+		envs:      envs,
 	}
 }
 
@@ -47,6 +58,10 @@ func (s *userSvc) Login(email, password string) (string, error) {
 		return "", err
 	}
 
+	return s.signToken(user), nil
+}
+
+func (s *userSvc) signToken(user *model.User) string {
 	claims := jwt.MapClaims{
 		"id":    user.ID,
 		"email": user.Email,
@@ -55,29 +70,79 @@ func (s *userSvc) Login(email, password string) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenStr, _ := token.SignedString([]byte(s.envs.Server.JwtSecret))
 
-	return tokenStr, nil
+	return tokenStr
 }
 
 func (s *userSvc) Register(email, password string) (string, error) {
-	user, err := s.stg.User(s.ctx).FindByEmail(email)
+	hash, _ := bcrypt.GenerateFromPassword([]byte(password), 10)
+
+	var user *model.User
+	err := s.stg.RunInTx(s.ctx, func(ctx context.Context) error {
+		var err error
+		user, err = s.stg.User(ctx).FindByEmail(email)
+		if err != nil {
+			return err
+		}
+
+		if user != nil {
+			return errors.New("user is already registered")
+		}
+
+		user = &model.User{
+			Email:        email,
+			PasswordHash: string(hash),
+		}
+		err = s.stg.User(ctx).CreateOne(user)
+		if err != nil {
+			return err
+		}
+
+		return s.publisher.Publish(ctx, events.UserRegistered{
+			UserID: user.ID,
+			Email:  user.Email,
+		})
+	})
 	if err != nil {
 		return "", err
 	}
 
-	if user != nil {
-		return "", errors.New("user is already registered")
-	}
+	// the token is signed for the created user, since a lagging replica may not have the user yet
+	return s.signToken(user), nil
+}
 
-	hash, _ := bcrypt.GenerateFromPassword([]byte(password), 10)
+func (s *userSvc) Profile(id uuid.UUID) (*model.User, error) {
+	return s.findUser(s.ctx, id)
+}
 
-	user = &model.User{
-		Email:        email,
-		PasswordHash: string(hash),
+func (s *userSvc) UpdateProfile(update *model.User, ifMatch []int64) (*model.User, error) {
+	// the version is compared with the primary, since a lagging replica would report a stale version
+	user, err := s.findUser(storage.UsePrimary(s.ctx), update.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ifMatch) > 0 && !slices.Contains(ifMatch, user.Version) {
+		return nil, errs.Newf(errs.Conflict, storage.ErrStaleVersion, "the user has been modified, its version %d is not one of %v", user.Version, ifMatch)
+	}
+	user.Email = update.Email
+	// the update fails with a stale version if the user is modified after it has been read
+	err = s.stg.User(s.ctx).UpdateOne(user, false)
+	if errors.Is(err, storage.ErrStaleVersion) {
+		return nil, errs.Newf(errs.Conflict, err, "the user has been modified since version %d", user.Version)
 	}
-	err = s.stg.User(s.ctx).CreateOne(user)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	return user, nil
+}
 
-	return s.Login(email, password)
+func (s *userSvc) findUser(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	user, err := s.stg.User(ctx).FindByUuid(id)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errs.Newf(errs.NotFound, nil, "user by id %s could not be found", id)
+	}
+	return user, nil
 }
@@ -0,0 +1,69 @@
+package svc
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/global/env"
+	"github.com/amahdian/golang-gin-boilerplate/global/test"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/amahdian/golang-gin-boilerplate/storage/memory"
+	"github.com/amahdian/golang-gin-boilerplate/svc/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	test.SetupTestingEnv()
+	os.Exit(m.Run())
+}
+
+func newTestUserSvc(stg storage.Storage) UserSvc {
+	envs := &env.Envs{}
+	envs.Server.JwtSecret = "secret"
+	return newUserSvc(context.Background(), stg, events.NewPublisher(stg), envs)
+}
+
+func TestUserSvcRegister(t *testing.T) {
+	stg := memory.NewStg()
+	userSvc := newTestUserSvc(stg)
+
+	token, err := userSvc.Register(test.UserEmail, test.UserPassword)
+	require.NoError(t, err)
+	assert.NotEmpty(t, token)
+
+	user, err := stg.User(context.Background()).FindByEmail(test.UserEmail)
+	require.NoError(t, err)
+	require.NotNil(t, user)
+	assert.NotEqual(t, test.UserPassword, user.PasswordHash, "the password must be hashed")
+
+	outboxEvents, err := stg.Outbox(context.Background()).ListAll()
+	require.NoError(t, err)
+	require.Len(t, outboxEvents, 1)
+	assert.Equal(t, events.UserRegistered{}.EventType(), outboxEvents[0].EventType)
+	assert.Equal(t, model.OutboxEventPending, outboxEvents[0].Status)
+
+	_, err = userSvc.Register(test.UserEmail, test.UserPassword)
+	assert.Error(t, err, "a user must not be registered twice")
+	outboxEvents, err = stg.Outbox(context.Background()).ListAll()
+	require.NoError(t, err)
+	assert.Len(t, outboxEvents, 1, "the event of a failed registration must be rolled back")
+}
+
+func TestUserSvcLogin(t *testing.T) {
+	stg := memory.NewStg()
+	userSvc := newTestUserSvc(stg)
+	_, err := userSvc.Register(test.UserEmail, test.UserPassword)
+	require.NoError(t, err)
+
+	token, err := userSvc.Login(test.UserEmail, test.UserPassword)
+	require.NoError(t, err)
+	assert.NotEmpty(t, token)
+
+	_, err = userSvc.Login(test.UserEmail, "wrong")
+	assert.Error(t, err)
+	_, err = userSvc.Login("unknown@example.com", test.UserPassword)
+	assert.Error(t, err)
+}
@@ -0,0 +1,204 @@
+// Package factory builds the models of the tests with unique and randomized fields, so the tests do not collide
+// with each other on a shared storage.
+//
+// A Definition holds the defaults of a model and how it is stored. Build returns a model without storing it, and
+// Create stores it through the storage.Storage of the factory, together with the related models it needs:
+//
+//	f := factory.New(t, stg)
+//	admin := factory.Create(f, factory.User, factory.Admin)
+//	event := factory.Create(f, factory.OutboxEvent) // creates the registered user as well
+//	jobs := factory.CreateList(f, factory.Job, 3, factory.JobFailed)
+//
+// The random fields are generated from the seed of the factory, which is logged when the test fails. Run the test
+// with -factory.seed to generate the same models again.
+package factory
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/stretchr/testify/require"
+)
+
+const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+var seedFlag = flag.Int64("factory.seed", 0, "the seed of the random fields of the test factories, random if zero")
+
+// Definition defines how the models of type T are built and stored.
+type Definition[T any] struct {
+	// Name is the name of the model in the failures of the test.
+	Name string
+	// Defaults sets the fields of a new model, before the options of the test are applied.
+	Defaults func(f *Factory, m *T)
+	// Related sets the related models which are still missing after the options are applied, e.g. with Related,
+	// so the test can pass its own related models instead.
+	Related func(f *Factory, m *T)
+	// Create stores the model through the storage.
+	Create func(ctx context.Context, stg storage.Storage, m *T) error
+}
+
+// Option changes a model after its defaults are set. The traits (e.g. Admin or JobFailed) are predefined options.
+type Option[T any] func(f *Factory, m *T)
+
+// With returns an option which overrides the fields of the model.
+func With[T any](fn func(m *T)) Option[T] {
+	return func(_ *Factory, m *T) {
+		fn(m)
+	}
+}
+
+// Factory builds and creates the models of a test.
+type Factory struct {
+	t   testing.TB
+	ctx context.Context
+	stg storage.Storage
+	// create is true while the models are built for Create, so their related models are created as well
+	create bool
+
+	state *state
+}
+
+type state struct {
+	seed int64
+	mu   sync.Mutex
+	rand *rand.Rand
+	seqs map[string]int
+}
+
+// New returns a factory which creates the models in the given storage. The storage can be nil if the test only
+// builds the models.
+func New(t testing.TB, stg storage.Storage) *Factory {
+	seed := *seedFlag
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	t.Cleanup(func() {
+		if t.Failed() {
+			t.Logf("the test factory used the seed %d, run the test with -factory.seed=%d to reproduce it", seed, seed)
+		}
+	})
+	return newFactory(t, stg, seed)
+}
+
+func newFactory(t testing.TB, stg storage.Storage, seed int64) *Factory {
+	return &Factory{
+		t:   t,
+		ctx: context.Background(),
+		stg: stg,
+		state: &state{
+			seed: seed,
+			rand: rand.New(rand.NewSource(seed)),
+			seqs: map[string]int{},
+		},
+	}
+}
+
+// WithContext returns a factory which creates the models with the given context, e.g. in a transaction.
+// The sequences and the random fields are shared with f.
+func (f *Factory) WithContext(ctx context.Context) *Factory {
+	clone := *f
+	clone.ctx = ctx
+	return &clone
+}
+
+// Seed returns the seed of the random fields.
+func (f *Factory) Seed() int64 {
+	return f.state.seed
+}
+
+// Seq returns the next number of the named sequence, starting from 1.
+func (f *Factory) Seq(name string) int {
+	f.state.mu.Lock()
+	defer f.state.mu.Unlock()
+	f.state.seqs[name]++
+	return f.state.seqs[name]
+}
+
+// Intn returns a random number in [0, n).
+func (f *Factory) Intn(n int) int {
+	f.state.mu.Lock()
+	defer f.state.mu.Unlock()
+	return f.state.rand.Intn(n)
+}
+
+// String returns a random string of lowercase letters and digits.
+func (f *Factory) String(n int) string {
+	f.state.mu.Lock()
+	defer f.state.mu.Unlock()
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = alphabet[f.state.rand.Intn(len(alphabet))]
+	}
+	return string(b)
+}
+
+// Build returns a new model of the definition without storing it. Its related models are built but not stored either.
+func Build[T any](f *Factory, d *Definition[T], opts ...Option[T]) *T {
+	f.t.Helper()
+	m := new(T)
+	if d.Defaults != nil {
+		d.Defaults(f, m)
+	}
+	for _, opt := range opts {
+		opt(f, m)
+	}
+	if d.Related != nil {
+		d.Related(f, m)
+	}
+	return m
+}
+
+// Create builds a new model of the definition and stores it along with its related models.
+// It fails the test if the model can't be stored.
+func Create[T any](f *Factory, d *Definition[T], opts ...Option[T]) *T {
+	f.t.Helper()
+	require.NotNil(f.t, f.stg, "the factory has no storage to create the %s in", d.Name)
+	creating := *f
+	creating.create = true
+
+	m := Build(&creating, d, opts...)
+	err := d.Create(f.ctx, f.stg, m)
+	require.NoError(f.t, err, "failed to create the %s", d.Name)
+	return m
+}
+
+// BuildList builds n models of the definition with the same options.
+func BuildList[T any](f *Factory, d *Definition[T], n int, opts ...Option[T]) []*T {
+	f.t.Helper()
+	models := make([]*T, n)
+	for i := range models {
+		models[i] = Build(f, d, opts...)
+	}
+	return models
+}
+
+// CreateList creates n models of the definition with the same options.
+func CreateList[T any](f *Factory, d *Definition[T], n int, opts ...Option[T]) []*T {
+	f.t.Helper()
+	models := make([]*T, n)
+	for i := range models {
+		models[i] = Create(f, d, opts...)
+	}
+	return models
+}
+
+// Related returns a related model for the model being built: it is created if the model is created, and only built
+// otherwise.
+func Related[T any](f *Factory, d *Definition[T], opts ...Option[T]) *T {
+	f.t.Helper()
+	if f.create {
+		return Create(f, d, opts...)
+	}
+	return Build(f, d, opts...)
+}
+
+// seqName returns the name of the next model of the sequence, e.g. user1.
+func (f *Factory) seqName(name string) string {
+	return fmt.Sprintf("%s%d", name, f.Seq(name))
+}
@@ -0,0 +1,100 @@
+package factory
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/global/test"
+	"github.com/amahdian/golang-gin-boilerplate/storage/memory"
+	"github.com/amahdian/golang-gin-boilerplate/svc/events"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestMain(m *testing.M) {
+	test.SetupTestingEnv()
+	os.Exit(m.Run())
+}
+
+func TestBuild(t *testing.T) {
+	f := New(t, nil)
+
+	users := BuildList(f, User, 2)
+	assert.NotEqual(t, users[0].Email, users[1].Email)
+	assert.Regexp(t, `^user1\.[a-z0-9]{6}@example\.com$`, users[0].Email)
+	require.NoError(t, bcrypt.CompareHashAndPassword([]byte(users[0].PasswordHash), []byte(test.UserPassword)))
+
+	admin := Build(f, User, Admin, Password("secret"), With(func(u *model.User) {
+		u.Email = "other@example.com"
+	}))
+	assert.Equal(t, "other@example.com", admin.Email, "the options are applied in order")
+	require.NoError(t, bcrypt.CompareHashAndPassword([]byte(admin.PasswordHash), []byte("secret")))
+
+	job := Build(f, Job, JobFailed)
+	assert.Equal(t, model.JobFailed, job.Status)
+	assert.Equal(t, job.MaxAttempts, job.Attempts)
+}
+
+func TestSeed(t *testing.T) {
+	f := newFactory(t, nil, 42)
+	replay := newFactory(t, nil, 42)
+
+	assert.Equal(t, Build(f, User).Email, Build(replay, User).Email, "the same seed must build the same models")
+}
+
+func TestCreate(t *testing.T) {
+	stg := memory.NewStg()
+	f := New(t, stg)
+	ctx := context.Background()
+
+	user := Create(f, User, Admin)
+	assert.NotEqual(t, uuid.Nil, user.ID)
+	found, err := stg.User(ctx).FindByEmail(AdminEmail)
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, user.ID, found.ID)
+
+	// the related user is created along with the event
+	event := Create(f, OutboxEvent, OutboxDelivered)
+	var payload events.UserRegistered
+	require.NoError(t, json.Unmarshal(event.Payload, &payload))
+	registered, err := stg.User(ctx).FindByEmail(payload.Email)
+	require.NoError(t, err)
+	require.NotNil(t, registered)
+	assert.Equal(t, registered.ID, payload.UserID)
+
+	// the related user given by the test is used instead
+	event = Create(f, OutboxEvent, RegisteredUser(user))
+	require.NoError(t, json.Unmarshal(event.Payload, &payload))
+	assert.Equal(t, user.ID, payload.UserID)
+
+	runs := CreateList(f, CronRun, 2, CronRunFailed)
+	for _, run := range runs {
+		found, err := stg.CronRun(ctx).FindLast(run.TaskName)
+		require.NoError(t, err)
+		require.NotNil(t, found)
+		assert.Equal(t, model.CronRunFailed, found.Status)
+	}
+
+	jobs := CreateList(f, Job, 3, JobFailed)
+	failed, err := stg.Job(ctx).ListByStatus(model.JobFailed, nil, nil)
+	require.NoError(t, err)
+	assert.Len(t, failed, len(jobs))
+}
+
+func TestBuildRelated(t *testing.T) {
+	stg := memory.NewStg()
+	f := New(t, stg)
+
+	event := Build(f, OutboxEvent)
+	var payload events.UserRegistered
+	require.NoError(t, json.Unmarshal(event.Payload, &payload))
+	found, err := stg.User(context.Background()).FindByEmail(payload.Email)
+	require.NoError(t, err)
+	assert.Nil(t, found, "the related models of a built model must not be created")
+}
@@ -0,0 +1,186 @@
+package factory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/model"
+	"github.com/amahdian/golang-gin-boilerplate/global/test"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/amahdian/golang-gin-boilerplate/svc/events"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// AdminEmail is the email of the Admin users, which is an admin email of the test servers (see testutil.TestEnvs).
+const AdminEmail = test.UserEmail
+
+// User builds the users with a unique email and test.UserPassword as their password.
+var User = &Definition[model.User]{
+	Name: "user",
+	Defaults: func(f *Factory, u *model.User) {
+		u.Email = fmt.Sprintf("%s.%s@example.com", f.seqName("user"), f.String(6))
+		u.PasswordHash = defaultPasswordHash()
+	},
+	Create: func(ctx context.Context, stg storage.Storage, u *model.User) error {
+		return stg.User(ctx).CreateOne(u)
+	},
+}
+
+// Admin gives the user the AdminEmail. Since the emails are unique, a storage can only have one admin at a time.
+var Admin Option[model.User] = func(_ *Factory, u *model.User) {
+	u.Email = AdminEmail
+}
+
+// SoftDeleted marks the user as soft deleted.
+var SoftDeleted Option[model.User] = func(_ *Factory, u *model.User) {
+	u.DeletedAt = gorm.DeletedAt{Time: now(), Valid: true}
+}
+
+// Password gives the user the password, instead of test.UserPassword.
+func Password(password string) Option[model.User] {
+	return func(f *Factory, u *model.User) {
+		u.PasswordHash = hashPassword(password)
+	}
+}
+
+// Job builds the pending jobs of the default queue which are due.
+var Job = &Definition[model.Job]{
+	Name: "job",
+	Defaults: func(f *Factory, j *model.Job) {
+		seq := f.Seq("job")
+		j.Queue = "default"
+		j.Kind = "test_job"
+		j.Payload = json.RawMessage(fmt.Sprintf(`{"seq":%d,"value":%q}`, seq, f.String(8)))
+		j.Status = model.JobPending
+		j.MaxAttempts = 10
+		j.RunAt = now()
+		j.CreatedAt = now()
+	},
+	Create: func(ctx context.Context, stg storage.Storage, j *model.Job) error {
+		return stg.Job(ctx).CreateOne(j)
+	},
+}
+
+// JobRunning marks the job as claimed by a worker.
+var JobRunning Option[model.Job] = func(_ *Factory, j *model.Job) {
+	lockedAt := now()
+	j.Status = model.JobRunning
+	j.Attempts = 1
+	j.LockedAt = &lockedAt
+}
+
+// JobCompleted marks the job as completed at its first attempt.
+var JobCompleted Option[model.Job] = func(_ *Factory, j *model.Job) {
+	completedAt := now()
+	j.Status = model.JobCompleted
+	j.Attempts = 1
+	j.CompletedAt = &completedAt
+}
+
+// JobFailed marks the job as failed after all its attempts.
+var JobFailed Option[model.Job] = func(f *Factory, j *model.Job) {
+	j.Status = model.JobFailed
+	j.Attempts = j.MaxAttempts
+	j.LastError = "failed: " + f.String(8)
+}
+
+// CronRun builds the succeeded scheduled runs of a task of their own.
+var CronRun = &Definition[model.CronRun]{
+	Name: "cron run",
+	Defaults: func(f *Factory, r *model.CronRun) {
+		r.TaskName = f.seqName("task")
+		r.Trigger = model.CronRunScheduled
+		r.Status = model.CronRunSucceeded
+		r.ScheduledAt = now().Truncate(time.Minute)
+		r.StartedAt = r.ScheduledAt
+		finishedAt := r.StartedAt.Add(time.Duration(1+f.Intn(1000)) * time.Millisecond)
+		r.FinishedAt = &finishedAt
+		r.Host = "test-host"
+	},
+	Create: func(ctx context.Context, stg storage.Storage, r *model.CronRun) error {
+		return stg.CronRun(ctx).CreateOne(r)
+	},
+}
+
+// CronRunRunning marks the run as not finished yet.
+var CronRunRunning Option[model.CronRun] = func(_ *Factory, r *model.CronRun) {
+	r.Status = model.CronRunRunning
+	r.FinishedAt = nil
+}
+
+// CronRunFailed marks the run as failed.
+var CronRunFailed Option[model.CronRun] = func(f *Factory, r *model.CronRun) {
+	r.Status = model.CronRunFailed
+	r.Error = "failed: " + f.String(8)
+}
+
+// CronRunManual marks the run as triggered manually.
+var CronRunManual Option[model.CronRun] = func(_ *Factory, r *model.CronRun) {
+	r.Trigger = model.CronRunManual
+}
+
+// OutboxEvent builds the pending events of the registration of a user. The user is created along with the event,
+// unless the event is given one with RegisteredUser.
+var OutboxEvent = &Definition[model.OutboxEvent]{
+	Name: "outbox event",
+	Defaults: func(_ *Factory, e *model.OutboxEvent) {
+		e.EventType = events.UserRegisteredType
+		e.Status = model.OutboxEventPending
+		e.NextAttemptAt = now()
+		e.CreatedAt = now()
+	},
+	Related: func(f *Factory, e *model.OutboxEvent) {
+		if len(e.Payload) == 0 {
+			RegisteredUser(Related(f, User))(f, e)
+		}
+	},
+	Create: func(ctx context.Context, stg storage.Storage, e *model.OutboxEvent) error {
+		return stg.Outbox(ctx).CreateOne(e)
+	},
+}
+
+// RegisteredUser makes the event the registration of the given user.
+func RegisteredUser(user *model.User) Option[model.OutboxEvent] {
+	return func(_ *Factory, e *model.OutboxEvent) {
+		payload, _ := json.Marshal(events.UserRegistered{UserID: user.ID, Email: user.Email})
+		e.EventType = events.UserRegisteredType
+		e.Payload = payload
+	}
+}
+
+// OutboxDelivered marks the event as delivered.
+var OutboxDelivered Option[model.OutboxEvent] = func(_ *Factory, e *model.OutboxEvent) {
+	deliveredAt := now()
+	e.Status = model.OutboxEventDelivered
+	e.Attempts = 1
+	e.DeliveredAt = &deliveredAt
+}
+
+// OutboxDead marks the event as dead after the failed deliveries.
+var OutboxDead Option[model.OutboxEvent] = func(f *Factory, e *model.OutboxEvent) {
+	e.Status = model.OutboxEventDead
+	e.Attempts = 10
+	e.LastError = "failed: " + f.String(8)
+}
+
+// now returns the current time at the precision of the db, so the created models compare equal to the stored ones.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// the passwords are hashed with the minimum cost, since the hashing would slow down the tests
+var defaultPasswordHash = sync.OnceValue(func() string {
+	return hashPassword(test.UserPassword)
+})
+
+func hashPassword(password string) string {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		panic(err)
+	}
+	return string(hash)
+}
@@ -0,0 +1,96 @@
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// GoldenDir is the directory of the golden files, relative to the package of the test.
+const GoldenDir = "testdata/golden"
+
+const scrubbedValue = "<scrubbed>"
+
+var updateGolden = flag.Bool("update", false, "update the golden files of the tests")
+
+type goldenConfig struct {
+	scrubbed [][]string
+}
+
+type GoldenOption func(config *goldenConfig)
+
+// Scrub replaces the values which change on every run (e.g. the ids, the tokens and the timestamps) before the
+// comparison. The paths are dot separated json keys or array indexes, and "*" matches any of them,
+// e.g. "data.id" or "data.*.created_at".
+func Scrub(paths ...string) GoldenOption {
+	return func(config *goldenConfig) {
+		for _, path := range paths {
+			config.scrubbed = append(config.scrubbed, strings.Split(path, "."))
+		}
+	}
+}
+
+// AssertGoldenJSON compares the json with the golden file testdata/golden/<name>.json of the test package.
+// The json is compared in its indented form with the sorted keys, so the golden files are easy to review.
+// Run the tests with -update to write the golden files instead, e.g. go test ./server/... -run TestLogin -update
+func AssertGoldenJSON(t testing.TB, name string, data []byte, opts ...GoldenOption) {
+	t.Helper()
+	config := &goldenConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	require.NoError(t, decoder.Decode(&value), "the golden value is not json: %s", data)
+	for _, path := range config.scrubbed {
+		value = scrub(value, path)
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	require.NoError(t, encoder.Encode(value))
+	actual := buf.Bytes()
+
+	path := filepath.Join(GoldenDir, name+".json")
+	if *updateGolden {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, actual, 0o644))
+		return
+	}
+	expected, err := os.ReadFile(path)
+	require.NoError(t, err, "failed to read the golden file, run the test with -update to create it")
+	assert.Equal(t, string(expected), string(actual), "the json does not match the golden file %s", path)
+}
+
+func scrub(value any, path []string) any {
+	if len(path) == 0 {
+		return scrubbedValue
+	}
+	key, rest := path[0], path[1:]
+	switch v := value.(type) {
+	case map[string]any:
+		for k, child := range v {
+			if key == "*" || key == k {
+				v[k] = scrub(child, rest)
+			}
+		}
+	case []any:
+		for i, child := range v {
+			if key == "*" || key == strconv.Itoa(i) {
+				v[i] = scrub(child, rest)
+			}
+		}
+	}
+	return value
+}
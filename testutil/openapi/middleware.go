@@ -0,0 +1,73 @@
+package openapi
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// Middleware wraps the http handler next, e.g. the router of a test server, rather than being a gin middleware, so only
+// the tests validate their requests and responses. It validates them against the spec and reports the violations:
+//   - the requests of the paths and methods which are not documented,
+//   - the requests which do not match their documented parameters and body, but are accepted by the handler,
+//   - the responses with a status that is not documented, or a body that does not match its documented schema.
+//
+// The invalid requests which are rejected by the handler (e.g. with a 400) are not reported, so the tests can still
+// send them on purpose.
+func Middleware(s *Spec, next http.Handler, report func(err error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body []byte
+		if r.Body != nil {
+			var err error
+			if body, err = io.ReadAll(r.Body); err != nil {
+				report(errors.Wrap(err, "failed to read the body of the request"))
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		op, err := s.Operation(r.Method, r.URL.Path)
+		if err != nil {
+			report(err)
+			return
+		}
+		if err = op.ValidateRequest(r, body); err != nil && rec.status < http.StatusBadRequest {
+			report(errors.Wrapf(err, "the request was accepted with the %d status", rec.status))
+		}
+		if err = op.ValidateResponse(rec.status, rec.body.Bytes()); err != nil {
+			report(err)
+		}
+	})
+}
+
+// responseRecorder passes the response through while it keeps a copy of its status and body.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *responseRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
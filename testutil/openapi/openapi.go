@@ -0,0 +1,303 @@
+// Package openapi validates the requests and the responses of the api against its swagger document (docs/swagger.json),
+// so the integration tests fail when the handlers and their annotations drift apart.
+//
+// The validation is strict: the paths, the methods, the status codes and the fields which are not documented are
+// reported as violations. The fields without a documented type (e.g. a plain "object") accept any value.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/amahdian/golang-gin-boilerplate/docs"
+	"github.com/go-openapi/spec"
+	"github.com/pkg/errors"
+)
+
+// Spec is a swagger 2.0 document of the api.
+type Spec struct {
+	doc        *spec.Swagger
+	operations []*Operation
+}
+
+// Route is an operation of the api, with the parameters of its path in braces, e.g. /api/v1/admin/jobs/{id}/retry.
+type Route struct {
+	Method string
+	Path   string
+}
+
+func (r Route) String() string {
+	return r.Method + " " + r.Path
+}
+
+// Operation is a documented operation, which validates its requests and responses.
+type Operation struct {
+	Route
+	spec      *Spec
+	segments  []string
+	op        *spec.Operation
+	itemParam []spec.Parameter
+}
+
+// LoadDocs loads the generated swagger document of the api.
+func LoadDocs() (*Spec, error) {
+	return Load([]byte(docs.SwaggerInfo.ReadDoc()))
+}
+
+// Load parses a swagger 2.0 document in json.
+func Load(doc []byte) (*Spec, error) {
+	s := &Spec{doc: &spec.Swagger{}}
+	if err := json.Unmarshal(doc, s.doc); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the swagger document")
+	}
+	if s.doc.Paths == nil {
+		return s, nil
+	}
+	for path, item := range s.doc.Paths.Paths {
+		operations := map[string]*spec.Operation{
+			http.MethodGet:     item.Get,
+			http.MethodPut:     item.Put,
+			http.MethodPost:    item.Post,
+			http.MethodDelete:  item.Delete,
+			http.MethodOptions: item.Options,
+			http.MethodHead:    item.Head,
+			http.MethodPatch:   item.Patch,
+		}
+		path = strings.TrimSuffix(s.doc.BasePath, "/") + path
+		for method, op := range operations {
+			if op == nil {
+				continue
+			}
+			s.operations = append(s.operations, &Operation{
+				Route:     Route{Method: method, Path: path},
+				spec:      s,
+				segments:  splitPath(path),
+				op:        op,
+				itemParam: item.Parameters,
+			})
+		}
+	}
+	sort.Slice(s.operations, func(i, j int) bool {
+		return s.operations[i].String() < s.operations[j].String()
+	})
+	return s, nil
+}
+
+// Routes returns the documented routes in order.
+func (s *Spec) Routes() []Route {
+	routes := make([]Route, len(s.operations))
+	for i, op := range s.operations {
+		routes[i] = op.Route
+	}
+	return routes
+}
+
+// CheckRoutes returns an error which lists the registered routes that are not documented and the documented routes
+// that are not registered. The parameters of the registered paths must be in braces, see GinPath.
+func (s *Spec) CheckRoutes(registered []Route) error {
+	documented := map[Route]bool{}
+	for _, route := range s.Routes() {
+		documented[route] = true
+	}
+	problems := make([]string, 0)
+	for _, route := range registered {
+		if !documented[route] {
+			problems = append(problems, fmt.Sprintf("%s is not documented", route))
+		}
+		delete(documented, route)
+	}
+	for route := range documented {
+		problems = append(problems, fmt.Sprintf("%s is documented but not registered", route))
+	}
+	sort.Strings(problems)
+	return newError("the routes do not match the swagger document", problems)
+}
+
+// GinPath converts the parameters of a gin path to the braces of the swagger paths, e.g. /jobs/:id to /jobs/{id}.
+func GinPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// Operation finds the documented operation of the request. The literal segments of the paths take precedence over
+// their parameters, e.g. /jobs/failed is matched before /jobs/{id}.
+func (s *Spec) Operation(method, path string) (*Operation, error) {
+	segments := splitPath(path)
+	var found *Operation
+	pathDocumented := false
+	bestLiterals := -1
+	for _, op := range s.operations {
+		literals, ok := matchPath(op.segments, segments)
+		if !ok {
+			continue
+		}
+		pathDocumented = true
+		if op.Method == method && literals > bestLiterals {
+			found, bestLiterals = op, literals
+		}
+	}
+	switch {
+	case found != nil:
+		return found, nil
+	case pathDocumented:
+		return nil, errors.Errorf("%s %s: the method is not documented", method, path)
+	default:
+		return nil, errors.Errorf("%s %s: the path is not documented", method, path)
+	}
+}
+
+// ValidateRequest validates the parameters and the body of the request. body is the body of the request, which has
+// already been read.
+func (o *Operation) ValidateRequest(r *http.Request, body []byte) error {
+	problems := make([]string, 0)
+	pathValues := o.pathValues(r.URL.Path)
+	query := r.URL.Query()
+	documentedQuery := map[string]bool{}
+
+	for _, param := range o.parameters() {
+		switch param.In {
+		case "path":
+			o.validateParam(&param, []string{pathValues[param.Name]}, &problems)
+		case "query":
+			documentedQuery[param.Name] = true
+			values, ok := query[param.Name]
+			if !ok {
+				if param.Required {
+					problems = append(problems, fmt.Sprintf("query %s: the parameter is required", param.Name))
+				}
+				continue
+			}
+			o.validateParam(&param, values, &problems)
+		case "header":
+			if param.Required && r.Header.Get(param.Name) == "" {
+				problems = append(problems, fmt.Sprintf("header %s: the header is required", param.Name))
+			}
+		case "body":
+			if len(body) == 0 {
+				if param.Required {
+					problems = append(problems, "body: the body is required")
+				}
+				continue
+			}
+			value, err := decodeJson(body)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("body: %v", err))
+				continue
+			}
+			o.spec.validateSchema(param.Schema, value, "body", &problems)
+		}
+	}
+	for name := range query {
+		if !documentedQuery[name] {
+			problems = append(problems, fmt.Sprintf("query %s: the parameter is not documented", name))
+		}
+	}
+	sort.Strings(problems)
+	return newError(fmt.Sprintf("%s: the request does not match the swagger document", o.Route), problems)
+}
+
+// ValidateResponse validates the status and the body of the response.
+func (o *Operation) ValidateResponse(status int, body []byte) error {
+	problems := make([]string, 0)
+	responses := o.op.Responses
+	if responses == nil {
+		responses = &spec.Responses{}
+	}
+	response, ok := responses.StatusCodeResponses[status]
+	if !ok {
+		if responses.Default == nil {
+			return errors.Errorf("%s: the %d status is not documented, the response is %s", o.Route, status, body)
+		}
+		response = *responses.Default
+	}
+
+	if response.Schema != nil {
+		value, err := decodeJson(body)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("response: %v", err))
+		} else {
+			o.spec.validateSchema(response.Schema, value, "response", &problems)
+		}
+	}
+	sort.Strings(problems)
+	return newError(fmt.Sprintf("%s: the %d response does not match the swagger document", o.Route, status), problems)
+}
+
+// parameters returns the parameters of the operation, which override the parameters of its path.
+func (o *Operation) parameters() []spec.Parameter {
+	params := make([]spec.Parameter, 0, len(o.itemParam)+len(o.op.Parameters))
+	overridden := map[string]bool{}
+	for _, param := range o.op.Parameters {
+		overridden[param.In+" "+param.Name] = true
+		params = append(params, param)
+	}
+	for _, param := range o.itemParam {
+		if !overridden[param.In+" "+param.Name] {
+			params = append(params, param)
+		}
+	}
+	return params
+}
+
+func (o *Operation) pathValues(path string) map[string]string {
+	values := map[string]string{}
+	for i, segment := range splitPath(path) {
+		if i < len(o.segments) && isParam(o.segments[i]) {
+			values[strings.Trim(o.segments[i], "{}")] = segment
+		}
+	}
+	return values
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+func isParam(segment string) bool {
+	return strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
+
+// matchPath matches the segments of a path with the segments of a documented path, and counts the literal segments.
+func matchPath(documented, segments []string) (literals int, ok bool) {
+	if len(documented) != len(segments) {
+		return 0, false
+	}
+	for i, segment := range documented {
+		switch {
+		case isParam(segment):
+			if segments[i] == "" {
+				return 0, false
+			}
+		case segment == segments[i]:
+			literals++
+		default:
+			return 0, false
+		}
+	}
+	return literals, true
+}
+
+// Error lists the violations of the swagger document.
+type Error struct {
+	Message  string
+	Problems []string
+}
+
+func newError(message string, problems []string) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	return &Error{Message: message, Problems: problems}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s:\n\t%s", e.Message, strings.Join(e.Problems, "\n\t"))
+}
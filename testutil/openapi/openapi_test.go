@@ -0,0 +1,153 @@
+package openapi
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadTestSpec(t *testing.T) *Spec {
+	t.Helper()
+	doc, err := os.ReadFile("testdata/swagger.json")
+	require.NoError(t, err)
+	s, err := Load(doc)
+	require.NoError(t, err)
+	return s
+}
+
+func TestOperation(t *testing.T) {
+	s := loadTestSpec(t)
+
+	op, err := s.Operation(http.MethodGet, "/items/42")
+	require.NoError(t, err)
+	assert.Equal(t, "/items/{id}", op.Path)
+
+	// the literal segments are matched first
+	op, err = s.Operation(http.MethodGet, "/items/latest")
+	require.NoError(t, err)
+	assert.Equal(t, "/items/latest", op.Path)
+
+	_, err = s.Operation(http.MethodDelete, "/items/42")
+	assert.ErrorContains(t, err, "the method is not documented")
+	_, err = s.Operation(http.MethodGet, "/users")
+	assert.ErrorContains(t, err, "the path is not documented")
+}
+
+func TestValidateRequest(t *testing.T) {
+	s := loadTestSpec(t)
+	list, err := s.Operation(http.MethodGet, "/items")
+	require.NoError(t, err)
+	create, err := s.Operation(http.MethodPost, "/items")
+	require.NoError(t, err)
+	get, err := s.Operation(http.MethodGet, "/items/1")
+	require.NoError(t, err)
+
+	assert.NoError(t, list.ValidateRequest(httptest.NewRequest(http.MethodGet, "/items?page=1&order=asc", nil), nil))
+	err = list.ValidateRequest(httptest.NewRequest(http.MethodGet, "/items?page=-1&order=up&size=10", nil), nil)
+	require.Error(t, err)
+	assert.Equal(t, []string{
+		`query order: "up" is not one of [asc desc]`,
+		"query page: -1 is less than the minimum 0",
+		"query size: the parameter is not documented",
+	}, err.(*Error).Problems)
+
+	err = get.ValidateRequest(httptest.NewRequest(http.MethodGet, "/items/abc", nil), nil)
+	assert.ErrorContains(t, err, `path id: "abc" is not an integer`)
+
+	assert.NoError(t, create.ValidateRequest(httptest.NewRequest(http.MethodPost, "/items", nil), []byte(`{"name":"a","count":1}`)))
+	err = create.ValidateRequest(httptest.NewRequest(http.MethodPost, "/items", nil), []byte(`{"count":1.5,"color":"red"}`))
+	require.Error(t, err)
+	assert.Equal(t, []string{
+		"body.color: the field is not documented",
+		"body.count: 1.5 is not an integer",
+		"body.name: the field is required",
+	}, err.(*Error).Problems)
+	assert.ErrorContains(t, create.ValidateRequest(httptest.NewRequest(http.MethodPost, "/items", nil), nil), "the body is required")
+}
+
+func TestValidateResponse(t *testing.T) {
+	s := loadTestSpec(t)
+	list, err := s.Operation(http.MethodGet, "/items")
+	require.NoError(t, err)
+
+	body := `{"total":1,"data":[{"name":"a","status":"active","payload":{"any":["thing"]},"count":null}]}`
+	assert.NoError(t, list.ValidateResponse(http.StatusOK, []byte(body)))
+
+	err = list.ValidateResponse(http.StatusOK, []byte(`{"total":"1","data":[{"name":"a","status":"deleted","extra":true}]}`))
+	require.Error(t, err)
+	assert.Equal(t, []string{
+		"response.data[0].extra: the field is not documented",
+		`response.data[0].status: "deleted" is not one of [active archived]`,
+		`response.total: "1" is not an integer`,
+	}, err.(*Error).Problems)
+
+	err = list.ValidateResponse(http.StatusNotFound, []byte(`{}`))
+	assert.ErrorContains(t, err, "the 404 status is not documented")
+
+	create, err := s.Operation(http.MethodPost, "/items")
+	require.NoError(t, err)
+	assert.NoError(t, create.ValidateResponse(http.StatusBadRequest, []byte(`{"messages":{"name":["required"]}}`)))
+	assert.Error(t, create.ValidateResponse(http.StatusBadRequest, []byte(`{"messages":{"name":[1]}}`)))
+}
+
+func TestMiddleware(t *testing.T) {
+	s := loadTestSpec(t)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "invalid") {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid"}`))
+			return
+		}
+		_, _ = w.Write(body)
+	})
+	var reported []error
+	middleware := Middleware(s, handler, func(err error) {
+		reported = append(reported, err)
+	})
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		reported = nil
+		w := httptest.NewRecorder()
+		middleware.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	w := serve(http.MethodPost, "/items", `{"name":"a"}`)
+	assert.Equal(t, `{"name":"a"}`, w.Body.String(), "the body of the request must be passed to the handler")
+	assert.Empty(t, reported)
+
+	// the invalid requests which are rejected are not reported
+	serve(http.MethodPost, "/items", `{"name":"invalid","extra":1}`)
+	assert.Empty(t, reported)
+
+	serve(http.MethodPost, "/items", `{"name":"a","extra":1}`)
+	require.Len(t, reported, 2)
+	assert.ErrorContains(t, reported[0], "the request was accepted with the 200 status")
+	assert.ErrorContains(t, reported[1], "response.extra: the field is not documented")
+
+	serve(http.MethodPost, "/users", `{}`)
+	require.Len(t, reported, 1)
+	assert.ErrorContains(t, reported[0], "the path is not documented")
+}
+
+func TestCheckRoutes(t *testing.T) {
+	s := loadTestSpec(t)
+
+	err := s.CheckRoutes([]Route{
+		{Method: http.MethodGet, Path: GinPath("/items")},
+		{Method: http.MethodPost, Path: "/items"},
+		{Method: http.MethodGet, Path: GinPath("/items/:id")},
+		{Method: http.MethodDelete, Path: GinPath("/items/:id")},
+	})
+	require.Error(t, err)
+	assert.Equal(t, []string{
+		"DELETE /items/{id} is not documented",
+		"GET /items/latest is documented but not registered",
+	}, err.(*Error).Problems)
+}
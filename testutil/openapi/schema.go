@@ -0,0 +1,282 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-openapi/spec"
+	"github.com/pkg/errors"
+)
+
+const definitionsPrefix = "#/definitions/"
+
+func decodeJson(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, errors.Wrapf(err, "the body is not json: %s", data)
+	}
+	return value, nil
+}
+
+// resolve follows the references of the schema to the definitions and merges the schemas of allOf.
+func (s *Spec) resolve(schema *spec.Schema) (*spec.Schema, error) {
+	for ref := schema.Ref.String(); ref != ""; ref = schema.Ref.String() {
+		if !strings.HasPrefix(ref, definitionsPrefix) {
+			return nil, errors.Errorf("the %s reference is not supported", ref)
+		}
+		definition, ok := s.doc.Definitions[strings.TrimPrefix(ref, definitionsPrefix)]
+		if !ok {
+			return nil, errors.Errorf("the %s definition does not exist", ref)
+		}
+		schema = &definition
+	}
+	if len(schema.AllOf) == 0 {
+		return schema, nil
+	}
+
+	merged := *schema
+	merged.AllOf = nil
+	merged.Properties = spec.SchemaProperties{}
+	for name, property := range schema.Properties {
+		merged.Properties[name] = property
+	}
+	for i := range schema.AllOf {
+		part, err := s.resolve(&schema.AllOf[i])
+		if err != nil {
+			return nil, err
+		}
+		if len(merged.Type) == 0 {
+			merged.Type = part.Type
+		}
+		for name, property := range part.Properties {
+			// the properties of the later schemas override the earlier ones, e.g. the data of a generic response
+			merged.Properties[name] = property
+		}
+		merged.Required = append(merged.Required, part.Required...)
+		if merged.AdditionalProperties == nil {
+			merged.AdditionalProperties = part.AdditionalProperties
+		}
+	}
+	return &merged, nil
+}
+
+// validateSchema validates a decoded json value against the schema, and adds the violations to the problems.
+// The null values are accepted unless the field is required, since the optional fields are pointers.
+func (s *Spec) validateSchema(schema *spec.Schema, value any, path string, problems *[]string) {
+	if schema == nil || value == nil {
+		return
+	}
+	schema, err := s.resolve(schema)
+	if err != nil {
+		*problems = append(*problems, fmt.Sprintf("%s: %v", path, err))
+		return
+	}
+
+	schemaType := ""
+	if len(schema.Type) > 0 {
+		schemaType = schema.Type[0]
+	} else if len(schema.Properties) > 0 {
+		schemaType = "object"
+	}
+	problem := checkType(schemaType, value)
+	if problem == "" {
+		problem = checkEnum(schema.Enum, value)
+	}
+	if problem == "" {
+		problem = checkRange(schema.Minimum, schema.Maximum, value)
+	}
+	if problem != "" {
+		*problems = append(*problems, fmt.Sprintf("%s: %s", path, problem))
+		return
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		s.validateObject(schema, v, path, problems)
+	case []any:
+		if schema.Items != nil && schema.Items.Schema != nil {
+			for i, item := range v {
+				s.validateSchema(schema.Items.Schema, item, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+	}
+}
+
+func (s *Spec) validateObject(schema *spec.Schema, object map[string]any, path string, problems *[]string) {
+	for _, name := range schema.Required {
+		if object[name] == nil {
+			*problems = append(*problems, fmt.Sprintf("%s.%s: the field is required", path, name))
+		}
+	}
+
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fieldPath := path + "." + name
+		if property, ok := schema.Properties[name]; ok {
+			s.validateSchema(&property, object[name], fieldPath, problems)
+			continue
+		}
+		additional := schema.AdditionalProperties
+		switch {
+		case additional != nil && additional.Schema != nil:
+			s.validateSchema(additional.Schema, object[name], fieldPath, problems)
+		case additional != nil && additional.Allows:
+		case additional == nil && len(schema.Properties) == 0:
+			// a plain object without documented properties, e.g. a json payload
+		default:
+			*problems = append(*problems, fmt.Sprintf("%s: the field is not documented", fieldPath))
+		}
+	}
+}
+
+// validateParam validates the values of a path or query parameter, which are strings regardless of their type.
+func (o *Operation) validateParam(param *spec.Parameter, values []string, problems *[]string) {
+	location := fmt.Sprintf("%s %s", param.In, param.Name)
+	if param.Type == "array" {
+		if param.CollectionFormat != "multi" && len(values) == 1 {
+			values = strings.Split(values[0], ",")
+		}
+		if param.Items == nil {
+			return
+		}
+		for _, raw := range values {
+			if problem := checkParamValue(param.Items.Type, param.Items.Enum, param.Items.Minimum, param.Items.Maximum, raw); problem != "" {
+				*problems = append(*problems, fmt.Sprintf("%s: %s", location, problem))
+			}
+		}
+		return
+	}
+	if len(values) > 1 {
+		*problems = append(*problems, fmt.Sprintf("%s: the parameter is not an array but has %d values", location, len(values)))
+	}
+	if param.Required && values[0] == "" {
+		*problems = append(*problems, fmt.Sprintf("%s: the parameter is required", location))
+		return
+	}
+	if problem := checkParamValue(param.Type, param.Enum, param.Minimum, param.Maximum, values[0]); problem != "" {
+		*problems = append(*problems, fmt.Sprintf("%s: %s", location, problem))
+	}
+}
+
+func checkParamValue(paramType string, enum []any, minimum, maximum *float64, raw string) string {
+	var value any = raw
+	switch paramType {
+	case "integer", "number":
+		value = json.Number(raw)
+	case "boolean":
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Sprintf("%q is not a boolean", raw)
+		}
+		value = b
+	}
+	if checkType(paramType, value) != "" {
+		return fmt.Sprintf("%q is not %s %s", raw, article(paramType), paramType)
+	}
+	if problem := checkEnum(enum, value); problem != "" {
+		return problem
+	}
+	return checkRange(minimum, maximum, value)
+}
+
+func checkType(schemaType string, value any) string {
+	ok := true
+	switch schemaType {
+	case "":
+		// any value
+	case "object":
+		_, ok = value.(map[string]any)
+	case "array":
+		_, ok = value.([]any)
+	case "string":
+		_, ok = value.(string)
+	case "boolean":
+		_, ok = value.(bool)
+	case "integer":
+		var n json.Number
+		if n, ok = value.(json.Number); ok {
+			_, err := n.Int64()
+			ok = err == nil
+		}
+	case "number":
+		var n json.Number
+		if n, ok = value.(json.Number); ok {
+			_, err := n.Float64()
+			ok = err == nil
+		}
+	default:
+		return fmt.Sprintf("the %s type is not supported", schemaType)
+	}
+	if !ok {
+		return fmt.Sprintf("%s is not %s %s", formatValue(value), article(schemaType), schemaType)
+	}
+	return ""
+}
+
+func checkEnum(enum []any, value any) string {
+	if len(enum) == 0 {
+		return ""
+	}
+	for _, allowed := range enum {
+		if normalize(allowed) == normalize(value) {
+			return ""
+		}
+	}
+	return fmt.Sprintf("%s is not one of %v", formatValue(value), enum)
+}
+
+func checkRange(minimum, maximum *float64, value any) string {
+	n, ok := value.(json.Number)
+	if !ok {
+		return ""
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return ""
+	}
+	if minimum != nil && f < *minimum {
+		return fmt.Sprintf("%s is less than the minimum %v", n, *minimum)
+	}
+	if maximum != nil && f > *maximum {
+		return fmt.Sprintf("%s is more than the maximum %v", n, *maximum)
+	}
+	return ""
+}
+
+// normalize converts the numbers of the document and of the values to the same representation.
+func normalize(value any) string {
+	switch v := value.(type) {
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			return fmt.Sprint(f)
+		}
+	case float64:
+		return fmt.Sprint(v)
+	}
+	return fmt.Sprintf("%T %v", value, value)
+}
+
+func formatValue(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+func article(word string) string {
+	if strings.ContainsAny(word[:1], "aeiou") {
+		return "an"
+	}
+	return "a"
+}
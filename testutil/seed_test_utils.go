@@ -0,0 +1,38 @@
+package testutil
+
+import (
+	"context"
+	"io/fs"
+	"testing"
+
+	"github.com/amahdian/golang-gin-boilerplate/assets"
+	"github.com/amahdian/golang-gin-boilerplate/storage"
+	"github.com/amahdian/golang-gin-boilerplate/storage/seed"
+	"github.com/stretchr/testify/require"
+)
+
+// TestingSeedSet is the seed set with the records the tests can rely on, e.g. the test.UserEmail user.
+const TestingSeedSet = "testing"
+
+// SeedSet loads the embedded seed set into the storage and fails the test if it can't. The returned loader
+// gives access to the named records, e.g. seed.Ref[*model.User](loader, "admin").
+func SeedSet(t testing.TB, stg storage.Storage, set string) *seed.Loader {
+	t.Helper()
+	seedFs, err := fs.Sub(assets.Seeds, assets.SeedsDir)
+	require.NoError(t, err)
+
+	loader := seed.NewLoader(stg)
+	_, err = loader.LoadSet(context.Background(), seedFs, set)
+	require.NoError(t, err, "failed to load the %s seed set", set)
+	return loader
+}
+
+// LoadFixtures loads the fixture files (e.g. from the testdata of the test) into the storage after the testing seed
+// set, so the fixtures can refer to its records. It fails the test if they can't be loaded.
+func LoadFixtures(t testing.TB, stg storage.Storage, paths ...string) *seed.Loader {
+	t.Helper()
+	loader := SeedSet(t, stg, TestingSeedSet)
+	_, err := loader.LoadFiles(context.Background(), paths...)
+	require.NoError(t, err, "failed to load the fixtures")
+	return loader
+}
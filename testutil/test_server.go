@@ -0,0 +1,283 @@
+package testutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/contracts/resp"
+	"github.com/amahdian/golang-gin-boilerplate/global/env"
+	"github.com/amahdian/golang-gin-boilerplate/global/test"
+	"github.com/amahdian/golang-gin-boilerplate/server"
+	"github.com/amahdian/golang-gin-boilerplate/storage/memory"
+	"github.com/amahdian/golang-gin-boilerplate/svc/auth"
+	"github.com/amahdian/golang-gin-boilerplate/testutil/openapi"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/sethvargo/go-envconfig"
+	"github.com/stretchr/testify/require"
+)
+
+// TestJwtSecret is the secret the tokens of the test servers are signed with.
+const TestJwtSecret = "test-secret"
+
+// testEnvs are the envs of the test servers, on top of the defaults of env.Envs.
+var testEnvs = map[string]string{
+	"GIN_MODE":     "test",
+	"LOG_LEVEL":    "warn",
+	"JWT_SECRET":   TestJwtSecret,
+	"ADMIN_EMAILS": test.UserEmail,
+	// the storage of the test servers is injected, so the dsn is never used
+	"DB_DSN": "postgres://localhost/test",
+}
+
+// TestEnvs returns the envs of the test servers with the given overrides. They are not read from the environment
+// or the .env files, so the tests do not depend on the machine they run on.
+func TestEnvs(t testing.TB, overrides map[string]string) *env.Envs {
+	t.Helper()
+	values := make(map[string]string, len(testEnvs)+len(overrides))
+	for key, value := range testEnvs {
+		values[key] = value
+	}
+	for key, value := range overrides {
+		values[key] = value
+	}
+
+	var envs env.Envs
+	err := envconfig.ProcessWith(context.Background(), &envconfig.Config{
+		Target:   &envs,
+		Lookuper: envconfig.MapLookuper(values),
+	})
+	require.NoError(t, err, "failed to load the test envs")
+	return &envs
+}
+
+// TestServer is a server.Server with its whole router, which serves the requests of the test in process.
+// The background jobs (e.g. the scheduler and the job workers) are not started.
+//
+// The requests and the responses are validated against the swagger document of the api (see openapi.Middleware),
+// and the test fails if they do not match it.
+type TestServer struct {
+	*server.Server
+	t       testing.TB
+	handler http.Handler
+}
+
+type testServerConfig struct {
+	envs             map[string]string
+	serverOpts       []server.Option
+	validateContract bool
+}
+
+type TestServerOption func(config *testServerConfig)
+
+// WithEnv overrides an env of the test server, e.g. WithEnv("ADMIN_EMAILS", "ops@example.com").
+func WithEnv(key, value string) TestServerOption {
+	return func(config *testServerConfig) {
+		config.envs[key] = value
+	}
+}
+
+// WithServerOptions passes the options to server.NewServer, e.g. server.WithStorage with a pg storage
+// in place of the default memory storage.
+func WithServerOptions(opts ...server.Option) TestServerOption {
+	return func(config *testServerConfig) {
+		config.serverOpts = append(config.serverOpts, opts...)
+	}
+}
+
+// WithoutContractValidation does not validate the requests and the responses against the swagger document,
+// e.g. to test the paths which are not part of the api.
+func WithoutContractValidation() TestServerOption {
+	return func(config *testServerConfig) {
+		config.validateContract = false
+	}
+}
+
+// the swagger document is parsed once for all the test servers
+var loadDocs = sync.OnceValues(openapi.LoadDocs)
+
+// NewTestServer builds a server with the test envs (see TestEnvs), a memory storage and the jwt authenticator.
+// The server is closed when the test ends.
+func NewTestServer(t testing.TB, opts ...TestServerOption) *TestServer {
+	t.Helper()
+	config := &testServerConfig{
+		envs:             map[string]string{},
+		serverOpts:       []server.Option{server.WithStorage(memory.NewStg())},
+		validateContract: true,
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	s, err := server.NewServer(TestEnvs(t, config.envs), config.serverOpts...)
+	require.NoError(t, err, "failed to build the test server")
+	t.Cleanup(func() {
+		_ = s.Close()
+	})
+
+	ts := &TestServer{Server: s, t: t, handler: s.Router}
+	if config.validateContract {
+		spec, err := loadDocs()
+		require.NoError(t, err, "failed to load the swagger document")
+		ts.handler = openapi.Middleware(spec, s.Router, func(err error) {
+			t.Errorf("the api does not match its swagger document, update the annotations and run make docs: %v", err)
+		})
+	}
+	return ts
+}
+
+// RequestOption changes a request before the test server serves it.
+type RequestOption func(r *http.Request)
+
+// WithHeader sets a header of the request.
+func WithHeader(key, value string) RequestOption {
+	return func(r *http.Request) {
+		r.Header.Set(key, value)
+	}
+}
+
+// WithToken authenticates the request with the given jwt token.
+func WithToken(token string) RequestOption {
+	return WithHeader("Authorization", "Bearer "+token)
+}
+
+// AsUser authenticates the request as the given user, who does not need to exist in the storage.
+func (ts *TestServer) AsUser(userInfo auth.UserInfo) RequestOption {
+	return WithToken(ts.Token(userInfo))
+}
+
+// AsAdmin authenticates the request as the first user of the ADMIN_EMAILS env.
+func (ts *TestServer) AsAdmin() RequestOption {
+	return ts.AsUser(ts.AdminUser())
+}
+
+// AdminUser returns a user with the first email of the ADMIN_EMAILS env.
+func (ts *TestServer) AdminUser() auth.UserInfo {
+	require.NotEmpty(ts.t, ts.Envs.Server.AdminEmails, "the test server has no admin emails")
+	return auth.UserInfo{
+		ID:    uuid.New(),
+		Email: ts.Envs.Server.AdminEmails[0],
+	}
+}
+
+// Token mints a jwt token of the user which expires like the tokens of the login.
+func (ts *TestServer) Token(userInfo auth.UserInfo) string {
+	return MintToken(ts.t, ts.Envs.Server.JwtSecret, userInfo, time.Hour*72)
+}
+
+// MintToken signs a jwt token of the user with the claims of the login. A negative ttl mints an expired token.
+func MintToken(t testing.TB, secret string, userInfo auth.UserInfo, ttl time.Duration) string {
+	t.Helper()
+	claims := jwt.MapClaims{
+		"id":    userInfo.ID,
+		"email": userInfo.Email,
+		"exp":   time.Now().Add(ttl).Unix(),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	require.NoError(t, err, "failed to sign the test token")
+	return token
+}
+
+// Do serves the request and returns the recorded response. The body is sent as is if it is a []byte, a string or an
+// io.Reader, and is encoded to json otherwise.
+func (ts *TestServer) Do(method, path string, body any, opts ...RequestOption) *httptest.ResponseRecorder {
+	ts.t.Helper()
+	var reader io.Reader
+	switch v := body.(type) {
+	case nil:
+	case []byte:
+		reader = bytes.NewReader(v)
+	case string:
+		reader = bytes.NewReader([]byte(v))
+	case io.Reader:
+		reader = v
+	default:
+		data, err := json.Marshal(v)
+		require.NoError(ts.t, err, "failed to encode the request body")
+		reader = bytes.NewReader(data)
+	}
+
+	r := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	w := httptest.NewRecorder()
+	ts.handler.ServeHTTP(w, r)
+	return w
+}
+
+func (ts *TestServer) Get(path string, opts ...RequestOption) *httptest.ResponseRecorder {
+	ts.t.Helper()
+	return ts.Do(http.MethodGet, path, nil, opts...)
+}
+
+func (ts *TestServer) Post(path string, body any, opts ...RequestOption) *httptest.ResponseRecorder {
+	ts.t.Helper()
+	return ts.Do(http.MethodPost, path, body, opts...)
+}
+
+func (ts *TestServer) Put(path string, body any, opts ...RequestOption) *httptest.ResponseRecorder {
+	ts.t.Helper()
+	return ts.Do(http.MethodPut, path, body, opts...)
+}
+
+func (ts *TestServer) Patch(path string, body any, opts ...RequestOption) *httptest.ResponseRecorder {
+	ts.t.Helper()
+	return ts.Do(http.MethodPatch, path, body, opts...)
+}
+
+func (ts *TestServer) Delete(path string, opts ...RequestOption) *httptest.ResponseRecorder {
+	ts.t.Helper()
+	return ts.Do(http.MethodDelete, path, nil, opts...)
+}
+
+// DecodeResponse requires the status of the response and decodes its resp.Response body.
+func DecodeResponse[T any](t testing.TB, res *httptest.ResponseRecorder, status int) *resp.Response[T] {
+	t.Helper()
+	requireStatus(t, res, status)
+	var body resp.Response[T]
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &body), "the body is not a response of %T: %s", body.Data, res.Body)
+	require.True(t, body.Success, "the response is not successful: %s", res.Body)
+	return &body
+}
+
+// RequireOk requires a 200 response and returns its data.
+func RequireOk[T any](t testing.TB, res *httptest.ResponseRecorder) T {
+	t.Helper()
+	return DecodeResponse[T](t, res, http.StatusOK).Data
+}
+
+// RequirePaginated requires a 200 response and decodes its resp.PaginatedResponse body.
+func RequirePaginated[T any](t testing.TB, res *httptest.ResponseRecorder) *resp.PaginatedResponse[T] {
+	t.Helper()
+	requireStatus(t, res, http.StatusOK)
+	var body resp.PaginatedResponse[T]
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &body), "the body is not a paginated response: %s", res.Body)
+	require.True(t, body.Success, "the response is not successful: %s", res.Body)
+	return &body
+}
+
+// RequireError requires the status of the response and decodes its resp.ErrorResponse body.
+func RequireError(t testing.TB, res *httptest.ResponseRecorder, status int) *resp.ErrorResponse {
+	t.Helper()
+	requireStatus(t, res, status)
+	var body resp.ErrorResponse
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &body), "the body is not an error response: %s", res.Body)
+	require.False(t, body.Success, "the error response is successful: %s", res.Body)
+	return &body
+}
+
+func requireStatus(t testing.TB, res *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	require.Equal(t, status, res.Code, "unexpected status of the response: %s", res.Body)
+}
@@ -0,0 +1,76 @@
+package testutil
+
+import (
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/amahdian/golang-gin-boilerplate/domain/contracts/resp"
+	"github.com/amahdian/golang-gin-boilerplate/global/test"
+	"github.com/amahdian/golang-gin-boilerplate/svc/auth"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	test.SetupTestingEnv()
+	os.Exit(m.Run())
+}
+
+func TestTestServerHealth(t *testing.T) {
+	ts := NewTestServer(t)
+
+	health := RequireOk[resp.HealthResponseDto](t, ts.Get("/health"))
+	assert.NotEmpty(t, health.AppName)
+	AssertGoldenJSON(t, "health", ts.Get("/health").Body.Bytes(), Scrub("data.appVersion"))
+}
+
+func TestTestServerAuth(t *testing.T) {
+	ts := NewTestServer(t)
+	user := auth.UserInfo{ID: uuid.New(), Email: "user@example.com"}
+
+	RequireError(t, ts.Get("/api/v1/admin/jobs"), http.StatusUnauthorized)
+	expired := MintToken(t, TestJwtSecret, user, -time.Minute)
+	RequireError(t, ts.Get("/api/v1/admin/jobs", WithToken(expired)), http.StatusUnauthorized)
+
+	errRes := RequireError(t, ts.Get("/api/v1/admin/jobs", ts.AsUser(user)), http.StatusForbidden)
+	assert.Contains(t, errRes.Error, "admin access is required")
+
+	jobs := RequirePaginated[map[string]any](t, ts.Get("/api/v1/admin/jobs", ts.AsAdmin()))
+	assert.Empty(t, jobs.Data)
+	assert.True(t, jobs.PageInfo.IsEmpty)
+}
+
+func TestTestServerEnvs(t *testing.T) {
+	ts := NewTestServer(t, WithEnv("ADMIN_EMAILS", "ops@example.com"))
+
+	require.Equal(t, []string{"ops@example.com"}, ts.Envs.Server.AdminEmails)
+	assert.Equal(t, "ops@example.com", ts.AdminUser().Email)
+	RequireError(t, ts.Get("/api/v1/admin/jobs", ts.AsUser(auth.UserInfo{ID: uuid.New(), Email: test.UserEmail})), http.StatusForbidden)
+}
+
+func TestTestServerClose(t *testing.T) {
+	ts := NewTestServer(t)
+
+	// the cleanup of the test closes the server again
+	_ = ts.Close()
+	assert.Equal(t, http.StatusOK, ts.Get("/health").Code, "the router doesn't depend on the released resources")
+}
+
+func TestScrub(t *testing.T) {
+	value := map[string]any{
+		"data": []any{
+			map[string]any{"id": "1", "name": "a"},
+			map[string]any{"id": "2", "name": "b"},
+		},
+	}
+	scrubbed := scrub(value, []string{"data", "*", "id"})
+	assert.Equal(t, map[string]any{
+		"data": []any{
+			map[string]any{"id": scrubbedValue, "name": "a"},
+			map[string]any{"id": scrubbedValue, "name": "b"},
+		},
+	}, scrubbed)
+}
@@ -5,6 +5,8 @@ import (
 	"github.com/amahdian/golang-gin-boilerplate/global/test"
 )
 
+// Deprecated: the users of TestUser collide with each other since they have the same email,
+// use factory.User which gives every user a unique one.
 func TestUser() *model.User {
 	return &model.User{
 		Email: test.UserEmail,